@@ -38,6 +38,16 @@ func main() {
 					os.Exit(1)
 				}
 
+				if err := system.CreateStateDirs(conf.StateDirPath, int(u.Uid), int(u.Gid)); err != nil {
+					log.Errorf("Failed to create state dir '%s': %+v", conf.StateDirPath, err)
+					os.Exit(1)
+				}
+
+				if err := system.CreateStateDirs(conf.LogDirPath, int(u.Uid), int(u.Gid)); err != nil {
+					log.Errorf("Failed to create log dir '%s': %+v", conf.LogDirPath, err)
+					os.Exit(1)
+				}
+
 				if err := system.EnableKeepCapability(); err != nil {
 					log.Warningf("Failed to enable keep capabilities: %+v", err)
 				}
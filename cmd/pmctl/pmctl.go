@@ -6,6 +6,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"sort"
 
@@ -14,6 +15,7 @@ import (
 
 	"github.com/vmware/pmd-next-gen/pkg/validator"
 	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/systemd"
 )
 
 func main() {
@@ -41,10 +43,105 @@ func main() {
 
 	app.EnableBashCompletion = true
 	app.Commands = []*cli.Command{
+		{
+			Name:        "audit",
+			Usage:       "Show the audit log of changes made through the API",
+			Description: "Show the audit log of changes made through the API",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "since", Aliases: []string{"s"}, Usage: "RFC 3339 time or duration such as 1h"},
+				&cli.StringFlag{Name: "user"},
+				&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Usage: "Path prefix such as /api/v1/network"},
+				&cli.StringFlag{Name: "limit", Aliases: []string{"n"}},
+			},
+
+			Action: func(c *cli.Context) error {
+				acquireAudit(c.String("since"), c.String("user"), c.String("path"), c.String("limit"), c.String("url"), token)
+				return nil
+			},
+		},
+		{
+			Name:        "monitor",
+			UsageText:   "monitor [--topic TOPIC]...",
+			Usage:       "Print link, address, route, unit, session, hostname and timedate changes as they happen",
+			Description: "Print link, address, route, unit, session, hostname and timedate changes as they happen",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{Name: "topic", Aliases: []string{"t"}, Usage: "Only show these topics (link, address, route, unit, session, hostname, timedate)"},
+			},
+
+			Action: func(c *cli.Context) error {
+				monitorEvents(c.StringSlice("topic"), c.String("url"), token)
+				return nil
+			},
+		},
+		{
+			Name:  "baseline",
+			Usage: "Capture a baseline of the system configuration and detect drift from it",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "capture",
+					Description: "Snapshot the system, network, sysctl, nft and enabled units configuration as a new baseline version",
+
+					Action: func(c *cli.Context) error {
+						captureBaseline(c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "diff",
+					UsageText:   "diff [--version VERSION]",
+					Description: "Show how the live system differs from a baseline, the latest one by default",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "version", Usage: "Baseline version to compare against"},
+					},
+
+					Action: func(c *cli.Context) error {
+						acquireBaselineDrift(c.String("version"), c.String("url"), token)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "state",
+			Usage: "Apply a declarative desired state document",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "apply",
+					UsageText:   "apply [FILE] [--dry-run]",
+					Description: "Bring the system to the state described by a YAML or JSON document, applying only what changed",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "dry-run", Usage: "Show the changes without applying them"},
+					},
+
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("No state document input\n")
+							return nil
+						}
+
+						applyState(c.Args().First(), c.Bool("dry-run"), c.String("url"), token)
+						return nil
+					},
+				},
+			},
+		},
 		{
 			Name:  "service",
 			Usage: "Introspects and controls the systemd services",
 			Subcommands: []*cli.Command{
+				{
+					Name:        "list",
+					UsageText:   "list [--state STATE]... [--type TYPE]... [PATTERN]...",
+					Description: "List the loaded units matching the states, types and globs",
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{Name: "state", Usage: "Load, active or sub state such as failed or running"},
+						&cli.StringSliceFlag{Name: "type", Usage: "Unit type such as service or timer"},
+					},
+					Action: func(c *cli.Context) error {
+						acquireSystemdUnits(c.StringSlice("state"), c.StringSlice("type"), c.Args().Slice(), c.String("url"), token)
+						return nil
+					},
+				},
 				{
 					Name:        "status",
 					Description: "Show terse runtime status information about one unit",
@@ -56,57 +153,247 @@ func main() {
 				},
 				{
 					Name:        "start",
-					Description: "Start (activate) one unit specified on the command line",
+					Description: "Start (activate) the units or globs specified on the command line",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("start", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("start", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "stop",
-					Description: "Stop (deactivate) one specified on the command line.",
+					Description: "Stop (deactivate) the units or globs specified on the command line.",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("stop", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("stop", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "restart",
-					Description: "Stop and then start one unit specified on the command line. If the unit is not running yet, it will be started.",
+					Description: "Stop and then start the units or globs specified on the command line. If a unit is not running yet, it will be started.",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("restart", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("restart", c.Args().Slice(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "enable",
+					Description: "Enable the unit files or globs, as specified on the command line",
+					Action: func(c *cli.Context) error {
+						executeSystemdUnitCommand("enable", c.Args().Slice(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "disable",
+					Description: "Disable the unit files or globs, as specified on the command line",
+					Action: func(c *cli.Context) error {
+						executeSystemdUnitCommand("disable", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "mask",
-					Description: "Mask one unit, as specified on the command line",
+					Description: "Mask the units or globs, as specified on the command line",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("mask", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("mask", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "unmask",
-					Description: "Unmask one unit file, as specified on the command line",
+					Description: "Unmask the unit files or globs, as specified on the command line",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("unmask", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("unmask", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "try-restart",
-					Description: "Stop and then start one unit specified on the command line if the unit are running. This does nothing if unit is not running.",
+					Description: "Stop and then start the units or globs specified on the command line if they are running. This does nothing if a unit is not running.",
+					Action: func(c *cli.Context) error {
+						executeSystemdUnitCommand("try-restart", c.Args().Slice(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "cat",
+					Description: "Show the unit file and its drop-ins",
+					Action: func(c *cli.Context) error {
+						acquireSystemdUnitCat(c.Args().First(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "run",
+					UsageText:   "run [--user USER] [--env NAME=VALUE]... [--memory-max SIZE] [--cpu-quota PERCENT] COMMAND [ARGS]...",
+					Description: "Run a command as a transient service and show its exit status and output",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "unit", Usage: "Name of the transient unit"},
+						&cli.StringFlag{Name: "user", Usage: "Run as this user"},
+						&cli.StringFlag{Name: "working-directory", Usage: "Working directory of the command"},
+						&cli.StringSliceFlag{Name: "env", Aliases: []string{"E"}, Usage: "Set an environment variable NAME=VALUE"},
+						&cli.StringFlag{Name: "memory-max", Usage: "Memory limit such as 512M"},
+						&cli.StringFlag{Name: "cpu-quota", Usage: "CPU time limit such as 50%"},
+						&cli.BoolFlag{Name: "private-tmp", Usage: "Run with a private /tmp"},
+						&cli.BoolFlag{Name: "private-network", Usage: "Run without network access"},
+						&cli.StringFlag{Name: "protect-system", Usage: "Mount the OS read-only: true, full or strict"},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						run := systemd.RunRequest{
+							Unit:             c.String("unit"),
+							Command:          c.Args().Slice(),
+							User:             c.String("user"),
+							WorkingDirectory: c.String("working-directory"),
+							Environment:      c.StringSlice("env"),
+							MemoryMax:        c.String("memory-max"),
+							CPUQuota:         c.String("cpu-quota"),
+							PrivateTmp:       c.Bool("private-tmp"),
+							PrivateNetwork:   c.Bool("private-network"),
+							ProtectSystem:    c.String("protect-system"),
+						}
+
+						runSystemdTransientUnit(&run, c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "resources",
+					Description: "Show the cgroup resource controls of one unit and its current usage",
+					Action: func(c *cli.Context) error {
+						acquireSystemdUnitResources(c.Args().First(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "set-resources",
+					UsageText:   "set-resources [--cpu-weight N] [--cpu-quota PERCENT] [--memory-max SIZE] [--memory-high SIZE] [--io-weight N] [--tasks-max N] [--allowed-cpus LIST] [--persist] UNIT",
+					Description: "Change the cgroup resource controls of one unit at runtime",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "cpu-weight", Usage: "CPU weight between 1 and 10000"},
+						&cli.StringFlag{Name: "cpu-quota", Usage: "CPU time limit such as 50%"},
+						&cli.StringFlag{Name: "memory-max", Usage: "Hard memory limit such as 512M"},
+						&cli.StringFlag{Name: "memory-high", Usage: "Memory throttling limit such as 384M"},
+						&cli.StringFlag{Name: "io-weight", Usage: "IO weight between 1 and 10000"},
+						&cli.StringFlag{Name: "tasks-max", Usage: "Maximum number of tasks"},
+						&cli.StringFlag{Name: "allowed-cpus", Usage: "CPUs the unit may run on such as 0-3,6"},
+						&cli.BoolFlag{Name: "persist", Usage: "Keep the settings across reboots"},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						res := systemd.ResourceRequest{
+							CPUWeight:   c.String("cpu-weight"),
+							CPUQuota:    c.String("cpu-quota"),
+							MemoryMax:   c.String("memory-max"),
+							MemoryHigh:  c.String("memory-high"),
+							IOWeight:    c.String("io-weight"),
+							TasksMax:    c.String("tasks-max"),
+							AllowedCPUs: c.String("allowed-cpus"),
+							Persist:     c.Bool("persist"),
+						}
+
+						configureSystemdUnitResources(c.Args().First(), &res, c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "failed",
+					UsageText:   "failed [--lines N]",
+					Description: "Show the failed units with their result, exit status, restarts, failed dependencies and last journal lines",
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "lines", Aliases: []string{"n"}, Value: 0, Usage: "Number of journal lines to show per unit"},
+					},
+					Action: func(c *cli.Context) error {
+						acquireSystemdFailedUnits(c.Int("lines"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "diagnose",
+					UsageText:   "diagnose [--lines N] UNIT",
+					Description: "Explain why one unit is down",
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "lines", Aliases: []string{"n"}, Value: 20, Usage: "Number of journal lines to show"},
+					},
+					Action: func(c *cli.Context) error {
+						acquireSystemdUnitDiagnosis(c.Args().First(), c.Int("lines"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "reset-failed",
+					Description: "Reset the failed state of the units or globs",
+					Action: func(c *cli.Context) error {
+						executeSystemdUnitCommand("reset-failed", c.Args().Slice(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:  "analyze",
+					Usage: "Analyze the boot performance",
+					Subcommands: []*cli.Command{
+						{
+							Name:        "time",
+							Description: "Show the time spent in firmware, loader, kernel, initrd and userspace",
+							Action: func(c *cli.Context) error {
+								acquireSystemdAnalyzeTime(c.String("url"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "blame",
+							Description: "List the units by the time they took to activate, slowest first",
+							Action: func(c *cli.Context) error {
+								acquireSystemdAnalyzeBlame(c.String("url"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "critical-chain",
+							UsageText:   "critical-chain [UNIT]",
+							Description: "Show the chain of units which delayed the activation of a unit, default.target by default",
+							Action: func(c *cli.Context) error {
+								acquireSystemdAnalyzeCriticalChain(c.Args().First(), c.String("url"), token)
+								return nil
+							},
+						},
+					},
+				},
+				{
+					Name:        "timers",
+					Description: "List the timers with the unit they activate, their calendar expressions and next and last elapse",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("try-restart", c.Args().First(), c.String("url"), token)
+						acquireSystemdTimers(c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "logs",
+					UsageText:   "logs [UNIT] [--follow] [--lines N] [--priority PRIORITY]",
+					Description: "Show the journal of one unit, or of the whole system when no unit is given",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "follow", Aliases: []string{"f"}, Usage: "Keep printing new entries as they are added"},
+						&cli.IntFlag{Name: "lines", Aliases: []string{"n"}, Value: 100, Usage: "Number of latest entries to show"},
+						&cli.StringFlag{Name: "priority", Usage: "Only show entries of this priority or a range such as err..warning"},
+					},
+					Action: func(c *cli.Context) error {
+						acquireJournal(c.Args().First(), c.Int("lines"), c.String("priority"), c.Bool("follow"), c.String("url"), token)
 						return nil
 					},
 				},
 				{
 					Name:        "reload-or-restart",
-					Description: "Reload one unit if they support it. If not, stop and then start instead. If the unit is not running yet, it will be started.",
+					Description: "Reload the units or globs if they support it. If not, stop and then start instead. If a unit is not running yet, it will be started.",
 					Action: func(c *cli.Context) error {
-						executeSystemdUnitCommand("reload-or-restart", c.Args().First(), c.String("url"), token)
+						executeSystemdUnitCommand("reload-or-restart", c.Args().Slice(), c.String("url"), token)
 						return nil
 					},
 				},
@@ -434,13 +721,558 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:        "reboot",
+					UsageText:   "reboot [--at TIME] [--message MESSAGE]",
+					Description: "Reboot the system now or at TIME: +MINUTES, +DURATION such as +1h, HH:MM or RFC 3339",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "at", Usage: "Schedule instead of running now"},
+						&cli.StringFlag{Name: "message", Usage: "Wall message sent to logged in users"},
+					},
+					Action: func(c *cli.Context) error {
+						executePowerAction("reboot", c.String("at"), c.String("message"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "poweroff",
+					UsageText:   "poweroff [--at TIME] [--message MESSAGE]",
+					Description: "Power off the system now or at TIME: +MINUTES, +DURATION such as +1h, HH:MM or RFC 3339",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "at", Usage: "Schedule instead of running now"},
+						&cli.StringFlag{Name: "message", Usage: "Wall message sent to logged in users"},
+					},
+					Action: func(c *cli.Context) error {
+						executePowerAction("poweroff", c.String("at"), c.String("message"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "halt",
+					UsageText:   "halt [--at TIME] [--message MESSAGE]",
+					Description: "Halt the system now or at TIME: +MINUTES, +DURATION such as +1h, HH:MM or RFC 3339",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "at", Usage: "Schedule instead of running now"},
+						&cli.StringFlag{Name: "message", Usage: "Wall message sent to logged in users"},
+					},
+					Action: func(c *cli.Context) error {
+						executePowerAction("halt", c.String("at"), c.String("message"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "kexec",
+					UsageText:   "kexec [--at TIME] [--message MESSAGE]",
+					Description: "Reboot the system into the loaded kexec kernel now or at TIME: +MINUTES, +DURATION such as +1h, HH:MM or RFC 3339",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "at", Usage: "Schedule instead of running now"},
+						&cli.StringFlag{Name: "message", Usage: "Wall message sent to logged in users"},
+					},
+					Action: func(c *cli.Context) error {
+						executePowerAction("kexec", c.String("at"), c.String("message"), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "suspend",
+					Description: "Suspend the system",
+					Action: func(c *cli.Context) error {
+						executePowerAction("suspend", "", "", c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "hibernate",
+					Description: "Hibernate the system",
+					Action: func(c *cli.Context) error {
+						executePowerAction("hibernate", "", "", c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "cancel-shutdown",
+					Description: "Cancel the scheduled reboot, power off, halt or kexec",
+					Action: func(c *cli.Context) error {
+						cancelScheduledShutdown(c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "power",
+					Description: "Show which power actions are possible and the scheduled shutdown",
+					Action: func(c *cli.Context) error {
+						acquirePower(c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "inhibitors",
+					Description: "List the inhibitor locks which delay or block shutdown and sleep",
+					Action: func(c *cli.Context) error {
+						acquireInhibitors(c.String("url"), token)
+						return nil
+					},
+				},
 			},
 		},
 		{
 			Name:    "network",
 			Aliases: []string{"n"},
 			Usage:   "Network device configuration",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "confirm-timeout", Usage: "Roll back the change unless confirmed within the timeout, e.g. 60s"},
+			},
+			Before: func(c *cli.Context) error {
+				networkConfirmTimeout = c.String("confirm-timeout")
+				return nil
+			},
 			Subcommands: []*cli.Command{
+				{
+					Name:        "confirm",
+					UsageText:   "confirm [TRANSACTION]",
+					Description: "Confirm a change applied with --confirm-timeout",
+
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						networkTransactionAction(c.Args().First(), "confirm", c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "rollback",
+					UsageText:   "rollback [TRANSACTION]",
+					Description: "Roll back a change applied with --confirm-timeout",
+
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						networkTransactionAction(c.Args().First(), "rollback", c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "transaction",
+					UsageText:   "transaction [TRANSACTION]",
+					Description: "Show the state of a change applied with --confirm-timeout",
+
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						acquireNetworkTransaction(c.Args().First(), c.String("url"), token)
+						return nil
+					},
+				},
+				{
+					Name:        "neigh",
+					Description: "Shows and changes the ARP and NDP neighbours and the bridge forwarding database",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "netns", Usage: "Name of the network namespace, or pid:<id> for the one of a process"},
+					},
+					Subcommands: []*cli.Command{
+						{
+							Name:        "show",
+							UsageText:   "show [--link LINK] [--family ipv4|ipv6]",
+							Description: "Shows the ARP and NDP neighbours with their state",
+							Flags: []cli.Flag{
+								&cli.StringFlag{Name: "link", Usage: "Show the neighbours of this link only"},
+								&cli.StringFlag{Name: "family", Usage: "ipv4 or ipv6"},
+							},
+
+							Action: func(c *cli.Context) error {
+								acquireNeighbors(false, c.String("link"), c.String("family"), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "add",
+							UsageText:   "add [dev LINK] [address ADDRESS] [mac MAC] [state {permanent|noarp|reachable|stale}] [router BOOL] [proxy BOOL]",
+							Description: "Adds a neighbour entry, permanent unless a state is given",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 4 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkNeighbor(http.MethodPost, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "replace",
+							UsageText:   "replace [dev LINK] [address ADDRESS] [mac MAC] [state {permanent|noarp|reachable|stale}] [router BOOL] [proxy BOOL]",
+							Description: "Adds or replaces a neighbour entry",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 4 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkNeighbor(http.MethodPut, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "remove",
+							UsageText:   "remove [dev LINK] [address ADDRESS]",
+							Description: "Removes a neighbour entry",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 4 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkNeighbor(http.MethodDelete, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "flush",
+							UsageText:   "flush [--all] LINK",
+							Description: "Removes the neighbours of the link, permanent and noarp entries are kept unless --all is given",
+							Flags: []cli.Flag{
+								&cli.BoolFlag{Name: "all", Usage: "Remove permanent and noarp entries as well"},
+							},
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 1 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkFlushNeighbors(c.Args().First(), c.Bool("all"), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "fdb",
+							Description: "Shows and changes the bridge forwarding database",
+							Subcommands: []*cli.Command{
+								{
+									Name:        "show",
+									UsageText:   "show [--link LINK]",
+									Description: "Shows the bridge forwarding database entries",
+									Flags: []cli.Flag{
+										&cli.StringFlag{Name: "link", Usage: "Show the entries of this link only"},
+									},
+
+									Action: func(c *cli.Context) error {
+										acquireNeighbors(true, c.String("link"), "", c.String("url"), c.String("netns"), token)
+										return nil
+									},
+								},
+								{
+									Name:        "add",
+									UsageText:   "add [dev LINK] [mac MAC] [state {permanent|static|dynamic}] [master] [self] [vlan VLAN] [vni VNI] [dst ADDRESS]",
+									Description: "Adds a bridge forwarding database entry, static unless a state is given",
+
+									Action: func(c *cli.Context) error {
+										if c.NArg() < 4 {
+											fmt.Printf("Too few arguments.\n")
+											return nil
+										}
+
+										networkFDB(http.MethodPost, c.Args(), c.String("url"), c.String("netns"), token)
+										return nil
+									},
+								},
+								{
+									Name:        "replace",
+									UsageText:   "replace [dev LINK] [mac MAC] [state {permanent|static|dynamic}] [master] [self] [vlan VLAN] [vni VNI] [dst ADDRESS]",
+									Description: "Adds or replaces a bridge forwarding database entry",
+
+									Action: func(c *cli.Context) error {
+										if c.NArg() < 4 {
+											fmt.Printf("Too few arguments.\n")
+											return nil
+										}
+
+										networkFDB(http.MethodPut, c.Args(), c.String("url"), c.String("netns"), token)
+										return nil
+									},
+								},
+								{
+									Name:        "remove",
+									UsageText:   "remove [dev LINK] [mac MAC] [state {permanent|static|dynamic}] [master] [self] [vlan VLAN] [vni VNI] [dst ADDRESS]",
+									Description: "Removes a bridge forwarding database entry",
+
+									Action: func(c *cli.Context) error {
+										if c.NArg() < 4 {
+											fmt.Printf("Too few arguments.\n")
+											return nil
+										}
+
+										networkFDB(http.MethodDelete, c.Args(), c.String("url"), c.String("netns"), token)
+										return nil
+									},
+								},
+							},
+						},
+					},
+				},
+				{
+					Name:        "netns",
+					Description: "Lists, creates and removes named network namespaces and moves links between them",
+					Subcommands: []*cli.Command{
+						{
+							Name:        "list",
+							Description: "Lists the named network namespaces and their links",
+
+							Action: func(c *cli.Context) error {
+								acquireNamespaces(c.String("url"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "add",
+							UsageText:   "add NAME",
+							Description: "Creates a named network namespace",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 1 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								createNamespace(c.Args().First(), c.String("url"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "remove",
+							UsageText:   "remove NAME",
+							Description: "Removes a named network namespace",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 1 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								removeNamespace(c.Args().First(), c.String("url"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "move",
+							UsageText:   "move [--from NAMESPACE] [--to NAMESPACE] LINK",
+							Description: "Moves a link between network namespaces given as name or pid:<id>, the namespace of the daemon when omitted",
+							Flags: []cli.Flag{
+								&cli.StringFlag{Name: "from", Usage: "Namespace the link is in"},
+								&cli.StringFlag{Name: "to", Usage: "Namespace to move the link to"},
+							},
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 1 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								moveLinkToNamespace(c.Args().First(), c.String("from"), c.String("to"), c.String("url"), token)
+								return nil
+							},
+						},
+					},
+				},
+				{
+					Name:        "runtime",
+					Description: "Shows and changes addresses, links, routes and routing policy rules through netlink without touching the systemd-networkd configuration. The changes are lost on reboot or when systemd-networkd reconfigures the link",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "netns", Usage: "Name of the network namespace to change, or pid:<id> for the one of a process"},
+					},
+					Subcommands: []*cli.Command{
+						{
+							Name:        "add-address",
+							UsageText:   "add-address [dev LINK] [address ADDRESS/PREFIX] [peer ADDRESS] [broadcast ADDRESS] [label LABEL] [scope SCOPE] [flags nodad,optimistic,home,mngtmpaddr,noprefixroute,autojoin] [valid-lft SECONDS] [preferred-lft SECONDS]",
+							Description: "Adds an address to the link at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeAddress(http.MethodPost, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "replace-address",
+							UsageText:   "replace-address [dev LINK] [address ADDRESS/PREFIX] [peer ADDRESS] [broadcast ADDRESS] [label LABEL] [scope SCOPE] [flags nodad,optimistic,home,mngtmpaddr,noprefixroute,autojoin] [valid-lft SECONDS] [preferred-lft SECONDS]",
+							Description: "Adds an address to the link or updates its flags and lifetimes at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeAddress(http.MethodPut, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "remove-address",
+							UsageText:   "remove-address [dev LINK] [address ADDRESS/PREFIX]",
+							Description: "Removes an address from the link at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeAddress(http.MethodDelete, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "set-link",
+							UsageText:   "set-link [dev LINK] [state {up|down}] [mtu MTU] [mac MAC] [txqlen LENGTH] [alias ALIAS] [master {LINK|none}]",
+							Description: "Sets the state, MTU, MAC address, transmit queue length, alias or master of the link at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeLink(c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "add-route",
+							UsageText:   "add-route [dst {PREFIX|default}] [dev LINK] [gw ADDRESS] [onlink BOOL] [src ADDRESS] [table TABLE] [metric METRIC] [scope SCOPE] [type {unicast|local|blackhole|unreachable|prohibit|throw}] [nexthop gw=ADDRESS,dev=LINK,weight=WEIGHT,onlink]...",
+							Description: "Adds a route at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeRoute(http.MethodPost, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "replace-route",
+							UsageText:   "replace-route [dst {PREFIX|default}] [dev LINK] [gw ADDRESS] [onlink BOOL] [src ADDRESS] [table TABLE] [metric METRIC] [scope SCOPE] [type {unicast|local|blackhole|unreachable|prohibit|throw}] [nexthop gw=ADDRESS,dev=LINK,weight=WEIGHT,onlink]...",
+							Description: "Adds or replaces a route at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeRoute(http.MethodPut, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "remove-route",
+							UsageText:   "remove-route [dst {PREFIX|default}] [dev LINK] [gw ADDRESS] [onlink BOOL] [src ADDRESS] [table TABLE] [metric METRIC] [scope SCOPE] [type {unicast|local|blackhole|unreachable|prohibit|throw}] [nexthop gw=ADDRESS,dev=LINK,weight=WEIGHT,onlink]...",
+							Description: "Removes a route at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRuntimeRoute(http.MethodDelete, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "show-routes",
+							UsageText:   "show-routes [--table {TABLE|all}]",
+							Description: "Shows the routes of the main table or of the given one with table, protocol, scope and type names from /etc/iproute2",
+							Flags: []cli.Flag{
+								&cli.StringFlag{Name: "table", Usage: "Number or name of the table, all for every table"},
+							},
+
+							Action: func(c *cli.Context) error {
+								acquireRoutes(c.String("table"), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "show-rules",
+							UsageText:   "show-rules [--family ipv4|ipv6]",
+							Description: "Shows the routing policy rules",
+							Flags: []cli.Flag{
+								&cli.StringFlag{Name: "family", Usage: "ipv4 or ipv6"},
+							},
+
+							Action: func(c *cli.Context) error {
+								acquireRules(c.String("family"), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "add-rule",
+							UsageText:   "add-rule [family {ipv4|ipv6}] [priority PRIORITY] [from PREFIX] [to PREFIX] [iif LINK] [oif LINK] [fwmark MARK[/MASK]] [uidrange UID[-UID]] [table TABLE] [action {table|goto|nop|blackhole|unreachable|prohibit}] [goto PRIORITY] [suppress-prefixlength LENGTH] [invert BOOL]",
+							Description: "Adds a routing policy rule at runtime, looking up the main table unless a table or action is given",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRule(http.MethodPost, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+						{
+							Name:        "remove-rule",
+							UsageText:   "remove-rule [family {ipv4|ipv6}] [priority PRIORITY] [from PREFIX] [to PREFIX] [iif LINK] [oif LINK] [fwmark MARK[/MASK]] [uidrange UID[-UID]] [table TABLE] [action {table|goto|nop|blackhole|unreachable|prohibit}] [goto PRIORITY] [suppress-prefixlength LENGTH] [invert BOOL]",
+							Description: "Removes the first routing policy rule matching the given fields at runtime",
+
+							Action: func(c *cli.Context) error {
+								if c.NArg() < 2 {
+									fmt.Printf("Too few arguments.\n")
+									return nil
+								}
+
+								networkRule(http.MethodDelete, c.Args(), c.String("url"), c.String("netns"), token)
+								return nil
+							},
+						},
+					},
+				},
+				{
+					Name:        "show-config",
+					UsageText:   "show-config [--type network|netdev|link] NAME",
+					Description: "Show the .network file of a link, the .netdev file of a netdev or the .link file of a link as it is on disk",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "type", Value: "network", Usage: "network, netdev or link"},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() < 1 {
+							fmt.Printf("Too few arguments.\n")
+							return nil
+						}
+
+						acquireNetworkConfig(c.String("type"), c.Args().First(), c.String("url"), token)
+						return nil
+					},
+				},
 				{
 					Name:        "set-dhcp",
 					UsageText:   "set-dhcp [LINK] [DHCP-MODE {yes|no|ipv4|ipv6}]",
@@ -1187,6 +2019,13 @@ func main() {
 			Name:    "link",
 			Aliases: []string{"l"},
 			Usage:   "Network device configuration",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "confirm-timeout", Usage: "Roll back the change unless confirmed within the timeout, e.g. 60s"},
+			},
+			Before: func(c *cli.Context) error {
+				networkConfirmTimeout = c.String("confirm-timeout")
+				return nil
+			},
 			Subcommands: []*cli.Command{
 				{
 					Name:        "set-mac",
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/audit"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+type AuditDesc struct {
+	Success bool           `json:"success"`
+	Message []audit.Record `json:"message"`
+	Errors  string         `json:"errors"`
+}
+
+func acquireAudit(since string, user string, path string, limit string, host string, token map[string]string) {
+	v := url.Values{}
+	for k, s := range map[string]string{"since": since, "user": user, "path": path, "limit": limit} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+
+	req := "/api/v1/_audit"
+	if len(v) > 0 {
+		req += "?" + v.Encode()
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, req, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to acquire audit log: %v\n", err)
+		return
+	}
+
+	m := AuditDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to acquire audit log: %v\n", m.Errors)
+		return
+	}
+
+	for _, rec := range m.Message {
+		result := color.HiGreenString("ok")
+		if !rec.Success {
+			result = color.HiRedString("failed")
+		}
+
+		fmt.Printf("%v %v %v %v %v (%v)\n", rec.Time.Local().Format("2006-01-02 15:04:05"), color.HiBlueString(rec.User), rec.Method, rec.Path, result, rec.Duration)
+		if len(rec.Body) > 0 {
+			fmt.Printf("    %v %s\n", color.HiBlueString("Body:"), rec.Body)
+		}
+		if rec.Error != "" {
+			fmt.Printf("    %v %v\n", color.HiBlueString("Error:"), rec.Error)
+		}
+	}
+}
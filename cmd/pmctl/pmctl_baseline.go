@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/baseline"
+)
+
+type BaselineInfoDesc struct {
+	Success bool          `json:"success"`
+	Message baseline.Info `json:"message"`
+	Errors  string        `json:"errors"`
+}
+
+type BaselineDriftDesc struct {
+	Success bool           `json:"success"`
+	Message baseline.Drift `json:"message"`
+	Errors  string         `json:"errors"`
+}
+
+func captureBaseline(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/baseline/capture", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to capture baseline: %v\n", err)
+		return
+	}
+
+	m := BaselineInfoDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to capture baseline: %v\n", m.Errors)
+		return
+	}
+
+	fmt.Printf("Captured baseline version %v at %v\n", m.Message.Version, m.Message.CapturedAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+func driftValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func acquireBaselineDrift(version string, host string, token map[string]string) {
+	req := "/api/v1/baseline/drift"
+	if version != "" {
+		req += "?version=" + version
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, req, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to acquire drift: %v\n", err)
+		return
+	}
+
+	m := BaselineDriftDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to acquire drift: %v\n", m.Errors)
+		return
+	}
+
+	d := m.Message
+	fmt.Printf("%v %v (captured %v)\n", color.HiBlueString("Baseline:"), d.Version, d.CapturedAt.Local().Format("2006-01-02 15:04:05"))
+	if !d.Drifted {
+		fmt.Printf("No drift detected\n")
+		return
+	}
+
+	subsystems := make([]string, 0, len(d.Subsystems))
+	for s := range d.Subsystems {
+		subsystems = append(subsystems, s)
+	}
+	sort.Strings(subsystems)
+
+	for _, s := range subsystems {
+		if len(d.Subsystems[s]) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n%v\n", color.HiBlueString(s))
+		for _, diff := range d.Subsystems[s] {
+			switch diff.Kind {
+			case baseline.DriftAdded:
+				fmt.Printf("  %v %v: %v\n", color.HiGreenString("+"), diff.Path, driftValue(diff.Current))
+			case baseline.DriftRemoved:
+				fmt.Printf("  %v %v: %v\n", color.HiRedString("-"), diff.Path, driftValue(diff.Baseline))
+			default:
+				fmt.Printf("  %v %v: %v -> %v\n", color.HiYellowString("~"), diff.Path, driftValue(diff.Baseline), driftValue(diff.Current))
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/events"
+)
+
+func displayEvent(event string, data []byte) bool {
+	e := events.Event{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		fmt.Printf("Failed to decode event: %v\n", err)
+		return true
+	}
+
+	kind := color.HiYellowString(e.Type)
+	switch e.Type {
+	case events.TypeNew:
+		kind = color.HiGreenString(e.Type)
+	case events.TypeDel:
+		kind = color.HiRedString(e.Type)
+	}
+
+	d, _ := json.Marshal(e.Data)
+	fmt.Printf("%v %v %v %v %s\n", e.Time.Local().Format("15:04:05.000"), color.HiBlueString(e.Topic), kind, e.Name, d)
+
+	return true
+}
+
+func monitorEvents(topics []string, host string, token map[string]string) {
+	req := "/api/v1/events"
+	if len(topics) > 0 {
+		v := url.Values{}
+		for _, t := range topics {
+			v.Add("topic", t)
+		}
+		req += "?" + v.Encode()
+	}
+
+	if err := web.DispatchEvents(host, req, token, displayEvent); err != nil {
+		fmt.Printf("Failed to monitor events: %v\n", err)
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+)
+
+// displayJobEvent shows the progress of a job while it runs. The result of the job is displayed
+// by the caller once the job completes.
+func displayJobEvent(event string, data []byte) {
+	e := jobs.Event{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return
+	}
+
+	switch e.Type {
+	case jobs.EventProgress:
+		fmt.Printf("%v %s\n", color.HiBlueString("==>"), e.Data)
+	case jobs.EventStderr:
+		fmt.Fprint(os.Stderr, e.Data)
+	case jobs.EventState:
+		if e.State != jobs.StateRunning && e.State != jobs.StateComplete {
+			fmt.Printf("%v job %s\n", color.HiBlueString("==>"), e.State)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/journal"
+)
+
+type JournalPageDesc struct {
+	Success bool         `json:"success"`
+	Message journal.Page `json:"message"`
+	Errors  string       `json:"errors"`
+}
+
+func displayJournalEntry(e *journal.Entry) {
+	ident := e.Identifier
+	if ident == "" {
+		ident = e.Unit
+	}
+	if e.Pid > 0 {
+		ident += "[" + strconv.Itoa(e.Pid) + "]"
+	}
+
+	msg := e.Message
+	switch {
+	case e.Priority <= 3:
+		msg = color.HiRedString(msg)
+	case e.Priority == 4:
+		msg = color.HiYellowString(msg)
+	}
+
+	fmt.Printf("%v %v %v: %v\n", color.HiBlueString(e.Time.Local().Format("Jan 02 15:04:05")), e.Hostname, ident, msg)
+}
+
+func acquireJournal(unit string, lines int, priority string, follow bool, host string, token map[string]string) {
+	v := url.Values{}
+	if unit != "" {
+		v.Set("unit", unit)
+	}
+	if priority != "" {
+		v.Set("priority", priority)
+	}
+	if lines > 0 {
+		v.Set("lines", strconv.Itoa(lines))
+	}
+
+	if follow {
+		if err := web.DispatchEvents(host, "/api/v1/journal/follow?"+v.Encode(), token, func(event string, data []byte) bool {
+			if event == "error" {
+				fmt.Printf("Failed to follow journal: %s\n", data)
+				return false
+			}
+
+			e := journal.Entry{}
+			if err := json.Unmarshal(data, &e); err != nil {
+				fmt.Printf("Failed to decode journal entry: %v\n", err)
+				return true
+			}
+
+			displayJournalEntry(&e)
+			return true
+		}); err != nil {
+			fmt.Printf("Failed to follow journal: %v\n", err)
+		}
+		return
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/journal?"+v.Encode(), token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch journal: %v\n", err)
+		return
+	}
+
+	j := JournalPageDesc{}
+	if err := json.Unmarshal(resp, &j); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !j.Success {
+		fmt.Printf("Failed to fetch journal: %v\n", j.Errors)
+		return
+	}
+
+	for i := range j.Message.Entries {
+		displayJournalEntry(&j.Message.Entries[i])
+	}
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -360,7 +361,7 @@ func networkConfigure(network *networkd.Network, host string, token map[string]s
 	var resp []byte
 	var err error
 
-	resp, err = web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, *network)
+	resp, err = dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, *network)
 	if err != nil {
 		fmt.Printf("Failed to configure network: %v\n", err)
 		return
@@ -690,7 +691,7 @@ func networkConfigureRemoveDHCPv4Server(link string, host string, token map[stri
 
 	var resp []byte
 
-	resp, err := web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+	resp, err := dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 	if err != nil {
 		fmt.Printf("Failed to remove network dhcp server: %v\n", err)
 		return
@@ -1071,7 +1072,7 @@ func networkRemoveRoutingPolicyRule(args cli.Args, host string, token map[string
 
 	var resp []byte
 
-	resp, err = web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+	resp, err = dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 	if err != nil {
 		fmt.Printf("Failed to remove network routing policy rule: %v\n", err)
 		return
@@ -1126,7 +1127,7 @@ func networkAddDns(args cli.Args, host string, token map[string]string) {
 				DNS: dns,
 			},
 		}
-		resp, err = web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
+		resp, err = dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
 		if err != nil {
 			fmt.Printf("Failed to add link Dns server: %v\n", err)
 			return
@@ -1182,7 +1183,7 @@ func networkRemoveDns(args cli.Args, host string, token map[string]string) {
 			},
 		}
 
-		resp, err = web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+		resp, err = dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 		if err != nil {
 			fmt.Printf("Failed to remove link Dns server: %v\n", err)
 			return
@@ -1237,7 +1238,7 @@ func networkAddDomains(args cli.Args, host string, token map[string]string) {
 				Domains: domains,
 			},
 		}
-		resp, err = web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
+		resp, err = dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
 		if err != nil {
 			fmt.Printf("Failed to add link  domains: %v\n", err)
 			return
@@ -1293,7 +1294,7 @@ func networkRemoveDomains(args cli.Args, host string, token map[string]string) {
 			},
 		}
 
-		resp, err = web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+		resp, err = dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 		if err != nil {
 			fmt.Printf("Failed to remove link domains: %v\n", err)
 			return
@@ -1344,7 +1345,7 @@ func networkAddNTP(args cli.Args, host string, token map[string]string) {
 				NTP: ntp,
 			},
 		}
-		resp, err = web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
+		resp, err = dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/network/configure", token, n)
 		if err != nil {
 			fmt.Printf("Failed to add link NTP server: %v\n", err)
 			return
@@ -1395,7 +1396,7 @@ func networkRemoveNTP(args cli.Args, host string, token map[string]string) {
 				NTP: ntp,
 			},
 		}
-		resp, err = web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+		resp, err = dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 		if err != nil {
 			fmt.Printf("Failed to remove link NTP server: %v\n", err)
 			return
@@ -1543,7 +1544,7 @@ func networkConfigureRemoveIPv6SendRA(link string, host string, token map[string
 
 	var resp []byte
 
-	resp, err := web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
+	resp, err := dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/network/remove", token, n)
 	if err != nil {
 		fmt.Printf("Failed to remove network IPv6SendRA: %v\n", err)
 		return
@@ -1724,3 +1725,51 @@ func networkConfigureDHCPv6Option(args cli.Args, host string, token map[string]s
 	// Dispatch Request.
 	networkConfigure(&n, host, token)
 }
+
+func acquireNetworkConfig(kind string, name string, host string, token map[string]string) {
+	switch kind {
+	case "network", "netdev", "link":
+	default:
+		fmt.Printf("Unknown type '%s'\n", kind)
+		return
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/network/networkd/"+kind+"/"+name, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch configuration: %v\n", err)
+		return
+	}
+
+	m := struct {
+		Success bool            `json:"success"`
+		Message json.RawMessage `json:"message"`
+		Errors  string          `json:"errors"`
+	}{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch configuration: %v\n", m.Errors)
+		return
+	}
+
+	f := struct {
+		File    string   `json:"File"`
+		DropIns []string `json:"DropIns"`
+	}{}
+	json.Unmarshal(m.Message, &f)
+
+	var b bytes.Buffer
+	if err := json.Indent(&b, m.Message, "", "  "); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	fmt.Printf("%v %v\n", color.HiBlueString("File:"), f.File)
+	for _, d := range f.DropIns {
+		fmt.Printf("%v %v\n", color.HiBlueString("Drop-In:"), d)
+	}
+	fmt.Println(b.String())
+}
@@ -22,7 +22,7 @@ func dispatchNetworkLinkConfigReq(l networkd.Link, host string, token map[string
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/link/configure", token, l)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/link/configure", token, l)
 	if err != nil {
 		fmt.Printf("Failed to set link: %v\n", err)
 		return
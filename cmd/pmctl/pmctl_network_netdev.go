@@ -46,7 +46,7 @@ func networkCreateVLan(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create VLan: %v\n", err)
 		return
@@ -107,7 +107,7 @@ func networkCreateBond(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create Bond: %v\n", err)
 		return
@@ -145,7 +145,7 @@ func networkCreateBridge(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create bridge: %v\n", err)
 		return
@@ -190,7 +190,7 @@ func networkCreateMacVLan(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create MacVLan: %v\n", err)
 		return
@@ -242,7 +242,7 @@ func networkCreateIpVLan(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create IpVLan: %v\n", err)
 		return
@@ -316,7 +316,7 @@ func networkCreateVxLan(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create VxLan: %v\n", err)
 		return
@@ -382,7 +382,7 @@ func networkCreateWireGuard(args cli.Args, host string, token map[string]string)
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create WireGuard: %v\n", err)
 		return
@@ -432,7 +432,7 @@ func networkCreateTunOrTap(args cli.Args, kind, host string, token map[string]st
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
+	resp, err := dispatchNetworkd(http.MethodPost, host, "/api/v1/network/networkd/netdev/configure", token, n)
 	if err != nil {
 		fmt.Printf("Failed to create %s: %v\n", kind, err)
 		return
@@ -469,7 +469,7 @@ func networkRemoveNetDev(args cli.Args, host string, token map[string]string) {
 		return
 	}
 
-	resp, err := web.DispatchSocket(http.MethodDelete, host, "/api/v1/network/networkd/netdev/remove", token, n)
+	resp, err := dispatchNetworkd(http.MethodDelete, host, "/api/v1/network/networkd/netdev/remove", token, n)
 	if err != nil {
 		fmt.Printf("Failed to remove netdev: %v\n", err)
 		return
@@ -0,0 +1,583 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/vmware/pmd-next-gen/pkg/validator"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/address"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/link"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/neigh"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/route"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/rule"
+)
+
+func dispatchNetlink(method string, host string, url string, ns string, token map[string]string, v interface{}) {
+	if ns != "" {
+		if strings.Contains(url, "?") {
+			url += "&netns=" + ns
+		} else {
+			url += "?netns=" + ns
+		}
+	}
+
+	resp, err := web.DispatchSocket(method, host, url, token, v)
+	if err != nil {
+		fmt.Printf("Failed to configure: %v\n", err)
+		return
+	}
+
+	m := web.JSONResponseMessage{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to configure: %v\n", m.Errors)
+	}
+}
+
+func networkRuntimeAddress(method string, args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	a := address.AddressAction{}
+	for i := 0; i+1 < len(argStrings); i += 2 {
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "dev":
+			a.Link = v
+		case "address":
+			a.Address.IP = v
+		case "peer":
+			a.Address.Peer = v
+		case "broadcast":
+			a.Address.Broadcast = v
+		case "label":
+			a.Address.Label = v
+		case "scope":
+			if !validator.IsUint8(v) {
+				fmt.Printf("Invalid scope=%s\n", v)
+				return
+			}
+			a.Address.Scope, _ = strconv.Atoi(v)
+		case "flags":
+			a.Address.FlagNames = strings.Split(v, ",")
+		case "valid-lft", "preferred-lft":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				fmt.Printf("Invalid %s=%s\n", argStrings[i], v)
+				return
+			}
+			if argStrings[i] == "valid-lft" {
+				a.Address.ValidLft = n
+			} else {
+				a.Address.PreferedLft = n
+			}
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+	}
+
+	if a.Link == "" || a.Address.IP == "" {
+		fmt.Printf("Missing dev or address\n")
+		return
+	}
+
+	dispatchNetlink(method, host, "/api/v1/network/netlink/address", ns, token, a)
+}
+
+func networkRuntimeLink(args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	l := link.LinkSetting{}
+	for i := 0; i+1 < len(argStrings); i += 2 {
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "dev":
+			l.Name = v
+		case "state":
+			l.State = v
+		case "mtu":
+			l.MTU = v
+		case "mac":
+			l.HardwareAddr = v
+		case "txqlen":
+			l.TxQLen = v
+		case "alias":
+			l.Alias = v
+		case "master":
+			l.Master = v
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+	}
+
+	if l.Name == "" {
+		fmt.Printf("Missing dev\n")
+		return
+	}
+
+	dispatchNetlink(http.MethodPut, host, "/api/v1/network/netlink/link/"+l.Name, ns, token, l)
+}
+
+// parseNextHop parses a nexthop given as comma separated key=value pairs of gw, dev, weight and onlink
+func parseNextHop(s string) (*route.NextHop, error) {
+	nh := route.NextHop{}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "gw":
+			nh.Gateway = v
+		case "dev":
+			nh.Link = v
+		case "weight":
+			w, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight=%s", v)
+			}
+			nh.Weight = w
+		case "onlink":
+			nh.OnLink = v == "" || validator.BoolToString(v) == "yes"
+		default:
+			return nil, fmt.Errorf("unknown nexthop key '%s'", k)
+		}
+	}
+
+	return &nh, nil
+}
+
+func networkRuntimeRoute(method string, args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	rt := route.RouteRequest{}
+	for i := 0; i+1 < len(argStrings); i += 2 {
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "dst":
+			rt.Destination = v
+		case "dev":
+			rt.Link = v
+		case "gw":
+			rt.Gateway = v
+		case "onlink":
+			if !validator.IsBool(v) {
+				fmt.Printf("Invalid onlink=%s\n", v)
+				return
+			}
+			rt.OnLink = validator.BoolToString(v) == "yes"
+		case "src":
+			rt.Source = v
+		case "table":
+			rt.Table = v
+		case "metric":
+			m, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				fmt.Printf("Invalid metric=%s\n", v)
+				return
+			}
+			rt.Metric = uint32(m)
+		case "scope":
+			rt.Scope = v
+		case "type":
+			rt.Type = v
+		case "nexthop":
+			nh, err := parseNextHop(v)
+			if err != nil {
+				fmt.Printf("Invalid nexthop=%s: %v\n", v, err)
+				return
+			}
+			rt.MultiPath = append(rt.MultiPath, *nh)
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+	}
+
+	if rt.Destination == "" {
+		fmt.Printf("Missing dst\n")
+		return
+	}
+
+	dispatchNetlink(method, host, "/api/v1/network/netlink/route", ns, token, rt)
+}
+
+type NeighborsDesc struct {
+	Success bool             `json:"success"`
+	Message []neigh.Neighbor `json:"message"`
+	Errors  string           `json:"errors"`
+}
+
+func acquireNeighbors(fdb bool, link string, family string, host string, ns string, token map[string]string) {
+	url := "/api/v1/network/netlink/neigh"
+	if fdb {
+		url += "/fdb"
+	}
+
+	q := []string{}
+	if link != "" {
+		q = append(q, "link="+link)
+	}
+	if family != "" {
+		q = append(q, "family="+family)
+	}
+	if ns != "" {
+		q = append(q, "netns="+ns)
+	}
+	if len(q) > 0 {
+		url += "?" + strings.Join(q, "&")
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, url, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch neighbours: %v\n", err)
+		return
+	}
+
+	m := NeighborsDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch neighbours: %v\n", m.Errors)
+		return
+	}
+
+	for _, n := range m.Message {
+		s := n.IP
+		if fdb {
+			s = n.HardwareAddr
+		}
+		s += " dev " + n.Link
+		if !fdb && n.HardwareAddr != "" {
+			s += " lladdr " + n.HardwareAddr
+		}
+		if fdb && n.IP != "" {
+			s += " dst " + n.IP
+		}
+		if n.Master != "" {
+			s += " master " + n.Master
+		}
+		if n.Vlan != 0 {
+			s += fmt.Sprintf(" vlan %d", n.Vlan)
+		}
+		if n.VNI != 0 {
+			s += fmt.Sprintf(" vni %d", n.VNI)
+		}
+		if len(n.Flags) > 0 {
+			s += " " + strings.Join(n.Flags, " ")
+		}
+
+		fmt.Printf("%v %v\n", s, color.HiBlueString(strings.Join(n.State, ",")))
+	}
+}
+
+func networkNeighbor(method string, args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	n := neigh.NeighborRequest{}
+	for i := 0; i+1 < len(argStrings); i += 2 {
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "dev":
+			n.Link = v
+		case "address":
+			n.IP = v
+		case "mac":
+			n.HardwareAddr = v
+		case "state":
+			n.State = v
+		case "router", "proxy":
+			if !validator.IsBool(v) {
+				fmt.Printf("Invalid %s=%s\n", argStrings[i], v)
+				return
+			}
+			if argStrings[i] == "router" {
+				n.Router = validator.BoolToString(v) == "yes"
+			} else {
+				n.Proxy = validator.BoolToString(v) == "yes"
+			}
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+	}
+
+	if n.Link == "" || n.IP == "" {
+		fmt.Printf("Missing dev or address\n")
+		return
+	}
+
+	dispatchNetlink(method, host, "/api/v1/network/netlink/neigh", ns, token, n)
+}
+
+func networkFlushNeighbors(link string, all bool, host string, ns string, token map[string]string) {
+	url := "/api/v1/network/netlink/neigh/flush/" + link
+	if all {
+		url += "?all=yes"
+	}
+
+	dispatchNetlink(http.MethodDelete, host, url, ns, token, nil)
+}
+
+func networkFDB(method string, args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	f := neigh.FDBRequest{}
+	for i := 0; i < len(argStrings); i++ {
+		switch argStrings[i] {
+		case "master":
+			f.Master = true
+			continue
+		case "self":
+			f.Self = true
+			continue
+		}
+
+		if i+1 >= len(argStrings) {
+			fmt.Printf("Missing value of '%s'\n", argStrings[i])
+			return
+		}
+
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "dev":
+			f.Link = v
+		case "mac":
+			f.HardwareAddr = v
+		case "state":
+			f.State = v
+		case "dst":
+			f.Dst = v
+		case "vlan", "vni":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				fmt.Printf("Invalid %s=%s\n", argStrings[i], v)
+				return
+			}
+			if argStrings[i] == "vlan" {
+				f.Vlan = n
+			} else {
+				f.VNI = n
+			}
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+		i++
+	}
+
+	if f.Link == "" || f.HardwareAddr == "" {
+		fmt.Printf("Missing dev or mac\n")
+		return
+	}
+
+	dispatchNetlink(method, host, "/api/v1/network/netlink/neigh/fdb", ns, token, f)
+}
+
+type RoutesDesc struct {
+	Success bool              `json:"success"`
+	Message []route.RouteInfo `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+func acquireRoutes(table string, host string, ns string, token map[string]string) {
+	q := []string{}
+	if table != "" {
+		q = append(q, "table="+table)
+	}
+	if ns != "" {
+		q = append(q, "netns="+ns)
+	}
+
+	url := "/api/v1/network/netlink/route"
+	if len(q) > 0 {
+		url += "?" + strings.Join(q, "&")
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, url, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch routes: %v\n", err)
+		return
+	}
+
+	m := RoutesDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch routes: %v\n", m.Errors)
+		return
+	}
+
+	for _, rt := range m.Message {
+		s := "default"
+		if rt.Dst.IP != "" {
+			s = fmt.Sprintf("%s/%d", rt.Dst.IP, rt.Dst.Mask)
+		}
+		if rt.TypeName != "unicast" {
+			s = rt.TypeName + " " + s
+		}
+		if rt.Gw != "" {
+			s += " via " + rt.Gw
+		}
+		if rt.LinkName != "" {
+			s += " dev " + rt.LinkName
+		}
+		if rt.MultiPath != "" {
+			s += " nexthop " + rt.MultiPath
+		}
+		s += " table " + rt.TableName + " proto " + rt.ProtocolName + " scope " + rt.ScopeName
+		if rt.Src != "" {
+			s += " src " + rt.Src
+		}
+		if rt.Priority != 0 {
+			s += fmt.Sprintf(" metric %d", rt.Priority)
+		}
+
+		fmt.Println(s)
+	}
+}
+
+type RulesDesc struct {
+	Success bool        `json:"success"`
+	Message []rule.Rule `json:"message"`
+	Errors  string      `json:"errors"`
+}
+
+func acquireRules(family string, host string, ns string, token map[string]string) {
+	q := []string{}
+	if family != "" {
+		q = append(q, "family="+family)
+	}
+	if ns != "" {
+		q = append(q, "netns="+ns)
+	}
+
+	url := "/api/v1/network/netlink/rule"
+	if len(q) > 0 {
+		url += "?" + strings.Join(q, "&")
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, url, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch routing policy rules: %v\n", err)
+		return
+	}
+
+	m := RulesDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch routing policy rules: %v\n", m.Errors)
+		return
+	}
+
+	for _, r := range m.Message {
+		s := ""
+		if r.Invert {
+			s += "not "
+		}
+		s += "from " + r.From
+		if r.To != "" {
+			s += " to " + r.To
+		}
+		if r.FwMark != 0 || r.FwMask != 0 {
+			s += fmt.Sprintf(" fwmark %#x", r.FwMark)
+			if r.FwMask != 0 && r.FwMask != 0xffffffff {
+				s += fmt.Sprintf("/%#x", r.FwMask)
+			}
+		}
+		if r.IIF != "" {
+			s += " iif " + r.IIF
+		}
+		if r.OIF != "" {
+			s += " oif " + r.OIF
+		}
+		if r.UIDRange != "" {
+			s += " uidrange " + r.UIDRange
+		}
+
+		switch r.Action {
+		case "table":
+			s += " lookup " + r.TableName
+		case "goto":
+			s += fmt.Sprintf(" goto %d", r.Goto)
+		default:
+			s += " " + r.Action
+		}
+		if r.SuppressPrefixLength >= 0 {
+			s += fmt.Sprintf(" suppress_prefixlength %d", r.SuppressPrefixLength)
+		}
+		if r.Protocol != "" && r.Protocol != "unspec" {
+			s += " proto " + r.Protocol
+		}
+
+		fmt.Printf("%v %v %v\n", color.HiBlueString(fmt.Sprintf("%d:", r.Priority)), color.HiYellowString(r.Family), s)
+	}
+}
+
+func networkRule(method string, args cli.Args, host string, ns string, token map[string]string) {
+	argStrings := args.Slice()
+
+	r := rule.RuleRequest{}
+	for i := 0; i+1 < len(argStrings); i += 2 {
+		v := argStrings[i+1]
+		switch argStrings[i] {
+		case "family":
+			r.Family = v
+		case "priority":
+			r.Priority = v
+		case "from":
+			r.From = v
+		case "to":
+			r.To = v
+		case "iif":
+			r.IIF = v
+		case "oif":
+			r.OIF = v
+		case "fwmark":
+			r.FwMark = v
+		case "uidrange":
+			r.UIDRange = v
+		case "table":
+			r.Table = v
+		case "action":
+			r.Action = v
+		case "goto":
+			r.Goto = v
+		case "suppress-prefixlength":
+			r.SuppressPrefixLength = v
+		case "invert":
+			if !validator.IsBool(v) {
+				fmt.Printf("Invalid invert=%s\n", v)
+				return
+			}
+			r.Invert = validator.BoolToString(v) == "yes"
+		default:
+			fmt.Printf("Unknown argument '%s'\n", argStrings[i])
+			return
+		}
+	}
+
+	dispatchNetlink(method, host, "/api/v1/network/netlink/rule", ns, token, r)
+}
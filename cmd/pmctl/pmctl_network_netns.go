@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/network/namespace"
+)
+
+type NamespacesDesc struct {
+	Success bool                  `json:"success"`
+	Message []namespace.Namespace `json:"message"`
+	Errors  string                `json:"errors"`
+}
+
+func acquireNamespaces(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/network/netns", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch network namespaces: %v\n", err)
+		return
+	}
+
+	m := NamespacesDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch network namespaces: %v\n", m.Errors)
+		return
+	}
+
+	for _, n := range m.Message {
+		fmt.Printf("%v %v\n", color.HiBlueString("Name:"), n.Name)
+		fmt.Printf("%v %v\n", color.HiBlueString("  ID:"), n.ID)
+		fmt.Printf("%v %v\n\n", color.HiBlueString("Links:"), strings.Join(n.Links, " "))
+	}
+}
+
+func createNamespace(name string, host string, token map[string]string) {
+	dispatchNetlink(http.MethodPost, host, "/api/v1/network/netns", "", token, namespace.Namespace{Name: name})
+}
+
+func removeNamespace(name string, host string, token map[string]string) {
+	dispatchNetlink(http.MethodDelete, host, "/api/v1/network/netns/"+name, "", token, nil)
+}
+
+func moveLinkToNamespace(link string, from string, to string, host string, token map[string]string) {
+	m := namespace.MoveLinkRequest{
+		Link: link,
+		From: from,
+		To:   to,
+	}
+
+	dispatchNetlink(http.MethodPost, host, "/api/v1/network/netns/move", "", token, m)
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/network/networkd"
+)
+
+type TransactionDesc struct {
+	Success bool                 `json:"success"`
+	Message networkd.Transaction `json:"message"`
+	Errors  string               `json:"errors"`
+}
+
+// Set from --confirm-timeout of the network and link commands
+var networkConfirmTimeout string
+
+// dispatchNetworkd sends a networkd configuration request, in commit confirmed mode when --confirm-timeout is given
+func dispatchNetworkd(method, host string, path string, token map[string]string, data interface{}) ([]byte, error) {
+	if networkConfirmTimeout == "" {
+		return web.DispatchSocket(method, host, path, token, data)
+	}
+
+	r, err := web.DispatchSocketWithStatus(method, host, path+"?confirm-timeout="+url.QueryEscape(networkConfirmTimeout), token, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode != http.StatusOK {
+		return nil, errors.New(r.Status)
+	}
+
+	if id := r.Header.Get("X-Transaction-Id"); id != "" {
+		fmt.Printf("%v %v applied. Confirm within %v with 'pmctl network confirm %v' or it is rolled back.\n",
+			color.HiBlueString("Transaction"), id, networkConfirmTimeout, id)
+	}
+
+	return r.Body, nil
+}
+
+func networkTransactionAction(id string, action string, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/network/networkd/transaction/"+id+"/"+action, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to %s transaction: %v\n", action, err)
+		return
+	}
+
+	m := NilDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to %s transaction: %v\n", action, m.Errors)
+	}
+}
+
+func acquireNetworkTransaction(id string, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/network/networkd/transaction/"+id, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to acquire transaction: %v\n", err)
+		return
+	}
+
+	m := TransactionDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to acquire transaction: %v\n", m.Errors)
+		return
+	}
+
+	t := m.Message
+	fmt.Printf("      %v %v\n", color.HiBlueString("Id:"), t.Id)
+	fmt.Printf("   %v %v\n", color.HiBlueString("State:"), t.State)
+	fmt.Printf("%v %v\n", color.HiBlueString("Endpoint:"), t.Endpoint)
+	fmt.Printf(" %v %v\n", color.HiBlueString("Timeout:"), t.Timeout)
+	if !t.Deadline.IsZero() {
+		fmt.Printf("%v %v\n", color.HiBlueString("Deadline:"), t.Deadline.Local())
+	}
+	if t.Error != "" {
+		fmt.Printf("   %v %v\n", color.HiBlueString("Error:"), t.Error)
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/system"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/management/power"
+)
+
+type PowerDesc struct {
+	Success bool           `json:"success"`
+	Message power.Describe `json:"message"`
+	Errors  string         `json:"errors"`
+}
+
+type ScheduledShutdownDesc struct {
+	Success bool                     `json:"success"`
+	Message *power.ScheduledShutdown `json:"message"`
+	Errors  string                   `json:"errors"`
+}
+
+type InhibitorsDesc struct {
+	Success bool              `json:"success"`
+	Message []power.Inhibitor `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+func displayScheduledShutdown(s *power.ScheduledShutdown) {
+	if s == nil || s.Type == "" {
+		fmt.Printf("       %v %v\n", color.HiBlueString("Scheduled:"), "none")
+		return
+	}
+
+	fmt.Printf("       %v %v at %v\n", color.HiBlueString("Scheduled:"), s.Type, system.UnixMicro(int64(s.USec)).Format(time.RFC1123))
+	if s.WallMessage != "" {
+		fmt.Printf("    %v %v\n", color.HiBlueString("Wall Message:"), s.WallMessage)
+	}
+}
+
+func executePowerAction(action string, when string, message string, host string, token map[string]string) {
+	p := power.PowerRequest{
+		Action:      action,
+		When:        when,
+		WallMessage: message,
+	}
+
+	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/system/power", token, p)
+	if err != nil {
+		fmt.Printf("Failed to execute power action: %v\n", err)
+		return
+	}
+
+	m := ScheduledShutdownDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to execute power action: %v\n", m.Errors)
+		return
+	}
+
+	if m.Message != nil {
+		displayScheduledShutdown(m.Message)
+	}
+}
+
+func cancelScheduledShutdown(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodDelete, host, "/api/v1/system/power/scheduled", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to cancel scheduled shutdown: %v\n", err)
+		return
+	}
+
+	m := web.JSONResponseMessage{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to cancel scheduled shutdown: %v\n", m.Errors)
+	}
+}
+
+func acquirePower(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/system/power", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch power state: %v\n", err)
+		return
+	}
+
+	m := PowerDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch power state: %v\n", m.Errors)
+		return
+	}
+
+	fmt.Printf("     %v %v\n", color.HiBlueString("Can Reboot:"), m.Message.CanReboot)
+	fmt.Printf("   %v %v\n", color.HiBlueString("Can PowerOff:"), m.Message.CanPowerOff)
+	fmt.Printf("       %v %v\n", color.HiBlueString("Can Halt:"), m.Message.CanHalt)
+	fmt.Printf("    %v %v\n", color.HiBlueString("Can Suspend:"), m.Message.CanSuspend)
+	fmt.Printf("  %v %v\n", color.HiBlueString("Can Hibernate:"), m.Message.CanHibernate)
+	fmt.Printf("%v %v\n", color.HiBlueString("Can HybridSleep:"), m.Message.CanHybridSleep)
+	displayScheduledShutdown(m.Message.Scheduled)
+}
+
+func acquireInhibitors(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/system/power/inhibitors", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch inhibitors: %v\n", err)
+		return
+	}
+
+	m := InhibitorsDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch inhibitors: %v\n", m.Errors)
+		return
+	}
+
+	fmt.Printf("%v %v %v %v %v %v\n", color.HiBlueString("%-20s", "WHO"), color.HiBlueString("%-8s", "UID"), color.HiBlueString("%-8s", "PID"), color.HiBlueString("%-30s", "WHAT"), color.HiBlueString("%-10s", "MODE"), color.HiBlueString("WHY"))
+	for _, i := range m.Message {
+		fmt.Printf("%-20s %-8d %-8d %-30s %-10s %s\n", i.Who, i.UID, i.PID, i.What, i.Mode, i.Why)
+	}
+}
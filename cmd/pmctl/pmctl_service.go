@@ -6,9 +6,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	sd "github.com/coreos/go-systemd/v22/dbus"
 	"github.com/fatih/color"
 
 	"github.com/vmware/pmd-next-gen/pkg/web"
@@ -21,10 +26,32 @@ type UnitStatus struct {
 	Errors  string             `json:"errors"`
 }
 
-func executeSystemdUnitCommand(verb string, unit string, host string, token map[string]string) {
+type UnitsDesc struct {
+	Success bool            `json:"success"`
+	Message []sd.UnitStatus `json:"message"`
+	Errors  string          `json:"errors"`
+}
+
+type UnitResultsDesc struct {
+	Success bool                 `json:"success"`
+	Message []systemd.UnitResult `json:"message"`
+	Errors  string               `json:"errors"`
+}
+
+func executeSystemdUnitCommand(verb string, units []string, host string, token map[string]string) {
+	if len(units) == 0 {
+		fmt.Printf("Too few arguments.\n")
+		return
+	}
+
+	if len(units) > 1 || strings.ContainsAny(units[0], "*?[") {
+		executeSystemdBulkUnitCommand(verb, units, host, token)
+		return
+	}
+
 	c := systemd.UnitRequest{
 		Verb: verb,
-		Unit: unit,
+		Unit: units[0],
 	}
 
 	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/service/systemd", token, c)
@@ -138,3 +165,446 @@ func acquireSystemdUnitStatus(unit string, host string, token map[string]string)
 		fmt.Println(u.Errors)
 	}
 }
+
+type UnitCatDesc struct {
+	Success bool            `json:"success"`
+	Message systemd.UnitCat `json:"message"`
+	Errors  string          `json:"errors"`
+}
+
+func acquireSystemdUnitCat(unit string, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/"+unit+"/cat", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch unit files: %v\n", err)
+		return
+	}
+
+	u := UnitCatDesc{}
+	if err := json.Unmarshal(resp, &u); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !u.Success {
+		fmt.Printf("Failed to fetch unit files: %v\n", u.Errors)
+		return
+	}
+
+	fmt.Print(u.Message.Text)
+}
+
+type TimersDesc struct {
+	Success bool            `json:"success"`
+	Message []systemd.Timer `json:"message"`
+	Errors  string          `json:"errors"`
+}
+
+func timerUSecString(usec uint64) string {
+	if usec == 0 {
+		return "n/a"
+	}
+
+	return time.UnixMicro(int64(usec)).Format(time.UnixDate)
+}
+
+func acquireSystemdTimers(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/timers", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch timers: %v\n", err)
+		return
+	}
+
+	t := TimersDesc{}
+	if err := json.Unmarshal(resp, &t); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !t.Success {
+		fmt.Printf("Failed to fetch timers: %v\n", t.Errors)
+		return
+	}
+
+	for _, u := range t.Message {
+		fmt.Printf("        %v %v\n", color.HiBlueString("Timer:"), u.Unit)
+		fmt.Printf("    %v %v (%v)\n", color.HiBlueString("Activates:"), u.Activates, u.ActivatesActiveState)
+		fmt.Printf("        %v %v (%v)\n", color.HiBlueString("State:"), u.ActiveState, u.SubState)
+		if len(u.OnCalendar) > 0 {
+			fmt.Printf("   %v %v\n", color.HiBlueString("OnCalendar:"), strings.Join(u.OnCalendar, ", "))
+		}
+		fmt.Printf("   %v %v\n", color.HiBlueString("Persistent:"), u.Persistent)
+		fmt.Printf("         %v %v\n", color.HiBlueString("Next:"), timerUSecString(u.NextElapseUSecRealtime))
+		fmt.Printf("         %v %v\n\n", color.HiBlueString("Last:"), timerUSecString(u.LastTriggerUSec))
+	}
+}
+
+type RunResultDesc struct {
+	Success bool              `json:"success"`
+	Message systemd.RunResult `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+func runSystemdTransientUnit(run *systemd.RunRequest, host string, token map[string]string) {
+	resp, err := web.DispatchAndStream(http.MethodPost, host, "/api/v1/service/systemd/run", token, run, displayJobEvent)
+	if err != nil {
+		fmt.Printf("Failed to run command: %v\n", err)
+		return
+	}
+
+	m := RunResultDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to run command: %v\n", m.Errors)
+		return
+	}
+
+	for _, l := range m.Message.Output {
+		fmt.Println(l)
+	}
+
+	fmt.Printf("%v %v %v=%v (%v)\n", color.HiBlueString("==>"), m.Message.Unit, m.Message.ExitCode, m.Message.ExitStatus, m.Message.Result)
+}
+
+type ResourcesDesc struct {
+	Success bool              `json:"success"`
+	Message systemd.Resources `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+func resourceLimitString(v uint64) string {
+	if v == math.MaxUint64 {
+		return "infinity"
+	}
+
+	return strconv.FormatUint(v, 10)
+}
+
+func acquireSystemdUnitResources(unit string, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/"+unit+"/resources", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch unit resources: %v\n", err)
+		return
+	}
+
+	m := ResourcesDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch unit resources: %v\n", m.Errors)
+		return
+	}
+
+	r := m.Message
+	fmt.Printf("                %v %v\n", color.HiBlueString("Unit:"), r.Unit)
+	fmt.Printf("       %v %v\n", color.HiBlueString("Control Group:"), r.ControlGroup)
+	fmt.Printf("           %v %v\n", color.HiBlueString("CPUWeight:"), resourceLimitString(r.Limits.CPUWeight))
+	fmt.Printf("  %v %v\n", color.HiBlueString("CPUQuotaPerSecUSec:"), resourceLimitString(r.Limits.CPUQuotaPerSecUSec))
+	fmt.Printf("           %v %v\n", color.HiBlueString("MemoryMax:"), resourceLimitString(r.Limits.MemoryMax))
+	fmt.Printf("          %v %v\n", color.HiBlueString("MemoryHigh:"), resourceLimitString(r.Limits.MemoryHigh))
+	fmt.Printf("            %v %v\n", color.HiBlueString("IOWeight:"), resourceLimitString(r.Limits.IOWeight))
+	fmt.Printf("            %v %v\n", color.HiBlueString("TasksMax:"), resourceLimitString(r.Limits.TasksMax))
+	if r.Limits.AllowedCPUs != "" {
+		fmt.Printf("         %v %v\n", color.HiBlueString("AllowedCPUs:"), r.Limits.AllowedCPUs)
+	}
+	fmt.Printf("      %v %v\n", color.HiBlueString("Memory Current:"), r.Usage.MemoryCurrent)
+	fmt.Printf("           %v %v\n", color.HiBlueString("CPU Usage:"), time.Duration(r.Usage.CPUUsageUSec)*time.Microsecond)
+	fmt.Printf("       %v %v\n", color.HiBlueString("Tasks Current:"), r.Usage.TasksCurrent)
+	fmt.Printf("             %v %v read, %v written\n", color.HiBlueString("IO Bytes:"), r.Usage.IOReadBytes, r.Usage.IOWriteBytes)
+}
+
+func configureSystemdUnitResources(unit string, res *systemd.ResourceRequest, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodPut, host, "/api/v1/service/systemd/"+unit+"/resources", token, res)
+	if err != nil {
+		fmt.Printf("Failed to configure unit resources: %v\n", err)
+		return
+	}
+
+	m := web.JSONResponseMessage{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to configure unit resources: %v\n", m.Errors)
+	}
+}
+
+type BootTimesDesc struct {
+	Success bool              `json:"success"`
+	Message systemd.BootTimes `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+type BlameDesc struct {
+	Success bool               `json:"success"`
+	Message []systemd.UnitTime `json:"message"`
+	Errors  string             `json:"errors"`
+}
+
+type CriticalChainDesc struct {
+	Success bool                `json:"success"`
+	Message []systemd.ChainUnit `json:"message"`
+	Errors  string              `json:"errors"`
+}
+
+func usecString(usec uint64) string {
+	return (time.Duration(usec) * time.Microsecond).Round(time.Millisecond).String()
+}
+
+func acquireSystemdAnalyzeTime(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/analyze/time", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch boot times: %v\n", err)
+		return
+	}
+
+	m := BootTimesDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch boot times: %v\n", m.Errors)
+		return
+	}
+
+	b := m.Message
+	if b.FirmwareUSec > 0 {
+		fmt.Printf("   %v %v\n", color.HiBlueString("Firmware:"), usecString(b.FirmwareUSec))
+	}
+	if b.LoaderUSec > 0 {
+		fmt.Printf("     %v %v\n", color.HiBlueString("Loader:"), usecString(b.LoaderUSec))
+	}
+	fmt.Printf("     %v %v\n", color.HiBlueString("Kernel:"), usecString(b.KernelUSec))
+	if b.InitRDUSec > 0 {
+		fmt.Printf("     %v %v\n", color.HiBlueString("InitRD:"), usecString(b.InitRDUSec))
+	}
+	fmt.Printf("  %v %v\n", color.HiBlueString("Userspace:"), usecString(b.UserspaceUSec))
+	fmt.Printf("      %v %v\n", color.HiBlueString("Total:"), usecString(b.TotalUSec))
+}
+
+func acquireSystemdAnalyzeBlame(host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/analyze/blame", token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch unit activation times: %v\n", err)
+		return
+	}
+
+	m := BlameDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch unit activation times: %v\n", m.Errors)
+		return
+	}
+
+	fmt.Printf("%v %v\n", color.HiBlueString("%12s", "TIME"), color.HiBlueString("UNIT"))
+	for _, t := range m.Message {
+		fmt.Printf("%12s %v\n", usecString(t.TimeUSec), t.Unit)
+	}
+}
+
+func acquireSystemdAnalyzeCriticalChain(unit string, host string, token map[string]string) {
+	req := "/api/v1/service/systemd/analyze/critical-chain"
+	if unit != "" {
+		req += "/" + unit
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, req, token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch critical chain: %v\n", err)
+		return
+	}
+
+	m := CriticalChainDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch critical chain: %v\n", m.Errors)
+		return
+	}
+
+	for _, c := range m.Message {
+		prefix := ""
+		if c.Level > 0 {
+			prefix = strings.Repeat("  ", c.Level-1) + "└─"
+		}
+
+		t := ""
+		if c.TimeUSec > 0 {
+			t = color.HiRedString(" +" + usecString(c.TimeUSec))
+		}
+
+		fmt.Printf("%v%v @%v%v\n", prefix, c.Unit, usecString(c.ActivatedUSec), t)
+	}
+}
+
+type DiagnosisDesc struct {
+	Success bool              `json:"success"`
+	Message systemd.Diagnosis `json:"message"`
+	Errors  string            `json:"errors"`
+}
+
+type FailedUnitsDesc struct {
+	Success bool                `json:"success"`
+	Message []systemd.Diagnosis `json:"message"`
+	Errors  string              `json:"errors"`
+}
+
+func displayUnitDiagnosis(d *systemd.Diagnosis) {
+	fmt.Printf("                %v %v\n", color.HiBlueString("Unit:"), d.Unit)
+	if d.Description != "" {
+		fmt.Printf("         %v %v\n", color.HiBlueString("Description:"), d.Description)
+	}
+	fmt.Printf("               %v %v (%v) %v\n", color.HiBlueString("State:"), d.ActiveState, d.SubState, d.LoadState)
+	if d.Result != "" {
+		fmt.Printf("              %v %v\n", color.HiBlueString("Result:"), d.Result)
+	}
+	if d.ExecMainCode != "" {
+		fmt.Printf("           %v %v=%v\n", color.HiBlueString("Main Exit:"), d.ExecMainCode, d.ExecMainStatus)
+	}
+	fmt.Printf("            %v %v\n", color.HiBlueString("Restarts:"), d.NRestarts)
+	if d.InactiveEnterTimestamp > 0 {
+		fmt.Printf("               %v %v\n", color.HiBlueString("Since:"), time.UnixMicro(int64(d.InactiveEnterTimestamp)).Format(time.UnixDate))
+	}
+	if len(d.FailedDependencies) > 0 {
+		fmt.Printf(" %v %v\n", color.HiBlueString("Failed Dependencies:"), strings.Join(d.FailedDependencies, " "))
+	}
+	if d.Error != "" {
+		fmt.Printf("               %v %v\n", color.HiRedString("Error:"), d.Error)
+	}
+
+	if len(d.Journal) > 0 {
+		fmt.Println()
+		for i := range d.Journal {
+			displayJournalEntry(&d.Journal[i])
+		}
+	}
+}
+
+func acquireSystemdUnitDiagnosis(unit string, lines int, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/"+unit+"/diagnose?lines="+strconv.Itoa(lines), token, nil)
+	if err != nil {
+		fmt.Printf("Failed to diagnose unit: %v\n", err)
+		return
+	}
+
+	m := DiagnosisDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to diagnose unit: %v\n", m.Errors)
+		return
+	}
+
+	displayUnitDiagnosis(&m.Message)
+}
+
+func acquireSystemdFailedUnits(lines int, host string, token map[string]string) {
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/failed?lines="+strconv.Itoa(lines), token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch failed units: %v\n", err)
+		return
+	}
+
+	m := FailedUnitsDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch failed units: %v\n", m.Errors)
+		return
+	}
+
+	for i := range m.Message {
+		displayUnitDiagnosis(&m.Message[i])
+		fmt.Println()
+	}
+}
+
+func executeSystemdBulkUnitCommand(verb string, units []string, host string, token map[string]string) {
+	b := systemd.BulkUnitRequest{
+		Verb:  verb,
+		Units: units,
+	}
+
+	resp, err := web.DispatchSocket(http.MethodPost, host, "/api/v1/service/systemd/bulk", token, b)
+	if err != nil {
+		fmt.Printf("Failed to execute systemd command: %v\n", err)
+		return
+	}
+
+	m := UnitResultsDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to execute systemd command: %v\n", m.Errors)
+		return
+	}
+
+	for _, r := range m.Message {
+		if r.Success {
+			fmt.Printf("%v %v\n", color.HiBlueString(r.Unit+":"), "ok")
+		} else {
+			fmt.Printf("%v %v\n", color.HiBlueString(r.Unit+":"), color.HiRedString(r.Error))
+		}
+	}
+}
+
+func acquireSystemdUnits(states []string, types []string, patterns []string, host string, token map[string]string) {
+	v := url.Values{}
+	for _, s := range states {
+		v.Add("state", s)
+	}
+	for _, t := range types {
+		v.Add("type", t)
+	}
+	for _, p := range patterns {
+		v.Add("pattern", p)
+	}
+
+	resp, err := web.DispatchSocket(http.MethodGet, host, "/api/v1/service/systemd/units?"+v.Encode(), token, nil)
+	if err != nil {
+		fmt.Printf("Failed to fetch units: %v\n", err)
+		return
+	}
+
+	m := UnitsDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		fmt.Printf("Failed to fetch units: %v\n", m.Errors)
+		return
+	}
+
+	fmt.Printf("%v %v %v %v %v\n", color.HiBlueString("%-40s", "UNIT"), color.HiBlueString("%-8s", "LOAD"), color.HiBlueString("%-10s", "ACTIVE"), color.HiBlueString("%-10s", "SUB"), color.HiBlueString("DESCRIPTION"))
+	for _, u := range m.Message {
+		fmt.Printf("%-40s %-8s %-10s %-10s %s\n", u.Name, u.LoadState, u.ActiveState, u.SubState, u.Description)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/vmware/pmd-next-gen/pkg/web"
@@ -57,3 +58,58 @@ func TestAcquireSystemdUnitStatus(t *testing.T) {
 		t.Fatalf(u.Errors)
 	}
 }
+
+func TestConfigureSystemdUnitFile(t *testing.T) {
+	u := systemd.UnitFile{
+		Unit: &systemd.UnitSection{
+			Description: "pmd test unit",
+		},
+		Service: &systemd.ServiceSection{
+			Type:      "oneshot",
+			ExecStart: []string{"/usr/bin/true"},
+		},
+	}
+
+	resp, err := web.DispatchSocket(http.MethodPut, "", "/api/v1/service/systemd/pmd-test.service/unitfile", nil, u)
+	if err != nil {
+		t.Fatalf("Failed to write unit file: %v\n", err)
+	}
+
+	m := web.JSONResponseMessage{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		t.Fatalf("Failed to decode json message: %v\n", err)
+	}
+	if !m.Success {
+		t.Fatalf("Failed to write unit file: %v\n", m.Errors)
+	}
+	defer web.DispatchSocket(http.MethodDelete, "", "/api/v1/service/systemd/pmd-test.service/unitfile", nil, nil)
+
+	d := systemd.UnitFile{
+		Service: &systemd.ServiceSection{
+			Environment: []string{"PMD_TEST=1"},
+		},
+	}
+
+	resp, err = web.DispatchSocket(http.MethodPut, "", "/api/v1/service/systemd/pmd-test.service/dropin/override", nil, d)
+	if err != nil {
+		t.Fatalf("Failed to write drop-in: %v\n", err)
+	}
+	defer web.DispatchSocket(http.MethodDelete, "", "/api/v1/service/systemd/pmd-test.service/dropin/override", nil, nil)
+
+	resp, err = web.DispatchSocket(http.MethodGet, "", "/api/v1/service/systemd/pmd-test.service/cat", nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to fetch unit files: %v\n", err)
+	}
+
+	c := UnitCatDesc{}
+	if err := json.Unmarshal(resp, &c); err != nil {
+		t.Fatalf("Failed to decode json message: %v\n", err)
+	}
+	if !c.Success {
+		t.Fatalf("Failed to fetch unit files: %v\n", c.Errors)
+	}
+
+	if !strings.Contains(c.Message.Text, "ExecStart") || !strings.Contains(c.Message.Text, "PMD_TEST=1") {
+		t.Fatalf("Unexpected unit files: %v\n", c.Message.Text)
+	}
+}
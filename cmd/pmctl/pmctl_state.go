@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/fatih/color"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/state"
+)
+
+type StatePlanDesc struct {
+	Success bool       `json:"success"`
+	Message state.Plan `json:"message"`
+	Errors  string     `json:"errors"`
+}
+
+func displayStatePlan(p *state.Plan) {
+	if len(p.Changes) == 0 {
+		fmt.Printf("No changes, the system is in the desired state\n")
+		return
+	}
+
+	for _, c := range p.Changes {
+		result := ""
+		if !p.DryRun {
+			result = color.HiGreenString("done")
+			if !c.Applied {
+				result = color.HiRedString("not applied")
+			}
+		}
+
+		fmt.Printf("%v %v '%v' %v\n", color.HiBlueString(c.Action), c.Subsystem, c.Name, result)
+		if c.Current != nil {
+			fmt.Printf("    %v %v\n", color.HiBlueString("Current:"), c.Current)
+		}
+		if c.Desired != nil {
+			fmt.Printf("    %v %v\n", color.HiBlueString("Desired:"), c.Desired)
+		}
+	}
+}
+
+func applyState(file string, dryRun bool, host string, token map[string]string) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Printf("Failed to read state document: %v\n", err)
+		return
+	}
+
+	s, err := state.Parse(b)
+	if err != nil {
+		fmt.Printf("Failed to parse state document '%s': %v\n", file, err)
+		return
+	}
+
+	var resp []byte
+	if dryRun {
+		resp, err = web.DispatchSocket(http.MethodPost, host, "/api/v1/state/apply?dry-run=true", token, s)
+	} else {
+		resp, err = web.DispatchAndStream(http.MethodPost, host, "/api/v1/state/apply", token, s, displayJobEvent)
+	}
+	if err != nil {
+		fmt.Printf("Failed to apply state: %v\n", err)
+		return
+	}
+
+	m := StatePlanDesc{}
+	if err := json.Unmarshal(resp, &m); err != nil {
+		fmt.Printf("Failed to decode json message: %v\n", err)
+		return
+	}
+
+	if !m.Success {
+		if len(m.Message.Changes) > 0 {
+			displayStatePlan(&m.Message)
+		}
+		fmt.Printf("Failed to apply state: %v\n", m.Errors)
+		return
+	}
+
+	displayStatePlan(&m.Message)
+}
@@ -726,7 +726,7 @@ func acquireTdnfAlterCmd(options *tdnf.Options, cmd string, pkgs string, host st
 		req = "/api/v1/tdnf/" + cmd + tdnfOptionsQuery(options)
 	}
 
-	msg, err := web.DispatchAndWait(http.MethodGet, host, req, token, nil)
+	msg, err := web.DispatchAndStream(http.MethodGet, host, req, token, nil, displayJobEvent)
 	if err != nil {
 		return nil, err
 	}
@@ -765,7 +765,7 @@ func acquireTdnfHistoryList(options *tdnf.HistoryCmdOptions, host string, token
 }
 
 func acquireTdnfHistoryAlterCmd(options *tdnf.HistoryCmdOptions, cmd string, host string, token map[string]string) (*AlterResultDesc, error) {
-	msg, err := web.DispatchAndWait(http.MethodGet, host, "/api/v1/tdnf/history/"+cmd+tdnfOptionsQuery(options), token, nil)
+	msg, err := web.DispatchAndStream(http.MethodGet, host, "/api/v1/tdnf/history/"+cmd+tdnfOptionsQuery(options), token, nil, displayJobEvent)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// An out of process plugin. photon-mgmtd runs it with 'describe' to learn about it and
+// with 'serve' to start it, then proxies /api/v1/hello to the socket it listens on.
+//
+// Build with: go build -o /usr/lib/photon-mgmt/plugins/hello ./examples/plugin/executable
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	hello "github.com/vmware/pmd-next-gen/examples/plugin"
+	"github.com/vmware/pmd-next-gen/pkg/plugin"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s describe|serve\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "describe":
+		json.NewEncoder(os.Stdout).Encode(plugin.Description{
+			Name:       "hello",
+			Version:    "0.1",
+			APIVersion: plugin.APIVersion,
+		})
+	case "serve":
+		l, err := net.Listen("unix", os.Getenv(plugin.SocketEnv))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to listen: %v\n", err)
+			os.Exit(1)
+		}
+
+		r := mux.NewRouter()
+		hello.RegisterRouterSayHello(r)
+
+		http.Serve(l, r)
+	}
+}
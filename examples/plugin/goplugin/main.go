@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Build with: go build -buildmode=plugin -o /usr/lib/photon-mgmt/plugins/hello.so ./examples/plugin/goplugin
+package main
+
+import (
+	hello "github.com/vmware/pmd-next-gen/examples/plugin"
+	"github.com/vmware/pmd-next-gen/pkg/plugin"
+)
+
+var PluginAPIVersion = plugin.APIVersion
+
+func New() plugin.Plugin {
+	return hello.New()
+}
+
+func main() {}
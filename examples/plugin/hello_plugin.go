@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package hello
+
+import (
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/plugin"
+)
+
+type helloPlugin struct{}
+
+// New returns the hello plugin
+func New() plugin.Plugin {
+	return &helloPlugin{}
+}
+
+func (h *helloPlugin) Name() string {
+	return "hello"
+}
+
+func (h *helloPlugin) Version() string {
+	return "0.1"
+}
+
+func (h *helloPlugin) Capabilities() []string {
+	return nil
+}
+
+func (h *helloPlugin) Init(c *conf.Config) error {
+	return nil
+}
+
+func (h *helloPlugin) Register(router *mux.Router) {
+	RegisterRouterSayHello(router)
+}
+
+func (h *helloPlugin) Shutdown() error {
+	return nil
+}
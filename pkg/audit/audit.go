@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/policy"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+const (
+	maxBodySize     = 64 * 1024
+	maxResponseSize = 4 * 1024
+
+	redacted = "*****"
+)
+
+// Keys whose values never reach the audit log, compared case insensitively
+var secretKeys = []string{
+	"password",
+	"privatekey",
+	"presharedkey",
+	"secret",
+	"token",
+	"passphrase",
+}
+
+type Record struct {
+	Time       time.Time          `json:"Time"`
+	User       string             `json:"User"`
+	Identity   *identity.Identity `json:"Identity"`
+	Method     string             `json:"Method"`
+	Path       string             `json:"Path"`
+	Body       json.RawMessage    `json:"Body,omitempty"`
+	StatusCode int                `json:"StatusCode"`
+	Success    bool               `json:"Success"`
+	Error      string             `json:"Error,omitempty"`
+	Duration   string             `json:"Duration"`
+}
+
+// Logger appends records as JSON lines and rotates the file once it exceeds maxSize
+type Logger struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+	lock sync.Mutex
+}
+
+var logger *Logger
+
+func InitAudit(c *conf.Config) error {
+	l := &Logger{
+		path:     path.Join(conf.LogDirPath, conf.AuditLogFile),
+		maxSize:  int64(c.Audit.MaxSizeMB) * 1024 * 1024,
+		maxFiles: c.Audit.MaxFiles,
+	}
+
+	if err := l.open(); err != nil {
+		log.Errorf("Failed to open audit log '%s': %v", l.path, err)
+		return err
+	}
+
+	logger = l
+	return nil
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = st.Size()
+
+	return nil
+}
+
+func rotatedPath(p string, n int) string {
+	return p + "." + strconv.Itoa(n)
+}
+
+// rotate must be called with the lock held
+func (l *Logger) rotate() error {
+	l.file.Close()
+
+	os.Remove(rotatedPath(l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedPath(l.path, i), rotatedPath(l.path, i+1))
+	}
+
+	if l.maxFiles > 0 {
+		if err := os.Rename(l.path, rotatedPath(l.path, 1)); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(l.path)
+	}
+
+	return l.open()
+}
+
+func (l *Logger) Write(rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+
+	return err
+}
+
+func isSecret(key string) bool {
+	k := strings.ToLower(key)
+	for _, s := range secretKeys {
+		if k == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if isSecret(k) {
+				t[k] = redacted
+			} else {
+				t[k] = redact(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redact(e)
+		}
+	}
+
+	return v
+}
+
+// redactBody drops secrets from JSON bodies. Anything else is not recorded as it may carry secrets we cannot spot.
+func redactBody(b []byte) json.RawMessage {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		j, _ := json.Marshal("<" + strconv.Itoa(len(b)) + " bytes of non JSON data>")
+		return j
+	}
+
+	j, err := json.Marshal(redact(v))
+	if err != nil {
+		return nil
+	}
+
+	return j
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if room := maxResponseSize - r.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		r.body.Write(b[:room])
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Middleware records every request which changes the system, including the ones the
+// authorization policy denies
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if logger == nil || !policy.ChangesSystem(r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+
+		rw := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		id := identity.FromRequest(r)
+		rec := Record{
+			Time:       start,
+			User:       id.String(),
+			Identity:   id,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Body:       redactBody(body),
+			StatusCode: rw.status,
+			Duration:   time.Since(start).String(),
+		}
+
+		// Handlers report failures in the JSON envelope while the status code stays 200
+		m := web.JSONResponseMessage{}
+		switch {
+		case rw.status == http.StatusAccepted:
+			rec.Success = true
+		case json.Unmarshal(rw.body.Bytes(), &m) == nil:
+			rec.Success = m.Success
+			rec.Error = m.Errors
+		default:
+			rec.Success = rw.status >= 200 && rw.status < 300
+		}
+
+		if err := logger.Write(&rec); err != nil {
+			log.Errorf("Failed to write audit record for '%s %s': %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
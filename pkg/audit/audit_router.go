@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+type Filter struct {
+	Since time.Time
+	User  string
+	Path  string
+	Limit int
+}
+
+func (f *Filter) match(rec *Record) bool {
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+
+	if f.User != "" && rec.User != f.User {
+		return false
+	}
+
+	if f.Path != "" && !strings.HasPrefix(rec.Path, f.Path) {
+		return false
+	}
+
+	return true
+}
+
+// parseSince accepts either a RFC 3339 timestamp or a duration relative to now such as '1h'
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.New("invalid since, expected RFC 3339 time or duration")
+	}
+
+	return time.Now().Add(-d), nil
+}
+
+func readFile(p string, f *Filter, l []Record) ([]Record, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	for scanner.Scan() {
+		rec := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+
+		if f.match(&rec) {
+			l = append(l, rec)
+		}
+	}
+
+	return l, scanner.Err()
+}
+
+// Acquire returns the matching records, oldest first. With a limit only the most recent ones are kept.
+func Acquire(f *Filter) ([]Record, error) {
+	if logger == nil {
+		return nil, errors.New("audit log not available")
+	}
+
+	l := []Record{}
+	var err error
+	for i := logger.maxFiles; i >= 1; i-- {
+		if l, err = readFile(rotatedPath(logger.path, i), f, l); err != nil {
+			return nil, err
+		}
+	}
+
+	if l, err = readFile(logger.path, f, l); err != nil {
+		return nil, err
+	}
+
+	if f.Limit > 0 && len(l) > f.Limit {
+		l = l[len(l)-f.Limit:]
+	}
+
+	return l, nil
+}
+
+func routerAcquireAudit(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.FormValue("since"))
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	f := Filter{
+		Since: since,
+		User:  r.FormValue("user"),
+		Path:  r.FormValue("path"),
+	}
+
+	if s := r.FormValue("limit"); s != "" {
+		if f.Limit, err = strconv.Atoi(s); err != nil {
+			web.JSONResponseError(errors.New("invalid limit"), w)
+			return
+		}
+	}
+
+	l, err := Acquire(&f)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func RegisterRouterAudit(router *mux.Router) {
+	n := router.PathPrefix("/_audit").Subrouter().StrictSlash(false)
+
+	n.HandleFunc("", routerAcquireAudit).Methods("GET")
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func setupLogger(t *testing.T, maxSize int64, maxFiles int) *Logger {
+	l := &Logger{
+		path:     path.Join(t.TempDir(), "audit.log"),
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := l.open(); err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	logger = l
+	t.Cleanup(func() {
+		logger = nil
+		l.file.Close()
+	})
+
+	return l
+}
+
+func readRecords(t *testing.T, p string) []Record {
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var l []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rec := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Failed to decode audit record '%s': %v", scanner.Text(), err)
+		}
+		l = append(l, rec)
+	}
+
+	return l
+}
+
+func serve(h http.HandlerFunc, method string, uri string, body string) {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	Middleware(h).ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestMiddlewareRedactsSecrets(t *testing.T) {
+	l := setupLogger(t, 0, 0)
+
+	body := `{"Name":"wg0","PrivateKey":"cGFzc3dvcmQ=","Peers":[{"Endpoint":"192.0.2.1:51820","PresharedKey":"c2VjcmV0"}],"User":{"Name":"bob","PassWord":"hunter2"}}`
+
+	var received string
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		web.JSONResponse("configured", w)
+	}, http.MethodPost, "/api/v1/network/networkd/netdev/configure", body)
+
+	if received != body {
+		t.Errorf("Handler received '%s' instead of the request body", received)
+	}
+
+	records := readRecords(t, l.path)
+	if len(records) != 1 {
+		t.Fatalf("Got %d audit records, want 1", len(records))
+	}
+
+	rec := records[0]
+	for _, secret := range []string{"cGFzc3dvcmQ=", "c2VjcmV0", "hunter2"} {
+		if strings.Contains(string(rec.Body), secret) {
+			t.Errorf("Secret '%s' leaked into the audit log: %s", secret, rec.Body)
+		}
+	}
+
+	var logged struct {
+		Name       string
+		PrivateKey string
+		Peers      []struct{ Endpoint, PresharedKey string }
+		User       struct{ Name, PassWord string }
+	}
+	if err := json.Unmarshal(rec.Body, &logged); err != nil {
+		t.Fatalf("Failed to decode recorded body: %v", err)
+	}
+	if logged.Name != "wg0" || logged.Peers[0].Endpoint != "192.0.2.1:51820" || logged.User.Name != "bob" {
+		t.Errorf("Recorded body lost values which are not secret: %s", rec.Body)
+	}
+	if logged.PrivateKey != redacted || logged.Peers[0].PresharedKey != redacted || logged.User.PassWord != redacted {
+		t.Errorf("Recorded body is not redacted: %s", rec.Body)
+	}
+
+	if rec.Method != http.MethodPost || rec.Path != "/api/v1/network/networkd/netdev/configure" || !rec.Success || rec.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected audit record %+v", rec)
+	}
+}
+
+func TestMiddlewareRecordsOutcome(t *testing.T) {
+	l := setupLogger(t, 0, 0)
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		web.JSONResponse("ok", w)
+	}, http.MethodGet, "/api/v1/system/describe", "")
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		web.JSONResponseError(os.ErrNotExist, w)
+	}, http.MethodDelete, "/api/v1/systemd/unit/remove", `{"Unit":"foo.service"}`)
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}, http.MethodPut, "/api/v1/system/hostname", "hostname=foo")
+
+	serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}, http.MethodPost, "/api/v1/tdnf/update", "")
+
+	records := readRecords(t, l.path)
+	if len(records) != 3 {
+		t.Fatalf("Got %d audit records, want one per request which changes the system", len(records))
+	}
+
+	if r := records[0]; r.Success || r.Error != os.ErrNotExist.Error() || string(r.Body) != `{"Unit":"foo.service"}` {
+		t.Errorf("Failure reported in the response envelope not recorded: %+v", r)
+	}
+	var placeholder string
+	if r := records[1]; r.Success || r.StatusCode != http.StatusForbidden || json.Unmarshal(r.Body, &placeholder) != nil || placeholder != "<12 bytes of non JSON data>" {
+		t.Errorf("Denied request with a form body not recorded as such: %+v", r)
+	}
+	if r := records[2]; !r.Success || r.StatusCode != http.StatusAccepted || r.Body != nil {
+		t.Errorf("Accepted job not recorded as success: %+v", r)
+	}
+}
+
+func TestLoggerRotate(t *testing.T) {
+	l := setupLogger(t, 512, 2)
+
+	for i := 0; i < 20; i++ {
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			web.JSONResponse("ok", w)
+		}, http.MethodPost, "/api/v1/system/hostname", `{"StaticHostname":"host"}`)
+	}
+
+	for _, p := range []string{l.path, l.path + ".1", l.path + ".2"} {
+		st, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Missing audit log '%s': %v", p, err)
+		}
+		if st.Size() > l.maxSize {
+			t.Errorf("Audit log '%s' grew to %d bytes, above %d", p, st.Size(), l.maxSize)
+		}
+	}
+
+	if _, err := os.Stat(l.path + ".3"); err == nil {
+		t.Errorf("Kept more than %d rotated audit logs", l.maxFiles)
+	}
+}
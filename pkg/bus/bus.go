@@ -27,7 +27,7 @@ func SystemBusPrivateConn() (*dbus.Conn, error) {
 
 	if err = conn.Hello(); err != nil {
 		conn.Close()
-		conn = nil
+		return nil, err
 	}
 
 	return conn, nil
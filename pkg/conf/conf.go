@@ -4,6 +4,8 @@
 package conf
 
 import (
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/vmware/pmd-next-gen/pkg/parser"
@@ -16,6 +18,8 @@ const (
 	TLSCert  = "cert/server.crt"
 	TLSKey   = "cert/server.key"
 
+	PolicyFile = "policy.toml"
+
 	DefaultLogLevel   = "info"
 	UseAuthentication = "true"
 
@@ -24,11 +28,25 @@ const (
 	ListenUnixSocket = "true"
 
 	UnixDomainSocketPath = "/run/photon-mgmt/mgmt.sock"
+
+	StateDirPath     = "/var/lib/photon-mgmt"
+	DefaultResultTTL = "24h"
+
+	LogDirPath           = "/var/log/photon-mgmt"
+	AuditLogFile         = "audit.log"
+	DefaultAuditMaxSize  = 10
+	DefaultAuditMaxFiles = 5
+
+	PluginDirPath = "/usr/lib/photon-mgmt/plugins"
 )
 
 type Config struct {
 	System  System  `mapstructure:"System"`
 	Network Network `mapstructure:"Network"`
+	Jobs    Jobs    `mapstructure:"Jobs"`
+	Audit   Audit   `mapstructure:"Audit"`
+	Plugins Plugins `mapstructure:"Plugins"`
+	Metrics Metrics `mapstructure:"Metrics"`
 }
 
 type System struct {
@@ -41,11 +59,34 @@ type Network struct {
 	ListenVSock      bool
 }
 
+type Jobs struct {
+	ResultTTL string `mapstructure:"ResultTTL"`
+}
+
+type Audit struct {
+	MaxSizeMB int `mapstructure:"MaxSizeMB"`
+	MaxFiles  int `mapstructure:"MaxFiles"`
+}
+
+type Plugins struct {
+	Directory string   `mapstructure:"Directory"`
+	Disable   []string `mapstructure:"Disable"`
+}
+
+type Metrics struct {
+	UseAuthentication bool `mapstructure:"UseAuthentication"`
+}
+
 func Parse() (*Config, error) {
 	viper.SetConfigName(ConfFile)
 	viper.AddConfigPath(ConfPath)
 
 	viper.SetDefault("System.LogLevel", DefaultLogLevel)
+	viper.SetDefault("Jobs.ResultTTL", DefaultResultTTL)
+	viper.SetDefault("Audit.MaxSizeMB", DefaultAuditMaxSize)
+	viper.SetDefault("Audit.MaxFiles", DefaultAuditMaxFiles)
+	viper.SetDefault("Plugins.Directory", PluginDirPath)
+	viper.SetDefault("Metrics.UseAuthentication", true)
 
 	if err := viper.ReadInConfig(); err != nil {
 		logrus.Errorf("Failed to parse config file. Using defaults: %v", err)
@@ -65,6 +106,11 @@ func Parse() (*Config, error) {
 
 	logrus.Debugf("Log level set to '%+v'", logrus.GetLevel().String())
 
+	if _, err := time.ParseDuration(c.Jobs.ResultTTL); err != nil {
+		logrus.Warnf("Failed to parse ResultTTL='%s', falling back to '%s': %v", c.Jobs.ResultTTL, DefaultResultTTL, err)
+		c.Jobs.ResultTTL = DefaultResultTTL
+	}
+
 	if c.Network.Listen != "" {
 		parser.ParseIpPort(c.Network.Listen)
 		if _, _, err := parser.ParseIpPort(c.Network.Listen); err != nil {
@@ -4,13 +4,20 @@
 package configfile
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/go-ini/ini"
+
+	"github.com/vmware/pmd-next-gen/pkg/parser"
+	"github.com/vmware/pmd-next-gen/pkg/share"
 )
 
 type Meta struct {
@@ -31,10 +38,147 @@ func Load(path string) (*Meta, error) {
 	}, nil
 }
 
+// resetValue stands for an empty assignment such as 'DNS=', which go-ini drops from the shadows
+const resetValue = "\x00"
+
+// readWithResets reads path replacing the value of empty assignments with resetValue
+func readWithResets(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(b), "\n")
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if t == "" || strings.HasPrefix(t, "#") || strings.HasPrefix(t, ";") || strings.HasPrefix(t, "[") {
+			continue
+		}
+
+		k, v, ok := strings.Cut(t, "=")
+		if ok && strings.TrimSpace(k) != "" && strings.TrimSpace(v) == "" {
+			lines[i] = t + resetValue
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// LoadWithDropIns loads path and the drop-ins after it, a section of a drop-in is appended to
+// the sections of the same name. Empty assignments are kept for DecodeSection to reset the
+// earlier values, so the result is meant to be decoded and not saved
+func LoadWithDropIns(path string, dropIns []string) (*Meta, error) {
+	sources := make([]interface{}, 0, len(dropIns)+1)
+	for _, f := range append([]string{path}, dropIns...) {
+		b, err := readWithResets(f)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, b)
+	}
+
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowNonUniqueSections: true, AllowShadows: true, AllowDuplicateShadowValues: true}, sources[0], sources[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Meta{
+		Path: path,
+		Cfg:  cfg,
+	}, nil
+}
+
+// Options of files read and written verbatim the way systemd does: '#' and ';' only start a
+// comment at the beginning of a line and values keep their quotes
+var rawOptions = ini.LoadOptions{
+	AllowNonUniqueSections:     true,
+	AllowShadows:               true,
+	AllowDuplicateShadowValues: true,
+	IgnoreInlineComment:        true,
+	PreserveSurroundedQuote:    true,
+}
+
+// LoadRaw loads path keeping values such as 'ExecStart=/bin/sh -c "a; b # c"' intact. Write it
+// back with RawBytes
+func LoadRaw(path string) (*Meta, error) {
+	cfg, err := ini.LoadSources(rawOptions, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Meta{
+		Path: path,
+		Cfg:  cfg,
+	}, nil
+}
+
+// NewRaw returns an empty configuration for path to be written with RawBytes
+func NewRaw(path string) *Meta {
+	return &Meta{
+		Path: path,
+		Cfg:  ini.Empty(rawOptions),
+	}
+}
+
+// RawBytes renders the configuration and its comments as plain key=value lines. Unlike Bytes
+// values are never quoted, which would change their meaning for systemd
+func (m *Meta) RawBytes() ([]byte, error) {
+	var b bytes.Buffer
+	for _, s := range m.Cfg.Sections() {
+		if s.Name() == ini.DefaultSection && len(s.Keys()) == 0 {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if s.Comment != "" {
+			b.WriteString(s.Comment + "\n")
+		}
+		b.WriteString("[" + s.Name() + "]\n")
+
+		for _, k := range s.Keys() {
+			values := k.ValueWithShadows()
+			if len(values) == 0 {
+				values = []string{""}
+			}
+
+			if k.Comment != "" {
+				b.WriteString(k.Comment + "\n")
+			}
+			for _, v := range values {
+				if strings.ContainsAny(k.Name()+v, "\r\n") {
+					return nil, fmt.Errorf("invalid value of key '%s': line break", k.Name())
+				}
+				b.WriteString(k.Name() + "=" + v + "\n")
+			}
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// New returns an empty configuration for path without touching the file system
+func New(path string) *Meta {
+	return &Meta{
+		Path: path,
+		Cfg:  ini.Empty(ini.LoadOptions{AllowNonUniqueSections: true, AllowShadows: true}),
+	}
+}
+
 func (m *Meta) Save() error {
 	return m.Cfg.SaveTo(m.Path)
 }
 
+func (m *Meta) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := m.Cfg.WriteTo(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
 func ParseKeyFromSectionString(path string, section string, key string) (string, error) {
 	c, err := Load(path)
 	if err != nil {
@@ -151,6 +295,35 @@ func (m *Meta) RemoveKeyFromSectionString(section string, key string, value stri
 	return errors.New("not found")
 }
 
+// RemoveValueFromSectionKey removes value from the values of a repeated key and keeps the others
+func (m *Meta) RemoveValueFromSectionKey(section string, key string, value string) error {
+	sections, err := m.Cfg.SectionsByName(section)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sections {
+		if !s.HasKey(key) {
+			continue
+		}
+
+		values := s.Key(key).ValueWithShadows()
+		if !share.StringContains(values, value) {
+			continue
+		}
+
+		s.DeleteKey(key)
+		for _, v := range values {
+			if v != value {
+				s.NewKey(key, v)
+			}
+		}
+		return nil
+	}
+
+	return errors.New("not found")
+}
+
 func (m *Meta) SetKeyToNewSectionString(key string, value string) {
 	m.Section.NewKey(key, value)
 }
@@ -217,3 +390,84 @@ func RemoveFilesSectionGlob(p string, pattern string, section string, key string
 
 	return nil
 }
+
+// KeyValues returns the values assigned to the key of the section in order and whether an empty
+// assignment of a file loaded by LoadWithDropIns resets the values before them
+func KeyValues(s *ini.Section, key string) ([]string, bool) {
+	values := s.Key(key).ValueWithShadows()
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] == resetValue {
+			return values[i+1:], true
+		}
+	}
+
+	return values, false
+}
+
+// DecodeSection sets the string, number, bool and string list fields of the struct v from the
+// keys named after them. Lists are whitespace separated and accumulate over repeated keys. An
+// empty assignment resets the field, unparsable values are skipped
+func DecodeSection(s *ini.Section, v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() || !s.HasKey(f.Name) {
+			continue
+		}
+
+		fv := rv.Field(i)
+		values, reset := KeyValues(s, f.Name)
+		if len(values) == 0 {
+			if reset {
+				fv.Set(reflect.Zero(fv.Type()))
+			}
+			continue
+		}
+		last := values[len(values)-1]
+
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(last)
+		case reflect.Bool:
+			if b, err := parser.ParseBool(last); err == nil {
+				fv.SetBool(b)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(last, 10, fv.Type().Bits()); err == nil {
+				fv.SetInt(n)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseUint(last, 10, fv.Type().Bits()); err == nil {
+				fv.SetUint(n)
+			}
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+
+			l, _ := fv.Interface().([]string)
+			if reset {
+				l = nil
+			}
+			for _, e := range values {
+				l = append(l, strings.Fields(e)...)
+			}
+			fv.Set(reflect.ValueOf(l))
+		}
+	}
+}
+
+// DecodeSections decodes every section of the name into v in order, so that the later ones
+// override the earlier ones
+func (m *Meta) DecodeSections(section string, v interface{}) {
+	sections, err := m.Cfg.SectionsByName(section)
+	if err != nil {
+		return
+	}
+
+	for _, s := range sections {
+		DecodeSection(s, v)
+	}
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package configfile
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+type testSection struct {
+	Name    string
+	Address []string
+	DNS     []string
+	MTU     uint
+	Enabled bool
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	p := path.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write '%s': %v", name, err)
+	}
+
+	return p
+}
+
+func TestDecodeSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    testSection
+	}{
+		{
+			name:    "scalars",
+			content: "[Test]\nName=eth0\nMTU=1500\nEnabled=yes\n",
+			want:    testSection{Name: "eth0", MTU: 1500, Enabled: true},
+		},
+		{
+			name:    "last scalar wins",
+			content: "[Test]\nName=eth0\nName=eth1\n",
+			want:    testSection{Name: "eth1"},
+		},
+		{
+			name:    "repeated keys",
+			content: "[Test]\nAddress=10.0.0.1/24\nAddress=10.0.0.2/24\nAddress=fe80::1/64\n",
+			want:    testSection{Address: []string{"10.0.0.1/24", "10.0.0.2/24", "fe80::1/64"}},
+		},
+		{
+			name:    "whitespace separated",
+			content: "[Test]\nDNS=1.1.1.1 8.8.8.8\nDNS=9.9.9.9\n",
+			want:    testSection{DNS: []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"}},
+		},
+		{
+			name:    "duplicate values",
+			content: "[Test]\nDNS=1.1.1.1\nDNS=8.8.8.8\nDNS=1.1.1.1\n",
+			want:    testSection{DNS: []string{"1.1.1.1", "8.8.8.8", "1.1.1.1"}},
+		},
+		{
+			name:    "reset",
+			content: "[Test]\nDNS=1.1.1.1\nDNS=\nDNS=8.8.8.8\n",
+			want:    testSection{DNS: []string{"8.8.8.8"}},
+		},
+		{
+			name:    "reset and same value",
+			content: "[Test]\nDNS=1.1.1.1\nDNS=\nDNS=1.1.1.1\n",
+			want:    testSection{DNS: []string{"1.1.1.1"}},
+		},
+		{
+			name:    "reset last",
+			content: "[Test]\nName=eth0\nName=\nDNS=1.1.1.1\nDNS=\n",
+			want:    testSection{},
+		},
+		{
+			name:    "unparsable",
+			content: "[Test]\nMTU=big\nEnabled=perhaps\n",
+			want:    testSection{},
+		},
+		{
+			name:    "sections",
+			content: "[Test]\nName=eth0\nDNS=1.1.1.1\n\n[Test]\nDNS=8.8.8.8\nMTU=9000\n",
+			want:    testSection{Name: "eth0", DNS: []string{"1.1.1.1", "8.8.8.8"}, MTU: 9000},
+		},
+		{
+			name:    "reset in a later section",
+			content: "[Test]\nDNS=1.1.1.1\n\n[Test]\nDNS=\nDNS=8.8.8.8\n",
+			want:    testSection{DNS: []string{"8.8.8.8"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeTestFile(t, t.TempDir(), "test.conf", tt.content)
+
+			m, err := LoadWithDropIns(p, nil)
+			if err != nil {
+				t.Fatalf("Failed to load: %v", err)
+			}
+
+			var got testSection
+			m.DecodeSections("Test", &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWithDropIns(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "10-eth0.network", `[Match]
+Name=eth0
+
+[Network]
+Address=10.0.0.1/24
+DNS=1.1.1.1
+`)
+	a := writeTestFile(t, dir, "a.conf", `[Network]
+# Replace the DNS servers
+DNS=
+DNS=8.8.8.8
+Address=10.0.0.2/24
+`)
+	b := writeTestFile(t, dir, "b.conf", `[Match]
+Name=eth1
+
+[Network]
+DNS=9.9.9.9
+`)
+
+	tests := []struct {
+		name    string
+		dropIns []string
+		want    testSection
+		match   string
+	}{
+		{
+			name:  "none",
+			want:  testSection{Address: []string{"10.0.0.1/24"}, DNS: []string{"1.1.1.1"}},
+			match: "eth0",
+		},
+		{
+			name:    "in order",
+			dropIns: []string{a, b},
+			want:    testSection{Address: []string{"10.0.0.1/24", "10.0.0.2/24"}, DNS: []string{"8.8.8.8", "9.9.9.9"}},
+			match:   "eth1",
+		},
+		{
+			name:    "reversed",
+			dropIns: []string{b, a},
+			want:    testSection{Address: []string{"10.0.0.1/24", "10.0.0.2/24"}, DNS: []string{"8.8.8.8"}},
+			match:   "eth1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := LoadWithDropIns(p, tt.dropIns)
+			if err != nil {
+				t.Fatalf("Failed to load: %v", err)
+			}
+
+			var got testSection
+			m.DecodeSections("Network", &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decoded %+v, want %+v", got, tt.want)
+			}
+
+			var match testSection
+			m.DecodeSections("Match", &match)
+			if match.Name != tt.match {
+				t.Errorf("Name = '%s', want '%s'", match.Name, tt.match)
+			}
+		})
+	}
+
+	if _, err := LoadWithDropIns(p, []string{path.Join(dir, "missing.conf")}); err == nil {
+		t.Errorf("Loaded with a drop-in which does not exist")
+	}
+}
+
+func TestRemoveValueFromSectionKey(t *testing.T) {
+	m := New("test.conf")
+	for _, a := range []string{"10.0.0.1/24", "10.0.0.2/24", "10.0.0.3/24"} {
+		m.NewKeyToSectionString("Network", "Address", a)
+	}
+
+	if err := m.RemoveValueFromSectionKey("Network", "Address", "10.0.0.2/24"); err != nil {
+		t.Fatalf("Failed to remove value: %v", err)
+	}
+
+	want := []string{"10.0.0.1/24", "10.0.0.3/24"}
+	if got := m.Cfg.Section("Network").Key("Address").ValueWithShadows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Address = %q, want %q", got, want)
+	}
+
+	if err := m.RemoveValueFromSectionKey("Network", "Address", "10.0.0.9/24"); err == nil {
+		t.Errorf("Removed an address which was never configured")
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/sys/unix"
+)
+
+type contextKey int
+
+const (
+	peerCredentialsKey contextKey = iota
+	claimsKey
+	rolesKey
+)
+
+// RoleAdmin is the role which may see and act on the requests of other callers
+const RoleAdmin = "admin"
+
+// Identity describes the caller of an API request
+type Identity struct {
+	Subject string `json:"Subject,omitempty"`
+	Uid     uint32 `json:"Uid"`
+	Gid     uint32 `json:"Gid"`
+	Pid     int32  `json:"Pid"`
+	Local   bool   `json:"Local"`
+	Remote  string `json:"Remote,omitempty"`
+
+	Claims map[string]interface{} `json:"-"`
+}
+
+func NewPeerCredentialsContext(ctx context.Context, credentials *unix.Ucred) context.Context {
+	return context.WithValue(ctx, peerCredentialsKey, credentials)
+}
+
+func PeerCredentials(ctx context.Context) (*unix.Ucred, bool) {
+	credentials, ok := ctx.Value(peerCredentialsKey).(*unix.Ucred)
+	if !ok || credentials == nil {
+		return nil, false
+	}
+
+	return credentials, true
+}
+
+func NewClaimsContext(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+func Claims(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(claimsKey).(map[string]interface{})
+	return claims, ok
+}
+
+// NewRolesContext records the roles the authorization policy granted to the caller
+func NewRolesContext(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey, roles)
+}
+
+func Roles(ctx context.Context) ([]string, bool) {
+	roles, ok := ctx.Value(rolesKey).([]string)
+	return roles, ok
+}
+
+// IsAdmin reports whether the caller may see and act on the requests of other callers: root on
+// the unix domain socket, callers granted the admin role, and every caller when no authorization
+// policy is installed
+func IsAdmin(r *http.Request) bool {
+	if credentials, ok := PeerCredentials(r.Context()); ok && credentials.Uid == 0 {
+		return true
+	}
+
+	roles, ok := Roles(r.Context())
+	if !ok {
+		return true
+	}
+
+	for _, role := range roles {
+		if role == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func FromRequest(r *http.Request) *Identity {
+	id := Identity{
+		Remote: r.RemoteAddr,
+	}
+
+	if credentials, ok := PeerCredentials(r.Context()); ok {
+		id.Uid = credentials.Uid
+		id.Gid = credentials.Gid
+		id.Pid = credentials.Pid
+		id.Local = true
+	}
+
+	if claims, ok := Claims(r.Context()); ok {
+		id.Claims = claims
+		if sub, ok := claims["sub"].(string); ok {
+			id.Subject = sub
+		}
+	}
+
+	return &id
+}
+
+func (id *Identity) String() string {
+	switch {
+	case id.Subject != "":
+		return id.Subject
+	case id.Local:
+		return fmt.Sprintf("uid=%d", id.Uid)
+	case id.Remote != "":
+		return id.Remote
+	}
+
+	return "unknown"
+}
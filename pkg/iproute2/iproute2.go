@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package iproute2
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Directories searched for the name tables in the order ip(8) does, the first one holding the
+// file wins. Drop-in *.conf files of all directories are read on top of it
+var confDirs = []string{
+	"/etc/iproute2",
+	"/usr/share/iproute2",
+	"/usr/lib/iproute2",
+}
+
+var (
+	defaultTables = map[int]string{
+		unix.RT_TABLE_UNSPEC:  "unspec",
+		unix.RT_TABLE_DEFAULT: "default",
+		unix.RT_TABLE_MAIN:    "main",
+		unix.RT_TABLE_LOCAL:   "local",
+	}
+
+	defaultProtocols = map[int]string{
+		unix.RTPROT_UNSPEC:   "unspec",
+		unix.RTPROT_REDIRECT: "redirect",
+		unix.RTPROT_KERNEL:   "kernel",
+		unix.RTPROT_BOOT:     "boot",
+		unix.RTPROT_STATIC:   "static",
+		unix.RTPROT_GATED:    "gated",
+		unix.RTPROT_RA:       "ra",
+		unix.RTPROT_MRT:      "mrt",
+		unix.RTPROT_ZEBRA:    "zebra",
+		unix.RTPROT_BIRD:     "bird",
+		unix.RTPROT_DNROUTED: "dnrouted",
+		unix.RTPROT_XORP:     "xorp",
+		unix.RTPROT_NTK:      "ntk",
+		unix.RTPROT_DHCP:     "dhcp",
+		unix.RTPROT_MROUTED:  "mrouted",
+		unix.RTPROT_BABEL:    "babel",
+		186:                  "bgp",
+		187:                  "isis",
+		188:                  "ospf",
+		189:                  "rip",
+		192:                  "eigrp",
+	}
+
+	defaultScopes = map[int]string{
+		unix.RT_SCOPE_UNIVERSE: "global",
+		unix.RT_SCOPE_SITE:     "site",
+		unix.RT_SCOPE_LINK:     "link",
+		unix.RT_SCOPE_HOST:     "host",
+		unix.RT_SCOPE_NOWHERE:  "nowhere",
+	}
+
+	// Route types are fixed by the kernel, ip(8) has no file for them
+	routeTypes = map[int]string{
+		unix.RTN_UNSPEC:      "unspec",
+		unix.RTN_UNICAST:     "unicast",
+		unix.RTN_LOCAL:       "local",
+		unix.RTN_BROADCAST:   "broadcast",
+		unix.RTN_ANYCAST:     "anycast",
+		unix.RTN_MULTICAST:   "multicast",
+		unix.RTN_BLACKHOLE:   "blackhole",
+		unix.RTN_UNREACHABLE: "unreachable",
+		unix.RTN_PROHIBIT:    "prohibit",
+		unix.RTN_THROW:       "throw",
+		unix.RTN_NAT:         "nat",
+		unix.RTN_XRESOLVE:    "xresolve",
+	}
+)
+
+func parseNumber(s string) (uint64, error) {
+	return strconv.ParseUint(s, 0, 32)
+}
+
+// readNameFile reads the "number name" lines of an ip(8) name table into names
+func readNameFile(file string, names map[int]string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		n, err := parseNumber(fields[0])
+		if err != nil {
+			continue
+		}
+
+		names[int(n)] = fields[1]
+	}
+}
+
+func readNames(file string, defaults map[int]string) map[int]string {
+	names := make(map[int]string, len(defaults))
+	for k, v := range defaults {
+		names[k] = v
+	}
+
+	for _, d := range confDirs {
+		p := path.Join(d, file)
+		if _, err := os.Stat(p); err == nil {
+			readNameFile(p, names)
+			break
+		}
+	}
+
+	for _, d := range confDirs {
+		confs, _ := filepath.Glob(path.Join(d, file+".d", "*.conf"))
+		for _, c := range confs {
+			readNameFile(c, names)
+		}
+	}
+
+	return names
+}
+
+func lookupName(names map[int]string, n int) string {
+	if s, ok := names[n]; ok {
+		return s
+	}
+
+	return strconv.Itoa(n)
+}
+
+func lookupNumber(names map[int]string, s string, what string, max uint64) (int, error) {
+	if n, err := parseNumber(s); err == nil {
+		if n > max {
+			return 0, fmt.Errorf("invalid %s '%s'", what, s)
+		}
+		return int(n), nil
+	}
+
+	for n, name := range names {
+		if name == s {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid %s '%s'", what, s)
+}
+
+// Names holds the table, protocol and scope names of ip(8). Load them once per listing rather
+// than per entry
+type Names struct {
+	tables    map[int]string
+	protocols map[int]string
+	scopes    map[int]string
+}
+
+func Load() *Names {
+	return &Names{
+		tables:    readNames("rt_tables", defaultTables),
+		protocols: readNames("rt_protos", defaultProtocols),
+		scopes:    readNames("rt_scopes", defaultScopes),
+	}
+}
+
+// TableName resolves a routing table id, unknown ids are returned as number
+func (n *Names) TableName(table int) string {
+	return lookupName(n.tables, table)
+}
+
+// ProtocolName resolves a route protocol, unknown ones are returned as number
+func (n *Names) ProtocolName(protocol int) string {
+	return lookupName(n.protocols, protocol)
+}
+
+// ScopeName resolves a route scope, unknown ones are returned as number
+func (n *Names) ScopeName(scope int) string {
+	return lookupName(n.scopes, scope)
+}
+
+// ParseTable resolves a routing table given as number or as name from rt_tables
+func ParseTable(s string) (int, error) {
+	return lookupNumber(readNames("rt_tables", defaultTables), s, "route table", math.MaxUint32)
+}
+
+// ParseProtocol resolves a route protocol given as number or as name from rt_protos
+func ParseProtocol(s string) (int, error) {
+	return lookupNumber(readNames("rt_protos", defaultProtocols), s, "route protocol", math.MaxUint8)
+}
+
+// ParseScope resolves a route scope given as number or as name from rt_scopes
+func ParseScope(s string) (int, error) {
+	return lookupNumber(readNames("rt_scopes", defaultScopes), s, "route scope", math.MaxUint8)
+}
+
+// TypeName returns the name ip-route(8) prints for a route type
+func TypeName(t int) string {
+	return lookupName(routeTypes, t)
+}
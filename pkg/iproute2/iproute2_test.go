@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package iproute2
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+// setupConfDirs points confDirs to an /etc and a /usr/lib directory of the test and writes the
+// given files, keyed by their path below either directory
+func setupConfDirs(t *testing.T, etc map[string]string, lib map[string]string) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+
+	for i, files := range []map[string]string{etc, lib} {
+		for f, content := range files {
+			p := path.Join(dirs[i], f)
+			if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+				t.Fatalf("Failed to create '%s': %v", path.Dir(p), err)
+			}
+			if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write '%s': %v", p, err)
+			}
+		}
+	}
+
+	saved := confDirs
+	confDirs = dirs
+	t.Cleanup(func() { confDirs = saved })
+}
+
+func TestLookupOrder(t *testing.T) {
+	setupConfDirs(t, map[string]string{
+		"rt_tables":             "254 main\n100 vpn\n101 guest\n",
+		"rt_tables.d/isp.conf":  "500 isp\n101 visitors\n",
+		"rt_tables.d/README":    "600 ignored\n",
+		"rt_protos.d/bird.conf": "12 bird\n",
+	}, map[string]string{
+		"rt_tables":                "700 shadowed\n",
+		"rt_tables.d/vendor.conf":  "800 vendor\n",
+		"rt_protos":                "2 kernel\n",
+		"rt_protos.d/bgpd.conf":    "186 bgpd\n",
+		"rt_scopes.d/ignored.conf": "not a table\n",
+	})
+
+	n := Load()
+
+	// The first rt_tables found wins, the drop-ins of every directory apply on top of it
+	for id, name := range map[int]string{100: "vpn", 101: "visitors", 500: "isp", 800: "vendor", 700: "700", 600: "600"} {
+		if got := n.TableName(id); got != name {
+			t.Errorf("Table %d is named '%s', want '%s'", id, got, name)
+		}
+	}
+
+	// Without a file of its own a table still gets the names built into ip(8)
+	if got := n.ProtocolName(4); got != "static" {
+		t.Errorf("Protocol 4 is named '%s'", got)
+	}
+	if got, want := n.ProtocolName(186), "bgpd"; got != want {
+		t.Errorf("Protocol 186 is named '%s', want the drop-in name '%s'", got, want)
+	}
+	if got := n.ScopeName(253); got != "link" {
+		t.Errorf("Scope 253 is named '%s'", got)
+	}
+
+	if _, err := ParseTable("guest"); err == nil {
+		t.Errorf("Table renamed by a drop-in still resolves by its old name")
+	}
+	if _, err := ParseTable("shadowed"); err == nil {
+		t.Errorf("Table of a shadowed rt_tables resolves")
+	}
+	if id, err := ParseProtocol("bird"); err != nil || id != 12 {
+		t.Errorf("Protocol 'bird' resolves to %d, err=%v", id, err)
+	}
+}
+
+func TestFileSyntax(t *testing.T) {
+	setupConfDirs(t, map[string]string{
+		"rt_tables": `#
+# reserved values
+#
+255	local
+0x65	guest
+100	vpn # tunnel
+0x10000 big
+	200   lab
+#300	commented
+400
+abc	garbage
+`,
+	}, nil)
+
+	for name, id := range map[string]int{"guest": 101, "vpn": 100, "big": 65536, "lab": 200, "local": 255} {
+		if got, err := ParseTable(name); err != nil || got != id {
+			t.Errorf("Table '%s' resolves to %d (err=%v), want %d", name, got, err, id)
+		}
+	}
+
+	// Comments, lines without a name and lines without a number name nothing
+	for _, name := range []string{"commented", "tunnel", "garbage", "#300", ""} {
+		if id, err := ParseTable(name); err == nil {
+			t.Errorf("Table '%s' resolves to %d", name, id)
+		}
+	}
+	if got := Load().TableName(400); got != "400" {
+		t.Errorf("Table 400 is named '%s'", got)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	setupConfDirs(t, nil, nil)
+
+	// Numbers pass through as long as they fit the attribute, tables are 32 bit wide while
+	// scopes and protocols are a single byte
+	if id, err := ParseTable("4294967295"); err != nil || id != 4294967295 {
+		t.Errorf("Largest table resolves to %d, err=%v", id, err)
+	}
+	if id, err := ParseTable("0x2a"); err != nil || id != 42 {
+		t.Errorf("Table 0x2a resolves to %d, err=%v", id, err)
+	}
+	if id, err := ParseScope("0xfe"); err != nil || id != 254 {
+		t.Errorf("Scope 0xfe resolves to %d, err=%v", id, err)
+	}
+
+	if _, err := ParseTable("4294967296"); err == nil {
+		t.Errorf("Table above 32 bit accepted")
+	}
+	if _, err := ParseTable("-1"); err == nil {
+		t.Errorf("Negative table accepted")
+	}
+	if _, err := ParseScope("256"); err == nil {
+		t.Errorf("Scope above 8 bit accepted")
+	}
+	if _, err := ParseProtocol("256"); err == nil {
+		t.Errorf("Protocol above 8 bit accepted")
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	if got := TypeName(1); got != "unicast" {
+		t.Errorf("Type 1 is named '%s'", got)
+	}
+	if got := TypeName(99); got != "99" {
+		t.Errorf("Unknown type is named '%s'", got)
+	}
+}
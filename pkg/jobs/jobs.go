@@ -4,30 +4,55 @@
 package jobs
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"path"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
-	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 
-	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/identity"
 )
 
-type Result struct {
-	Output interface{}
-	Err    error
-}
+const (
+	StatePending     = "pending"
+	StateRunning     = "running"
+	StateComplete    = "complete"
+	StateFailed      = "failed"
+	StateCancelled   = "cancelled"
+	StateInterrupted = "interrupted"
+
+	expireInterval = time.Minute
+)
 
 type Job struct {
-	ResultChannel chan Result
-	Id            uint64
+	Id         uint64          `json:"Id"`
+	State      string          `json:"State"`
+	Owner      string          `json:"Owner"`
+	Endpoint   string          `json:"Endpoint"`
+	CreatedAt  time.Time       `json:"CreatedAt"`
+	StartedAt  time.Time       `json:"StartedAt"`
+	FinishedAt time.Time       `json:"FinishedAt"`
+	Output     json.RawMessage `json:"Output,omitempty"`
+	Error      string          `json:"Error,omitempty"`
+
+	cancel       context.CancelFunc
+	events       []Event
+	eventCounter uint64
+	subscribers  map[chan Event]struct{}
 }
 
 type Jobs struct {
-	jobMap     map[uint64]Job
-	resultMap  map[uint64]Result
+	jobMap     map[uint64]*Job
 	jobCounter uint64
+	store      *store
+	resultTTL  time.Duration
 	Mutex      *sync.Mutex
 }
 
@@ -36,110 +61,263 @@ var jobs *Jobs
 func New() *Jobs {
 	if jobs != nil {
 		return jobs
-	} else {
-		jobs = &Jobs{
-			jobMap:    make(map[uint64]Job),
-			resultMap: make(map[uint64]Result),
-			Mutex:     &sync.Mutex{},
+	}
+
+	ttl, _ := time.ParseDuration(conf.DefaultResultTTL)
+	jobs = &Jobs{
+		jobMap:    make(map[uint64]*Job),
+		resultTTL: ttl,
+		Mutex:     &sync.Mutex{},
+	}
+
+	return jobs
+}
+
+// InitJobs loads the jobs persisted by a previous instance and starts expiring finished results
+func InitJobs(c *conf.Config) error {
+	j := New()
+
+	if ttl, err := time.ParseDuration(c.Jobs.ResultTTL); err == nil {
+		j.resultTTL = ttl
+	}
+
+	s, err := newStore(path.Join(conf.StateDirPath, "jobs"))
+	if err != nil {
+		log.Errorf("Failed to open job store, jobs will not survive restart: %v", err)
+		return err
+	}
+
+	loaded, err := s.load()
+	if err != nil {
+		log.Errorf("Failed to load jobs from '%s': %v", s.path, err)
+		return err
+	}
+
+	j.Mutex.Lock()
+	j.store = s
+	for _, job := range loaded {
+		if !job.finished() {
+			job.State = StateInterrupted
+			job.Error = "interrupted by photon-mgmtd restart"
+			job.FinishedAt = time.Now()
+			j.save(job)
+		}
+
+		j.jobMap[job.Id] = job
+		if job.Id > j.jobCounter {
+			j.jobCounter = job.Id
 		}
-		return jobs
+	}
+	j.Mutex.Unlock()
+
+	go j.expire()
+
+	return nil
+}
+
+func (j *Job) finished() bool {
+	return j.State != StatePending && j.State != StateRunning
+}
+
+// save must be called with the mutex held
+func (j *Jobs) save(job *Job) {
+	if j.store == nil {
+		return
+	}
+
+	if err := j.store.save(job); err != nil {
+		log.Errorf("Failed to persist job='%d': %v", job.Id, err)
 	}
 }
 
-func NewJob() *Job {
+func (j *Jobs) expire() {
+	t := time.NewTicker(expireInterval)
+	defer t.Stop()
+
+	for range t.C {
+		j.Mutex.Lock()
+		for id, job := range j.jobMap {
+			if job.finished() && time.Since(job.FinishedAt) > j.resultTTL {
+				log.Debugf("Expiring job='%d' finished at '%v'", id, job.FinishedAt)
+				j.remove(id)
+			}
+		}
+		j.Mutex.Unlock()
+	}
+}
+
+// remove must be called with the mutex held
+func (j *Jobs) remove(id uint64) {
+	delete(j.jobMap, id)
+
+	if j.store != nil {
+		if err := j.store.remove(id); err != nil {
+			log.Errorf("Failed to remove job='%d' from store: %v", id, err)
+		}
+	}
+}
+
+func NewJob(r *http.Request) *Job {
+	jobs := New()
+
 	jobs.Mutex.Lock()
 	defer jobs.Mutex.Unlock()
 
 	jobs.jobCounter++
 	job := Job{
-		ResultChannel: make(chan Result),
-		Id:            jobs.jobCounter,
+		Id:        jobs.jobCounter,
+		State:     StatePending,
+		Owner:     identity.FromRequest(r).String(),
+		Endpoint:  r.Method + " " + r.URL.RequestURI(),
+		CreatedAt: time.Now(),
 	}
 
-	jobs.jobMap[jobs.jobCounter] = job
+	jobs.jobMap[job.Id] = &job
+	jobs.save(&job)
 
 	return &job
 }
 
 func RemoveJob(id uint64) {
+	jobs := New()
+
 	jobs.Mutex.Lock()
 	defer jobs.Mutex.Unlock()
 
-	delete(jobs.jobMap, id)
+	jobs.remove(id)
 }
 
-func RemoveResult(id uint64) {
-	jobs.Mutex.Lock()
-	defer jobs.Mutex.Unlock()
+// CreateJob runs acquireFunc in the background. The context handed to acquireFunc is
+// cancelled when the job is cancelled through the API.
+func CreateJob(r *http.Request, acquireFunc func(ctx context.Context) (interface{}, error)) *Job {
+	job := NewJob(r)
 
-	delete(jobs.resultMap, id)
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = newJobContext(ctx, job)
+
+	jobs.Mutex.Lock()
+	job.cancel = cancel
+	jobs.Mutex.Unlock()
 
-func CreateJob(acquireFunc func() (interface{}, error)) *Job {
-	job := NewJob()
 	go func() {
-		s, err := acquireFunc()
-		result := Result{
-			Output: s,
-			Err:    err,
+		defer cancel()
+
+		jobs.Mutex.Lock()
+		job.State = StateRunning
+		job.StartedAt = time.Now()
+		jobs.save(job)
+		job.publishState()
+		jobs.Mutex.Unlock()
+
+		s, err := acquireFunc(ctx)
+
+		// A failed job may still report how far it got
+		var output []byte
+		if s != nil {
+			o, merr := json.Marshal(s)
+			if merr != nil && err == nil {
+				err = merr
+			}
+			if string(o) != "null" {
+				output = o
+			}
+		}
+
+		jobs.Mutex.Lock()
+		defer jobs.Mutex.Unlock()
+
+		switch {
+		case ctx.Err() != nil:
+			job.State = StateCancelled
+			job.Error = "cancelled"
+		case err != nil:
+			job.State = StateFailed
+			job.Error = err.Error()
+			job.Output = output
+		default:
+			job.State = StateComplete
+			job.Output = output
 		}
-		job.ResultChannel <- result
+
+		job.FinishedAt = time.Now()
+		jobs.save(job)
+		job.publishState()
 	}()
+
 	return job
 }
 
-func AcceptedResponse(w http.ResponseWriter, job *Job) error {
-	w.Header().Set("Location", "/api/v1/_jobs/status/"+strconv.FormatUint(job.Id, 10))
-	w.WriteHeader(http.StatusAccepted)
-	return nil
-}
+// CancelOrRemoveJob cancels a job which is still running or forgets one which already finished.
+// Both happen under the lock, so a job finishing meanwhile is removed rather than reported as
+// finished. It returns whether the job was cancelled
+func CancelOrRemoveJob(id uint64) (bool, error) {
+	jobs := New()
 
-func routerAcquireStatus(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
-	if err != nil {
-		web.JSONResponseError(errors.New("invalid id"), w)
-	}
-	if job, ok := jobs.jobMap[id]; ok {
-		select {
-		case result := <-job.ResultChannel:
-			jobs.resultMap[id] = result
-			RemoveJob(id)
-			web.JSONResponse(
-				web.StatusResponse{
-					Status: "complete",
-					Link:   "/api/v1/_jobs/result/" + strconv.FormatUint(id, 10),
-				},
-				w)
-		default:
-			web.JSONResponse(web.StatusResponse{Status: "inprogress"}, w)
-		}
-	} else {
-		web.JSONResponseError(errors.New("not found"), w)
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	job, ok := jobs.jobMap[id]
+	if !ok {
+		return false, errors.New("not found")
+	}
+
+	if job.finished() {
+		jobs.remove(id)
+		return false, nil
 	}
+
+	if job.cancel != nil {
+		job.cancel()
+	}
+
+	return true, nil
 }
 
-func routerAcquireResult(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
-	if err != nil {
-		web.JSONResponseError(errors.New("invalid id"), w)
+// AcquireJob returns a copy of the job which is safe to read without holding the mutex
+func AcquireJob(id uint64) (*Job, bool) {
+	jobs := New()
+
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	job, ok := jobs.jobMap[id]
+	if !ok {
+		return nil, false
 	}
-	if result, ok := jobs.resultMap[id]; ok {
-		if result.Err != nil {
-			web.JSONResponseError(result.Err, w)
-		} else {
-			web.JSONResponse(result.Output, w)
+
+	j := *job
+	j.events = nil
+	j.subscribers = nil
+	return &j, true
+}
+
+func AcquireJobs(state string, owner string) []Job {
+	jobs := New()
+
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	l := []Job{}
+	for _, job := range jobs.jobMap {
+		if state != "" && job.State != state {
+			continue
 		}
-		RemoveResult(id)
-	} else {
-		web.JSONResponseError(errors.New("not found"), w)
+		if owner != "" && job.Owner != owner {
+			continue
+		}
+
+		l = append(l, *job)
 	}
-}
 
-func RegisterRouterJobs(router *mux.Router) {
-	jobs = New()
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Id < l[j].Id
+	})
 
-	n := router.PathPrefix("/_jobs").Subrouter().StrictSlash(false)
+	return l
+}
 
-	n.HandleFunc("/status/{id}", routerAcquireStatus).Methods("GET")
-	n.HandleFunc("/result/{id}", routerAcquireResult).Methods("GET")
+func AcceptedResponse(w http.ResponseWriter, job *Job) error {
+	w.Header().Set("Location", "/api/v1/_jobs/status/"+strconv.FormatUint(job.Id, 10))
+	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	EventProgress = "progress"
+	EventStdout   = "stdout"
+	EventStderr   = "stderr"
+	EventState    = "state"
+
+	maxEventHistory  = 1024
+	subscriberBuffer = 256
+
+	keepAliveInterval = 15 * time.Second
+)
+
+type Event struct {
+	Seq   uint64    `json:"Seq"`
+	Type  string    `json:"Type"`
+	Data  string    `json:"Data,omitempty"`
+	State string    `json:"State,omitempty"`
+	Error string    `json:"Error,omitempty"`
+	Time  time.Time `json:"Time"`
+}
+
+type jobContextKey struct{}
+
+func newJobContext(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, job)
+}
+
+func jobFromContext(ctx context.Context) (*Job, bool) {
+	job, ok := ctx.Value(jobContextKey{}).(*Job)
+	return job, ok
+}
+
+// publish must be called with the mutex held
+func (job *Job) publish(e Event) {
+	job.eventCounter++
+	e.Seq = job.eventCounter
+	e.Time = time.Now()
+
+	job.events = append(job.events, e)
+	if len(job.events) > maxEventHistory {
+		job.events = job.events[len(job.events)-maxEventHistory:]
+	}
+
+	for ch := range job.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// Slow readers are dropped rather than stalling the job
+			delete(job.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	if job.finished() {
+		for ch := range job.subscribers {
+			delete(job.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishState must be called with the mutex held
+func (job *Job) publishState() {
+	job.publish(Event{
+		Type:  EventState,
+		State: job.State,
+		Error: job.Error,
+	})
+}
+
+func publish(ctx context.Context, e Event) {
+	job, ok := jobFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	jobs := New()
+
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	job.publish(e)
+}
+
+// Progress reports a human readable progress message for the job running with ctx
+func Progress(ctx context.Context, format string, args ...interface{}) {
+	publish(ctx, Event{
+		Type: EventProgress,
+		Data: fmt.Sprintf(format, args...),
+	})
+}
+
+type outputWriter struct {
+	ctx    context.Context
+	stream string
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	publish(o.ctx, Event{
+		Type: o.stream,
+		Data: string(p),
+	})
+
+	return len(p), nil
+}
+
+// NewOutputWriter returns a writer which publishes everything written to it as stdout or stderr
+// events of the job running with ctx
+func NewOutputWriter(ctx context.Context, stream string) io.Writer {
+	if _, ok := jobFromContext(ctx); !ok {
+		return io.Discard
+	}
+
+	return &outputWriter{
+		ctx:    ctx,
+		stream: stream,
+	}
+}
+
+// Subscribe returns the events published so far and a channel delivering the following ones.
+// The channel is nil when the job already finished and is closed once it finishes.
+func Subscribe(id uint64) ([]Event, chan Event, bool) {
+	jobs := New()
+
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	job, ok := jobs.jobMap[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	history := append([]Event{}, job.events...)
+	if job.finished() {
+		// Jobs loaded from the store have no event history
+		if len(history) == 0 || history[len(history)-1].Type != EventState {
+			history = append(history, Event{
+				Seq:   job.eventCounter + 1,
+				Type:  EventState,
+				State: job.State,
+				Error: job.Error,
+				Time:  job.FinishedAt,
+			})
+		}
+		return history, nil, true
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	if job.subscribers == nil {
+		job.subscribers = make(map[chan Event]struct{})
+	}
+	job.subscribers[ch] = struct{}{}
+
+	return history, ch, true
+}
+
+func Unsubscribe(id uint64, ch chan Event) {
+	if ch == nil {
+		return
+	}
+
+	jobs := New()
+
+	jobs.Mutex.Lock()
+	defer jobs.Mutex.Unlock()
+
+	job, ok := jobs.jobMap[id]
+	if !ok {
+		return
+	}
+
+	if _, ok := job.subscribers[ch]; ok {
+		delete(job.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func parseJobId(r *http.Request) (uint64, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid id")
+	}
+
+	return id, nil
+}
+
+func routerAcquireStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := parseJobId(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	job, ok := AcquireJob(id)
+	if !ok {
+		web.JSONResponseError(errors.New("not found"), w)
+		return
+	}
+
+	if !job.finished() {
+		web.JSONResponse(web.StatusResponse{Status: "inprogress"}, w)
+		return
+	}
+
+	web.JSONResponse(
+		web.StatusResponse{
+			Status: "complete",
+			Link:   "/api/v1/_jobs/result/" + strconv.FormatUint(id, 10),
+		},
+		w)
+}
+
+func routerAcquireResult(w http.ResponseWriter, r *http.Request) {
+	id, err := parseJobId(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	job, ok := AcquireJob(id)
+	if !ok || !job.finished() {
+		web.JSONResponseError(errors.New("not found"), w)
+		return
+	}
+
+	// A failed job may still have output, such as the plan of a state which was partly applied
+	if job.State != StateComplete {
+		web.JSONResponseErrorWithMessage(errors.New(job.Error), job.Output, w)
+		return
+	}
+
+	web.JSONResponse(job.Output, w)
+}
+
+func routerAcquireJobs(w http.ResponseWriter, r *http.Request) {
+	// Only admins see the jobs of other callers
+	owner := r.FormValue("owner")
+	if !identity.IsAdmin(r) {
+		owner = identity.FromRequest(r).String()
+	}
+
+	web.JSONResponse(AcquireJobs(r.FormValue("state"), owner), w)
+}
+
+func routerAcquireJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseJobId(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	job, ok := AcquireJob(id)
+	if !ok {
+		web.JSONResponseError(errors.New("not found"), w)
+		return
+	}
+
+	web.JSONResponse(job, w)
+}
+
+// routerRemoveJob cancels a job which is still running or forgets one which already finished
+func routerRemoveJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseJobId(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	cancelled, err := CancelOrRemoveJob(id)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	if !cancelled {
+		web.JSONResponse("removed", w)
+		return
+	}
+
+	web.JSONResponse("cancelled", w)
+}
+
+// routerAcquireEvents streams the progress, output and final state of a job as Server-Sent Events
+func routerAcquireEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := parseJobId(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	history, ch, ok := Subscribe(id)
+	if !ok {
+		web.JSONResponseError(errors.New("not found"), w)
+		return
+	}
+	defer Unsubscribe(id, ch)
+
+	stream, err := web.NewEventStream(w)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	for _, e := range history {
+		if err := stream.Send(e.Type, e); err != nil {
+			return
+		}
+	}
+
+	if ch == nil {
+		return
+	}
+
+	t := time.NewTicker(keepAliveInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+			if err := stream.KeepAlive(); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := stream.Send(e.Type, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func RegisterRouterJobs(router *mux.Router) {
+	jobs = New()
+
+	n := router.PathPrefix("/_jobs").Subrouter().StrictSlash(false)
+
+	n.HandleFunc("", routerAcquireJobs).Methods("GET")
+	n.HandleFunc("/status/{id}", routerAcquireStatus).Methods("GET")
+	n.HandleFunc("/result/{id}", routerAcquireResult).Methods("GET")
+	n.HandleFunc("/{id:[0-9]+}", routerAcquireJob).Methods("GET")
+	n.HandleFunc("/{id:[0-9]+}", routerRemoveJob).Methods("DELETE")
+	n.HandleFunc("/{id:[0-9]+}/events", routerAcquireEvents).Methods("GET")
+}
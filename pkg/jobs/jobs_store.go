@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package jobs
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/system"
+)
+
+// store keeps one JSON file per job so that jobs survive a restart of photon-mgmtd
+type store struct {
+	path string
+}
+
+func newStore(p string) (*store, error) {
+	if err := system.CreateDirectoryNested(p, 0750); err != nil {
+		return nil, err
+	}
+
+	return &store{
+		path: p,
+	}, nil
+}
+
+func (s *store) jobPath(id uint64) string {
+	return path.Join(s.path, strconv.FormatUint(id, 10)+".json")
+}
+
+func (s *store) save(job *Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return system.WriteFileAtomic(s.jobPath(job.Id), b, 0640)
+}
+
+func (s *store) remove(id uint64) error {
+	if err := os.Remove(s.jobPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *store) load() ([]*Job, error) {
+	matches, err := filepath.Glob(path.Join(s.path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var l []*Job
+	for _, f := range matches {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		job := Job{}
+		if err := json.Unmarshal(b, &job); err != nil {
+			log.Warnf("Skipping corrupt job file='%s': %v", f, err)
+			continue
+		}
+
+		l = append(l, &job)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package netns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netns"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+const (
+	// RunDir holds the named namespaces the way ip-netns(8) creates them
+	RunDir = "/run/netns"
+
+	// QueryKey is the query parameter which selects the namespace of a request
+	QueryKey = "netns"
+
+	// PidPrefix selects the namespace of a process, as in pid:4242
+	PidPrefix = "pid:"
+)
+
+type contextKey struct{}
+
+// ValidateName rejects names which are not a plain file name below RunDir. Numbers are refused
+// too, they would be taken for a process id by tools like ip-netns(8)
+func ValidateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("invalid network namespace name '%s'", name)
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return fmt.Errorf("invalid network namespace name '%s', use %s%s for the namespace of a process", name, PidPrefix, name)
+	}
+
+	return nil
+}
+
+// Open returns the handle of a namespace given as the name of a namespace in RunDir or as
+// pid:<process id>
+func Open(ns string) (netns.NsHandle, error) {
+	if p, found := strings.CutPrefix(ns, PidPrefix); found {
+		pid, err := strconv.Atoi(p)
+		if err != nil || pid <= 0 {
+			return netns.None(), fmt.Errorf("invalid process id '%s'", p)
+		}
+
+		h, err := netns.GetFromPid(pid)
+		if err != nil {
+			return netns.None(), fmt.Errorf("failed to open network namespace of process '%d': %v", pid, err)
+		}
+		return h, nil
+	}
+
+	if err := ValidateName(ns); err != nil {
+		return netns.None(), err
+	}
+
+	h, err := netns.GetFromPath(path.Join(RunDir, ns))
+	if err != nil {
+		return netns.None(), fmt.Errorf("failed to open network namespace '%s': %v", ns, err)
+	}
+
+	return h, nil
+}
+
+// Do runs fn on an OS thread which is switched to the namespace. The thread is never handed
+// back to the scheduler so no other goroutine runs in the namespace; it is switched back and
+// terminated once fn returns. fn must not start goroutines which do netlink or socket calls
+func Do(ns string, fn func() error) error {
+	target, err := Open(ns)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		origin, err := netns.Get()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer origin.Close()
+
+		if err := netns.Set(target); err != nil {
+			errc <- fmt.Errorf("failed to enter network namespace '%s': %v", ns, err)
+			return
+		}
+
+		defer func() {
+			// The main thread is not terminated when its goroutine exits locked, so switch back
+			if err := netns.Set(origin); err != nil {
+				log.Errorf("Failed to leave network namespace='%s': %v", ns, err)
+			}
+
+			if r := recover(); r != nil {
+				errc <- fmt.Errorf("panic in network namespace '%s': %v", ns, r)
+			}
+		}()
+
+		errc <- fn()
+	}()
+
+	return <-errc
+}
+
+// FromContext returns the namespace the request runs in, empty for the one of the daemon
+func FromContext(ctx context.Context) string {
+	ns, _ := ctx.Value(contextKey{}).(string)
+	return ns
+}
+
+// ProcPath returns the path below /proc for the namespace of the request. /proc/net follows
+// the thread group leader while /proc/thread-self/net follows the calling thread
+func ProcPath(ctx context.Context, elem ...string) string {
+	if FromContext(ctx) == "" {
+		return path.Join(append([]string{"/proc"}, elem...)...)
+	}
+
+	return path.Join(append([]string{"/proc/thread-self"}, elem...)...)
+}
+
+// WithProcEnv points gopsutil to the proc files of the namespace of the request
+func WithProcEnv(ctx context.Context) context.Context {
+	if FromContext(ctx) == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, common.EnvKey, common.EnvMap{common.HostProcEnvKey: "/proc/thread-self"})
+}
+
+// Middleware runs the request in the namespace named by the netns query parameter
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.URL.Query().Get(QueryKey)
+		if ns == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, ns)
+		if err := Do(ns, func() error {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return nil
+		}); err != nil {
+			web.JSONResponseError(err, w)
+		}
+	})
+}
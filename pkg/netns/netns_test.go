@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package netns
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netns"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"blue", "ns-1", "4242a", "0x10", ".hidden"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("Name '%s' refused: %v", name, err)
+		}
+	}
+
+	// Numbers would be ambiguous with process ids, the error points to the pid: syntax
+	err := ValidateName("4242")
+	if err == nil || !strings.Contains(err.Error(), "pid:4242") {
+		t.Errorf("Numeric name not refused with a hint, err=%v", err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../etc", "a/b", "a\x00b", "-1"} {
+		if ValidateName(name) == nil {
+			t.Errorf("Name %q accepted", name)
+		}
+	}
+}
+
+func TestOpenProcess(t *testing.T) {
+	self, err := netns.Get()
+	if err != nil {
+		t.Skipf("Network namespace of the test not available: %v", err)
+	}
+	defer self.Close()
+
+	h, err := Open(PidPrefix + strconv.Itoa(os.Getpid()))
+	if err != nil {
+		t.Fatalf("Failed to open the namespace of the test process: %v", err)
+	}
+	defer h.Close()
+
+	if !h.Equal(self) {
+		t.Errorf("Opened namespace %s, want the one of the test %s", h, self)
+	}
+
+	// A bare number is a name, never a process
+	if h, err := Open(strconv.Itoa(os.Getpid())); err == nil {
+		h.Close()
+		t.Errorf("Numeric namespace name opened the namespace of a process")
+	}
+
+	for _, ns := range []string{"pid:", "pid:0", "pid:-1", "pid:self", "pid:1/../2"} {
+		if h, err := Open(ns); err == nil {
+			h.Close()
+			t.Errorf("Namespace '%s' opened", ns)
+		}
+	}
+}
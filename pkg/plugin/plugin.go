@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/system"
+)
+
+// APIVersion is the version of the Plugin interface. Plugins built against another
+// version are refused
+const APIVersion = 1
+
+const (
+	KindBuiltin    = "builtin"
+	KindGoPlugin   = "go-plugin"
+	KindExecutable = "executable"
+
+	StateLoaded   = "loaded"
+	StateDisabled = "disabled"
+	StateFailed   = "failed"
+)
+
+type Plugin interface {
+	Name() string
+	Version() string
+
+	// Capabilities lists the capabilities such as CAP_NET_ADMIN the plugin needs to work
+	Capabilities() []string
+
+	Init(c *conf.Config) error
+	Register(router *mux.Router)
+	Shutdown() error
+}
+
+type Info struct {
+	Name         string   `json:"Name"`
+	Version      string   `json:"Version"`
+	Kind         string   `json:"Kind"`
+	Path         string   `json:"Path,omitempty"`
+	Capabilities []string `json:"Capabilities"`
+	State        string   `json:"State"`
+	Error        string   `json:"Error,omitempty"`
+}
+
+type entry struct {
+	plugin Plugin
+	info   Info
+}
+
+type Registry struct {
+	mutex   sync.Mutex
+	entries []*entry
+}
+
+var registry = &Registry{}
+
+// reservedNames are routes below /api/v1 which photon-mgmtd serves itself
+var reservedNames = map[string]bool{
+	"_audit":   true,
+	"_jobs":    true,
+	"_plugins": true,
+	"metrics":  true,
+}
+
+func (r *Registry) add(p Plugin, kind string, path string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = append(r.entries, &entry{
+		plugin: p,
+		info: Info{
+			Name:         p.Name(),
+			Version:      p.Version(),
+			Kind:         kind,
+			Path:         path,
+			Capabilities: p.Capabilities(),
+		},
+	})
+}
+
+func (r *Registry) addFailed(name string, kind string, path string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = append(r.entries, &entry{
+		info: Info{
+			Name:  name,
+			Kind:  kind,
+			Path:  path,
+			State: StateFailed,
+			Error: err.Error(),
+		},
+	})
+}
+
+// Register adds a plugin compiled into photon-mgmtd
+func Register(p Plugin) {
+	registry.add(p, KindBuiltin, "")
+}
+
+func missingCapabilities(p Plugin) error {
+	for _, c := range p.Capabilities() {
+		ok, err := system.HasCapability(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("missing capability '%s'", c)
+		}
+	}
+
+	return nil
+}
+
+// Load discovers the external plugins, then initializes the enabled plugins and registers
+// their routes in the order they were added
+func Load(c *conf.Config, router *mux.Router) {
+	discover(c.Plugins.Directory)
+
+	disabled := make(map[string]bool)
+	for _, n := range c.Plugins.Disable {
+		disabled[n] = true
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	names := make(map[string]bool)
+	for _, e := range registry.entries {
+		if e.info.State == StateFailed {
+			continue
+		}
+
+		switch {
+		case reservedNames[e.info.Name]:
+			e.info.State = StateFailed
+			e.info.Error = "reserved plugin name"
+		case names[e.info.Name]:
+			e.info.State = StateFailed
+			e.info.Error = "duplicate plugin name"
+		case disabled[e.info.Name]:
+			e.info.State = StateDisabled
+		}
+		names[e.info.Name] = true
+
+		if e.info.State != "" {
+			log.Infof("Skipping plugin='%s' state='%s' %s", e.info.Name, e.info.State, e.info.Error)
+			continue
+		}
+
+		if err := missingCapabilities(e.plugin); err != nil {
+			e.info.State = StateFailed
+			e.info.Error = err.Error()
+			log.Errorf("Failed to load plugin='%s': %v", e.info.Name, err)
+			continue
+		}
+
+		if err := e.plugin.Init(c); err != nil {
+			e.info.State = StateFailed
+			e.info.Error = err.Error()
+			log.Errorf("Failed to initialize plugin='%s': %v", e.info.Name, err)
+			continue
+		}
+
+		e.plugin.Register(router)
+		e.info.State = StateLoaded
+
+		log.Debugf("Loaded plugin='%s' version='%s' kind='%s'", e.info.Name, e.info.Version, e.info.Kind)
+	}
+}
+
+// Shutdown stops the loaded plugins in the reverse order they were loaded
+func Shutdown() {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for i := len(registry.entries) - 1; i >= 0; i-- {
+		e := registry.entries[i]
+		if e.info.State != StateLoaded {
+			continue
+		}
+
+		if err := e.plugin.Shutdown(); err != nil {
+			log.Errorf("Failed to shutdown plugin='%s': %v", e.info.Name, err)
+		}
+	}
+}
+
+// Infos returns the state of all known plugins sorted by name
+func Infos() []Info {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	l := make([]Info, 0, len(registry.entries))
+	for _, e := range registry.entries {
+		l = append(l, e.info)
+	}
+
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+
+	return l
+}
+
+type builtin struct {
+	name         string
+	register     func(router *mux.Router)
+	capabilities []string
+}
+
+// NewBuiltin wraps the router registration function of a plugin compiled into photon-mgmtd
+func NewBuiltin(name string, register func(router *mux.Router), capabilities ...string) Plugin {
+	return &builtin{
+		name:         name,
+		register:     register,
+		capabilities: capabilities,
+	}
+}
+
+func (b *builtin) Name() string {
+	return b.name
+}
+
+func (b *builtin) Version() string {
+	return conf.Version
+}
+
+func (b *builtin) Capabilities() []string {
+	return append([]string{}, b.capabilities...)
+}
+
+func (b *builtin) Init(c *conf.Config) error {
+	return nil
+}
+
+func (b *builtin) Register(router *mux.Router) {
+	b.register(router)
+}
+
+func (b *builtin) Shutdown() error {
+	return nil
+}
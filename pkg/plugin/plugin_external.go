@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/exec"
+	"path"
+	goplugin "plugin"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+)
+
+const (
+	// Environment variable telling an executable plugin where to serve HTTP
+	SocketEnv = "PHOTON_MGMT_PLUGIN_SOCKET"
+
+	// Header carrying the caller of a request proxied to an executable plugin
+	UserHeader = "X-Photon-Mgmt-User"
+
+	socketDirPath = "/run/photon-mgmt/plugins"
+
+	describeTimeout = 5 * time.Second
+	startTimeout    = 5 * time.Second
+	stopTimeout     = 5 * time.Second
+)
+
+// Description is printed as JSON by an executable plugin invoked with 'describe'
+type Description struct {
+	Name         string   `json:"Name"`
+	Version      string   `json:"Version"`
+	APIVersion   int      `json:"APIVersion"`
+	Capabilities []string `json:"Capabilities"`
+}
+
+// discover loads the Go plugins (*.so) and the executables found in dir
+func discover(dir string) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Failed to read plugin directory='%s': %v", dir, err)
+		}
+		return
+	}
+
+	for _, f := range files {
+		p := path.Join(dir, f.Name())
+
+		st, err := os.Stat(p)
+		if err != nil || !st.Mode().IsRegular() {
+			continue
+		}
+
+		if strings.HasSuffix(f.Name(), ".so") {
+			name := strings.TrimSuffix(f.Name(), ".so")
+			if err := checkOwnership(st); err != nil {
+				registry.addFailed(name, KindGoPlugin, p, err)
+				continue
+			}
+
+			pl, err := openGoPlugin(p)
+			if err != nil {
+				log.Errorf("Failed to open plugin='%s': %v", p, err)
+				registry.addFailed(name, KindGoPlugin, p, err)
+				continue
+			}
+
+			registry.add(pl, KindGoPlugin, p)
+		} else if st.Mode().Perm()&0111 != 0 {
+			if err := checkOwnership(st); err != nil {
+				registry.addFailed(f.Name(), KindExecutable, p, err)
+				continue
+			}
+
+			pl, err := openExecutable(p)
+			if err != nil {
+				log.Errorf("Failed to describe plugin='%s': %v", p, err)
+				registry.addFailed(f.Name(), KindExecutable, p, err)
+				continue
+			}
+
+			registry.add(pl, KindExecutable, p)
+		}
+	}
+}
+
+// checkOwnership refuses plugins which somebody else than root could have replaced
+func checkOwnership(st os.FileInfo) error {
+	s, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("failed to stat plugin")
+	}
+
+	if s.Uid != 0 || st.Mode().Perm()&0022 != 0 {
+		return errors.New("plugin must be owned by root and not writable by group or others")
+	}
+
+	return nil
+}
+
+// openGoPlugin loads a plugin built with -buildmode=plugin. It must export
+// 'var PluginAPIVersion = plugin.APIVersion' and 'func New() plugin.Plugin'
+func openGoPlugin(p string) (Plugin, error) {
+	g, err := goplugin.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, err := g.Lookup("PluginAPIVersion")
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := sym.(*int)
+	if !ok {
+		return nil, errors.New("PluginAPIVersion is not an int")
+	}
+	if *v != APIVersion {
+		return nil, fmt.Errorf("plugin API version %d is not supported, expected %d", *v, APIVersion)
+	}
+
+	sym, err = g.Lookup("New")
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := sym.(func() Plugin)
+	if !ok {
+		return nil, errors.New("New is not a func() plugin.Plugin")
+	}
+
+	return n(), nil
+}
+
+// executable is an out of process plugin serving HTTP on a unix domain socket.
+// Requests below /api/v1/<name> are proxied to it as /<name>/...
+type executable struct {
+	path        string
+	description Description
+	socket      string
+	cmd         *exec.Cmd
+	exited      chan struct{}
+}
+
+func openExecutable(p string) (Plugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, p, "describe").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	d := Description{}
+	if err := json.Unmarshal(out, &d); err != nil {
+		return nil, err
+	}
+
+	if d.APIVersion != APIVersion {
+		return nil, fmt.Errorf("plugin API version %d is not supported, expected %d", d.APIVersion, APIVersion)
+	}
+
+	if d.Name == "" || strings.ContainsAny(d.Name, "/.") {
+		return nil, fmt.Errorf("invalid plugin name '%s'", d.Name)
+	}
+
+	return &executable{
+		path:        p,
+		description: d,
+		socket:      path.Join(socketDirPath, d.Name+".sock"),
+	}, nil
+}
+
+func (e *executable) Name() string {
+	return e.description.Name
+}
+
+func (e *executable) Version() string {
+	return e.description.Version
+}
+
+func (e *executable) Capabilities() []string {
+	return append([]string{}, e.description.Capabilities...)
+}
+
+func (e *executable) Init(c *conf.Config) error {
+	if err := os.MkdirAll(socketDirPath, 0750); err != nil {
+		return err
+	}
+	os.Remove(e.socket)
+
+	e.cmd = exec.Command(e.path, "serve")
+	// Nothing of the environment of photon-mgmtd, such as secrets, is handed to the plugin
+	e.cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		SocketEnv + "=" + e.socket,
+	}
+	e.cmd.Stdout = os.Stdout
+	e.cmd.Stderr = os.Stderr
+
+	if err := e.cmd.Start(); err != nil {
+		return err
+	}
+
+	e.exited = make(chan struct{})
+	go func() {
+		err := e.cmd.Wait()
+		log.Infof("Plugin='%s' exited: %v", e.Name(), err)
+		close(e.exited)
+	}()
+
+	deadline := time.After(startTimeout)
+	for {
+		if _, err := os.Stat(e.socket); err == nil {
+			return nil
+		}
+
+		select {
+		case <-e.exited:
+			return errors.New("plugin exited before listening")
+		case <-deadline:
+			e.Shutdown()
+			return fmt.Errorf("plugin did not listen on '%s'", e.socket)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (e *executable) Register(router *mux.Router) {
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			r.URL.Host = e.Name()
+
+			// The plugin sees the path below the API root, /<name>/...
+			if i := strings.Index(r.URL.Path, "/"+e.Name()); i > 0 {
+				r.URL.Path = r.URL.Path[i:]
+				r.URL.RawPath = ""
+			}
+			r.Header.Set(UserHeader, identity.FromRequest(r).String())
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", e.socket)
+			},
+		},
+		// Flush right away so that plugins can stream events
+		FlushInterval: -1,
+	}
+
+	router.PathPrefix("/" + e.Name()).Handler(proxy)
+}
+
+func (e *executable) Shutdown() error {
+	defer os.Remove(e.socket)
+
+	if e.cmd == nil || e.cmd.Process == nil {
+		return nil
+	}
+
+	select {
+	case <-e.exited:
+		return nil
+	default:
+	}
+
+	if err := e.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	select {
+	case <-e.exited:
+	case <-time.After(stopTimeout):
+		return e.cmd.Process.Kill()
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package plugin
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerAcquirePlugins(w http.ResponseWriter, r *http.Request) {
+	web.JSONResponse(Infos(), w)
+}
+
+func RegisterRouterPlugins(router *mux.Router) {
+	n := router.PathPrefix("/_plugins").Subrouter()
+
+	n.HandleFunc("", routerAcquirePlugins).Methods("GET")
+}
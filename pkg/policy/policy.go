@@ -0,0 +1,400 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+	"github.com/vmware/pmd-next-gen/pkg/share"
+	"github.com/vmware/pmd-next-gen/pkg/system"
+)
+
+const (
+	RoleOperator     = "operator"
+	RoleNetworkAdmin = "network-admin"
+	RoleAdmin        = identity.RoleAdmin
+
+	anyMethod = "*"
+
+	// Jobs are cancelled with DELETE on their id below this path
+	jobsPath = "/api/v1/_jobs/"
+)
+
+// Paths of the tdnf commands which alter the system although they are served as GET
+var alteringGetPaths = []string{
+	"/api/v1/tdnf/autoremove",
+	"/api/v1/tdnf/clean",
+	"/api/v1/tdnf/distro-sync",
+	"/api/v1/tdnf/downgrade",
+	"/api/v1/tdnf/erase",
+	"/api/v1/tdnf/install",
+	"/api/v1/tdnf/makecache",
+	"/api/v1/tdnf/reinstall",
+	"/api/v1/tdnf/update",
+	"/api/v1/tdnf/history/init",
+	"/api/v1/tdnf/history/rollback",
+	"/api/v1/tdnf/history/undo",
+	"/api/v1/tdnf/history/redo",
+	"/api/v1/tdnf/mark",
+}
+
+func getPermissions(paths []string) []string {
+	l := []string{}
+	for _, p := range paths {
+		l = append(l, http.MethodGet+" "+p)
+	}
+
+	return l
+}
+
+// Read only access to everything except the tdnf commands which alter the system
+var operatorPermissions = Role{
+	Name:  RoleOperator,
+	Allow: []string{"GET /"},
+	Deny:  getPermissions(alteringGetPaths),
+}
+
+// ChangesSystem tells whether the route alters the system. Besides the methods which change
+// state by definition this covers the commands which are served as GET
+func ChangesSystem(method string, path string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return true
+	}
+
+	for _, p := range alteringGetPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+var builtinRoles = []Role{
+	operatorPermissions,
+	{
+		Name:  RoleNetworkAdmin,
+		Allow: append([]string{"* /api/v1/network"}, operatorPermissions.Allow...),
+		Deny:  operatorPermissions.Deny,
+	},
+	{
+		Name:  RoleAdmin,
+		Allow: []string{"* /"},
+	},
+}
+
+type Authorization struct {
+	DefaultRole string `mapstructure:"DefaultRole"`
+}
+
+type Role struct {
+	Name  string   `mapstructure:"Name"`
+	Allow []string `mapstructure:"Allow"`
+	Deny  []string `mapstructure:"Deny"`
+}
+
+type Binding struct {
+	Role   string   `mapstructure:"Role"`
+	Users  []string `mapstructure:"Users"`
+	Groups []string `mapstructure:"Groups"`
+	Uids   []uint32 `mapstructure:"Uids"`
+}
+
+type Policy struct {
+	Authorization Authorization `mapstructure:"Authorization"`
+	Roles         []Role        `mapstructure:"Role"`
+	Bindings      []Binding     `mapstructure:"Binding"`
+
+	rules map[string]*ruleSet
+}
+
+type rule struct {
+	methods []string
+	prefix  string
+}
+
+type ruleSet struct {
+	allow []rule
+	deny  []rule
+}
+
+func parseRule(s string) (rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+		return rule{}, fmt.Errorf("invalid permission '%s', expected '<METHOD[,METHOD...]|*> /path'", s)
+	}
+
+	return rule{
+		methods: strings.Split(strings.ToUpper(fields[0]), ","),
+		prefix:  strings.TrimSuffix(fields[1], "/"),
+	}, nil
+}
+
+func (r *rule) match(method string, path string) bool {
+	if !share.StringContains(r.methods, anyMethod) && !share.StringContains(r.methods, method) {
+		return false
+	}
+
+	return r.prefix == "" || path == r.prefix || strings.HasPrefix(path, r.prefix+"/")
+}
+
+func parseRules(l []string) ([]rule, error) {
+	var rules []rule
+	for _, s := range l {
+		r, err := parseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+func (p *Policy) compile() error {
+	p.rules = make(map[string]*ruleSet)
+
+	// Roles defined in the policy file replace the builtin ones of the same name
+	for _, role := range append(builtinRoles, p.Roles...) {
+		if role.Name == "" {
+			return errors.New("role without a name")
+		}
+
+		allow, err := parseRules(role.Allow)
+		if err != nil {
+			return errors.Wrapf(err, "role '%s'", role.Name)
+		}
+
+		deny, err := parseRules(role.Deny)
+		if err != nil {
+			return errors.Wrapf(err, "role '%s'", role.Name)
+		}
+
+		p.rules[role.Name] = &ruleSet{
+			allow: allow,
+			deny:  deny,
+		}
+	}
+
+	if p.Authorization.DefaultRole != "" {
+		if _, ok := p.rules[p.Authorization.DefaultRole]; !ok {
+			return fmt.Errorf("unknown default role '%s'", p.Authorization.DefaultRole)
+		}
+	}
+
+	for _, b := range p.Bindings {
+		if _, ok := p.rules[b.Role]; !ok {
+			return fmt.Errorf("binding refers to unknown role '%s'", b.Role)
+		}
+	}
+
+	return nil
+}
+
+// Load reads the policy file. It returns an error satisfying os.IsNotExist when the file does not exist.
+func Load(path string) (*Policy, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse policy file '%s'", path)
+	}
+
+	p := Policy{}
+	if err := v.Unmarshal(&p); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode policy file '%s'", path)
+	}
+
+	if err := p.compile(); err != nil {
+		return nil, errors.Wrapf(err, "invalid policy file '%s'", path)
+	}
+
+	return &p, nil
+}
+
+func claimValues(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return strings.Fields(t)
+	case []interface{}:
+		var l []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				l = append(l, s)
+			}
+		}
+		return l
+	}
+
+	return nil
+}
+
+func localGroups(id *identity.Identity) []string {
+	groups := []string{}
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(id.Gid), 10)); err == nil {
+		groups = append(groups, g.Name)
+	}
+
+	u, err := system.GetUserCredentialsByUid(id.Uid)
+	if err != nil {
+		return groups
+	}
+
+	gids, _ := u.GroupIds()
+	for _, gid := range gids {
+		if g, err := user.LookupGroupId(gid); err == nil && !share.StringContains(groups, g.Name) {
+			groups = append(groups, g.Name)
+		}
+	}
+
+	return groups
+}
+
+// GrantedRoles returns the roles granted to the caller by the JWT claims 'roles' and 'scope' and by the bindings
+func (p *Policy) GrantedRoles(id *identity.Identity) []string {
+	roles := []string{}
+	add := func(role string) {
+		if _, ok := p.rules[role]; ok && !share.StringContains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	for _, k := range []string{"roles", "scope", "scopes"} {
+		for _, r := range claimValues(id.Claims[k]) {
+			add(r)
+		}
+	}
+
+	var username string
+	var groups []string
+	if id.Local {
+		if u, err := system.GetUserCredentialsByUid(id.Uid); err == nil {
+			username = u.Username
+		}
+		groups = localGroups(id)
+	}
+
+	for _, b := range p.Bindings {
+		switch {
+		case id.Subject != "" && share.StringContains(b.Users, id.Subject):
+			add(b.Role)
+		case username != "" && share.StringContains(b.Users, username):
+			add(b.Role)
+		case id.Local && uint32Contains(b.Uids, id.Uid):
+			add(b.Role)
+		default:
+			for _, g := range groups {
+				if share.StringContains(b.Groups, g) {
+					add(b.Role)
+					break
+				}
+			}
+		}
+	}
+
+	if len(roles) == 0 && p.Authorization.DefaultRole != "" {
+		add(p.Authorization.DefaultRole)
+	}
+
+	return roles
+}
+
+func uint32Contains(l []uint32, v uint32) bool {
+	for _, e := range l {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) permits(roles []string, method string, path string) bool {
+	for _, role := range roles {
+		rs := p.rules[role]
+
+		denied := false
+		for _, r := range rs.deny {
+			if r.match(method, path) {
+				denied = true
+				break
+			}
+		}
+		if denied {
+			continue
+		}
+
+		for _, r := range rs.allow {
+			if r.match(method, path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// permitsJobCancel lets the owner of a job cancel it, as well as the roles which may call the
+// endpoint the job was created by
+func (p *Policy) permitsJobCancel(id *identity.Identity, roles []string, path string) bool {
+	jobId, err := strconv.ParseUint(strings.TrimPrefix(path, jobsPath), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	job, ok := jobs.AcquireJob(jobId)
+	if !ok {
+		return false
+	}
+
+	// A remote address is no identity, the port changes with every connection
+	if (id.Subject != "" || id.Local) && job.Owner == id.String() {
+		return true
+	}
+
+	method, uri, _ := strings.Cut(job.Endpoint, " ")
+	uri, _, _ = strings.Cut(uri, "?")
+
+	return p.permits(roles, method, uri)
+}
+
+// Authorize checks whether any role of the caller permits method on path. Deny rules only
+// apply to the role they belong to.
+func (p *Policy) Authorize(id *identity.Identity, method string, path string) error {
+	// root on the unix domain socket can do everything anyway
+	if id.Local && id.Uid == 0 {
+		return nil
+	}
+
+	roles := p.GrantedRoles(id)
+	if p.permits(roles, method, path) {
+		return nil
+	}
+
+	if method == http.MethodDelete && strings.HasPrefix(path, jobsPath) && p.permitsJobCancel(id, roles, path) {
+		return nil
+	}
+
+	log.Infof("Access denied: caller='%s' roles='%v' method='%s' path='%s'", id.String(), roles, method, path)
+
+	return errors.New(http.StatusText(http.StatusForbidden))
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+)
+
+func newTestPolicy(t *testing.T, roles []Role) *Policy {
+	p := Policy{
+		Roles: roles,
+	}
+	if err := p.compile(); err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+
+	return &p
+}
+
+func caller(subject string, roles ...interface{}) *identity.Identity {
+	return &identity.Identity{
+		Subject: subject,
+		Remote:  "192.0.2.1:4242",
+		Claims: map[string]interface{}{
+			"sub":   subject,
+			"roles": roles,
+		},
+	}
+}
+
+func newTestJob(subject string, method string, uri string) string {
+	r := httptest.NewRequest(method, uri, nil)
+	r = r.WithContext(identity.NewClaimsContext(r.Context(), map[string]interface{}{"sub": subject}))
+
+	return strconv.FormatUint(jobs.NewJob(r).Id, 10)
+}
+
+func TestAuthorize(t *testing.T) {
+	p := newTestPolicy(t, []Role{
+		{
+			Name:  "auditor",
+			Allow: []string{"GET /api/v1/audit", "GET,POST /api/v1/system"},
+			Deny:  []string{"POST /api/v1/system/hostname"},
+		},
+	})
+
+	tdnfJob := newTestJob("alice", http.MethodGet, "/api/v1/tdnf/install/curl?async=true")
+	networkJob := newTestJob("carol", http.MethodPost, "/api/v1/network/networkd/network/configure")
+
+	tests := []struct {
+		name   string
+		id     *identity.Identity
+		method string
+		path   string
+		allow  bool
+	}{
+		{"root on the unix socket", &identity.Identity{Local: true}, http.MethodDelete, "/api/v1/system", true},
+		{"no role", caller("bob"), http.MethodGet, "/api/v1/system/hostname", false},
+		{"unknown role", caller("bob", "nobody"), http.MethodGet, "/", false},
+		{"admin", caller("bob", "admin"), http.MethodPost, "/api/v1/tdnf/install", true},
+		{"operator reads", caller("bob", "operator"), http.MethodGet, "/api/v1/system/hostname", true},
+		{"operator writes", caller("bob", "operator"), http.MethodPost, "/api/v1/system/hostname", false},
+		{"operator alters packages", caller("bob", "operator"), http.MethodGet, "/api/v1/tdnf/install/curl", false},
+		{"operator lists packages", caller("bob", "operator"), http.MethodGet, "/api/v1/tdnf/list", true},
+		{"network-admin configures network", caller("bob", "network-admin"), http.MethodPost, "/api/v1/network/netlink/route", true},
+		{"network-admin alters packages", caller("bob", "network-admin"), http.MethodGet, "/api/v1/tdnf/update", false},
+		{"prefix is not a path element", caller("bob", "network-admin"), http.MethodPost, "/api/v1/networkd", false},
+		{"custom role allow", caller("bob", "auditor"), http.MethodPost, "/api/v1/system/timezone", true},
+		{"custom role deny", caller("bob", "auditor"), http.MethodPost, "/api/v1/system/hostname", false},
+		{"deny of one role does not hide another", caller("bob", "auditor", "admin"), http.MethodPost, "/api/v1/system/hostname", true},
+		{"roles from scope claim", &identity.Identity{Subject: "bob", Claims: map[string]interface{}{"scope": "operator"}}, http.MethodGet, "/api/v1/audit", true},
+		{"owner cancels own job", caller("alice"), http.MethodDelete, "/api/v1/_jobs/" + tdnfJob, true},
+		{"network-admin cancels package job", caller("bob", "network-admin"), http.MethodDelete, "/api/v1/_jobs/" + tdnfJob, false},
+		{"network-admin cancels network job", caller("bob", "network-admin"), http.MethodDelete, "/api/v1/_jobs/" + networkJob, true},
+		{"operator cancels network job", caller("bob", "operator"), http.MethodDelete, "/api/v1/_jobs/" + networkJob, false},
+		{"admin cancels any job", caller("bob", "admin"), http.MethodDelete, "/api/v1/_jobs/" + tdnfJob, true},
+		{"unknown job", caller("bob", "network-admin"), http.MethodDelete, "/api/v1/_jobs/999999", false},
+		{"remote address is no owner", &identity.Identity{Remote: "192.0.2.1:4242"}, http.MethodDelete, "/api/v1/_jobs/" + tdnfJob, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.id, tt.method, tt.path)
+			if tt.allow && err != nil {
+				t.Errorf("Expected %s %s to be allowed: %v", tt.method, tt.path, err)
+			}
+			if !tt.allow && err == nil {
+				t.Errorf("Expected %s %s to be denied", tt.method, tt.path)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		valid  bool
+	}{
+		{"builtin roles only", Policy{}, true},
+		{"default role", Policy{Authorization: Authorization{DefaultRole: "operator"}}, true},
+		{"unknown default role", Policy{Authorization: Authorization{DefaultRole: "nobody"}}, false},
+		{"binding to unknown role", Policy{Bindings: []Binding{{Role: "nobody"}}}, false},
+		{"role without a name", Policy{Roles: []Role{{Allow: []string{"GET /"}}}}, false},
+		{"permission without path", Policy{Roles: []Role{{Name: "r", Allow: []string{"GET"}}}}, false},
+		{"relative path", Policy{Roles: []Role{{Name: "r", Deny: []string{"GET api"}}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.compile()
+			if tt.valid && err != nil {
+				t.Errorf("Expected policy to compile: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected policy to be rejected")
+			}
+		})
+	}
+}
+
+func TestChangesSystem(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		changes bool
+	}{
+		{http.MethodPost, "/api/v1/system/hostname/update", true},
+		{http.MethodPut, "/api/v1/network/netlink/link/eth0", true},
+		{http.MethodDelete, "/api/v1/_jobs/1", true},
+		{http.MethodPatch, "/api/v1/service", true},
+		{http.MethodGet, "/api/v1/system/hostname", false},
+		{http.MethodHead, "/api/v1/tdnf/list", false},
+		{http.MethodGet, "/api/v1/tdnf/list/curl", false},
+		{http.MethodGet, "/api/v1/tdnf/install/curl", true},
+		{http.MethodGet, "/api/v1/tdnf/erase/curl", true},
+		{http.MethodGet, "/api/v1/tdnf/update", true},
+		{http.MethodGet, "/api/v1/tdnf/history/undo", true},
+		{http.MethodGet, "/api/v1/tdnf/history/list", false},
+		{http.MethodGet, "/api/v1/tdnf/mark/install/curl", true},
+		{http.MethodGet, "/api/v1/tdnf/updateinfo", false},
+	}
+
+	for _, tt := range tests {
+		if got := ChangesSystem(tt.method, tt.path); got != tt.changes {
+			t.Errorf("ChangesSystem(%s, %s) = %v, expected %v", tt.method, tt.path, got, tt.changes)
+		}
+	}
+}
@@ -8,13 +8,19 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 
+	"github.com/vmware/pmd-next-gen/pkg/audit"
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/policy"
 	"github.com/vmware/pmd-next-gen/pkg/share"
 	"github.com/vmware/pmd-next-gen/pkg/system"
 	"github.com/vmware/pmd-next-gen/pkg/validator"
@@ -86,7 +92,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(identity.NewClaimsContext(r.Context(), cls)))
 	})
 }
 
@@ -115,9 +121,11 @@ func authenticateLocalUser(credentials *unix.Ucred) error {
 
 func UnixDomainPeerCredential(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var credentialsContextKey = struct{}{}
-
-		credentials := r.Context().Value(credentialsContextKey).(*unix.Ucred)
+		credentials, ok := identity.PeerCredentials(r.Context())
+		if !ok {
+			web.JSONResponseError(errors.New("missing peer credentials"), w)
+			return
+		}
 
 		if err := authenticateLocalUser(credentials); err != nil {
 			web.JSONResponseError(err, w)
@@ -127,3 +135,58 @@ func UnixDomainPeerCredential(next http.Handler) http.Handler {
 		}
 	})
 }
+
+func AuthorizationMiddleware(p *policy.Policy) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := identity.FromRequest(r)
+			if err := p.Authorize(id, r.Method, r.URL.Path); err != nil {
+				web.JSONResponseErrorWithStatus(err, http.StatusForbidden, w)
+				return
+			}
+
+			// Handlers such as the job listing narrow what they return to callers without the admin role
+			next.ServeHTTP(w, r.WithContext(identity.NewRolesContext(r.Context(), p.GrantedRoles(id))))
+		})
+	}
+}
+
+// exemptMetrics lets scrapers fetch /metrics without passing the middleware when the metrics
+// do not use authentication
+func exemptMetrics(c *conf.Config, m mux.MiddlewareFunc) mux.MiddlewareFunc {
+	if c.Metrics.UseAuthentication {
+		return m
+	}
+
+	return func(next http.Handler) http.Handler {
+		h := m(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == metricsPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// useAuditAndAuthorization installs the audit log and the authorization policy after the
+// authentication middlewares, so that the caller is known. The audit log wraps the policy to
+// record denied requests as well
+func useAuditAndAuthorization(c *conf.Config, r *mux.Router) error {
+	r.Use(audit.Middleware)
+
+	p, err := policy.Load(path.Join(conf.ConfPath, conf.PolicyFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Infof("No authorization policy found, all authenticated callers have full access")
+			return nil
+		}
+
+		return err
+	}
+
+	r.Use(exemptMetrics(c, AuthorizationMiddleware(p)))
+	return nil
+}
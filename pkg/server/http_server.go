@@ -18,44 +18,54 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/identity"
 	"github.com/vmware/pmd-next-gen/pkg/parser"
+	"github.com/vmware/pmd-next-gen/pkg/plugin"
 	"github.com/vmware/pmd-next-gen/pkg/system"
-	"github.com/vmware/pmd-next-gen/plugins/management"
-	"github.com/vmware/pmd-next-gen/plugins/network"
 	"github.com/vmware/pmd-next-gen/plugins/proc"
-	"github.com/vmware/pmd-next-gen/plugins/systemd"
-	"github.com/vmware/pmd-next-gen/plugins/tdnf"
 
 	"github.com/linuxkit/virtsock/pkg/vsock"
+	"github.com/vmware/pmd-next-gen/pkg/audit"
 	"github.com/vmware/pmd-next-gen/pkg/jobs"
 )
 
+// metricsPath is served outside of the versioned API where scrapers expect it
+const metricsPath = "/metrics"
+
 var httpSrv *http.Server
 
-func NewRouter() *mux.Router {
+func NewRouter(c *conf.Config) *mux.Router {
 	r := mux.NewRouter()
 	s := r.PathPrefix("/api/v1").Subrouter()
 
-	systemd.InitSystemd()
-	systemd.RegisterRouterSystemd(s)
-
-	management.RegisterRouterManagement(s)
-	network.RegisterRouterNetwork(s)
+	if err := audit.InitAudit(c); err != nil {
+		log.Warnf("Failed to initialize audit log: %v", err)
+	}
 
-	proc.RegisterRouterProc(s)
+	registerBuiltinPlugins()
+	plugin.Load(c, s)
+	plugin.RegisterRouterPlugins(s)
 
-	tdnf.RegisterRouterTdnf(s)
+	proc.RegisterRouterMetrics(r)
 
+	if err := jobs.InitJobs(c); err != nil {
+		log.Warnf("Failed to initialize persistent job store: %v", err)
+	}
 	jobs.RegisterRouterJobs(s)
 
+	audit.RegisterRouterAudit(s)
+
 	return r
 }
 
 func runUnixDomainHttpServer(c *conf.Config, r *mux.Router) error {
-	var credentialsContextKey = struct{}{}
-
 	if c.System.UseAuthentication {
-		r.Use(UnixDomainPeerCredential)
+		r.Use(exemptMetrics(c, UnixDomainPeerCredential))
+	}
+
+	if err := useAuditAndAuthorization(c, r); err != nil {
+		log.Errorf("Failed to load authorization policy: %v", err)
+		return err
 	}
 
 	httpSrv = &http.Server{
@@ -63,7 +73,7 @@ func runUnixDomainHttpServer(c *conf.Config, r *mux.Router) error {
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
 			file, _ := c.(*net.UnixConn).File()
 			credentials, _ := unix.GetsockoptUcred(int(file.Fd()), unix.SOL_SOCKET, unix.SO_PEERCRED)
-			return context.WithValue(ctx, credentialsContextKey, credentials)
+			return identity.NewPeerCredentialsContext(ctx, credentials)
 		},
 	}
 
@@ -86,6 +96,11 @@ func runUnixDomainHttpServer(c *conf.Config, r *mux.Router) error {
 }
 
 func runVSockHttpServer(c *conf.Config, r *mux.Router) error {
+	if err := useAuditAndAuthorization(c, r); err != nil {
+		log.Errorf("Failed to load authorization policy: %v", err)
+		return err
+	}
+
 	httpSrv = &http.Server{
 		Handler: r,
 	}
@@ -104,7 +119,12 @@ func runVSockHttpServer(c *conf.Config, r *mux.Router) error {
 
 func runWebHttpServer(c *conf.Config, r *mux.Router) error {
 	if c.System.UseAuthentication {
-		r.Use(AuthMiddleware)
+		r.Use(exemptMetrics(c, AuthMiddleware))
+	}
+
+	if err := useAuditAndAuthorization(c, r); err != nil {
+		log.Errorf("Failed to load authorization policy: %v", err)
+		return err
 	}
 
 	ip, port, _ := parser.ParseIpPort(c.Network.Listen)
@@ -151,6 +171,8 @@ func Run(c *conf.Config) error {
 		case sig := <-sigs:
 			log.Printf("Signal received='%v'. Shutting down photon-mgmtd ...", sig)
 
+			plugin.Shutdown()
+
 			if err := httpSrv.Shutdown(ctx); err != nil {
 				os.Exit(1)
 			}
@@ -160,14 +182,12 @@ func Run(c *conf.Config) error {
 		}
 	}()
 
-	r := NewRouter()
+	r := NewRouter(c)
 	if c.Network.ListenUnixSocket {
-		runUnixDomainHttpServer(c, r)
+		return runUnixDomainHttpServer(c, r)
 	} else if c.Network.ListenVSock {
-		runVSockHttpServer(c, r)
-	} else {
-		runWebHttpServer(c, r)
+		return runVSockHttpServer(c, r)
 	}
 
-	return nil
+	return runWebHttpServer(c, r)
 }
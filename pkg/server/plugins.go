@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package server
+
+import (
+	"github.com/vmware/pmd-next-gen/pkg/plugin"
+	"github.com/vmware/pmd-next-gen/plugins/baseline"
+	"github.com/vmware/pmd-next-gen/plugins/events"
+	"github.com/vmware/pmd-next-gen/plugins/journal"
+	"github.com/vmware/pmd-next-gen/plugins/management"
+	"github.com/vmware/pmd-next-gen/plugins/network"
+	"github.com/vmware/pmd-next-gen/plugins/proc"
+	"github.com/vmware/pmd-next-gen/plugins/state"
+	"github.com/vmware/pmd-next-gen/plugins/systemd"
+	"github.com/vmware/pmd-next-gen/plugins/tdnf"
+)
+
+// registerBuiltinPlugins adds the plugins compiled into photon-mgmtd, in the order their routes are registered.
+// The capabilities are among the ones photon-mgmtd keeps after dropping root: netlink, nft and net.*
+// sysctl changes need CAP_NET_ADMIN, switching network namespaces and the other sysctl keys need
+// CAP_SYS_ADMIN
+func registerBuiltinPlugins() {
+	plugin.Register(plugin.NewBuiltin("systemd", systemd.RegisterRouterSystemd))
+	plugin.Register(plugin.NewBuiltin("management", management.RegisterRouterManagement, "CAP_NET_ADMIN", "CAP_SYS_ADMIN"))
+	plugin.Register(plugin.NewBuiltin("network", network.RegisterRouterNetwork, "CAP_NET_ADMIN", "CAP_SYS_ADMIN"))
+	plugin.Register(plugin.NewBuiltin("proc", proc.RegisterRouterProc, "CAP_SYS_ADMIN"))
+	plugin.Register(plugin.NewBuiltin("tdnf", tdnf.RegisterRouterTdnf))
+	plugin.Register(plugin.NewBuiltin("state", state.RegisterRouterState, "CAP_NET_ADMIN", "CAP_SYS_ADMIN"))
+	plugin.Register(plugin.NewBuiltin("baseline", baseline.RegisterRouterBaseline, "CAP_NET_ADMIN"))
+	plugin.Register(plugin.NewBuiltin("events", events.RegisterRouterEvents))
+	plugin.Register(plugin.NewBuiltin("journal", journal.RegisterRouterJournal))
+}
@@ -4,6 +4,8 @@
 package system
 
 import (
+	"fmt"
+	"strings"
 	"syscall"
 
 	"github.com/syndtr/gocapability/capability"
@@ -35,3 +37,28 @@ func EnableKeepCapability() error {
 func DisableKeepCapability() error {
 	return unix.Prctl(unix.PR_SET_KEEPCAPS, 0, 0, 0, 0)
 }
+
+// HasCapability reports whether the effective set of the process holds the capability,
+// given as CAP_NET_ADMIN or net_admin
+func HasCapability(name string) (bool, error) {
+	n := strings.TrimPrefix(strings.ToLower(name), "cap_")
+
+	for _, c := range capability.List() {
+		if c.String() != n {
+			continue
+		}
+
+		caps, err := capability.NewPid2(0)
+		if err != nil {
+			return false, err
+		}
+
+		if err := caps.Load(); err != nil {
+			return false, err
+		}
+
+		return caps.Get(capability.EFFECTIVE, c), nil
+	}
+
+	return false, fmt.Errorf("unknown capability '%s'", name)
+}
@@ -62,6 +62,22 @@ func WriteFullFile(path string, lines []string) error {
 	return nil
 }
 
+// WriteFileAtomic writes to a temporary file first and renames it over path, so that a crash never
+// leaves a truncated file behind
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
 func ReadOneLineFile(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
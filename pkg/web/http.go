@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -77,10 +78,7 @@ func buildHttpRequest(ctx context.Context, method string, url string, headers ma
 	return httpRequest, nil
 }
 
-func DispatchSocketWithStatus(method, host string, url string, headers map[string]string, data interface{}) (*Response, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
-	defer cancel()
-
+func newHttpClient(host string, url string) (*http.Client, string) {
 	var httpClient *http.Client
 	if validator.IsEmpty(host) {
 		httpClient = &http.Client{
@@ -111,6 +109,15 @@ func DispatchSocketWithStatus(method, host string, url string, headers map[strin
 		}
 	}
 
+	return httpClient, url
+}
+
+func DispatchSocketWithStatus(method, host string, url string, headers map[string]string, data interface{}) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	httpClient, url := newHttpClient(host, url)
+
 	req, err := buildHttpRequest(ctx, method, url, headers, data)
 	if err != nil {
 		return nil, err
@@ -150,52 +157,92 @@ func DispatchSocket(method, host string, url string, headers map[string]string,
 	return r.Body, err
 }
 
+func acquireJobResult(host string, location string, token map[string]string, progress bool) ([]byte, error) {
+	dots := false
+	defer func() {
+		if dots {
+			fmt.Printf("\n")
+		}
+	}()
+
+	for {
+		s, err := DispatchSocket(http.MethodGet, host, location, token, nil)
+		if err != nil {
+			fmt.Printf("retrieving job status failed: %v\n", err)
+			return nil, err
+		}
+		status := StatusDesc{}
+		if err := json.Unmarshal(s, &status); err != nil {
+			fmt.Printf("Failed to decode json message: %v\n", err)
+			return nil, err
+		}
+		if status.Message.Status == "complete" {
+			msg, err := DispatchSocket(http.MethodGet, host, status.Message.Link, token, nil)
+			if err != nil {
+				fmt.Printf("retrieving result failed: %v\n", err)
+				return nil, err
+			}
+			return msg, nil
+		} else if status.Message.Status != "inprogress" {
+			return nil, errors.New("unexptected status")
+		}
+		time.Sleep(1 * time.Second)
+		if progress {
+			fmt.Printf(".")
+			dots = true
+		}
+	}
+}
+
 func DispatchAndWait(method, host string, url string, token map[string]string, data interface{}) ([]byte, error) {
-	var msg []byte
 	r, err := DispatchSocketWithStatus(method, host, url, token, data)
 	if err != nil {
 		return nil, err
 	}
-	if r.StatusCode == 202 {
-		if location := r.Header.Get("Location"); location != "" {
-			for {
-				s, err := DispatchSocket(http.MethodGet, host, location, token, nil)
-				if err != nil {
-					fmt.Printf("retrieving job status failed: %v\n", err)
-					return nil, err
-				}
-				status := StatusDesc{}
-				err = json.Unmarshal(s, &status)
-				if err != nil {
-					fmt.Printf("Failed to decode json message: %v\n", err)
-					return nil, err
-				}
-				if status.Message.Status == "complete" {
-					link := status.Message.Link
-					msg, err = DispatchSocket(http.MethodGet, host, link, token, nil)
-					if err != nil {
-						fmt.Printf("retrieving result failed: %v\n", err)
-						return nil, err
-					}
-					break
-				} else if status.Message.Status != "inprogress" {
-					err = errors.New("unexptected status")
-					return nil, err
-				}
-				time.Sleep(1 * time.Second)
-				fmt.Printf(".")
-			}
-			fmt.Printf("\n")
-		} else {
-			err = errors.New("no location in headers")
-			return nil, err
+
+	switch r.StatusCode {
+	case http.StatusAccepted:
+		location := r.Header.Get("Location")
+		if location == "" {
+			return nil, errors.New("no location in headers")
 		}
-	} else if r.StatusCode == 200 {
-		msg = r.Body
-	} else {
+		return acquireJobResult(host, location, token, true)
+	case http.StatusOK:
+		return r.Body, nil
+	}
+
+	return nil, errors.New(r.Status)
+}
+
+// DispatchAndStream works like DispatchAndWait but follows the events of a job while it runs
+// and hands them to onEvent. It falls back to polling when the event stream is unavailable.
+func DispatchAndStream(method, host string, url string, token map[string]string, data interface{}, onEvent func(event string, data []byte)) ([]byte, error) {
+	r, err := DispatchSocketWithStatus(method, host, url, token, data)
+	if err != nil {
 		return nil, err
 	}
-	return msg, err
+
+	switch r.StatusCode {
+	case http.StatusAccepted:
+		location := r.Header.Get("Location")
+		if location == "" {
+			return nil, errors.New("no location in headers")
+		}
+
+		events := "/api/v1/_jobs/" + path.Base(location) + "/events"
+		if err := DispatchEvents(host, events, token, func(event string, data []byte) bool {
+			onEvent(event, data)
+			return true
+		}); err != nil {
+			return acquireJobResult(host, location, token, true)
+		}
+
+		return acquireJobResult(host, location, token, false)
+	case http.StatusOK:
+		return r.Body, nil
+	}
+
+	return nil, errors.New(r.Status)
 }
 
 func BuildAuthTokenFromEnv() (map[string]string, error) {
@@ -46,6 +46,36 @@ func JSONResponseError(err error, w http.ResponseWriter) error {
 	return httpResponse(&m, w)
 }
 
+// JSONResponseErrorWithMessage reports the error together with what was produced before it failed
+func JSONResponseErrorWithMessage(err error, response interface{}, w http.ResponseWriter) error {
+	m := JSONResponseMessage{
+		Success: false,
+		Message: response,
+		Errors:  err.Error(),
+	}
+
+	return httpResponse(&m, w)
+}
+
+func JSONResponseErrorWithStatus(err error, status int, w http.ResponseWriter) error {
+	m := JSONResponseMessage{
+		Success: false,
+		Errors:  err.Error(),
+	}
+
+	j, err := json.Marshal(m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+
+	return nil
+}
+
 func JSONUnmarshal(msg []byte) (map[string]interface{}, error) {
 	m := make(map[string]interface{})
 
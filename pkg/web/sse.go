@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxEventSize = 1024 * 1024
+)
+
+// EventStream writes Server-Sent Events to a client
+type EventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func NewEventStream(w http.ResponseWriter) (*EventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &EventStream{
+		w:       w,
+		flusher: flusher,
+	}, nil
+}
+
+func (s *EventStream) Send(event string, data interface{}) error {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, j); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+// KeepAlive sends a comment so that idle connections are not dropped by proxies
+func (s *EventStream) KeepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+// DispatchEvents reads Server-Sent Events from url and hands each one to onEvent until the
+// server closes the stream or onEvent returns false
+func DispatchEvents(host string, url string, headers map[string]string, onEvent func(event string, data []byte) bool) error {
+	httpClient, url := newHttpClient(host, url)
+
+	req, err := buildHttpRequest(context.Background(), http.MethodGet, url, headers, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not complete HTTP request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := decodeHttpResponse(resp)
+		if err != nil {
+			return err
+		}
+
+		m := JSONResponseMessage{}
+		if err := json.Unmarshal(body, &m); err == nil && !m.Success {
+			return errors.New(m.Errors)
+		}
+
+		return errors.New("not an event stream")
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 4096), maxEventSize)
+
+	var event string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				if !onEvent(event, data.Bytes()) {
+					return nil
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return scanner.Err()
+}
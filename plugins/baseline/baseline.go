@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package baseline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/system"
+	"github.com/vmware/pmd-next-gen/plugins/management"
+	"github.com/vmware/pmd-next-gen/plugins/management/sysctl"
+	"github.com/vmware/pmd-next-gen/plugins/network"
+	"github.com/vmware/pmd-next-gen/plugins/network/firewall"
+	"github.com/vmware/pmd-next-gen/plugins/systemd"
+)
+
+const (
+	baselineDir = "baseline"
+)
+
+type Info struct {
+	Version    int       `json:"Version"`
+	CapturedAt time.Time `json:"CapturedAt"`
+	CapturedBy string    `json:"CapturedBy"`
+}
+
+// Baseline is a versioned snapshot of the configuration of the host
+type Baseline struct {
+	Info
+
+	System  *management.Describe `json:"System"`
+	Network *network.Describe    `json:"Network"`
+	Sysctl  map[string]string    `json:"Sysctl"`
+	Nft     string               `json:"Nft"`
+	Units   []string             `json:"Units"`
+}
+
+var lock sync.Mutex
+
+func baselinePath() string {
+	return path.Join(conf.StateDirPath, baselineDir)
+}
+
+func versionPath(version int) string {
+	return path.Join(baselinePath(), strconv.Itoa(version)+".json")
+}
+
+// acquire snapshots the subsystems of the host
+func acquire(ctx context.Context) (*Baseline, error) {
+	var err error
+	b := Baseline{}
+
+	b.System, err = management.AcquireDescribe(ctx)
+	if err != nil {
+		log.Errorf("Failed to describe system: %v", err)
+		return nil, err
+	}
+
+	b.Network, err = network.AcquireDescribe(ctx)
+	if err != nil {
+		log.Errorf("Failed to describe network: %v", err)
+		return nil, err
+	}
+
+	b.Sysctl, err = sysctl.AcquirePattern("")
+	if err != nil {
+		log.Errorf("Failed to acquire sysctl parameters: %v", err)
+		return nil, err
+	}
+
+	b.Nft, err = firewall.AcquireRuleset()
+	if err != nil {
+		log.Errorf("Failed to acquire nft ruleset: %v", err)
+		return nil, err
+	}
+
+	b.Units, err = systemd.AcquireEnabledUnits(ctx)
+	if err != nil {
+		log.Errorf("Failed to acquire enabled units: %v", err)
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// AcquireVersions returns the captured baselines, oldest first
+func AcquireVersions() ([]Info, error) {
+	matches, err := filepath.Glob(path.Join(baselinePath(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	l := []Info{}
+	for _, f := range matches {
+		v, err := strconv.Atoi(strings.TrimSuffix(path.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+
+		b, err := AcquireBaseline(v)
+		if err != nil {
+			log.Warnf("Skipping corrupt baseline file='%s': %v", f, err)
+			continue
+		}
+
+		l = append(l, b.Info)
+	}
+
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Version < l[j].Version
+	})
+
+	return l, nil
+}
+
+// AcquireBaseline loads a baseline. Version 0 selects the latest one
+func AcquireBaseline(version int) (*Baseline, error) {
+	if version == 0 {
+		l, err := AcquireVersions()
+		if err != nil {
+			return nil, err
+		}
+		if len(l) == 0 {
+			return nil, errors.New("no baseline captured")
+		}
+
+		version = l[len(l)-1].Version
+	}
+
+	c, err := os.ReadFile(versionPath(version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("baseline not found")
+		}
+		return nil, err
+	}
+
+	b := Baseline{}
+	if err := json.Unmarshal(c, &b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// Capture snapshots the host and saves it as the next baseline version
+func Capture(ctx context.Context, owner string) (*Info, error) {
+	b, err := acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := system.CreateDirectoryNested(baselinePath(), 0750); err != nil {
+		return nil, err
+	}
+
+	l, err := AcquireVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	b.Version = 1
+	if len(l) > 0 {
+		b.Version = l[len(l)-1].Version + 1
+	}
+	b.CapturedAt = time.Now()
+	b.CapturedBy = owner
+
+	c, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := system.WriteFileAtomic(versionPath(b.Version), c, 0640); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Captured baseline version='%d'", b.Version)
+	return &b.Info, nil
+}
+
+// AcquireDrift compares the host against a baseline. Version 0 selects the latest one
+func AcquireDrift(ctx context.Context, version int) (*Drift, error) {
+	b, err := AcquireBaseline(version)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.diff(c)
+}
@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DriftAdded   = "added"
+	DriftRemoved = "removed"
+	DriftChanged = "changed"
+)
+
+// Keys which change on their own and are not drift
+var volatileKeys = map[string]bool{
+	"Statistics":        true,
+	"PreferedLft":       true,
+	"ValidLft":          true,
+	"TimeUSec":          true,
+	"RTCTimeUSec":       true,
+	"NTPSynchronized":   true,
+	"NNames":            true,
+	"SystemState":       true,
+	"UserStat":          true,
+	"VirtualMemoryStat": true,
+	"uptime":            true,
+	"bootTime":          true,
+	"procs":             true,
+}
+
+// Kernel counters exported through /proc/sys
+var volatileSysctlPrefixes = []string{
+	"fs.dentry-state",
+	"fs.file-nr",
+	"fs.inode-nr",
+	"fs.inode-state",
+	"fs.aio-nr",
+	"fs.quota.",
+	"kernel.random.",
+	"kernel.ns_last_pid",
+	"kernel.pty.nr",
+	"net.netfilter.nf_conntrack_count",
+}
+
+// Keys identifying the elements of a list, tried in order
+var identityKeys = []string{"Name", "Unit", "LinkName", "Index", "Ifindex"}
+
+type Difference struct {
+	Path     string      `json:"Path"`
+	Kind     string      `json:"Kind"`
+	Baseline interface{} `json:"Baseline,omitempty"`
+	Current  interface{} `json:"Current,omitempty"`
+}
+
+type Drift struct {
+	Version    int                     `json:"Version"`
+	CapturedAt time.Time               `json:"CapturedAt"`
+	Drifted    bool                    `json:"Drifted"`
+	Subsystems map[string][]Difference `json:"Subsystems"`
+}
+
+// normalize converts v into the generic form produced by encoding/json
+func normalize(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var n interface{}
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+func canonical(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func joinPath(p string, k string) string {
+	if p == "" {
+		return k
+	}
+
+	return p + "." + k
+}
+
+// elementKey returns the identity of a list element, or false when it has none
+func elementKey(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	for _, k := range identityKeys {
+		if id, ok := m[k]; ok && id != nil && id != "" {
+			return fmt.Sprintf("%v", id), true
+		}
+	}
+
+	return "", false
+}
+
+func diffValue(p string, a interface{}, b interface{}, d []Difference) []Difference {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			return diffMap(p, av, bv, d)
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			return diffList(p, av, bv, d)
+		}
+	}
+
+	if canonical(a) != canonical(b) {
+		d = append(d, Difference{Path: p, Kind: DriftChanged, Baseline: a, Current: b})
+	}
+
+	return d
+}
+
+func diffMap(p string, a map[string]interface{}, b map[string]interface{}, d []Difference) []Difference {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		if !volatileKeys[k] {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		av, aok := a[k]
+		bv, bok := b[k]
+		switch {
+		case !aok:
+			d = append(d, Difference{Path: joinPath(p, k), Kind: DriftAdded, Current: bv})
+		case !bok:
+			d = append(d, Difference{Path: joinPath(p, k), Kind: DriftRemoved, Baseline: av})
+		default:
+			d = diffValue(joinPath(p, k), av, bv, d)
+		}
+	}
+
+	return d
+}
+
+// diffList matches elements by their identity key and falls back to comparing the lists as sets
+func diffList(p string, a []interface{}, b []interface{}, d []Difference) []Difference {
+	index := func(l []interface{}) (map[string]interface{}, []string, bool) {
+		m := make(map[string]interface{})
+		var keys []string
+		for _, v := range l {
+			k, ok := elementKey(v)
+			if !ok {
+				return nil, nil, false
+			}
+			if _, dup := m[k]; dup {
+				return nil, nil, false
+			}
+			m[k] = v
+			keys = append(keys, k)
+		}
+		return m, keys, true
+	}
+
+	am, akeys, aok := index(a)
+	bm, bkeys, bok := index(b)
+	if aok && bok {
+		for _, k := range akeys {
+			if bv, ok := bm[k]; ok {
+				d = diffValue(p+"["+k+"]", am[k], bv, d)
+			} else {
+				d = append(d, Difference{Path: p + "[" + k + "]", Kind: DriftRemoved, Baseline: am[k]})
+			}
+		}
+		for _, k := range bkeys {
+			if _, ok := am[k]; !ok {
+				d = append(d, Difference{Path: p + "[" + k + "]", Kind: DriftAdded, Current: bm[k]})
+			}
+		}
+
+		return d
+	}
+
+	set := func(l []interface{}) map[string]interface{} {
+		m := make(map[string]interface{})
+		for _, v := range l {
+			m[canonical(stripVolatile(v))] = v
+		}
+		return m
+	}
+
+	as, bs := set(a), set(b)
+	for _, v := range a {
+		if _, ok := bs[canonical(stripVolatile(v))]; !ok {
+			d = append(d, Difference{Path: p + "[]", Kind: DriftRemoved, Baseline: v})
+		}
+	}
+	for _, v := range b {
+		if _, ok := as[canonical(stripVolatile(v))]; !ok {
+			d = append(d, Difference{Path: p + "[]", Kind: DriftAdded, Current: v})
+		}
+	}
+
+	return d
+}
+
+func stripVolatile(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range t {
+			if !volatileKeys[k] {
+				m[k] = stripVolatile(e)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			l = append(l, stripVolatile(e))
+		}
+		return l
+	}
+
+	return v
+}
+
+func diffStructured(a interface{}, b interface{}) ([]Difference, error) {
+	na, err := normalize(a)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := normalize(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValue("", na, nb, []Difference{}), nil
+}
+
+func volatileSysctl(key string) bool {
+	for _, p := range volatileSysctlPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func diffSysctl(a map[string]string, b map[string]string) []Difference {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		if !volatileSysctl(k) {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	d := []Difference{}
+	for _, k := range sorted {
+		av, aok := a[k]
+		bv, bok := b[k]
+		switch {
+		case !aok:
+			d = append(d, Difference{Path: k, Kind: DriftAdded, Current: bv})
+		case !bok:
+			d = append(d, Difference{Path: k, Kind: DriftRemoved, Baseline: av})
+		case strings.Join(strings.Fields(av), " ") != strings.Join(strings.Fields(bv), " "):
+			d = append(d, Difference{Path: k, Kind: DriftChanged, Baseline: av, Current: bv})
+		}
+	}
+
+	return d
+}
+
+// diffLines compares two lists of lines as sets
+func diffLines(p string, a []string, b []string) []Difference {
+	as := make(map[string]bool)
+	for _, l := range a {
+		as[l] = true
+	}
+	bs := make(map[string]bool)
+	for _, l := range b {
+		bs[l] = true
+	}
+
+	d := []Difference{}
+	for _, l := range a {
+		if !bs[l] {
+			d = append(d, Difference{Path: p, Kind: DriftRemoved, Baseline: l})
+		}
+	}
+	for _, l := range b {
+		if !as[l] {
+			d = append(d, Difference{Path: p, Kind: DriftAdded, Current: l})
+		}
+	}
+
+	return d
+}
+
+func rulesetLines(s string) []string {
+	var l []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != "}" {
+			l = append(l, line)
+		}
+	}
+
+	return l
+}
+
+func (b *Baseline) diff(c *Baseline) (*Drift, error) {
+	var err error
+	d := Drift{
+		Version:    b.Version,
+		CapturedAt: b.CapturedAt,
+		Subsystems: make(map[string][]Difference),
+	}
+
+	d.Subsystems["system"], err = diffStructured(b.System, c.System)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Subsystems["network"], err = diffStructured(b.Network, c.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Subsystems["sysctl"] = diffSysctl(b.Sysctl, c.Sysctl)
+	d.Subsystems["nft"] = diffLines("ruleset", rulesetLines(b.Nft), rulesetLines(c.Nft))
+	d.Subsystems["units"] = diffLines("enabled", b.Units, c.Units)
+
+	for _, l := range d.Subsystems {
+		if len(l) > 0 {
+			d.Drifted = true
+		}
+	}
+
+	return &d, nil
+}
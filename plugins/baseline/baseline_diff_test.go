@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package baseline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffUnchanged(t *testing.T) {
+	b := Baseline{
+		Sysctl: map[string]string{
+			"net.ipv4.ip_forward":       "1",
+			"net.ipv4.tcp_rmem":         "4096\t131072\t6291456",
+			"fs.file-nr":                "1024\t0\t9223372036854775807",
+			"kernel.random.boot_id":     "1b0e2c56-2f2b-4a51-a8c5-0d0f6d7a6d2e",
+			"net.ipv6.conf.all.forward": "0",
+		},
+		Nft:   "table inet filter {\n\tchain input {\n\t\ttype filter hook input priority 0;\n\t}\n}\n",
+		Units: []string{"sshd.service", "systemd-networkd.service"},
+	}
+
+	c := b
+	c.Sysctl = map[string]string{
+		"net.ipv4.ip_forward":       "1",
+		"net.ipv4.tcp_rmem":         "4096 131072 6291456",
+		"fs.file-nr":                "2048\t0\t9223372036854775807",
+		"kernel.random.boot_id":     "9d3f1c20-7a41-4e0e-b6b2-3c1d0f3e4a5b",
+		"net.ipv6.conf.all.forward": "0",
+	}
+	c.Nft = "table inet filter {\n  chain input {\n    type filter hook input priority 0;\n  }\n}"
+	c.Units = []string{"systemd-networkd.service", "sshd.service"}
+
+	d, err := b.diff(&c)
+	if err != nil {
+		t.Fatalf("Failed to diff baselines: %v", err)
+	}
+
+	if d.Drifted {
+		t.Errorf("Counters, whitespace and ordering reported as drift: %+v", d.Subsystems)
+	}
+}
+
+func TestDiffDrifted(t *testing.T) {
+	b := Baseline{
+		Sysctl: map[string]string{
+			"net.ipv4.ip_forward": "0",
+			"vm.swappiness":       "60",
+		},
+		Nft:   "table inet filter {\n\tchain input {\n\t\ttcp dport 22 accept\n\t}\n}\n",
+		Units: []string{"sshd.service", "chronyd.service"},
+	}
+
+	c := Baseline{
+		Sysctl: map[string]string{
+			"net.ipv4.ip_forward": "1",
+			"kernel.sysrq":        "1",
+		},
+		Nft:   "table inet filter {\n\tchain input {\n\t\ttcp dport 22 accept\n\t\ttcp dport 80 accept\n\t}\n}\n",
+		Units: []string{"sshd.service", "nginx.service"},
+	}
+
+	d, err := b.diff(&c)
+	if err != nil {
+		t.Fatalf("Failed to diff baselines: %v", err)
+	}
+
+	if !d.Drifted {
+		t.Fatalf("Drift not reported")
+	}
+
+	want := map[string][]Difference{
+		"sysctl": {
+			{Path: "kernel.sysrq", Kind: DriftAdded, Current: "1"},
+			{Path: "net.ipv4.ip_forward", Kind: DriftChanged, Baseline: "0", Current: "1"},
+			{Path: "vm.swappiness", Kind: DriftRemoved, Baseline: "60"},
+		},
+		"nft": {
+			{Path: "ruleset", Kind: DriftAdded, Current: "tcp dport 80 accept"},
+		},
+		"units": {
+			{Path: "enabled", Kind: DriftRemoved, Baseline: "chronyd.service"},
+			{Path: "enabled", Kind: DriftAdded, Current: "nginx.service"},
+		},
+	}
+
+	for s, l := range want {
+		if !reflect.DeepEqual(d.Subsystems[s], l) {
+			t.Errorf("Drift of %s = %+v, want %+v", s, d.Subsystems[s], l)
+		}
+	}
+}
+
+type testLink struct {
+	Name       string
+	MTU        int
+	Addresses  []string
+	Statistics map[string]int
+}
+
+func TestDiffStructured(t *testing.T) {
+	before := map[string]interface{}{
+		"Hostname": "photon",
+		"Links": []testLink{
+			{Name: "lo", MTU: 65536, Addresses: []string{"127.0.0.1/8"}, Statistics: map[string]int{"RxPackets": 10}},
+			{Name: "eth0", MTU: 1500, Addresses: []string{"192.0.2.10/24", "2001:db8::10/64"}},
+			{Name: "eth1", MTU: 1500},
+		},
+	}
+
+	after := map[string]interface{}{
+		"Hostname": "photon",
+		"Links": []testLink{
+			{Name: "eth0", MTU: 9000, Addresses: []string{"2001:db8::10/64", "192.0.2.20/24"}},
+			{Name: "lo", MTU: 65536, Addresses: []string{"127.0.0.1/8"}, Statistics: map[string]int{"RxPackets": 99}},
+			{Name: "wg0", MTU: 1420},
+		},
+		"Timezone": "UTC",
+	}
+
+	d, err := diffStructured(before, after)
+	if err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+
+	paths := map[string]string{}
+	for _, e := range d {
+		paths[e.Path+" "+e.Kind] = e.Kind
+	}
+
+	for _, p := range []string{
+		"Links[eth0].MTU changed",
+		"Links[eth0].Addresses[] removed",
+		"Links[eth0].Addresses[] added",
+		"Links[eth1] removed",
+		"Links[wg0] added",
+		"Timezone added",
+	} {
+		if _, ok := paths[p]; !ok {
+			t.Errorf("Missing difference '%s' in %+v", p, d)
+		}
+	}
+
+	if len(d) != 6 {
+		t.Errorf("Got %d differences, want 6: %+v", len(d), d)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package baseline
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/identity"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func parseVersion(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("version")
+	if s == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, errors.New("invalid version")
+	}
+
+	return v, nil
+}
+
+func routerCaptureBaseline(w http.ResponseWriter, r *http.Request) {
+	info, err := Capture(r.Context(), identity.FromRequest(r).String())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(info, w)
+}
+
+func routerAcquireVersions(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireVersions()
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func routerAcquireDrift(w http.ResponseWriter, r *http.Request) {
+	v, err := parseVersion(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	d, err := AcquireDrift(r.Context(), v)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(d, w)
+}
+
+func RegisterRouterBaseline(router *mux.Router) {
+	n := router.PathPrefix("/baseline").Subrouter()
+
+	n.HandleFunc("", routerAcquireVersions).Methods("GET")
+	n.HandleFunc("/capture", routerCaptureBaseline).Methods("POST")
+	n.HandleFunc("/drift", routerAcquireDrift).Methods("GET")
+}
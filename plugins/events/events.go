@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TopicLink     = "link"
+	TopicAddress  = "address"
+	TopicRoute    = "route"
+	TopicUnit     = "unit"
+	TopicSession  = "session"
+	TopicHostname = "hostname"
+	TopicTimeDate = "timedate"
+
+	TypeNew     = "new"
+	TypeDel     = "del"
+	TypeChanged = "changed"
+
+	subscriberBuffer = 256
+)
+
+var topics = map[string]bool{
+	TopicLink:     true,
+	TopicAddress:  true,
+	TopicRoute:    true,
+	TopicUnit:     true,
+	TopicSession:  true,
+	TopicHostname: true,
+	TopicTimeDate: true,
+}
+
+type Event struct {
+	Seq   uint64      `json:"Seq"`
+	Topic string      `json:"Topic"`
+	Type  string      `json:"Type"`
+	Name  string      `json:"Name,omitempty"`
+	Data  interface{} `json:"Data,omitempty"`
+	Time  time.Time   `json:"Time"`
+}
+
+type Subscriber struct {
+	C      chan Event
+	topics map[string]bool
+}
+
+// Hub fans out the events of all sources to the subscribers. The sources run only
+// while somebody is subscribed
+type Hub struct {
+	mutex       sync.Mutex
+	seq         uint64
+	subscribers map[*Subscriber]struct{}
+	cancel      context.CancelFunc
+}
+
+var hub = &Hub{
+	subscribers: make(map[*Subscriber]struct{}),
+}
+
+// Topics returns the names of the supported topics
+func Topics() []string {
+	l := make([]string, 0, len(topics))
+	for t := range topics {
+		l = append(l, t)
+	}
+	sort.Strings(l)
+
+	return l
+}
+
+// ParseTopics validates a list of topics. Elements may hold comma separated topics.
+// An empty list selects all topics
+func ParseTopics(l []string) (map[string]bool, error) {
+	m := make(map[string]bool)
+	for _, e := range l {
+		for _, t := range strings.Split(e, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			if !topics[t] {
+				return nil, fmt.Errorf("unknown topic '%s', supported topics are %s", t, strings.Join(Topics(), ", "))
+			}
+			m[t] = true
+		}
+	}
+
+	if len(m) == 0 {
+		for t := range topics {
+			m[t] = true
+		}
+	}
+
+	return m, nil
+}
+
+func Subscribe(t map[string]bool) *Subscriber {
+	s := &Subscriber{
+		C:      make(chan Event, subscriberBuffer),
+		topics: t,
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.subscribers[s] = struct{}{}
+	if hub.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		hub.cancel = cancel
+
+		startSources(ctx)
+	}
+
+	return s
+}
+
+func Unsubscribe(s *Subscriber) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if _, ok := hub.subscribers[s]; ok {
+		delete(hub.subscribers, s)
+		close(s.C)
+	}
+
+	if len(hub.subscribers) == 0 && hub.cancel != nil {
+		hub.cancel()
+		hub.cancel = nil
+	}
+}
+
+func publish(topic string, kind string, name string, data interface{}) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.seq++
+	e := Event{
+		Seq:   hub.seq,
+		Topic: topic,
+		Type:  kind,
+		Name:  name,
+		Data:  data,
+		Time:  time.Now(),
+	}
+
+	for s := range hub.subscribers {
+		if !s.topics[topic] {
+			continue
+		}
+
+		select {
+		case s.C <- e:
+		default:
+			// Slow readers are dropped rather than stalling the sources
+			log.Warnf("Dropping slow event subscriber")
+			delete(hub.subscribers, s)
+			close(s.C)
+		}
+	}
+}
+
+// startSources must be called with the mutex held
+func startSources(ctx context.Context) {
+	if err := watchNetlink(ctx); err != nil {
+		log.Errorf("Failed to subscribe to netlink updates: %v", err)
+	}
+
+	if err := watchBus(ctx); err != nil {
+		log.Errorf("Failed to subscribe to bus signals: %v", err)
+	}
+}
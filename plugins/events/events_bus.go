@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package events
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/bus"
+)
+
+const (
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+
+	systemdInterface = "org.freedesktop.systemd1"
+	systemdUnitPath  = "/org/freedesktop/systemd1/unit"
+	unitInterface    = "org.freedesktop.systemd1.Unit"
+
+	logindInterface = "org.freedesktop.login1.Manager"
+	logindPath      = "/org/freedesktop/login1"
+
+	hostnameInterface = "org.freedesktop.hostname1"
+	hostnamePath      = "/org/freedesktop/hostname1"
+
+	timedateInterface = "org.freedesktop.timedate1"
+	timedatePath      = "/org/freedesktop/timedate1"
+)
+
+type UnitEvent struct {
+	ActiveState string `json:"ActiveState,omitempty"`
+	SubState    string `json:"SubState,omitempty"`
+}
+
+type SessionEvent struct {
+	Path string `json:"Path"`
+}
+
+// unescapeUnitPath reverses the escaping systemd applies to unit names in object paths
+func unescapeUnitPath(p dbus.ObjectPath) string {
+	s := path.Base(string(p))
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func propertyValues(m map[string]dbus.Variant) map[string]interface{} {
+	v := make(map[string]interface{})
+	for k, e := range m {
+		v[k] = e.Value()
+	}
+
+	return v
+}
+
+func onPropertiesChanged(s *dbus.Signal) {
+	if len(s.Body) < 2 {
+		return
+	}
+
+	iface, _ := s.Body[0].(string)
+	changed, _ := s.Body[1].(map[string]dbus.Variant)
+
+	switch {
+	case iface == unitInterface:
+		u := UnitEvent{}
+		if v, ok := changed["ActiveState"]; ok {
+			u.ActiveState, _ = v.Value().(string)
+		}
+		if v, ok := changed["SubState"]; ok {
+			u.SubState, _ = v.Value().(string)
+		}
+		if u.ActiveState == "" && u.SubState == "" {
+			return
+		}
+
+		publish(TopicUnit, TypeChanged, unescapeUnitPath(s.Path), u)
+	case iface == hostnameInterface:
+		publish(TopicHostname, TypeChanged, "", propertyValues(changed))
+	case iface == timedateInterface:
+		publish(TopicTimeDate, TypeChanged, "", propertyValues(changed))
+	}
+}
+
+func onSession(s *dbus.Signal) {
+	if len(s.Body) < 2 {
+		return
+	}
+
+	kind := TypeNew
+	if s.Name == logindInterface+".SessionRemoved" {
+		kind = TypeDel
+	}
+
+	id, _ := s.Body[0].(string)
+	p, _ := s.Body[1].(dbus.ObjectPath)
+
+	publish(TopicSession, kind, id, SessionEvent{Path: string(p)})
+}
+
+func watchBus(ctx context.Context) error {
+	conn, err := bus.SystemBusPrivateConn()
+	if err != nil {
+		return err
+	}
+
+	matches := [][]dbus.MatchOption{
+		{
+			dbus.WithMatchPathNamespace(systemdUnitPath),
+			dbus.WithMatchInterface(propertiesInterface),
+			dbus.WithMatchMember("PropertiesChanged"),
+		},
+		{
+			dbus.WithMatchObjectPath(logindPath),
+			dbus.WithMatchInterface(logindInterface),
+			dbus.WithMatchMember("SessionNew"),
+		},
+		{
+			dbus.WithMatchObjectPath(logindPath),
+			dbus.WithMatchInterface(logindInterface),
+			dbus.WithMatchMember("SessionRemoved"),
+		},
+		{
+			dbus.WithMatchObjectPath(hostnamePath),
+			dbus.WithMatchInterface(propertiesInterface),
+			dbus.WithMatchMember("PropertiesChanged"),
+		},
+		{
+			dbus.WithMatchObjectPath(timedatePath),
+			dbus.WithMatchInterface(propertiesInterface),
+			dbus.WithMatchMember("PropertiesChanged"),
+		},
+	}
+
+	for _, m := range matches {
+		if err := conn.AddMatchSignalContext(ctx, m...); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	// systemd emits unit signals only while at least one client is subscribed
+	if err := conn.Object(systemdInterface, "/org/freedesktop/systemd1").CallWithContext(ctx, systemdInterface+".Manager.Subscribe", 0).Err; err != nil {
+		log.Warnf("Failed to subscribe to systemd signals: %v", err)
+	}
+
+	signals := make(chan *dbus.Signal, subscriberBuffer)
+	conn.Signal(signals)
+
+	go func() {
+		<-ctx.Done()
+		conn.RemoveSignal(signals)
+		conn.Close()
+		close(signals)
+	}()
+
+	go func() {
+		for s := range signals {
+			switch s.Name {
+			case propertiesInterface + ".PropertiesChanged":
+				onPropertiesChanged(s)
+			case logindInterface + ".SessionNew", logindInterface + ".SessionRemoved":
+				onSession(s)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package events
+
+import (
+	"context"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type LinkEvent struct {
+	Index        int    `json:"Index"`
+	Name         string `json:"Name"`
+	OperState    string `json:"OperState"`
+	Flags        string `json:"Flags"`
+	MTU          int    `json:"MTU"`
+	HardwareAddr string `json:"HardwareAddr,omitempty"`
+}
+
+type AddressEvent struct {
+	Address   string `json:"Address"`
+	LinkIndex int    `json:"LinkIndex"`
+	Scope     int    `json:"Scope"`
+}
+
+type RouteEvent struct {
+	Destination string `json:"Destination"`
+	Gateway     string `json:"Gateway,omitempty"`
+	LinkIndex   int    `json:"LinkIndex"`
+	Table       int    `json:"Table"`
+	Protocol    int    `json:"Protocol"`
+	Scope       int    `json:"Scope"`
+}
+
+func linkName(index int) string {
+	l, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return strconv.Itoa(index)
+	}
+
+	return l.Attrs().Name
+}
+
+func onError(err error) {
+	log.Errorf("Failed to receive netlink update: %v", err)
+}
+
+func watchNetlink(ctx context.Context) error {
+	links := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribeWithOptions(links, ctx.Done(), netlink.LinkSubscribeOptions{ErrorCallback: onError}); err != nil {
+		return err
+	}
+
+	addresses := make(chan netlink.AddrUpdate)
+	if err := netlink.AddrSubscribeWithOptions(addresses, ctx.Done(), netlink.AddrSubscribeOptions{ErrorCallback: onError}); err != nil {
+		return err
+	}
+
+	routes := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribeWithOptions(routes, ctx.Done(), netlink.RouteSubscribeOptions{ErrorCallback: onError}); err != nil {
+		return err
+	}
+
+	go func() {
+		for u := range links {
+			kind := TypeNew
+			if u.Header.Type == unix.RTM_DELLINK {
+				kind = TypeDel
+			}
+
+			a := u.Link.Attrs()
+			publish(TopicLink, kind, a.Name, LinkEvent{
+				Index:        a.Index,
+				Name:         a.Name,
+				OperState:    a.OperState.String(),
+				Flags:        a.Flags.String(),
+				MTU:          a.MTU,
+				HardwareAddr: a.HardwareAddr.String(),
+			})
+		}
+	}()
+
+	go func() {
+		for u := range addresses {
+			kind := TypeNew
+			if !u.NewAddr {
+				kind = TypeDel
+			}
+
+			publish(TopicAddress, kind, linkName(u.LinkIndex), AddressEvent{
+				Address:   u.LinkAddress.String(),
+				LinkIndex: u.LinkIndex,
+				Scope:     u.Scope,
+			})
+		}
+	}()
+
+	go func() {
+		for u := range routes {
+			kind := TypeNew
+			if u.Type == unix.RTM_DELROUTE {
+				kind = TypeDel
+			}
+
+			dst := "default"
+			if u.Dst != nil {
+				dst = u.Dst.String()
+			}
+
+			r := RouteEvent{
+				Destination: dst,
+				LinkIndex:   u.LinkIndex,
+				Table:       u.Table,
+				Protocol:    u.Protocol,
+				Scope:       int(u.Scope),
+			}
+			if u.Gw != nil {
+				r.Gateway = u.Gw.String()
+			}
+
+			publish(TopicRoute, kind, dst, r)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package events
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+)
+
+// routerAcquireEvents streams the system changes as Server-Sent Events named after their topic.
+// The stream is limited to the topics given with ?topic=link,unit or repeated topic parameters
+func routerAcquireEvents(w http.ResponseWriter, r *http.Request) {
+	t, err := ParseTopics(r.URL.Query()["topic"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	s := Subscribe(t)
+	defer Unsubscribe(s)
+
+	stream, err := web.NewEventStream(w)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	k := time.NewTicker(keepAliveInterval)
+	defer k.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-k.C:
+			if err := stream.KeepAlive(); err != nil {
+				return
+			}
+		case e, ok := <-s.C:
+			if !ok {
+				return
+			}
+			if err := stream.Send(e.Topic, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func routerAcquireTopics(w http.ResponseWriter, r *http.Request) {
+	web.JSONResponse(Topics(), w)
+}
+
+func RegisterRouterEvents(router *mux.Router) {
+	n := router.PathPrefix("/events").Subrouter()
+
+	n.HandleFunc("", routerAcquireEvents).Methods("GET")
+	n.HandleFunc("/topics", routerAcquireTopics).Methods("GET")
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultLines = 100
+	MaxLines     = 10000
+
+	maxEntrySize = 1024 * 1024
+)
+
+var (
+	journalctlPath = "journalctl"
+
+	unitRegexp     = regexp.MustCompile(`^[a-zA-Z0-9:_.\\@*?\[\]-]+$`)
+	priorityRegexp = regexp.MustCompile(`^(emerg|alert|crit|err|warning|notice|info|debug|[0-7])(\.\.(emerg|alert|crit|err|warning|notice|info|debug|[0-7]))?$`)
+	bootRegexp     = regexp.MustCompile(`^(-?[0-9]+|[0-9a-f]{32})$`)
+	idRegexp       = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	timeRegexp     = regexp.MustCompile(`^[a-zA-Z0-9 :.+-]+$`)
+)
+
+// Query selects journal entries. Entries are returned oldest first
+type Query struct {
+	Unit     string
+	Priority string
+	Boot     string
+	Since    string
+	Until    string
+	Cursor   string
+	Lines    int
+
+	// InvocationId selects what the processes of one run of a unit logged
+	InvocationId string
+}
+
+type Entry struct {
+	Cursor     string    `json:"Cursor"`
+	Time       time.Time `json:"Time"`
+	BootId     string    `json:"BootId"`
+	Hostname   string    `json:"Hostname"`
+	Unit       string    `json:"Unit,omitempty"`
+	Identifier string    `json:"Identifier,omitempty"`
+	Pid        int       `json:"Pid,omitempty"`
+	Priority   int       `json:"Priority"`
+	Message    string    `json:"Message"`
+}
+
+// Page holds entries and the cursor to pass to fetch the entries following them
+type Page struct {
+	Entries []Entry `json:"Entries"`
+	Cursor  string  `json:"Cursor"`
+	More    bool    `json:"More"`
+}
+
+func (q *Query) Validate() error {
+	switch {
+	case q.Unit != "" && !unitRegexp.MatchString(q.Unit):
+		return fmt.Errorf("invalid unit '%s'", q.Unit)
+	case q.InvocationId != "" && !idRegexp.MatchString(q.InvocationId):
+		return fmt.Errorf("invalid invocation id '%s'", q.InvocationId)
+	case q.Priority != "" && !priorityRegexp.MatchString(q.Priority):
+		return fmt.Errorf("invalid priority '%s'", q.Priority)
+	case q.Boot != "" && !bootRegexp.MatchString(q.Boot):
+		return fmt.Errorf("invalid boot '%s'", q.Boot)
+	case q.Since != "" && !timeRegexp.MatchString(q.Since):
+		return fmt.Errorf("invalid since '%s'", q.Since)
+	case q.Until != "" && !timeRegexp.MatchString(q.Until):
+		return fmt.Errorf("invalid until '%s'", q.Until)
+	case strings.ContainsAny(q.Cursor, "\n\x00"):
+		return errors.New("invalid cursor")
+	case q.Lines < 1 || q.Lines > MaxLines:
+		return fmt.Errorf("lines must be between 1 and %d", MaxLines)
+	}
+
+	return nil
+}
+
+// args builds the journalctl arguments. Values are passed as --opt=value so that they are never taken for options
+func (q *Query) args() []string {
+	args := []string{"--output=json", "--no-pager", "--quiet"}
+
+	if q.Unit != "" {
+		args = append(args, "--unit="+q.Unit)
+	}
+	if q.Priority != "" {
+		args = append(args, "--priority="+q.Priority)
+	}
+	if q.Boot != "" {
+		args = append(args, "--boot="+q.Boot)
+	}
+	if q.Since != "" {
+		args = append(args, "--since="+q.Since)
+	}
+	if q.Until != "" {
+		args = append(args, "--until="+q.Until)
+	}
+	if q.Cursor != "" {
+		args = append(args, "--after-cursor="+q.Cursor)
+	}
+	if q.InvocationId != "" {
+		args = append(args, "_SYSTEMD_INVOCATION_ID="+q.InvocationId)
+	}
+
+	return args
+}
+
+// journalString decodes a field which journalctl prints as an array of bytes when it is not valid UTF-8
+func journalString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var b []byte
+	var l []int
+	if err := json.Unmarshal(raw, &l); err == nil {
+		for _, c := range l {
+			b = append(b, byte(c))
+		}
+	}
+
+	return string(b)
+}
+
+func parseEntry(line []byte) (*Entry, error) {
+	f := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(line, &f); err != nil {
+		return nil, err
+	}
+
+	e := Entry{
+		Cursor:     journalString(f["__CURSOR"]),
+		BootId:     journalString(f["_BOOT_ID"]),
+		Hostname:   journalString(f["_HOSTNAME"]),
+		Unit:       journalString(f["_SYSTEMD_UNIT"]),
+		Identifier: journalString(f["SYSLOG_IDENTIFIER"]),
+		Message:    journalString(f["MESSAGE"]),
+	}
+
+	if us, err := strconv.ParseInt(journalString(f["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		e.Time = time.UnixMicro(us)
+	}
+	e.Pid, _ = strconv.Atoi(journalString(f["_PID"]))
+	e.Priority, _ = strconv.Atoi(journalString(f["PRIORITY"]))
+
+	return &e, nil
+}
+
+// stream runs journalctl and hands every entry to onEntry until journalctl exits,
+// onEntry returns false or ctx is cancelled
+func stream(ctx context.Context, args []string, onEntry func(e *Entry) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := exec.CommandContext(ctx, journalctlPath, args...)
+
+	var stderr strings.Builder
+	c.Stderr = &stderr
+
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Start(); err != nil {
+		log.Errorf("Failed to start journalctl: %v", err)
+		return err
+	}
+
+	stopped, err := scan(out, onEntry)
+	if err != nil || stopped {
+		cancel()
+		c.Wait()
+		return err
+	}
+
+	if err := c.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			return errors.New(s)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// scan decodes the entries read from r. It reports whether onEntry stopped it
+func scan(r io.Reader, onEntry func(e *Entry) bool) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+
+	for scanner.Scan() {
+		e, err := parseEntry(scanner.Bytes())
+		if err != nil {
+			log.Warnf("Skipping undecodable journal entry: %v", err)
+			continue
+		}
+
+		if !onEntry(e) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// AcquirePage returns up to q.Lines entries. Without a cursor or since the latest entries are
+// returned, otherwise the entries following them
+func AcquirePage(ctx context.Context, q *Query) (*Page, error) {
+	p := Page{
+		Entries: []Entry{},
+		Cursor:  q.Cursor,
+	}
+
+	args := q.args()
+	tail := q.Cursor == "" && q.Since == ""
+	if tail {
+		args = append(args, "--lines="+strconv.Itoa(q.Lines))
+	}
+
+	// Read one entry more than asked for to learn whether there are more
+	if err := stream(ctx, args, func(e *Entry) bool {
+		if len(p.Entries) == q.Lines {
+			p.More = true
+			return false
+		}
+
+		p.Entries = append(p.Entries, *e)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(p.Entries) > 0 {
+		p.Cursor = p.Entries[len(p.Entries)-1].Cursor
+	}
+
+	return &p, nil
+}
+
+// Follow hands the latest q.Lines entries, or the entries after the cursor, and then the new
+// ones to onEntry until ctx is cancelled or onEntry returns false
+func Follow(ctx context.Context, q *Query, onEntry func(e *Entry) bool) error {
+	args := append(q.args(), "--follow")
+	if q.Cursor == "" && q.Since == "" {
+		args = append(args, "--lines="+strconv.Itoa(q.Lines))
+	}
+
+	return stream(ctx, args, onEntry)
+}
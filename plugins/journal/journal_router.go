@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package journal
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+)
+
+func parseQuery(r *http.Request) (*Query, error) {
+	v := r.URL.Query()
+
+	q := Query{
+		Unit:     v.Get("unit"),
+		Priority: v.Get("priority"),
+		Boot:     v.Get("boot"),
+		Since:    v.Get("since"),
+		Until:    v.Get("until"),
+		Cursor:   v.Get("cursor"),
+		Lines:    DefaultLines,
+	}
+
+	if s := v.Get("lines"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("invalid lines")
+		}
+		q.Lines = n
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+func routerAcquireJournal(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	p, err := AcquirePage(r.Context(), q)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(p, w)
+}
+
+// routerFollowJournal streams the entries as Server-Sent Events named 'entry'
+func routerFollowJournal(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	stream, err := web.NewEventStream(w)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	entries := make(chan *Entry)
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(r.Context(), q, func(e *Entry) bool {
+			select {
+			case entries <- e:
+				return true
+			case <-r.Context().Done():
+				return false
+			}
+		})
+	}()
+
+	k := time.NewTicker(keepAliveInterval)
+	defer k.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case err := <-done:
+			if err != nil {
+				stream.Send("error", err.Error())
+			}
+			return
+		case <-k.C:
+			if err := stream.KeepAlive(); err != nil {
+				return
+			}
+		case e := <-entries:
+			if err := stream.Send("entry", e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func RegisterRouterJournal(router *mux.Router) {
+	n := router.PathPrefix("/journal").Subrouter()
+
+	n.HandleFunc("", routerAcquireJournal).Methods("GET")
+	n.HandleFunc("/follow", routerFollowJournal).Methods("GET")
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEntries = `{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1685959815000000","_BOOT_ID":"4f9c0a8e2b7d4c1e9a6b3d2f1e0c9b8a","_HOSTNAME":"photon","_SYSTEMD_UNIT":"sshd.service","SYSLOG_IDENTIFIER":"sshd","_PID":"812","PRIORITY":"6","MESSAGE":"Server listening on 0.0.0.0 port 22."}
+not json
+{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1685959816000000","_HOSTNAME":"photon","PRIORITY":"3","MESSAGE":[102,97,105,108,101,100,255]}
+{"__CURSOR":"s=1;i=3","__REALTIME_TIMESTAMP":"1685959817000000","_HOSTNAME":"photon","PRIORITY":"5","MESSAGE":"third"}
+`
+
+// fakeJournalctl replaces journalctl with a script printing testEntries and records its arguments
+func fakeJournalctl(t *testing.T) string {
+	dir := t.TempDir()
+	args := path.Join(dir, "args")
+
+	entries := path.Join(dir, "entries")
+	if err := os.WriteFile(entries, []byte(testEntries), 0644); err != nil {
+		t.Fatalf("Failed to write entries: %v", err)
+	}
+
+	script := path.Join(dir, "journalctl")
+	s := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$@\" > %s\ncat %s\n", args, entries)
+	if err := os.WriteFile(script, []byte(s), 0755); err != nil {
+		t.Fatalf("Failed to write journalctl: %v", err)
+	}
+
+	saved := journalctlPath
+	journalctlPath = script
+	t.Cleanup(func() { journalctlPath = saved })
+
+	return args
+}
+
+func readArgs(t *testing.T, p string) []string {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Failed to read journalctl arguments: %v", err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestAcquirePage(t *testing.T) {
+	args := fakeJournalctl(t)
+
+	q := Query{
+		Unit:     "sshd.service",
+		Priority: "err..info",
+		Boot:     "-1",
+		Lines:    1,
+	}
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Failed to validate query: %v", err)
+	}
+
+	p, err := AcquirePage(context.Background(), &q)
+	if err != nil {
+		t.Fatalf("Failed to acquire page: %v", err)
+	}
+
+	want := []string{"--output=json", "--no-pager", "--quiet", "--unit=sshd.service", "--priority=err..info", "--boot=-1", "--lines=1"}
+	if got := readArgs(t, args); !reflect.DeepEqual(got, want) {
+		t.Errorf("journalctl called with %q, want %q", got, want)
+	}
+
+	e := Entry{
+		Cursor:     "s=1;i=1",
+		Time:       time.UnixMicro(1685959815000000),
+		BootId:     "4f9c0a8e2b7d4c1e9a6b3d2f1e0c9b8a",
+		Hostname:   "photon",
+		Unit:       "sshd.service",
+		Identifier: "sshd",
+		Pid:        812,
+		Priority:   6,
+		Message:    "Server listening on 0.0.0.0 port 22.",
+	}
+	if len(p.Entries) != 1 || !reflect.DeepEqual(p.Entries[0], e) {
+		t.Errorf("Entries = %+v, want %+v", p.Entries, e)
+	}
+	if !p.More || p.Cursor != "s=1;i=1" {
+		t.Errorf("Page should continue after cursor 's=1;i=1', got More=%t Cursor='%s'", p.More, p.Cursor)
+	}
+
+	// The next page starts after the cursor, takes undecodable lines in its stride and keeps raw bytes
+	q = Query{
+		Cursor: p.Cursor,
+		Lines:  5,
+	}
+	if p, err = AcquirePage(context.Background(), &q); err != nil {
+		t.Fatalf("Failed to acquire page: %v", err)
+	}
+
+	want = []string{"--output=json", "--no-pager", "--quiet", "--after-cursor=s=1;i=1"}
+	if got := readArgs(t, args); !reflect.DeepEqual(got, want) {
+		t.Errorf("journalctl called with %q, want %q", got, want)
+	}
+
+	if len(p.Entries) != 3 || p.More || p.Cursor != "s=1;i=3" {
+		t.Fatalf("Unexpected page %+v", p)
+	}
+	if m := p.Entries[1].Message; m != "failed\xff" {
+		t.Errorf("Message of bytes decoded as %q", m)
+	}
+	if e := p.Entries[1]; e.Priority != 3 || e.Pid != 0 || e.Unit != "" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+}
+
+func TestFollowStops(t *testing.T) {
+	fakeJournalctl(t)
+
+	q := Query{Lines: 10}
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Failed to validate query: %v", err)
+	}
+
+	var cursors []string
+	err := Follow(context.Background(), &q, func(e *Entry) bool {
+		cursors = append(cursors, e.Cursor)
+		return len(cursors) < 2
+	})
+	if err != nil {
+		t.Fatalf("Failed to follow journal: %v", err)
+	}
+
+	if !reflect.DeepEqual(cursors, []string{"s=1;i=1", "s=1;i=2"}) {
+		t.Errorf("Follow handed %q, want it to stop after the second entry", cursors)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	good := []Query{
+		{Unit: "systemd-networkd.service", Lines: DefaultLines},
+		{Unit: "getty@tty1.service", Lines: DefaultLines},
+		{Unit: "sshd*", Lines: DefaultLines},
+		{Priority: "4", Lines: DefaultLines},
+		{Priority: "emerg..err", Lines: DefaultLines},
+		{Boot: "0", Lines: DefaultLines},
+		{Boot: "4f9c0a8e2b7d4c1e9a6b3d2f1e0c9b8a", Lines: DefaultLines},
+		{Since: "2023-06-05 10:00:00", Until: "-1h", Lines: DefaultLines},
+		{Since: "yesterday", Lines: DefaultLines},
+		{InvocationId: "0c4ef1b2a6d94c5f8e7a3b2d1c0f9e8d", Lines: DefaultLines},
+		{Lines: 1},
+		{Lines: MaxLines},
+	}
+	for _, q := range good {
+		if err := q.Validate(); err != nil {
+			t.Errorf("Query %+v not accepted: %v", q, err)
+		}
+	}
+
+	// Values end up as journalctl arguments, nothing may pass for an option or a match
+	bad := []Query{
+		{Unit: "--file=/etc/shadow", Lines: DefaultLines},
+		{Unit: "sshd.service _UID=0", Lines: DefaultLines},
+		{Priority: "loud", Lines: DefaultLines},
+		{Priority: "3..8", Lines: DefaultLines},
+		{Boot: "--help", Lines: DefaultLines},
+		{Boot: "4f9c0a8e", Lines: DefaultLines},
+		{Since: "10:00;reboot", Lines: DefaultLines},
+		{Until: "tomorrow\n", Lines: DefaultLines},
+		{Cursor: "s=1\n--merge", Lines: DefaultLines},
+		{InvocationId: "0C4EF1B2A6D94C5F8E7A3B2D1C0F9E8D", Lines: DefaultLines},
+		{InvocationId: "_SYSTEMD_UNIT=sshd.service", Lines: DefaultLines},
+		{Unit: "sshd.service"},
+		{Lines: -1},
+		{Lines: MaxLines + 1},
+	}
+	for _, q := range bad {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Query %+v accepted", q)
+		}
+	}
+
+	q := Query{}
+	if err := q.Validate(); err == nil || err.Error() != fmt.Sprintf("lines must be between 1 and %d", MaxLines) {
+		t.Errorf("Query without lines refused with '%v'", err)
+	}
+}
+
+func TestQueryArgs(t *testing.T) {
+	base := []string{"--output=json", "--no-pager", "--quiet"}
+
+	for _, c := range []struct {
+		q    Query
+		args []string
+	}{
+		{Query{Lines: 5}, nil},
+		{Query{Unit: "sshd.service"}, []string{"--unit=sshd.service"}},
+		{Query{Priority: "err", Boot: "-1"}, []string{"--priority=err", "--boot=-1"}},
+		{Query{Since: "yesterday", Until: "now"}, []string{"--since=yesterday", "--until=now"}},
+		{Query{Cursor: "s=1;i=2"}, []string{"--after-cursor=s=1;i=2"}},
+		// The invocation is a match, not an option
+		{Query{InvocationId: "0c4ef1b2a6d94c5f8e7a3b2d1c0f9e8d"}, []string{"_SYSTEMD_INVOCATION_ID=0c4ef1b2a6d94c5f8e7a3b2d1c0f9e8d"}},
+	} {
+		want := append(append([]string{}, base...), c.args...)
+		if got := c.q.args(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Arguments of %+v = %q, want %q", c.q, got, want)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	for line, want := range map[string]Entry{
+		`{"__CURSOR":"s=2;i=7","__REALTIME_TIMESTAMP":"1685959815123456","_PID":"1","PRIORITY":"4","MESSAGE":"Reached target"}`: {
+			Cursor:   "s=2;i=7",
+			Time:     time.UnixMicro(1685959815123456),
+			Pid:      1,
+			Priority: 4,
+			Message:  "Reached target",
+		},
+		// Fields journalctl could not print as text arrive as byte arrays
+		`{"_SYSTEMD_UNIT":[115,115,104,100],"MESSAGE":[0,1]}`: {
+			Unit:    "sshd",
+			Message: "\x00\x01",
+		},
+		// Malformed numbers leave the fields unset rather than failing the entry
+		`{"__REALTIME_TIMESTAMP":"soon","_PID":"-","PRIORITY":"high","_HOSTNAME":"photon"}`: {
+			Hostname: "photon",
+		},
+		`{}`: {},
+	} {
+		e, err := parseEntry([]byte(line))
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", line, err)
+			continue
+		}
+		if !reflect.DeepEqual(*e, want) {
+			t.Errorf("Entry of '%s' = %+v, want %+v", line, *e, want)
+		}
+	}
+
+	for _, line := range []string{"", "not json", `["MESSAGE"]`, `{"MESSAGE":`} {
+		if _, err := parseEntry([]byte(line)); err == nil {
+			t.Errorf("Line '%s' parsed", line)
+		}
+	}
+}
+
+func TestJournalString(t *testing.T) {
+	for raw, want := range map[string]string{
+		`"sshd"`:                    "sshd",
+		`"café"`:                    "café",
+		`[104,105]`:                 "hi",
+		`[195,169,255]`:             "é\xff",
+		`null`:                      "",
+		`42`:                        "",
+		`["a","b"]`:                 "",
+		`{"MESSAGE":"not a field"}`: "",
+	} {
+		if got := journalString(json.RawMessage(raw)); got != want {
+			t.Errorf("journalString(%s) = %q, want %q", raw, got, want)
+		}
+	}
+
+	if got := journalString(nil); got != "" {
+		t.Errorf("Missing field decoded as %q", got)
+	}
+}
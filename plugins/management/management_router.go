@@ -4,6 +4,7 @@
 package management
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -14,6 +15,7 @@ import (
 	"github.com/vmware/pmd-next-gen/plugins/management/group"
 	"github.com/vmware/pmd-next-gen/plugins/management/hostname"
 	"github.com/vmware/pmd-next-gen/plugins/management/login"
+	"github.com/vmware/pmd-next-gen/plugins/management/power"
 	"github.com/vmware/pmd-next-gen/plugins/management/sysctl"
 	"github.com/vmware/pmd-next-gen/plugins/management/timedate"
 	"github.com/vmware/pmd-next-gen/plugins/management/user"
@@ -36,65 +38,65 @@ type Describe struct {
 	VirtualMemoryStat *mem.VirtualMemoryStat    `json:"VirtualMemoryStat"`
 }
 
-func routerDescribeSystem(w http.ResponseWriter, r *http.Request) {
+func AcquireDescribe(ctx context.Context) (*Describe, error) {
 	var err error
 	s := Describe{}
 
-	s.Hostname, err = hostname.MethodDescribe(r.Context())
+	s.Hostname, err = hostname.MethodDescribe(ctx)
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	s.Systemd, err = systemd.ManagerDescribe(r.Context())
+	s.Systemd, err = systemd.ManagerDescribe(ctx)
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	s.TimeDate, err = timedate.DBusAcquireTimeDate()
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	s.NetworkDescribe, err = networkd.AcquireNetworkState(r.Context())
+	s.NetworkDescribe, err = networkd.AcquireNetworkState(ctx)
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	s.LinksDescribe, err = networkd.AcquireLinks(r.Context())
+	s.LinksDescribe, err = networkd.AcquireLinks(ctx)
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	s.Addresses, err = address.AcquireAddresses()
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	s.Routes, err = route.AcquireRoutes()
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	s.HostInfo, err = host.Info()
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	s.UserStat, err = host.Users()
 	if err != nil {
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	s.VirtualMemoryStat, err = mem.VirtualMemoryWithContext(r.Context())
+	s.VirtualMemoryStat, err = mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func routerDescribeSystem(w http.ResponseWriter, r *http.Request) {
+	s, err := AcquireDescribe(r.Context())
 	if err != nil {
 		web.JSONResponseError(err, w)
 		return
@@ -111,6 +113,7 @@ func RegisterRouterManagement(router *mux.Router) {
 
 	hostname.RegisterRouterHostname(n)
 	login.RegisterRouterLogin(n)
+	power.RegisterRouterPower(n)
 	timedate.RegisterRouterTimeDate(n)
 
 	sysctl.RegisterRouterSysctl(n)
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type powerAction struct {
+	// Method of logind which runs the action at once
+	Method string
+	// Type logind takes to schedule the action, empty if it cannot be scheduled
+	Schedule string
+}
+
+var powerActions = map[string]powerAction{
+	"reboot":       {Method: "Reboot", Schedule: "reboot"},
+	"poweroff":     {Method: "PowerOff", Schedule: "poweroff"},
+	"halt":         {Method: "Halt", Schedule: "halt"},
+	"kexec":        {Schedule: "kexec"},
+	"suspend":      {Method: "Suspend"},
+	"hibernate":    {Method: "Hibernate"},
+	"hybrid-sleep": {Method: "HybridSleep"},
+}
+
+// PowerRequest runs a power action now or, when When is set, schedules it. When takes "now",
+// "+MINUTES", "+DURATION" such as +1h30m, "HH:MM" or an RFC 3339 time
+type PowerRequest struct {
+	Action      string `json:"Action"`
+	When        string `json:"When"`
+	WallMessage string `json:"WallMessage"`
+}
+
+type ScheduledShutdown struct {
+	Type        string `json:"Type"`
+	USec        uint64 `json:"USec"`
+	WallMessage string `json:"WallMessage"`
+}
+
+type Inhibitor struct {
+	What string `json:"What"`
+	Who  string `json:"Who"`
+	Why  string `json:"Why"`
+	Mode string `json:"Mode"`
+	UID  uint32 `json:"UID"`
+	PID  uint32 `json:"PID"`
+}
+
+// Describe tells which power actions are possible, as logind answers "yes", "no", "challenge"
+// or "na", and the pending scheduled shutdown
+type Describe struct {
+	CanPowerOff    string             `json:"CanPowerOff"`
+	CanReboot      string             `json:"CanReboot"`
+	CanHalt        string             `json:"CanHalt"`
+	CanSuspend     string             `json:"CanSuspend"`
+	CanHibernate   string             `json:"CanHibernate"`
+	CanHybridSleep string             `json:"CanHybridSleep"`
+	Scheduled      *ScheduledShutdown `json:"Scheduled"`
+}
+
+type wallMessage struct {
+	Message string
+	Enable  bool
+}
+
+// The wall message logind had before a scheduled shutdown replaced it, restored on cancel
+var savedWallMessage struct {
+	sync.Mutex
+	w *wallMessage
+}
+
+// parseWhen returns the time to schedule at, the zero time means now
+func parseWhen(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "now" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasPrefix(s, "+") {
+		v := strings.TrimPrefix(s, "+")
+		if m, err := strconv.ParseUint(v, 10, 32); err == nil {
+			if m == 0 {
+				return time.Time{}, nil
+			}
+			return now.Add(time.Duration(m) * time.Minute), nil
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return time.Time{}, fmt.Errorf("invalid time '%s'", s)
+		}
+		if d == 0 {
+			return time.Time{}, nil
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", s)
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("time '%s' is in the past", s)
+	}
+
+	return t, nil
+}
+
+// Execute runs or schedules the power action. It returns the scheduled shutdown, nil when the
+// action was run at once
+func (p *PowerRequest) Execute(ctx context.Context) (*ScheduledShutdown, error) {
+	a, ok := powerActions[p.Action]
+	if !ok {
+		return nil, fmt.Errorf("unknown power action '%s'", p.Action)
+	}
+
+	at, err := parseWhen(p.When, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !at.IsZero() && a.Schedule == "" {
+		return nil, fmt.Errorf("power action '%s' cannot be scheduled", p.Action)
+	}
+
+	c, err := NewSDConnection()
+	if err != nil {
+		log.Errorf("Failed to establish connection to the system bus: %s", err)
+		return nil, err
+	}
+	defer c.Close()
+
+	savedWallMessage.Lock()
+	defer savedWallMessage.Unlock()
+
+	var prev *wallMessage
+	if p.WallMessage != "" {
+		prev, err = c.dBusAcquireWallMessage()
+		if err != nil {
+			log.Errorf("Failed to acquire wall message: %v", err)
+			return nil, err
+		}
+
+		if err := c.dBusSetWallMessage(ctx, p.WallMessage, true); err != nil {
+			log.Errorf("Failed to set wall message: %v", err)
+			return nil, err
+		}
+	}
+
+	restore := func() {
+		if prev != nil {
+			if err := c.dBusSetWallMessage(ctx, prev.Message, prev.Enable); err != nil {
+				log.Warnf("Failed to restore wall message: %v", err)
+			}
+		}
+	}
+
+	if at.IsZero() {
+		log.Infof("Executing power action='%s'", p.Action)
+
+		if a.Method == "" {
+			err = c.dBusKexec(ctx)
+		} else {
+			err = c.dBusPowerAction(ctx, a.Method)
+		}
+		if err != nil {
+			log.Errorf("Failed to execute power action='%s': %v", p.Action, err)
+			restore()
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	if err := c.dBusScheduleShutdown(ctx, a.Schedule, uint64(at.UnixMicro())); err != nil {
+		log.Errorf("Failed to schedule power action='%s' at='%s': %v", p.Action, at, err)
+		restore()
+		return nil, err
+	}
+
+	// Rescheduling keeps the message from before the first schedule
+	if prev != nil && savedWallMessage.w == nil {
+		savedWallMessage.w = prev
+	}
+
+	log.Infof("Scheduled power action='%s' at='%s'", p.Action, at)
+	return c.DBusAcquireScheduledShutdown()
+}
+
+// CancelScheduledShutdown cancels the pending scheduled shutdown and restores the wall message
+// it replaced. When that is unknown, as after a restart of photon-mgmtd, the message is cleared
+func CancelScheduledShutdown(ctx context.Context) error {
+	c, err := NewSDConnection()
+	if err != nil {
+		log.Errorf("Failed to establish connection to the system bus: %s", err)
+		return err
+	}
+	defer c.Close()
+
+	savedWallMessage.Lock()
+	defer savedWallMessage.Unlock()
+
+	cancelled, err := c.dBusCancelScheduledShutdown(ctx)
+	if err != nil {
+		log.Errorf("Failed to cancel scheduled shutdown: %v", err)
+		return err
+	}
+	if !cancelled {
+		return errors.New("no shutdown is scheduled")
+	}
+
+	w := savedWallMessage.w
+	if w == nil {
+		w = &wallMessage{Enable: true}
+	}
+	if err := c.dBusSetWallMessage(ctx, w.Message, w.Enable); err != nil {
+		log.Errorf("Failed to restore wall message: %v", err)
+		return err
+	}
+	savedWallMessage.w = nil
+
+	log.Info("Cancelled scheduled shutdown")
+	return nil
+}
+
+func AcquireDescribe(ctx context.Context) (*Describe, error) {
+	c, err := NewSDConnection()
+	if err != nil {
+		log.Errorf("Failed to establish connection to the system bus: %s", err)
+		return nil, err
+	}
+	defer c.Close()
+
+	d := Describe{}
+	for method, v := range map[string]*string{
+		"PowerOff":    &d.CanPowerOff,
+		"Reboot":      &d.CanReboot,
+		"Halt":        &d.CanHalt,
+		"Suspend":     &d.CanSuspend,
+		"Hibernate":   &d.CanHibernate,
+		"HybridSleep": &d.CanHybridSleep,
+	} {
+		if s, err := c.dBusCan(ctx, method); err == nil {
+			*v = s
+		}
+	}
+
+	d.Scheduled, err = c.DBusAcquireScheduledShutdown()
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func AcquireInhibitors(ctx context.Context) ([]Inhibitor, error) {
+	c, err := NewSDConnection()
+	if err != nil {
+		log.Errorf("Failed to establish connection to the system bus: %s", err)
+		return nil, err
+	}
+	defer c.Close()
+
+	return c.DBusAcquireInhibitors(ctx)
+}
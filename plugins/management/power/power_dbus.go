@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package power
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/pmd-next-gen/pkg/bus"
+)
+
+const (
+	dbusInterface        = "org.freedesktop.login1"
+	dbusPath             = "/org/freedesktop/login1"
+	dbusManagerInterface = "org.freedesktop.login1.Manager"
+
+	systemdInterface        = "org.freedesktop.systemd1"
+	systemdPath             = "/org/freedesktop/systemd1"
+	systemdManagerInterface = "org.freedesktop.systemd1.Manager"
+)
+
+type SDConnection struct {
+	conn   *dbus.Conn
+	object dbus.BusObject
+}
+
+func NewSDConnection() (*SDConnection, error) {
+	conn, err := bus.SystemBusPrivateConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %v", err)
+	}
+
+	return &SDConnection{
+		conn:   conn,
+		object: conn.Object(dbusInterface, dbus.ObjectPath(dbusPath)),
+	}, nil
+}
+
+func (c *SDConnection) Close() {
+	c.conn.Close()
+}
+
+// dBusPowerAction calls one of Reboot, PowerOff, Halt, Suspend, Hibernate or HybridSleep. They
+// are not interactive, polkit is not asked for authentication
+func (c *SDConnection) dBusPowerAction(ctx context.Context, method string) error {
+	return c.object.CallWithContext(ctx, dbusManagerInterface+"."+method, 0, false).Err
+}
+
+// dBusKexec starts kexec.target the way systemctl kexec does, logind has no method for it
+func (c *SDConnection) dBusKexec(ctx context.Context) error {
+	var job dbus.ObjectPath
+
+	o := c.conn.Object(systemdInterface, dbus.ObjectPath(systemdPath))
+	return o.CallWithContext(ctx, systemdManagerInterface+".StartUnit", 0, "kexec.target", "replace-irreversibly").Store(&job)
+}
+
+func (c *SDConnection) dBusCan(ctx context.Context, method string) (string, error) {
+	var s string
+	if err := c.object.CallWithContext(ctx, dbusManagerInterface+".Can"+method, 0).Store(&s); err != nil {
+		return "", err
+	}
+
+	return s, nil
+}
+
+func (c *SDConnection) dBusScheduleShutdown(ctx context.Context, kind string, usec uint64) error {
+	return c.object.CallWithContext(ctx, dbusManagerInterface+".ScheduleShutdown", 0, kind, usec).Err
+}
+
+func (c *SDConnection) dBusCancelScheduledShutdown(ctx context.Context) (bool, error) {
+	var cancelled bool
+	if err := c.object.CallWithContext(ctx, dbusManagerInterface+".CancelScheduledShutdown", 0).Store(&cancelled); err != nil {
+		return false, err
+	}
+
+	return cancelled, nil
+}
+
+func (c *SDConnection) dBusSetWallMessage(ctx context.Context, message string, enable bool) error {
+	return c.object.CallWithContext(ctx, dbusManagerInterface+".SetWallMessage", 0, message, enable).Err
+}
+
+func (c *SDConnection) dBusAcquireWallMessage() (*wallMessage, error) {
+	m, err := c.DBusAcquire("WallMessage")
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := c.DBusAcquire("EnableWallMessages")
+	if err != nil {
+		return nil, err
+	}
+
+	w := wallMessage{}
+	w.Message, _ = m.Value().(string)
+	w.Enable, _ = e.Value().(bool)
+
+	return &w, nil
+}
+
+func (c *SDConnection) DBusAcquire(property string) (dbus.Variant, error) {
+	return c.object.GetProperty(dbusManagerInterface + "." + property)
+}
+
+func (c *SDConnection) DBusAcquireScheduledShutdown() (*ScheduledShutdown, error) {
+	v, err := c.DBusAcquire("ScheduledShutdown")
+	if err != nil {
+		return nil, err
+	}
+
+	s := ScheduledShutdown{}
+	if l, ok := v.Value().([]interface{}); ok && len(l) == 2 {
+		s.Type, _ = l[0].(string)
+		s.USec, _ = l[1].(uint64)
+	}
+
+	if w, err := c.DBusAcquire("WallMessage"); err == nil {
+		s.WallMessage, _ = w.Value().(string)
+	}
+
+	return &s, nil
+}
+
+func (c *SDConnection) DBusAcquireInhibitors(ctx context.Context) ([]Inhibitor, error) {
+	out := [][]interface{}{}
+	if err := c.object.CallWithContext(ctx, dbusManagerInterface+".ListInhibitors", 0).Store(&out); err != nil {
+		return nil, err
+	}
+
+	inhibitors := []Inhibitor{}
+	for _, v := range out {
+		if len(v) < 6 {
+			continue
+		}
+
+		i := Inhibitor{}
+		i.What, _ = v[0].(string)
+		i.Who, _ = v[1].(string)
+		i.Why, _ = v[2].(string)
+		i.Mode, _ = v[3].(string)
+		i.UID, _ = v[4].(uint32)
+		i.PID, _ = v[5].(uint32)
+
+		inhibitors = append(inhibitors, i)
+	}
+
+	return inhibitors, nil
+}
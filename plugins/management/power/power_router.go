@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package power
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerAcquirePower(w http.ResponseWriter, r *http.Request) {
+	d, err := AcquireDescribe(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(d, w)
+}
+
+func routerExecutePower(w http.ResponseWriter, r *http.Request) {
+	p := PowerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	s, err := p.Execute(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(s, w)
+}
+
+func routerCancelScheduledShutdown(w http.ResponseWriter, r *http.Request) {
+	if err := CancelScheduledShutdown(r.Context()); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("cancelled", w)
+}
+
+func routerAcquireInhibitors(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireInhibitors(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func RegisterRouterPower(router *mux.Router) {
+	s := router.PathPrefix("/power").Subrouter().StrictSlash(false)
+
+	s.HandleFunc("", routerAcquirePower).Methods("GET")
+	s.HandleFunc("", routerExecutePower).Methods("POST")
+	s.HandleFunc("/scheduled", routerCancelScheduledShutdown).Methods("DELETE")
+	s.HandleFunc("/inhibitors", routerAcquireInhibitors).Methods("GET")
+}
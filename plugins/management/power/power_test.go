@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package power
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	cest = time.FixedZone("CEST", 2*60*60)
+	now  = time.Date(2023, time.June, 5, 10, 30, 15, 0, cest)
+)
+
+func TestParseWhenImmediate(t *testing.T) {
+	// All of these run the action right away instead of scheduling it
+	for _, s := range []string{"", "now", " now ", "+0", "+0s"} {
+		when, err := parseWhen(s, now)
+		if err != nil || !when.IsZero() {
+			t.Errorf("'%s' scheduled for %v (err=%v), want immediately", s, when, err)
+		}
+	}
+}
+
+func TestParseWhenRelative(t *testing.T) {
+	// A bare number counts minutes like shutdown(8) does
+	for s, d := range map[string]time.Duration{
+		"+5":     5 * time.Minute,
+		"+90":    90 * time.Minute,
+		"+1h30m": 90 * time.Minute,
+		"+45s":   45 * time.Second,
+	} {
+		when, err := parseWhen(s, now)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", s, err)
+			continue
+		}
+		if got := when.Sub(now); got != d {
+			t.Errorf("'%s' scheduled %v from now, want %v", s, got, d)
+		}
+	}
+}
+
+func TestParseWhenClockTime(t *testing.T) {
+	// Later today if the time is still ahead, otherwise tomorrow, in the local time zone
+	today := map[string]time.Time{
+		"11:00": time.Date(2023, time.June, 5, 11, 0, 0, 0, cest),
+		"23:59": time.Date(2023, time.June, 5, 23, 59, 0, 0, cest),
+	}
+	tomorrow := map[string]time.Time{
+		"10:30": time.Date(2023, time.June, 6, 10, 30, 0, 0, cest),
+		"09:00": time.Date(2023, time.June, 6, 9, 0, 0, 0, cest),
+		"00:00": time.Date(2023, time.June, 6, 0, 0, 0, 0, cest),
+	}
+
+	for _, m := range []map[string]time.Time{today, tomorrow} {
+		for s, want := range m {
+			when, err := parseWhen(s, now)
+			if err != nil || !when.Equal(want) || when.Location() != cest {
+				t.Errorf("'%s' scheduled for %v (err=%v), want %v", s, when, err, want)
+			}
+		}
+	}
+}
+
+func TestParseWhenAbsolute(t *testing.T) {
+	when, err := parseWhen("2023-06-05T09:00:00Z", now)
+	if err != nil {
+		t.Fatalf("Failed to parse time: %v", err)
+	}
+	if !when.Equal(now.Add(29*time.Minute + 45*time.Second)) {
+		t.Errorf("Scheduled for %v", when)
+	}
+
+	// A shutdown is never scheduled in the past, not even at the current second
+	for _, s := range []string{"2023-06-05T10:00:00+02:00", "2023-06-05T08:30:15Z"} {
+		if when, err := parseWhen(s, now); err == nil {
+			t.Errorf("Past time '%s' scheduled for %v", s, when)
+		}
+	}
+}
+
+func TestParseWhenMalformed(t *testing.T) {
+	for _, s := range []string{"+-5m", "+", "+soon", "5", "24:00", "10:60", "tomorrow", "2023-06-05 12:00"} {
+		if when, err := parseWhen(s, now); err == nil {
+			t.Errorf("Malformed time '%s' scheduled for %v", s, when)
+		}
+	}
+}
@@ -178,16 +178,12 @@ func (s *Sysctl) Acquire(w http.ResponseWriter) error {
 	return err
 }
 
-// GetPatern will return all the entry with matching pattern
-// If pattern is empty it should return all values
-func (s *Sysctl) GetPattern(w http.ResponseWriter) error {
-	if validator.IsEmpty(s.Pattern) {
-		log.Infof("Input pattern is empty return all system configuration")
-	}
-
-	re, err := regexp.CompilePOSIX(s.Pattern)
+// AcquirePattern returns all the entries with matching pattern
+// If pattern is empty it returns all values
+func AcquirePattern(pattern string) (map[string]string, error) {
+	re, err := regexp.CompilePOSIX(pattern)
 	if err != nil {
-		return fmt.Errorf("Failed to acquire sysctl parameter, Invalid pattern='%s': %v", s.Pattern, err)
+		return nil, fmt.Errorf("Failed to acquire sysctl parameter, Invalid pattern='%s': %v", pattern, err)
 	}
 
 	sysctlMap := make(map[string]string)
@@ -201,7 +197,7 @@ func (s *Sysctl) GetPattern(w http.ResponseWriter) error {
 
 	if err := createSysctlMapFromDir(procSysPath, sysctlMap); err != nil {
 		log.Errorf("Failed to read configuration from '%s': %v", procSysPath, err)
-		return err
+		return nil, err
 	}
 
 	result := make(map[string]string)
@@ -211,12 +207,46 @@ func (s *Sysctl) GetPattern(w http.ResponseWriter) error {
 		}
 		result[k] = v
 	}
+
+	return result, nil
+}
+
+// GetPatern will return all the entry with matching pattern
+// If pattern is empty it should return all values
+func (s *Sysctl) GetPattern(w http.ResponseWriter) error {
+	if validator.IsEmpty(s.Pattern) {
+		log.Infof("Input pattern is empty return all system configuration")
+	}
+
+	result, err := AcquirePattern(s.Pattern)
+	if err != nil {
+		return err
+	}
+
 	return web.JSONResponse(result, w)
 }
 
+// AcquireValue returns the value of the sysctl key currently in effect
+func AcquireValue(key string) (string, error) {
+	sysctlMap := make(map[string]string)
+	if err := getKeyValueFromProcSys(key, sysctlMap); err != nil {
+		return "", err
+	}
+
+	return sysctlMap[key], nil
+}
+
 // Update sysctl configuration file and apply
 // Action can be SET, UPDATE or DELETE
 func (s *Sysctl) Update(w http.ResponseWriter) error {
+	if err := s.Configure(); err != nil {
+		return err
+	}
+
+	return web.JSONResponse("Configuration updated", w)
+}
+
+func (s *Sysctl) Configure() error {
 	if validator.IsEmpty(s.FileName) {
 		s.FileName = sysctlPath
 	} else {
@@ -261,7 +291,7 @@ func (s *Sysctl) Update(w http.ResponseWriter) error {
 		}
 	}
 
-	return web.JSONResponse("Configuration updated", w)
+	return nil
 }
 
 // Load all the configuration files and apply
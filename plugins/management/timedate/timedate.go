@@ -26,7 +26,7 @@ type Describe struct {
 	RTCTimeUSec     uint64 `json:"RTCTimeUSec"`
 }
 
-func (t *TimeDate) ConfigureTimeDate(w http.ResponseWriter) error {
+func (t *TimeDate) Configure() error {
 	conn, err := NewSDConnection()
 	if err != nil {
 		log.Errorf("Failed to get systemd bus connection: %v", err)
@@ -40,6 +40,14 @@ func (t *TimeDate) ConfigureTimeDate(w http.ResponseWriter) error {
 		return err
 	}
 
+	return nil
+}
+
+func (t *TimeDate) ConfigureTimeDate(w http.ResponseWriter) error {
+	if err := t.Configure(); err != nil {
+		return err
+	}
+
 	web.JSONResponse("configured", w)
 	return nil
 }
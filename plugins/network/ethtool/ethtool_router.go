@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
@@ -57,6 +58,7 @@ func routerConfigureEthTool(w http.ResponseWriter, r *http.Request) {
 
 func RegisterRouterEthTool(n *mux.Router) {
 	e := n.PathPrefix("/ethtool").Subrouter().StrictSlash(false)
+	e.Use(netns.Middleware)
 
 	e.HandleFunc("/{link}", routerAcquirEthTool).Methods("GET")
 	e.HandleFunc("/{link}/{property}", routerAcquirActionEthTool).Methods("GET")
@@ -197,7 +197,7 @@ func (n *Nft) ParseTable(tbl *nftables.Table) error {
 	return nil
 }
 
-func (n *Nft) AddTable(w http.ResponseWriter) error {
+func (n *Nft) ConfigureTable() error {
 	tbl := nftables.Table{}
 	if err := n.ParseTable(&tbl); err != nil {
 		log.Errorf("Failed to parse table: %v", err)
@@ -213,9 +213,33 @@ func (n *Nft) AddTable(w http.ResponseWriter) error {
 		return err
 	}
 
+	return nil
+}
+
+func (n *Nft) AddTable(w http.ResponseWriter) error {
+	if err := n.ConfigureTable(); err != nil {
+		return err
+	}
+
 	return web.JSONResponse("added", w)
 }
 
+// TableExists reports whether the table is present in the running ruleset
+func (n *Nft) TableExists() (bool, error) {
+	tableMap := make(map[string]*nftables.Table)
+	if err := getTablesAndCreateMap(tableMap); err != nil {
+		return false, err
+	}
+
+	family := n.Table.Family
+	if validator.IsEmpty(family) {
+		family = "ipv4"
+	}
+
+	_, ok := tableMap[createTableMapKey(n.Table.Name, convertToUnixFamily(family))]
+	return ok, nil
+}
+
 func (n *Nft) RemoveTable(w http.ResponseWriter) error {
 	tbl := nftables.Table{}
 	if err := n.ParseTable(&tbl); err != nil {
@@ -314,6 +338,30 @@ func (n *Nft) ParseChain(ch *nftables.Chain) error {
 }
 
 func (n *Nft) AddChain(w http.ResponseWriter) error {
+	if err := n.ConfigureChain(); err != nil {
+		return err
+	}
+
+	return web.JSONResponse("added", w)
+}
+
+// ChainExists reports whether the chain is present in the running ruleset
+func (n *Nft) ChainExists() (bool, error) {
+	chainMap := make(map[string]*nftables.Chain)
+	if err := getChainsAndCreateMap(chainMap); err != nil {
+		return false, err
+	}
+
+	family := n.Chain.Family
+	if validator.IsEmpty(family) {
+		family = "ipv4"
+	}
+
+	_, ok := chainMap[createChainMapKey(n.Chain.Table, n.Chain.Name, convertToUnixFamily(family))]
+	return ok, nil
+}
+
+func (n *Nft) ConfigureChain() error {
 	ch := nftables.Chain{}
 	if err := n.ParseChain(&ch); err != nil {
 		log.Errorf("Failed to parse chain: %v", err)
@@ -342,7 +390,7 @@ func (n *Nft) AddChain(w http.ResponseWriter) error {
 		return err
 	}
 
-	return web.JSONResponse("added", w)
+	return nil
 }
 
 func (n *Nft) RemoveChain(w http.ResponseWriter) error {
@@ -398,11 +446,21 @@ func (n *Nft) ShowChain(w http.ResponseWriter) error {
 	return web.JSONResponse(chainMap, w)
 }
 
-func (n *Nft) SaveNFT(w http.ResponseWriter) error {
+// AcquireRuleset returns the running ruleset as printed by 'nft list ruleset'
+func AcquireRuleset() (string, error) {
 	stdout, err := system.ExecAndCapture("nft", "list", "ruleset")
 	if err != nil {
 		log.Errorf("Failed to acquire command output=%v", err)
-		return fmt.Errorf("Failed to acquire command output=%v", err)
+		return "", fmt.Errorf("Failed to acquire command output=%v", err)
+	}
+
+	return stdout, nil
+}
+
+func (n *Nft) SaveNFT(w http.ResponseWriter) error {
+	stdout, err := AcquireRuleset()
+	if err != nil {
+		return err
 	}
 
 	if err := ioutil.WriteFile(nftFilePath, []byte(stdout), 0644); err != nil {
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	vnetns "github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/pmd-next-gen/pkg/netns"
+)
+
+type Namespace struct {
+	Name  string   `json:"Name"`
+	ID    string   `json:"ID"`
+	Links []string `json:"Links"`
+}
+
+// MoveLinkRequest moves a link between namespaces given as name or process id, an empty
+// namespace is the one of the daemon
+type MoveLinkRequest struct {
+	Link string `json:"Link"`
+	From string `json:"From"`
+	To   string `json:"To"`
+}
+
+func openNamespace(ns string) (vnetns.NsHandle, error) {
+	if ns == "" {
+		return vnetns.GetFromPath("/proc/self/ns/net")
+	}
+
+	return netns.Open(ns)
+}
+
+func acquireNamespace(name string) (*Namespace, error) {
+	h, err := netns.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+
+	n := Namespace{
+		Name:  name,
+		ID:    h.UniqueId(),
+		Links: []string{},
+	}
+
+	nh, err := netlink.NewHandleAt(h)
+	if err != nil {
+		return nil, err
+	}
+	defer nh.Delete()
+
+	links, err := nh.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		n.Links = append(n.Links, l.Attrs().Name)
+	}
+
+	return &n, nil
+}
+
+// AcquireNamespaces lists the named namespaces with their links
+func AcquireNamespaces() ([]Namespace, error) {
+	entries, err := os.ReadDir(netns.RunDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Namespace{}, nil
+		}
+		return nil, err
+	}
+
+	l := []Namespace{}
+	for _, e := range entries {
+		n, err := acquireNamespace(e.Name())
+		if err != nil {
+			log.Debugf("Failed to acquire network namespace='%s': %v", e.Name(), err)
+			continue
+		}
+
+		l = append(l, *n)
+	}
+
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+
+	return l, nil
+}
+
+// AcquireNamespace returns the named namespace with its links
+func AcquireNamespace(name string) (*Namespace, error) {
+	return acquireNamespace(name)
+}
+
+// prepareRunDir makes the directory a shared mount point like ip-netns(8) does, so that the
+// namespaces mounted on it are seen from other mount namespaces
+func prepareRunDir() error {
+	if err := os.MkdirAll(netns.RunDir, 0755); err != nil {
+		return err
+	}
+
+	err := unix.Mount("", netns.RunDir, "none", unix.MS_SHARED|unix.MS_REC, "")
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, unix.EINVAL) {
+		return err
+	}
+
+	// Not a mount point yet
+	if err := unix.Mount(netns.RunDir, netns.RunDir, "none", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+
+	return unix.Mount("", netns.RunDir, "none", unix.MS_SHARED|unix.MS_REC, "")
+}
+
+// CreateNamespace creates a named namespace which lives on while it is mounted in netns.RunDir
+func CreateNamespace(name string) (*Namespace, error) {
+	if err := netns.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	if err := prepareRunDir(); err != nil {
+		log.Errorf("Failed to prepare '%s': %v", netns.RunDir, err)
+		return nil, err
+	}
+
+	p := path.Join(netns.RunDir, name)
+	f, err := os.OpenFile(p, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("network namespace '%s' already exists", name)
+		}
+		return nil, err
+	}
+	f.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		// The thread is left locked so that it is terminated with the goroutine rather than
+		// going on in the new namespace
+		runtime.LockOSThread()
+
+		origin, err := vnetns.Get()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer origin.Close()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			errc <- err
+			return
+		}
+
+		// The main thread is not terminated when its goroutine exits locked, so switch back
+		defer func() {
+			if err := vnetns.Set(origin); err != nil {
+				log.Errorf("Failed to leave network namespace='%s': %v", name, err)
+			}
+		}()
+
+		errc <- unix.Mount("/proc/thread-self/ns/net", p, "none", unix.MS_BIND, "")
+	}()
+
+	if err := <-errc; err != nil {
+		log.Errorf("Failed to create network namespace='%s': %v", name, err)
+		os.Remove(p)
+		return nil, err
+	}
+
+	log.Infof("Created network namespace='%s'", name)
+	return acquireNamespace(name)
+}
+
+// RemoveNamespace unmounts the named namespace, it goes away once no process or link is left in it
+func RemoveNamespace(name string) error {
+	if err := netns.ValidateName(name); err != nil {
+		return err
+	}
+
+	p := path.Join(netns.RunDir, name)
+	if _, err := os.Stat(p); err != nil {
+		return fmt.Errorf("network namespace '%s' does not exist", name)
+	}
+
+	if err := unix.Unmount(p, unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+		log.Errorf("Failed to unmount network namespace='%s': %v", name, err)
+		return err
+	}
+
+	if err := os.Remove(p); err != nil {
+		log.Errorf("Failed to remove network namespace='%s': %v", name, err)
+		return err
+	}
+
+	log.Infof("Removed network namespace='%s'", name)
+	return nil
+}
+
+// Move moves the link into the target namespace. The link keeps its name, addresses and routes
+// are flushed by the kernel
+func (m *MoveLinkRequest) Move() error {
+	if m.Link == "" {
+		return errors.New("missing link")
+	}
+
+	from, err := openNamespace(m.From)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := openNamespace(m.To)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	if from.Equal(to) {
+		return errors.New("link is already in the namespace")
+	}
+
+	h, err := netlink.NewHandleAt(from)
+	if err != nil {
+		return err
+	}
+	defer h.Delete()
+
+	link, err := h.LinkByName(m.Link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s' in network namespace='%s': %v", m.Link, m.From, err)
+		return err
+	}
+
+	if err := h.LinkSetNsFd(link, int(to)); err != nil {
+		log.Errorf("Failed to move link='%s' to network namespace='%s': %v", m.Link, m.To, err)
+		return err
+	}
+
+	log.Infof("Moved link='%s' from network namespace='%s' to='%s'", m.Link, m.From, m.To)
+	return nil
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package namespace
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerAcquireNamespaces(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireNamespaces()
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func routerAcquireNamespace(w http.ResponseWriter, r *http.Request) {
+	n, err := AcquireNamespace(mux.Vars(r)["name"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(n, w)
+}
+
+func routerCreateNamespace(w http.ResponseWriter, r *http.Request) {
+	n := Namespace{}
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	ns, err := CreateNamespace(n.Name)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(ns, w)
+}
+
+func routerRemoveNamespace(w http.ResponseWriter, r *http.Request) {
+	if err := RemoveNamespace(mux.Vars(r)["name"]); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("removed", w)
+}
+
+func routerMoveLink(w http.ResponseWriter, r *http.Request) {
+	m := MoveLinkRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	if err := m.Move(); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("moved", w)
+}
+
+func RegisterRouterNamespace(router *mux.Router) {
+	s := router.PathPrefix("/netns").Subrouter().StrictSlash(false)
+
+	s.HandleFunc("", routerAcquireNamespaces).Methods("GET")
+	s.HandleFunc("", routerCreateNamespace).Methods("POST")
+	s.HandleFunc("/move", routerMoveLink).Methods("POST")
+	s.HandleFunc("/{name}", routerAcquireNamespace).Methods("GET")
+	s.HandleFunc("/{name}", routerRemoveNamespace).Methods("DELETE")
+}
@@ -5,21 +5,48 @@ package address
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// Address flags by the names ip-address(8) uses. Only the ones marked settable may be requested
+	addressFlags = []struct {
+		Name     string
+		Flag     int
+		Settable bool
+	}{
+		{"secondary", unix.IFA_F_SECONDARY, false},
+		{"nodad", unix.IFA_F_NODAD, true},
+		{"optimistic", unix.IFA_F_OPTIMISTIC, true},
+		{"dadfailed", unix.IFA_F_DADFAILED, false},
+		{"home", unix.IFA_F_HOMEADDRESS, true},
+		{"deprecated", unix.IFA_F_DEPRECATED, false},
+		{"tentative", unix.IFA_F_TENTATIVE, false},
+		{"permanent", unix.IFA_F_PERMANENT, false},
+		{"mngtmpaddr", unix.IFA_F_MANAGETEMPADDR, true},
+		{"noprefixroute", unix.IFA_F_NOPREFIXROUTE, true},
+		{"autojoin", unix.IFA_F_MCAUTOJOIN, true},
+		{"stable-privacy", unix.IFA_F_STABLE_PRIVACY, false},
+	}
 )
 
 type Address struct {
-	IP          string `json:"IP"`
-	Mask        int    `json:"Mask"`
-	Label       string `json:"Label"`
-	Flags       int    `json:"Flags"`
-	Scope       int    `json:"Scope"`
-	Peer        string `json:"Peer"`
-	Broadcast   string `json:"Broadcast"`
-	PreferedLft int    `json:"PreferedLft"`
-	ValidLft    int    `json:"ValidLft"`
+	IP          string   `json:"IP"`
+	Mask        int      `json:"Mask"`
+	Label       string   `json:"Label"`
+	Flags       int      `json:"Flags"`
+	FlagNames   []string `json:"FlagNames"`
+	Scope       int      `json:"Scope"`
+	Peer        string   `json:"Peer"`
+	Broadcast   string   `json:"Broadcast"`
+	PreferedLft int      `json:"PreferedLft"`
+	ValidLft    int      `json:"ValidLft"`
 }
 
 type AddressInfo struct {
@@ -47,13 +74,116 @@ func decodeJSONRequest(r *http.Request) (*AddressAction, error) {
 	return &address, nil
 }
 
+func addressFlagNames(flags int) []string {
+	var names []string
+	for _, f := range addressFlags {
+		if flags&f.Flag != 0 {
+			names = append(names, f.Name)
+		}
+	}
+
+	return names
+}
+
+// settableAddressFlags masks the raw flags of a request, so that they can not set what the
+// names may not
+func settableAddressFlags() int {
+	mask := 0
+	for _, f := range addressFlags {
+		if f.Settable {
+			mask |= f.Flag
+		}
+	}
+
+	return mask
+}
+
+func parseAddressFlags(names []string) (int, error) {
+	flags := 0
+	for _, n := range names {
+		found := false
+		for _, f := range addressFlags {
+			if f.Name == strings.ToLower(n) && f.Settable {
+				flags |= f.Flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid address flag '%s'", n)
+		}
+	}
+
+	return flags, nil
+}
+
+// buildAddr converts the request into the netlink address. IP takes the prefix length either
+// as CIDR or in Mask, and Peer as an address or a CIDR. Lifetimes are in seconds, 0 is forever
+func (a *AddressAction) buildAddr() (*netlink.Addr, error) {
+	ip := a.Address.IP
+	if !strings.Contains(ip, "/") && a.Address.Mask > 0 {
+		ip = fmt.Sprintf("%s/%d", ip, a.Address.Mask)
+	}
+
+	addr, err := netlink.ParseAddr(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	addr.Label = a.Address.Label
+	addr.Scope = a.Address.Scope
+
+	flags, err := parseAddressFlags(a.Address.FlagNames)
+	if err != nil {
+		return nil, err
+	}
+	// Flags of an acquired address such as permanent are dropped, so that it can be passed back
+	addr.Flags = a.Address.Flags&settableAddressFlags() | flags
+
+	if a.Address.Peer != "" {
+		peer := a.Address.Peer
+		if !strings.Contains(peer, "/") {
+			ones, _ := addr.Mask.Size()
+			peer = fmt.Sprintf("%s/%d", peer, ones)
+		}
+
+		addr.Peer, err = netlink.ParseIPNet(peer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.Address.Broadcast != "" {
+		addr.Broadcast = net.ParseIP(a.Address.Broadcast)
+		if addr.Broadcast == nil {
+			return nil, fmt.Errorf("invalid broadcast address '%s'", a.Address.Broadcast)
+		}
+	}
+
+	if a.Address.ValidLft < 0 || a.Address.PreferedLft < 0 {
+		return nil, fmt.Errorf("invalid address lifetime")
+	}
+	if a.Address.ValidLft > 0 || a.Address.PreferedLft > 0 {
+		addr.ValidLft = a.Address.ValidLft
+		addr.PreferedLft = a.Address.PreferedLft
+		if addr.ValidLft == 0 {
+			addr.ValidLft = int(^uint32(0))
+		}
+		if addr.PreferedLft == 0 || addr.PreferedLft > addr.ValidLft {
+			addr.PreferedLft = addr.ValidLft
+		}
+	}
+
+	return addr, nil
+}
+
 func (a *AddressAction) Add() error {
 	link, err := netlink.LinkByName(a.Link)
 	if err != nil {
 		return err
 	}
 
-	addr, err := netlink.ParseAddr(a.Address.IP)
+	addr, err := a.buildAddr()
 	if err != nil {
 		return err
 	}
@@ -65,13 +195,32 @@ func (a *AddressAction) Add() error {
 	return nil
 }
 
+// Replace adds the address or updates the flags and lifetimes of the one present
+func (a *AddressAction) Replace() error {
+	link, err := netlink.LinkByName(a.Link)
+	if err != nil {
+		return err
+	}
+
+	addr, err := a.buildAddr()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.AddrReplace(link, addr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (a *AddressAction) Remove() error {
 	link, err := netlink.LinkByName(a.Link)
 	if err != nil {
 		return err
 	}
 
-	addr, err := netlink.ParseAddr(a.Address.IP)
+	addr, err := a.buildAddr()
 	if err != nil {
 		return err
 	}
@@ -89,6 +238,7 @@ func fillOneAddress(a *netlink.Addr) Address {
 		Label:       a.Label,
 		Scope:       a.Scope,
 		Flags:       a.Flags,
+		FlagNames:   addressFlagNames(a.Flags),
 		PreferedLft: a.PreferedLft,
 		ValidLft:    a.ValidLft,
 	}
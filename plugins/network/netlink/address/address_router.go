@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
@@ -20,8 +21,33 @@ func routerAcquireAddress(w http.ResponseWriter, r *http.Request) {
 	web.JSONResponse(addrs, w)
 }
 
+func routerConfigureAddress(w http.ResponseWriter, r *http.Request) {
+	a, err := decodeJSONRequest(r)
+	if err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		err = a.Add()
+	case "PUT":
+		err = a.Replace()
+	case "DELETE":
+		err = a.Remove()
+	}
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
 func RegisterRouterAddress(router *mux.Router) {
 	s := router.PathPrefix("/netlink").Subrouter().StrictSlash(false)
+	s.Use(netns.Middleware)
 
 	s.HandleFunc("/address", routerAcquireAddress).Methods("GET")
+	s.HandleFunc("/address", routerConfigureAddress).Methods("POST", "PUT", "DELETE")
 }
@@ -4,6 +4,8 @@
 package link
 
 import (
+	"net"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 )
@@ -22,3 +24,99 @@ func setMTU(link string, mtu int) error {
 
 	return nil
 }
+
+func setState(link string, up bool) error {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return err
+	}
+
+	if up {
+		err = netlink.LinkSetUp(l)
+	} else {
+		err = netlink.LinkSetDown(l)
+	}
+	if err != nil {
+		log.Errorf("Failed to set link='%s' up='%t': %v", link, up, err)
+		return err
+	}
+
+	return nil
+}
+
+func setHardwareAddr(link string, mac net.HardwareAddr) error {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return err
+	}
+
+	if err = netlink.LinkSetHardwareAddr(l, mac); err != nil {
+		log.Errorf("Failed to set link='%s' MAC='%s': %v", link, mac, err)
+		return err
+	}
+
+	return nil
+}
+
+func setTxQLen(link string, qlen int) error {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return err
+	}
+
+	if err = netlink.LinkSetTxQLen(l, qlen); err != nil {
+		log.Errorf("Failed to set link='%s' TxQLen='%d': %v", link, qlen, err)
+		return err
+	}
+
+	return nil
+}
+
+func setAlias(link string, alias string) error {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return err
+	}
+
+	if err = netlink.LinkSetAlias(l, alias); err != nil {
+		log.Errorf("Failed to set link='%s' alias='%s': %v", link, alias, err)
+		return err
+	}
+
+	return nil
+}
+
+// setMaster enslaves the link to the master, an empty master releases it
+func setMaster(link string, master string) error {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return err
+	}
+
+	if master == "" {
+		if err = netlink.LinkSetNoMaster(l); err != nil {
+			log.Errorf("Failed to release link='%s' from its master: %v", link, err)
+			return err
+		}
+
+		return nil
+	}
+
+	m, err := netlink.LinkByName(master)
+	if err != nil {
+		log.Errorf("Failed to find master link='%s': %v", master, err)
+		return err
+	}
+
+	if err = netlink.LinkSetMaster(l, m); err != nil {
+		log.Errorf("Failed to set link='%s' master='%s': %v", link, master, err)
+		return err
+	}
+
+	return nil
+}
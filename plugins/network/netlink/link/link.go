@@ -4,6 +4,11 @@
 package link
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
 	"github.com/vishvananda/netlink"
 )
 
@@ -16,6 +21,18 @@ type Link struct {
 	Enslave []string `json:"Enslave"`
 }
 
+// LinkSetting changes the runtime attributes of a link, empty fields are left as they are.
+// Master takes "none" to release the link from its master
+type LinkSetting struct {
+	Name         string `json:"Name"`
+	State        string `json:"State"`
+	MTU          string `json:"MTU"`
+	HardwareAddr string `json:"HardwareAddr"`
+	TxQLen       string `json:"TxQLen"`
+	Alias        string `json:"Alias"`
+	Master       string `json:"Master"`
+}
+
 type LinkInfo struct {
 	Index            int                     `json:"Index"`
 	Mtu              int                     `json:"MTU"`
@@ -94,3 +111,85 @@ func AcquireLinks() ([]LinkInfo, error) {
 
 	return j, nil
 }
+
+// Configure applies the setting. The link is brought down first and up last, as most drivers
+// change the MAC address only while the link is down
+func (l *LinkSetting) Configure() error {
+	if l.Name == "" {
+		return errors.New("missing link")
+	}
+
+	var up, down bool
+	switch l.State {
+	case "":
+	case "up":
+		up = true
+	case "down":
+		down = true
+	default:
+		return fmt.Errorf("invalid link state '%s'", l.State)
+	}
+
+	var mtu, qlen int
+	var mac net.HardwareAddr
+	var err error
+	if l.MTU != "" {
+		if mtu, err = strconv.Atoi(l.MTU); err != nil || mtu <= 0 {
+			return fmt.Errorf("invalid MTU '%s'", l.MTU)
+		}
+	}
+	if l.TxQLen != "" {
+		if qlen, err = strconv.Atoi(l.TxQLen); err != nil || qlen < 0 {
+			return fmt.Errorf("invalid TxQLen '%s'", l.TxQLen)
+		}
+	}
+	if l.HardwareAddr != "" {
+		if mac, err = net.ParseMAC(l.HardwareAddr); err != nil {
+			return fmt.Errorf("invalid MAC address '%s'", l.HardwareAddr)
+		}
+	}
+
+	if down {
+		if err := setState(l.Name, false); err != nil {
+			return err
+		}
+	}
+
+	if l.MTU != "" {
+		if err := setMTU(l.Name, mtu); err != nil {
+			return err
+		}
+	}
+	if l.HardwareAddr != "" {
+		if err := setHardwareAddr(l.Name, mac); err != nil {
+			return err
+		}
+	}
+	if l.TxQLen != "" {
+		if err := setTxQLen(l.Name, qlen); err != nil {
+			return err
+		}
+	}
+	if l.Alias != "" {
+		if err := setAlias(l.Name, l.Alias); err != nil {
+			return err
+		}
+	}
+	switch l.Master {
+	case "":
+	case "none":
+		if err := setMaster(l.Name, ""); err != nil {
+			return err
+		}
+	default:
+		if err := setMaster(l.Name, l.Master); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		return setState(l.Name, true)
+	}
+
+	return nil
+}
@@ -4,10 +4,12 @@
 package link
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
@@ -20,8 +22,26 @@ func routerAcquireLink(w http.ResponseWriter, r *http.Request) {
 	web.JSONResponse(links, w)
 }
 
+func routerConfigureLink(w http.ResponseWriter, r *http.Request) {
+	l := LinkSetting{}
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+	l.Name = mux.Vars(r)["link"]
+
+	if err := l.Configure(); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
 func RegisterRouterLink(router *mux.Router) {
 	s := router.PathPrefix("/netlink").Subrouter().StrictSlash(false)
+	s.Use(netns.Middleware)
 
 	s.HandleFunc("/link", routerAcquireLink).Methods("GET")
+	s.HandleFunc("/link/{link}", routerConfigureLink).Methods("PUT")
 }
@@ -0,0 +1,460 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package neigh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// Neighbour states by the names ip-neighbour(8) prints
+	neighStates = []struct {
+		Name  string
+		State int
+	}{
+		{"INCOMPLETE", netlink.NUD_INCOMPLETE},
+		{"REACHABLE", netlink.NUD_REACHABLE},
+		{"STALE", netlink.NUD_STALE},
+		{"DELAY", netlink.NUD_DELAY},
+		{"PROBE", netlink.NUD_PROBE},
+		{"FAILED", netlink.NUD_FAILED},
+		{"NOARP", netlink.NUD_NOARP},
+		{"PERMANENT", netlink.NUD_PERMANENT},
+	}
+
+	neighFlags = []struct {
+		Name string
+		Flag int
+	}{
+		{"use", netlink.NTF_USE},
+		{"self", netlink.NTF_SELF},
+		{"master", netlink.NTF_MASTER},
+		{"proxy", netlink.NTF_PROXY},
+		{"extern_learn", unix.NTF_EXT_LEARNED},
+		{"offload", unix.NTF_OFFLOADED},
+		{"router", netlink.NTF_ROUTER},
+	}
+
+	// States an entry can be added with
+	neighRequestStates = map[string]int{
+		"permanent": netlink.NUD_PERMANENT,
+		"noarp":     netlink.NUD_NOARP,
+		"reachable": netlink.NUD_REACHABLE,
+		"stale":     netlink.NUD_STALE,
+	}
+)
+
+type Neighbor struct {
+	Link         string   `json:"Link"`
+	LinkIndex    int      `json:"LinkIndex"`
+	Family       string   `json:"Family"`
+	IP           string   `json:"IP"`
+	HardwareAddr string   `json:"HardwareAddr"`
+	State        []string `json:"State"`
+	Flags        []string `json:"Flags"`
+	Vlan         int      `json:"Vlan"`
+	VNI          int      `json:"VNI"`
+	Master       string   `json:"Master"`
+}
+
+// NeighborRequest adds, replaces or removes an ARP or NDP entry. State takes permanent, noarp,
+// reachable or stale and defaults to permanent
+type NeighborRequest struct {
+	Link         string `json:"Link"`
+	IP           string `json:"IP"`
+	HardwareAddr string `json:"HardwareAddr"`
+	State        string `json:"State"`
+	Router       bool   `json:"Router"`
+	Proxy        bool   `json:"Proxy"`
+}
+
+// FDBRequest adds, replaces or removes a bridge forwarding database entry. State takes
+// permanent, static or dynamic and defaults to static. Master programs the bridge the link is
+// enslaved to, Self the link itself; Self is implied when neither is set. Dst and VNI are the
+// remote of a VXLAN link
+type FDBRequest struct {
+	Link         string `json:"Link"`
+	HardwareAddr string `json:"HardwareAddr"`
+	State        string `json:"State"`
+	Master       bool   `json:"Master"`
+	Self         bool   `json:"Self"`
+	Vlan         int    `json:"Vlan"`
+	VNI          int    `json:"VNI"`
+	Dst          string `json:"Dst"`
+}
+
+func familyName(family int) string {
+	switch family {
+	case netlink.FAMILY_V4:
+		return "ipv4"
+	case netlink.FAMILY_V6:
+		return "ipv6"
+	case unix.AF_BRIDGE:
+		return "bridge"
+	}
+
+	return fmt.Sprintf("%d", family)
+}
+
+func stateNames(state int) []string {
+	names := []string{}
+	for _, s := range neighStates {
+		if state&s.State != 0 {
+			names = append(names, s.Name)
+		}
+	}
+
+	return names
+}
+
+func flagNames(flags int) []string {
+	names := []string{}
+	for _, f := range neighFlags {
+		if flags&f.Flag != 0 {
+			names = append(names, f.Name)
+		}
+	}
+
+	return names
+}
+
+func linkName(index int) string {
+	if index == 0 {
+		return ""
+	}
+
+	l, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+
+	return l.Attrs().Name
+}
+
+func fillOneNeighbor(n *netlink.Neigh) Neighbor {
+	nb := Neighbor{
+		Link:      linkName(n.LinkIndex),
+		LinkIndex: n.LinkIndex,
+		Family:    familyName(n.Family),
+		State:     stateNames(n.State),
+		Flags:     flagNames(n.Flags),
+		Vlan:      n.Vlan,
+		VNI:       n.VNI,
+		Master:    linkName(n.MasterIndex),
+	}
+
+	if n.IP != nil {
+		nb.IP = n.IP.String()
+	}
+	if n.HardwareAddr != nil {
+		nb.HardwareAddr = n.HardwareAddr.String()
+	}
+
+	return nb
+}
+
+func linkIndex(link string) (int, error) {
+	if link == "" {
+		return 0, nil
+	}
+
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		log.Errorf("Failed to find link='%s': %v", link, err)
+		return 0, err
+	}
+
+	return l.Attrs().Index, nil
+}
+
+// AcquireNeighbors lists the IPv4 and IPv6 neighbours of the link or of all links. family takes
+// ipv4, ipv6 or empty for both
+func AcquireNeighbors(link string, family string) ([]Neighbor, error) {
+	index, err := linkIndex(link)
+	if err != nil {
+		return nil, err
+	}
+
+	var families []int
+	switch family {
+	case "":
+		families = []int{netlink.FAMILY_V4, netlink.FAMILY_V6}
+	case "ipv4":
+		families = []int{netlink.FAMILY_V4}
+	case "ipv6":
+		families = []int{netlink.FAMILY_V6}
+	default:
+		return nil, fmt.Errorf("invalid family '%s'", family)
+	}
+
+	l := []Neighbor{}
+	for _, f := range families {
+		neighs, err := netlink.NeighList(index, f)
+		if err != nil {
+			return nil, err
+		}
+
+		proxies, err := netlink.NeighProxyList(index, f)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range append(neighs, proxies...) {
+			l = append(l, fillOneNeighbor(&n))
+		}
+	}
+
+	return l, nil
+}
+
+// AcquireFDB lists the bridge forwarding database entries of the link or of all links
+func AcquireFDB(link string) ([]Neighbor, error) {
+	index, err := linkIndex(link)
+	if err != nil {
+		return nil, err
+	}
+
+	neighs, err := netlink.NeighList(index, unix.AF_BRIDGE)
+	if err != nil {
+		return nil, err
+	}
+
+	l := []Neighbor{}
+	for _, n := range neighs {
+		l = append(l, fillOneNeighbor(&n))
+	}
+
+	return l, nil
+}
+
+func (r *NeighborRequest) buildNeigh() (*netlink.Neigh, error) {
+	index, err := linkIndex(r.Link)
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		return nil, errors.New("missing link")
+	}
+
+	n := netlink.Neigh{
+		LinkIndex: index,
+		State:     netlink.NUD_PERMANENT,
+	}
+
+	n.IP = net.ParseIP(r.IP)
+	if n.IP == nil {
+		return nil, fmt.Errorf("invalid neighbour address '%s'", r.IP)
+	}
+	if n.IP.To4() != nil {
+		n.Family = netlink.FAMILY_V4
+	} else {
+		n.Family = netlink.FAMILY_V6
+	}
+
+	if r.HardwareAddr != "" {
+		if n.HardwareAddr, err = net.ParseMAC(r.HardwareAddr); err != nil {
+			return nil, fmt.Errorf("invalid MAC address '%s'", r.HardwareAddr)
+		}
+	}
+
+	if r.State != "" {
+		s, ok := neighRequestStates[strings.ToLower(r.State)]
+		if !ok {
+			return nil, fmt.Errorf("invalid neighbour state '%s'", r.State)
+		}
+		n.State = s
+	}
+
+	if r.Router {
+		n.Flags |= netlink.NTF_ROUTER
+	}
+	if r.Proxy {
+		n.Flags |= netlink.NTF_PROXY
+		n.State = netlink.NUD_NONE
+	}
+
+	return &n, nil
+}
+
+func (r *NeighborRequest) Add() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if n.HardwareAddr == nil && !r.Proxy {
+		return errors.New("missing MAC address")
+	}
+
+	if err := netlink.NeighAdd(n); err != nil {
+		log.Errorf("Failed to add neighbour='%s' link='%s': %v", r.IP, r.Link, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *NeighborRequest) Replace() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if n.HardwareAddr == nil && !r.Proxy {
+		return errors.New("missing MAC address")
+	}
+
+	if err := netlink.NeighSet(n); err != nil {
+		log.Errorf("Failed to replace neighbour='%s' link='%s': %v", r.IP, r.Link, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *NeighborRequest) Remove() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.NeighDel(n); err != nil {
+		log.Errorf("Failed to remove neighbour='%s' link='%s': %v", r.IP, r.Link, err)
+		return err
+	}
+
+	return nil
+}
+
+// FlushNeighbors removes the neighbours of the link like ip-neighbour(8) flush does. Permanent
+// and noarp entries are kept unless all is set. It returns the number of removed entries
+func FlushNeighbors(link string, all bool) (int, error) {
+	index, err := linkIndex(link)
+	if err != nil {
+		return 0, err
+	}
+	if index == 0 {
+		return 0, errors.New("missing link")
+	}
+
+	removed := 0
+	for _, f := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		neighs, err := netlink.NeighList(index, f)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, n := range neighs {
+			if !all && n.State&(netlink.NUD_PERMANENT|netlink.NUD_NOARP) != 0 {
+				continue
+			}
+
+			if err := netlink.NeighDel(&n); err != nil {
+				log.Errorf("Failed to remove neighbour='%s' link='%s': %v", n.IP, link, err)
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	log.Infof("Flushed %d neighbours of link='%s'", removed, link)
+	return removed, nil
+}
+
+func (r *FDBRequest) buildNeigh() (*netlink.Neigh, error) {
+	index, err := linkIndex(r.Link)
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		return nil, errors.New("missing link")
+	}
+
+	n := netlink.Neigh{
+		LinkIndex: index,
+		Family:    unix.AF_BRIDGE,
+		State:     netlink.NUD_NOARP,
+		Vlan:      r.Vlan,
+		VNI:       r.VNI,
+	}
+
+	if n.HardwareAddr, err = net.ParseMAC(r.HardwareAddr); err != nil {
+		return nil, fmt.Errorf("invalid MAC address '%s'", r.HardwareAddr)
+	}
+
+	switch strings.ToLower(r.State) {
+	case "", "static":
+	case "permanent":
+		n.State |= netlink.NUD_PERMANENT
+	case "dynamic":
+		n.State = netlink.NUD_REACHABLE
+	default:
+		return nil, fmt.Errorf("invalid FDB state '%s'", r.State)
+	}
+
+	if r.Master {
+		n.Flags |= netlink.NTF_MASTER
+	}
+	if r.Self || !r.Master {
+		n.Flags |= netlink.NTF_SELF
+	}
+
+	if r.Dst != "" {
+		n.IP = net.ParseIP(r.Dst)
+		if n.IP == nil {
+			return nil, fmt.Errorf("invalid FDB destination '%s'", r.Dst)
+		}
+	}
+
+	return &n, nil
+}
+
+func (r *FDBRequest) Add() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.NeighAdd(n); err != nil {
+		log.Errorf("Failed to add FDB entry='%s' link='%s': %v", r.HardwareAddr, r.Link, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *FDBRequest) Replace() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.NeighSet(n); err != nil {
+		log.Errorf("Failed to replace FDB entry='%s' link='%s': %v", r.HardwareAddr, r.Link, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *FDBRequest) Remove() error {
+	n, err := r.buildNeigh()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.NeighDel(n); err != nil {
+		log.Errorf("Failed to remove FDB entry='%s' link='%s': %v", r.HardwareAddr, r.Link, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package neigh
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/netns"
+	"github.com/vmware/pmd-next-gen/pkg/parser"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerAcquireNeighbors(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireNeighbors(r.URL.Query().Get("link"), r.URL.Query().Get("family"))
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func routerConfigureNeighbor(w http.ResponseWriter, r *http.Request) {
+	n := NeighborRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case "POST":
+		err = n.Add()
+	case "PUT":
+		err = n.Replace()
+	case "DELETE":
+		err = n.Remove()
+	}
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
+func routerFlushNeighbors(w http.ResponseWriter, r *http.Request) {
+	all := false
+	if v := r.URL.Query().Get("all"); v != "" {
+		b, err := parser.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Error decoding request", http.StatusBadRequest)
+			return
+		}
+		all = b
+	}
+
+	n, err := FlushNeighbors(mux.Vars(r)["link"], all)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(n, w)
+}
+
+func routerAcquireFDB(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireFDB(r.URL.Query().Get("link"))
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func routerConfigureFDB(w http.ResponseWriter, r *http.Request) {
+	f := FDBRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case "POST":
+		err = f.Add()
+	case "PUT":
+		err = f.Replace()
+	case "DELETE":
+		err = f.Remove()
+	}
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
+func RegisterRouterNeigh(router *mux.Router) {
+	s := router.PathPrefix("/netlink").Subrouter().StrictSlash(false)
+	s.Use(netns.Middleware)
+
+	s.HandleFunc("/neigh", routerAcquireNeighbors).Methods("GET")
+	s.HandleFunc("/neigh", routerConfigureNeighbor).Methods("POST", "PUT", "DELETE")
+	s.HandleFunc("/neigh/fdb", routerAcquireFDB).Methods("GET")
+	s.HandleFunc("/neigh/fdb", routerConfigureFDB).Methods("POST", "PUT", "DELETE")
+	s.HandleFunc("/neigh/flush/{link}", routerFlushNeighbors).Methods("DELETE")
+}
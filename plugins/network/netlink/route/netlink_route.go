@@ -5,14 +5,29 @@ package route
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
 	"syscall"
 
+	"github.com/vmware/pmd-next-gen/pkg/iproute2"
 	"github.com/vmware/pmd-next-gen/pkg/parser"
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	routeTypes = map[string]int{
+		"unicast":     unix.RTN_UNICAST,
+		"local":       unix.RTN_LOCAL,
+		"blackhole":   unix.RTN_BLACKHOLE,
+		"unreachable": unix.RTN_UNREACHABLE,
+		"prohibit":    unix.RTN_PROHIBIT,
+		"throw":       unix.RTN_THROW,
+	}
 )
 
 type Route struct {
@@ -22,6 +37,29 @@ type Route struct {
 	OnLink  string `json:"onlink"`
 }
 
+type NextHop struct {
+	Link    string `json:"Link"`
+	Gateway string `json:"Gateway"`
+	Weight  int    `json:"Weight"`
+	OnLink  bool   `json:"OnLink"`
+}
+
+// RouteRequest adds, replaces or removes a route at runtime. Destination is a prefix or
+// "default", Table and Scope take a number or a name from /etc/iproute2, Type unicast, local, blackhole,
+// unreachable, prohibit or throw. Empty fields take the kernel defaults
+type RouteRequest struct {
+	Destination string    `json:"Destination"`
+	Link        string    `json:"Link"`
+	Gateway     string    `json:"Gateway"`
+	OnLink      bool      `json:"OnLink"`
+	Source      string    `json:"Source"`
+	Table       string    `json:"Table"`
+	Metric      uint32    `json:"Metric"`
+	Scope       string    `json:"Scope"`
+	Type        string    `json:"Type"`
+	MultiPath   []NextHop `json:"MultiPath"`
+}
+
 type RouteInfo struct {
 	LinkName   string `json:"LinkName"`
 	LinkIndex  int    `json:"LinkIndex"`
@@ -31,21 +69,25 @@ type RouteInfo struct {
 		IP   string `json:"IP"`
 		Mask int    `json:"Mask"`
 	} `json:"Dst"`
-	Src       string   `json:"Src"`
-	Gw        string   `json:"Gw"`
-	MultiPath string   `json:"MultiPath"`
-	Protocol  int      `json:"Protocol"`
-	Priority  int      `json:"Priority"`
-	Table     int      `json:"Table"`
-	Type      int      `json:"Type"`
-	Tos       int      `json:"Tos"`
-	Flags     []string `json:"Flags"`
-	MPLSDst   string   `json:"MPLSDst"`
-	NewDst    string   `json:"NewDst"`
-	Encap     string   `json:"Encap"`
-	Mtu       int      `json:"MTU"`
-	AdvMSS    int      `json:"AdvMSS"`
-	Hoplimit  int      `json:"Hoplimit"`
+	Src          string   `json:"Src"`
+	Gw           string   `json:"Gw"`
+	MultiPath    string   `json:"MultiPath"`
+	Protocol     int      `json:"Protocol"`
+	Priority     int      `json:"Priority"`
+	Table        int      `json:"Table"`
+	Type         int      `json:"Type"`
+	Tos          int      `json:"Tos"`
+	Flags        []string `json:"Flags"`
+	MPLSDst      string   `json:"MPLSDst"`
+	NewDst       string   `json:"NewDst"`
+	Encap        string   `json:"Encap"`
+	Mtu          int      `json:"MTU"`
+	AdvMSS       int      `json:"AdvMSS"`
+	Hoplimit     int      `json:"Hoplimit"`
+	ScopeName    string   `json:"ScopeName"`
+	ProtocolName string   `json:"ProtocolName"`
+	TableName    string   `json:"TableName"`
+	TypeName     string   `json:"TypeName"`
 }
 
 func decodeJSONRequest(r *http.Request) (*Route, error) {
@@ -162,15 +204,271 @@ func (rt *Route) RemoveGateWay() error {
 	return nil
 }
 
-func fillOneRoute(rt *netlink.Route) *RouteInfo {
-	link, err := netlink.LinkByIndex(rt.LinkIndex)
+func parseRouteTable(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	t, err := iproute2.ParseTable(s)
+	if err != nil || t == unix.RT_TABLE_UNSPEC {
+		return 0, fmt.Errorf("invalid route table '%s'", s)
+	}
+
+	return t, nil
+}
+
+func parseRouteScope(s string) (netlink.Scope, error) {
+	sc, err := iproute2.ParseScope(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return netlink.Scope(sc), nil
+}
+
+func parseRouteIP(s string, what string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid %s '%s'", what, s)
+	}
+
+	return ip, nil
+}
+
+// buildRoute converts the request into the netlink route. A default destination takes the
+// family of the other addresses like ip-route(8) does. Scope and type are left to the caller
+// unless the request sets them, adding and removing a route default to different values
+func (rt *RouteRequest) buildRoute() (*netlink.Route, error) {
+	route := netlink.Route{
+		Priority: int(rt.Metric),
+	}
+
+	var err error
+	if rt.Link != "" {
+		link, err := netlink.LinkByName(rt.Link)
+		if err != nil {
+			return nil, err
+		}
+		route.LinkIndex = link.Attrs().Index
+	}
+
+	if rt.Gateway != "" {
+		if route.Gw, err = parseRouteIP(rt.Gateway, "gateway"); err != nil {
+			return nil, err
+		}
+	}
+	if rt.OnLink {
+		route.Flags |= syscall.RTNH_F_ONLINK
+	}
+
+	if rt.Source != "" {
+		if route.Src, err = parseRouteIP(rt.Source, "source"); err != nil {
+			return nil, err
+		}
+	}
+
+	if route.Table, err = parseRouteTable(rt.Table); err != nil {
+		return nil, err
+	}
+
+	if rt.Type != "" {
+		t, ok := routeTypes[rt.Type]
+		if !ok {
+			return nil, fmt.Errorf("invalid route type '%s'", rt.Type)
+		}
+		route.Type = t
+	}
+
+	for _, nh := range rt.MultiPath {
+		if nh.Weight < 0 || nh.Weight > 256 {
+			return nil, fmt.Errorf("invalid nexthop weight '%d'", nh.Weight)
+		}
+
+		h := netlink.NexthopInfo{}
+		if nh.Weight > 0 {
+			h.Hops = nh.Weight - 1
+		}
+
+		if nh.Link != "" {
+			link, err := netlink.LinkByName(nh.Link)
+			if err != nil {
+				return nil, err
+			}
+			h.LinkIndex = link.Attrs().Index
+		}
+		if nh.Gateway != "" {
+			if h.Gw, err = parseRouteIP(nh.Gateway, "nexthop gateway"); err != nil {
+				return nil, err
+			}
+		}
+		if nh.OnLink {
+			h.Flags |= syscall.RTNH_F_ONLINK
+		}
+
+		route.MultiPath = append(route.MultiPath, &h)
+	}
+
+	switch rt.Destination {
+	case "":
+		return nil, errors.New("missing route destination")
+	case "default":
+	default:
+		if route.Dst, err = netlink.ParseIPNet(rt.Destination); err != nil {
+			if ip := net.ParseIP(rt.Destination); ip != nil {
+				route.Dst = netlink.NewIPNet(ip)
+			} else {
+				return nil, fmt.Errorf("invalid route destination '%s'", rt.Destination)
+			}
+		}
+	}
+
+	family, err := routeFamily(&route)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt.Destination == "default" {
+		if family == netlink.FAMILY_V6 {
+			_, route.Dst, _ = net.ParseCIDR("::/0")
+		} else {
+			_, route.Dst, _ = net.ParseCIDR("0.0.0.0/0")
+		}
+	}
+
+	if rt.Scope != "" {
+		if route.Scope, err = parseRouteScope(rt.Scope); err != nil {
+			return nil, err
+		}
+	}
+
+	return &route, nil
+}
+
+// routeFamily returns the family of the addresses of the route, its destination, gateway, source
+// and the gateways of its next hops, which must all agree. A route without any is IPv4 like
+// ip-route(8) assumes
+func routeFamily(route *netlink.Route) (int, error) {
+	var ips []net.IP
+	if route.Dst != nil {
+		ips = append(ips, route.Dst.IP)
+	}
+	ips = append(ips, route.Gw, route.Src)
+	for _, nh := range route.MultiPath {
+		ips = append(ips, nh.Gw)
+	}
+
+	family := netlink.FAMILY_ALL
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+
+		f := netlink.FAMILY_V6
+		if ip.To4() != nil {
+			f = netlink.FAMILY_V4
+		}
+
+		if family != netlink.FAMILY_ALL && family != f {
+			return 0, errors.New("route addresses of different families")
+		}
+		family = f
+	}
+
+	if family == netlink.FAMILY_ALL {
+		family = netlink.FAMILY_V4
+	}
+
+	return family, nil
+}
+
+// setDefaultScope scopes a route the way ip-route(8) does when it is added without a scope:
+// a local route to the host and a unicast route without a gateway to the link. A route without
+// a type is added as unicast
+func (rt *RouteRequest) setDefaultScope(route *netlink.Route) {
+	if rt.Scope != "" {
+		return
+	}
+
+	switch {
+	case route.Type == unix.RTN_LOCAL:
+		route.Scope = netlink.SCOPE_HOST
+	case (route.Type == unix.RTN_UNSPEC || route.Type == unix.RTN_UNICAST) && route.Gw == nil && len(route.MultiPath) == 0:
+		route.Scope = netlink.SCOPE_LINK
+	}
+}
+
+// buildRemoveRoute builds the route to remove. Like ip-route(8) it matches a route of any scope
+// and type unless the request names them
+func (rt *RouteRequest) buildRemoveRoute() (*netlink.Route, error) {
+	route, err := rt.buildRoute()
+	if err != nil {
+		return nil, err
+	}
+
+	if rt.Scope == "" {
+		route.Scope = netlink.SCOPE_NOWHERE
+	}
+
+	return route, nil
+}
+
+func (rt *RouteRequest) Add() error {
+	route, err := rt.buildRoute()
+	if err != nil {
+		return err
+	}
+	rt.setDefaultScope(route)
+
+	if err := netlink.RouteAdd(route); err != nil {
+		log.Errorf("Failed to add route destination='%s': %v", rt.Destination, err)
+		return err
+	}
+
+	return nil
+}
+
+func (rt *RouteRequest) Replace() error {
+	route, err := rt.buildRoute()
 	if err != nil {
-		log.Debugf("Failed to acquire link ifindex='%d': %v", rt.LinkIndex, err)
-		return nil
+		return err
+	}
+	rt.setDefaultScope(route)
+
+	if err := netlink.RouteReplace(route); err != nil {
+		log.Errorf("Failed to replace route destination='%s': %v", rt.Destination, err)
+		return err
+	}
+
+	return nil
+}
+
+func (rt *RouteRequest) Remove() error {
+	route, err := rt.buildRemoveRoute()
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.RouteDel(route); err != nil {
+		log.Errorf("Failed to remove route destination='%s': %v", rt.Destination, err)
+		return err
+	}
+
+	return nil
+}
+
+func fillOneRoute(rt *netlink.Route, names *iproute2.Names) *RouteInfo {
+	var name string
+	if rt.LinkIndex != 0 {
+		link, err := netlink.LinkByIndex(rt.LinkIndex)
+		if err != nil {
+			log.Debugf("Failed to acquire link ifindex='%d': %v", rt.LinkIndex, err)
+			return nil
+		}
+		name = link.Attrs().Name
 	}
 
 	route := RouteInfo{
-		LinkName:   link.Attrs().Name,
+		LinkName:   name,
 		LinkIndex:  rt.LinkIndex,
 		ILinkIndex: rt.ILinkIndex,
 		Scope:      int(rt.Scope),
@@ -182,6 +480,11 @@ func fillOneRoute(rt *netlink.Route) *RouteInfo {
 		Mtu:        rt.MTU,
 		AdvMSS:     rt.AdvMSS,
 		Hoplimit:   rt.Hoplimit,
+
+		ScopeName:    names.ScopeName(int(rt.Scope)),
+		ProtocolName: names.ProtocolName(rt.Protocol),
+		TableName:    names.TableName(rt.Table),
+		TypeName:     iproute2.TypeName(rt.Type),
 	}
 
 	if rt.Gw != nil {
@@ -192,6 +495,14 @@ func fillOneRoute(rt *netlink.Route) *RouteInfo {
 		route.Src = rt.Src.String()
 	}
 
+	if len(rt.MultiPath) > 0 {
+		var l []string
+		for _, nh := range rt.MultiPath {
+			l = append(l, nh.String())
+		}
+		route.MultiPath = strings.Join(l, " ")
+	}
+
 	if rt.Dst != nil {
 		route.Dst.IP = rt.Dst.IP.String()
 		route.Dst.Mask, _ = rt.Dst.Mask.Size()
@@ -205,13 +516,16 @@ func fillOneRoute(rt *netlink.Route) *RouteInfo {
 }
 
 func buildRouteList(routes []netlink.Route) []RouteInfo {
+	names := iproute2.Load()
+
 	var rts []RouteInfo
 	for _, rt := range routes {
-		if rt.LinkIndex == 0 {
+		// Routes without a link are only of interest when they reject traffic or spread it
+		if rt.LinkIndex == 0 && rt.Type == unix.RTN_UNICAST && len(rt.MultiPath) == 0 {
 			continue
 		}
 
-		route := fillOneRoute(&rt)
+		route := fillOneRoute(&rt, names)
 		if route != nil {
 			rts = append(rts, *route)
 		}
@@ -229,6 +543,30 @@ func AcquireRoutes() ([]RouteInfo, error) {
 	return buildRouteList(routes), nil
 }
 
+// AcquireRoutesByTable lists the routes of a table given as number or name from /etc/iproute2.
+// An empty table lists the main table and "all" every table
+func AcquireRoutesByTable(table string) ([]RouteInfo, error) {
+	if table == "" {
+		return AcquireRoutes()
+	}
+
+	filter := netlink.Route{}
+	if table != "all" {
+		t, err := iproute2.ParseTable(table)
+		if err != nil {
+			return nil, err
+		}
+		filter.Table = t
+	}
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildRouteList(routes), nil
+}
+
 func (rt *Route) Configure() error {
 	switch rt.Action {
 	case "add-default-gw":
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package route
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestBuildRouteDefaultFamily(t *testing.T) {
+	// The default destination follows the family of whichever other address the route has
+	routes := map[string]RouteRequest{
+		"0.0.0.0/0": {Destination: "default", Gateway: "192.168.0.1"},
+		"::/0":      {Destination: "default", Gateway: "fe80::1"},
+	}
+	v6 := []RouteRequest{
+		{Destination: "default", Source: "2001:db8::1", Type: "unreachable"},
+		{Destination: "default", MultiPath: []NextHop{{Gateway: "2001:db8::1"}, {Gateway: "2001:db8::2", Weight: 2}}},
+	}
+
+	for dst, rt := range routes {
+		route, err := rt.buildRoute()
+		if err != nil {
+			t.Fatalf("Failed to build route %+v: %v", rt, err)
+		}
+		if route.Dst.String() != dst {
+			t.Errorf("Default route via '%s' goes to '%s', want '%s'", rt.Gateway, route.Dst, dst)
+		}
+	}
+
+	for _, rt := range v6 {
+		route, err := rt.buildRoute()
+		if err != nil {
+			t.Fatalf("Failed to build route %+v: %v", rt, err)
+		}
+		if route.Dst.String() != "::/0" {
+			t.Errorf("Route %+v goes to '%s', want the IPv6 default", rt, route.Dst)
+		}
+	}
+
+	// Like ip-route(8) a route without any address is IPv4
+	route, err := (&RouteRequest{Destination: "default", Type: "blackhole"}).buildRoute()
+	if err != nil || route.Dst.String() != "0.0.0.0/0" {
+		t.Errorf("Blackhole default route built as %+v, err=%v", route, err)
+	}
+}
+
+func TestBuildRouteDestination(t *testing.T) {
+	route, err := (&RouteRequest{Destination: "10.0.0.1", Type: "throw", Metric: 100}).buildRoute()
+	if err != nil {
+		t.Fatalf("Failed to build route: %v", err)
+	}
+	if route.Dst.String() != "10.0.0.1/32" || route.Priority != 100 {
+		t.Errorf("Host route built as %+v", route)
+	}
+
+	route, err = (&RouteRequest{Destination: "10.0.0.0/8", Gateway: "192.168.0.1", Scope: "global"}).buildRoute()
+	if err != nil {
+		t.Fatalf("Failed to build route: %v", err)
+	}
+	if route.Dst.String() != "10.0.0.0/8" || route.Gw.String() != "192.168.0.1" || route.Scope != netlink.SCOPE_UNIVERSE {
+		t.Errorf("Route via gateway built as %+v", route)
+	}
+}
+
+func TestBuildRouteRefused(t *testing.T) {
+	for reason, rt := range map[string]RouteRequest{
+		"next hops of both families":   {Destination: "default", MultiPath: []NextHop{{Gateway: "192.168.0.1"}, {Gateway: "2001:db8::1"}}},
+		"IPv4 destination via IPv6":    {Destination: "10.0.0.0/8", Gateway: "2001:db8::1"},
+		"IPv6 destination from IPv4":   {Destination: "2001:db8::/32", Source: "192.168.0.10"},
+		"no destination":               {Gateway: "192.168.0.1"},
+		"truncated gateway":            {Destination: "default", Gateway: "192.168.0"},
+		"weight above 256":             {Destination: "default", MultiPath: []NextHop{{Gateway: "192.168.0.1", Weight: 257}}},
+		"type ip-route(8) can not add": {Destination: "default", Type: "anycast"},
+		"unknown scope":                {Destination: "default", Scope: "planet"},
+	} {
+		if route, err := rt.buildRoute(); err == nil {
+			t.Errorf("Route with %s built as %+v", reason, route)
+		}
+	}
+}
+
+func TestSetDefaultScope(t *testing.T) {
+	scope := func(rt RouteRequest) netlink.Scope {
+		route, err := rt.buildRoute()
+		if err != nil {
+			t.Fatalf("Failed to build route %+v: %v", rt, err)
+		}
+
+		rt.setDefaultScope(route)
+		return route.Scope
+	}
+
+	// Reachable directly on the link unless there is a gateway to go through
+	if s := scope(RouteRequest{Destination: "10.0.0.0/8"}); s != netlink.SCOPE_LINK {
+		t.Errorf("Route without gateway has scope %d, want link", s)
+	}
+	if s := scope(RouteRequest{Destination: "10.0.0.0/8", Gateway: "192.168.0.1"}); s != netlink.SCOPE_UNIVERSE {
+		t.Errorf("Route via gateway has scope %d, want global", s)
+	}
+	if s := scope(RouteRequest{Destination: "default", MultiPath: []NextHop{{Gateway: "192.168.0.1"}}}); s != netlink.SCOPE_UNIVERSE {
+		t.Errorf("Route via next hops has scope %d, want global", s)
+	}
+
+	if s := scope(RouteRequest{Destination: "10.0.0.1", Type: "local"}); s != netlink.SCOPE_HOST {
+		t.Errorf("Local route has scope %d, want host", s)
+	}
+	if s := scope(RouteRequest{Destination: "10.0.0.0/8", Type: "blackhole"}); s != netlink.SCOPE_UNIVERSE {
+		t.Errorf("Blackhole route has scope %d, want global", s)
+	}
+
+	// A requested scope is never overridden
+	if s := scope(RouteRequest{Destination: "10.0.0.0/8", Scope: "host"}); s != netlink.SCOPE_HOST {
+		t.Errorf("Route requested with host scope has scope %d", s)
+	}
+}
+
+func TestBuildRemoveRoute(t *testing.T) {
+	// Without scope and type the kernel removes the first route to the destination, whatever
+	// scope or type it was added with
+	route, err := (&RouteRequest{Destination: "10.0.0.0/8", Table: "100"}).buildRemoveRoute()
+	if err != nil {
+		t.Fatalf("Failed to build route: %v", err)
+	}
+	if route.Scope != netlink.SCOPE_NOWHERE || route.Type != 0 || route.Table != 100 {
+		t.Errorf("Route to remove built as %+v, want any scope and type", route)
+	}
+
+	// The same request adds a link scoped unicast route
+	add, err := (&RouteRequest{Destination: "10.0.0.0/8", Table: "100"}).buildRoute()
+	if err != nil {
+		t.Fatalf("Failed to build route: %v", err)
+	}
+	(&RouteRequest{}).setDefaultScope(add)
+	if add.Scope != netlink.SCOPE_LINK {
+		t.Errorf("Route to add has scope %d, want link", add.Scope)
+	}
+
+	// What the request names has to match
+	route, err = (&RouteRequest{Destination: "default", Gateway: "192.168.0.1", Type: "unicast", Scope: "global"}).buildRemoveRoute()
+	if err != nil {
+		t.Fatalf("Failed to build route: %v", err)
+	}
+	if route.Scope != netlink.SCOPE_UNIVERSE || route.Type != unix.RTN_UNICAST {
+		t.Errorf("Route to remove built as %+v, want a global unicast route", route)
+	}
+	route, err = (&RouteRequest{Destination: "10.0.0.1", Type: "local"}).buildRemoveRoute()
+	if err != nil || route.Scope != netlink.SCOPE_NOWHERE || route.Type != unix.RTN_LOCAL {
+		t.Errorf("Local route to remove built as %+v, err=%v", route, err)
+	}
+}
@@ -4,10 +4,12 @@
 package route
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
@@ -35,10 +37,35 @@ func routerDeleteRoute(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func routerConfigureRoute(w http.ResponseWriter, r *http.Request) {
+	rt := RouteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&rt); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case "POST":
+		err = rt.Add()
+	case "PUT":
+		err = rt.Replace()
+	case "DELETE":
+		err = rt.Remove()
+	}
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
 func routerAcquireRoute(w http.ResponseWriter, r *http.Request) {
-	rts, err := AcquireRoutes()
+	rts, err := AcquireRoutesByTable(r.URL.Query().Get("table"))
 	if err != nil {
 		web.JSONResponseError(err, w)
+		return
 	}
 
 	web.JSONResponse(rts, w)
@@ -46,8 +73,10 @@ func routerAcquireRoute(w http.ResponseWriter, r *http.Request) {
 
 func RegisterRouterRoute(router *mux.Router) {
 	s := router.PathPrefix("/netlink").Subrouter().StrictSlash(false)
+	s.Use(netns.Middleware)
 
 	s.HandleFunc("/route/{link}", routerAddRoute).Methods("POST")
 	s.HandleFunc("/route/{link}", routerDeleteRoute).Methods("DELETE")
 	s.HandleFunc("/route", routerAcquireRoute).Methods("GET")
+	s.HandleFunc("/route", routerConfigureRoute).Methods("POST", "PUT", "DELETE")
 }
@@ -0,0 +1,458 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/pmd-next-gen/pkg/iproute2"
+)
+
+var (
+	// Rule actions by the names ip-rule(8) takes
+	ruleActions = map[string]int{
+		"table":       unix.FR_ACT_TO_TBL,
+		"lookup":      unix.FR_ACT_TO_TBL,
+		"goto":        unix.FR_ACT_GOTO,
+		"nop":         unix.FR_ACT_NOP,
+		"blackhole":   unix.FR_ACT_BLACKHOLE,
+		"unreachable": unix.FR_ACT_UNREACHABLE,
+		"prohibit":    unix.FR_ACT_PROHIBIT,
+	}
+
+	ruleActionNames = map[int]string{
+		unix.FR_ACT_TO_TBL:      "table",
+		unix.FR_ACT_GOTO:        "goto",
+		unix.FR_ACT_NOP:         "nop",
+		unix.FR_ACT_BLACKHOLE:   "blackhole",
+		unix.FR_ACT_UNREACHABLE: "unreachable",
+		unix.FR_ACT_PROHIBIT:    "prohibit",
+	}
+)
+
+type Rule struct {
+	Family               string `json:"Family"`
+	Priority             int    `json:"Priority"`
+	Action               string `json:"Action"`
+	Table                int    `json:"Table"`
+	TableName            string `json:"TableName"`
+	Goto                 int    `json:"Goto"`
+	From                 string `json:"From"`
+	To                   string `json:"To"`
+	IIF                  string `json:"IIF"`
+	OIF                  string `json:"OIF"`
+	FwMark               uint32 `json:"FwMark"`
+	FwMask               uint32 `json:"FwMask"`
+	UIDRange             string `json:"UIDRange"`
+	SuppressPrefixLength int    `json:"SuppressPrefixLength"`
+	Invert               bool   `json:"Invert"`
+	Protocol             string `json:"Protocol"`
+	L3MDev               bool   `json:"L3MDev"`
+}
+
+// RuleRequest adds or removes a routing policy rule at runtime. From and To take a prefix or an
+// address, FwMark a mark with an optional /mask, UIDRange a uid or start-end and Table a number
+// or a name from /etc/iproute2. Action takes table, goto, nop, blackhole, unreachable or
+// prohibit and defaults to table, which defaults to main when adding. Removing matches the rules
+// on the fields which are set
+type RuleRequest struct {
+	Family               string `json:"Family"`
+	Priority             string `json:"Priority"`
+	From                 string `json:"From"`
+	To                   string `json:"To"`
+	IIF                  string `json:"IIF"`
+	OIF                  string `json:"OIF"`
+	FwMark               string `json:"FwMark"`
+	UIDRange             string `json:"UIDRange"`
+	Table                string `json:"Table"`
+	Action               string `json:"Action"`
+	Goto                 string `json:"Goto"`
+	SuppressPrefixLength string `json:"SuppressPrefixLength"`
+	Invert               bool   `json:"Invert"`
+}
+
+func familyName(family int) string {
+	switch family {
+	case netlink.FAMILY_V4:
+		return "ipv4"
+	case netlink.FAMILY_V6:
+		return "ipv6"
+	}
+
+	return fmt.Sprintf("%d", family)
+}
+
+func parseFamily(family string) ([]int, error) {
+	switch family {
+	case "":
+		return []int{netlink.FAMILY_V4, netlink.FAMILY_V6}, nil
+	case "ipv4":
+		return []int{netlink.FAMILY_V4}, nil
+	case "ipv6":
+		return []int{netlink.FAMILY_V6}, nil
+	}
+
+	return nil, fmt.Errorf("invalid family '%s'", family)
+}
+
+func parseUint32(s string, what string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", what, s)
+	}
+
+	return uint32(n), nil
+}
+
+func parsePrefix(s string, what string) (*net.IPNet, error) {
+	if p, err := netlink.ParseIPNet(s); err == nil {
+		return p, nil
+	}
+
+	if ip := net.ParseIP(s); ip != nil {
+		return netlink.NewIPNet(ip), nil
+	}
+
+	return nil, fmt.Errorf("invalid %s '%s'", what, s)
+}
+
+// prefixAttr parses the prefix into its attribute and prefix length. The family is taken from
+// the prefix unless it is already set, in which case they have to match
+func prefixAttr(s string, what string, attrType int, family *int, length *uint8) (*nl.RtAttr, error) {
+	prefix, err := parsePrefix(s, what)
+	if err != nil {
+		return nil, err
+	}
+
+	f, data := netlink.FAMILY_V6, []byte(prefix.IP.To16())
+	if ip := prefix.IP.To4(); ip != nil {
+		f, data = netlink.FAMILY_V4, ip
+	}
+
+	if *family != 0 && *family != f {
+		return nil, fmt.Errorf("%s '%s' does not match the family", what, s)
+	}
+	*family = f
+
+	ones, _ := prefix.Mask.Size()
+	*length = uint8(ones)
+
+	return nl.NewRtAttr(attrType, data), nil
+}
+
+func parseUIDRange(s string) (uint32, uint32, error) {
+	start, end, found := strings.Cut(s, "-")
+
+	first, err := parseUint32(start, "uid range")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return first, first, nil
+	}
+
+	last, err := parseUint32(end, "uid range")
+	if err != nil {
+		return 0, 0, err
+	}
+	if last < first {
+		return 0, 0, fmt.Errorf("invalid uid range '%s'", s)
+	}
+
+	return first, last, nil
+}
+
+func uint32Attr(attrType int, v uint32) *nl.RtAttr {
+	return nl.NewRtAttr(attrType, nl.Uint32Attr(v))
+}
+
+func stringAttr(attrType int, s string) *nl.RtAttr {
+	return nl.NewRtAttr(attrType, append([]byte(s), 0))
+}
+
+// buildRequest converts the rule into the RTM_NEWRULE or RTM_DELRULE message the way
+// ip-rule(8) builds it. The vendored netlink package knows neither uid ranges nor actions
+func (r *RuleRequest) buildRequest(req *nl.NetlinkRequest, add bool) error {
+	msg := nl.NewRtMsg()
+	msg.Protocol = unix.RTPROT_BOOT
+	msg.Scope = unix.RT_SCOPE_UNIVERSE
+	msg.Table = unix.RT_TABLE_UNSPEC
+	msg.Type = unix.FR_ACT_UNSPEC
+
+	family := 0
+	if r.Family != "" {
+		families, err := parseFamily(r.Family)
+		if err != nil {
+			return err
+		}
+		family = families[0]
+	}
+
+	var attrs []*nl.RtAttr
+	if r.From != "" && r.From != "all" {
+		a, err := prefixAttr(r.From, "source prefix", unix.FRA_SRC, &family, &msg.Src_len)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, a)
+	}
+	if r.To != "" && r.To != "all" {
+		a, err := prefixAttr(r.To, "destination prefix", unix.FRA_DST, &family, &msg.Dst_len)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, a)
+	}
+
+	if family == 0 {
+		family = netlink.FAMILY_V4
+	}
+	msg.Family = uint8(family)
+
+	if r.Invert {
+		msg.Flags |= unix.FIB_RULE_INVERT
+	}
+
+	if r.Priority != "" {
+		p, err := parseUint32(r.Priority, "priority")
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, uint32Attr(unix.FRA_PRIORITY, p))
+	}
+
+	if r.FwMark != "" {
+		mark, mask, found := strings.Cut(r.FwMark, "/")
+
+		m, err := parseUint32(mark, "fwmark")
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, uint32Attr(unix.FRA_FWMARK, m))
+
+		if found {
+			m, err := parseUint32(mask, "fwmark mask")
+			if err != nil {
+				return err
+			}
+			attrs = append(attrs, uint32Attr(unix.FRA_FWMASK, m))
+		}
+	}
+
+	if r.IIF != "" {
+		attrs = append(attrs, stringAttr(unix.FRA_IIFNAME, r.IIF))
+	}
+	if r.OIF != "" {
+		attrs = append(attrs, stringAttr(unix.FRA_OIFNAME, r.OIF))
+	}
+
+	if r.UIDRange != "" {
+		start, end, err := parseUIDRange(r.UIDRange)
+		if err != nil {
+			return err
+		}
+
+		b := make([]byte, 8)
+		nl.NativeEndian().PutUint32(b[0:4], start)
+		nl.NativeEndian().PutUint32(b[4:8], end)
+		attrs = append(attrs, nl.NewRtAttr(unix.FRA_UID_RANGE, b))
+	}
+
+	if r.Action != "" {
+		a, ok := ruleActions[strings.ToLower(r.Action)]
+		if !ok {
+			return fmt.Errorf("invalid rule action '%s'", r.Action)
+		}
+		msg.Type = uint8(a)
+	}
+
+	if r.Table != "" {
+		t, err := iproute2.ParseTable(r.Table)
+		if err != nil {
+			return err
+		}
+		if t == unix.RT_TABLE_UNSPEC {
+			return fmt.Errorf("invalid route table '%s'", r.Table)
+		}
+
+		if t < 256 {
+			msg.Table = uint8(t)
+		} else {
+			attrs = append(attrs, uint32Attr(unix.FRA_TABLE, uint32(t)))
+		}
+		if msg.Type == unix.FR_ACT_UNSPEC {
+			msg.Type = unix.FR_ACT_TO_TBL
+		}
+	}
+
+	if add && msg.Type == unix.FR_ACT_UNSPEC {
+		msg.Type = unix.FR_ACT_TO_TBL
+	}
+	if add && msg.Type == unix.FR_ACT_TO_TBL && r.Table == "" {
+		msg.Table = unix.RT_TABLE_MAIN
+	}
+
+	if r.Goto != "" {
+		if msg.Type != unix.FR_ACT_GOTO {
+			return errors.New("goto target without goto action")
+		}
+
+		g, err := parseUint32(r.Goto, "goto target")
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, uint32Attr(unix.FRA_GOTO, g))
+	} else if add && msg.Type == unix.FR_ACT_GOTO {
+		return errors.New("missing goto target")
+	}
+
+	if r.SuppressPrefixLength != "" {
+		if msg.Type != unix.FR_ACT_TO_TBL {
+			return errors.New("suppress prefix length needs a table action")
+		}
+
+		l, err := parseUint32(r.SuppressPrefixLength, "suppress prefix length")
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, uint32Attr(unix.FRA_SUPPRESS_PREFIXLEN, l))
+	}
+
+	req.AddData(msg)
+	for _, a := range attrs {
+		req.AddData(a)
+	}
+
+	return nil
+}
+
+func (r *RuleRequest) Add() error {
+	req := nl.NewNetlinkRequest(unix.RTM_NEWRULE, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK)
+	if err := r.buildRequest(req, true); err != nil {
+		return err
+	}
+
+	if _, err := req.Execute(unix.NETLINK_ROUTE, 0); err != nil {
+		log.Errorf("Failed to add routing policy rule priority='%s' table='%s': %v", r.Priority, r.Table, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *RuleRequest) Remove() error {
+	req := nl.NewNetlinkRequest(unix.RTM_DELRULE, unix.NLM_F_ACK)
+	if err := r.buildRequest(req, false); err != nil {
+		return err
+	}
+
+	if _, err := req.Execute(unix.NETLINK_ROUTE, 0); err != nil {
+		log.Errorf("Failed to remove routing policy rule priority='%s' table='%s': %v", r.Priority, r.Table, err)
+		return err
+	}
+
+	return nil
+}
+
+func parseRule(b []byte, names *iproute2.Names) (*Rule, error) {
+	msg := nl.DeserializeRtMsg(b)
+	attrs, err := nl.ParseRouteAttr(b[msg.Len():])
+	if err != nil {
+		return nil, err
+	}
+
+	native := nl.NativeEndian()
+	r := Rule{
+		Family:               familyName(int(msg.Family)),
+		Action:               ruleActionNames[int(msg.Type)],
+		Table:                int(msg.Table),
+		From:                 "all",
+		Invert:               msg.Flags&unix.FIB_RULE_INVERT != 0,
+		SuppressPrefixLength: -1,
+	}
+	if r.Action == "" {
+		r.Action = strconv.Itoa(int(msg.Type))
+	}
+
+	for _, a := range attrs {
+		switch int(a.Attr.Type) {
+		case unix.FRA_SRC:
+			r.From = fmt.Sprintf("%s/%d", net.IP(a.Value), msg.Src_len)
+		case unix.FRA_DST:
+			r.To = fmt.Sprintf("%s/%d", net.IP(a.Value), msg.Dst_len)
+		case unix.FRA_PRIORITY:
+			r.Priority = int(native.Uint32(a.Value[0:4]))
+		case unix.FRA_TABLE:
+			r.Table = int(native.Uint32(a.Value[0:4]))
+		case unix.FRA_GOTO:
+			r.Goto = int(native.Uint32(a.Value[0:4]))
+		case unix.FRA_FWMARK:
+			r.FwMark = native.Uint32(a.Value[0:4])
+		case unix.FRA_FWMASK:
+			r.FwMask = native.Uint32(a.Value[0:4])
+		case unix.FRA_IIFNAME:
+			r.IIF = strings.TrimRight(string(a.Value), "\x00")
+		case unix.FRA_OIFNAME:
+			r.OIF = strings.TrimRight(string(a.Value), "\x00")
+		case unix.FRA_UID_RANGE:
+			r.UIDRange = fmt.Sprintf("%d-%d", native.Uint32(a.Value[0:4]), native.Uint32(a.Value[4:8]))
+		case unix.FRA_SUPPRESS_PREFIXLEN:
+			if l := native.Uint32(a.Value[0:4]); l != 0xffffffff {
+				r.SuppressPrefixLength = int(l)
+			}
+		case unix.FRA_PROTOCOL:
+			r.Protocol = names.ProtocolName(int(a.Value[0]))
+		case unix.FRA_L3MDEV:
+			r.L3MDev = a.Value[0] != 0
+		}
+	}
+
+	if r.Table != unix.RT_TABLE_UNSPEC {
+		r.TableName = names.TableName(r.Table)
+	}
+
+	return &r, nil
+}
+
+// AcquireRules lists the routing policy rules like ip-rule(8) does. family takes ipv4, ipv6 or
+// empty for both
+func AcquireRules(family string) ([]Rule, error) {
+	families, err := parseFamily(family)
+	if err != nil {
+		return nil, err
+	}
+
+	names := iproute2.Load()
+
+	l := []Rule{}
+	for _, f := range families {
+		req := nl.NewNetlinkRequest(unix.RTM_GETRULE, unix.NLM_F_DUMP)
+		req.AddData(nl.NewIfInfomsg(f))
+
+		msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWRULE)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range msgs {
+			r, err := parseRule(m, names)
+			if err != nil {
+				return nil, err
+			}
+
+			l = append(l, *r)
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package rule
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/netns"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerAcquireRules(w http.ResponseWriter, r *http.Request) {
+	l, err := AcquireRules(r.URL.Query().Get("family"))
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(l, w)
+}
+
+func routerConfigureRule(w http.ResponseWriter, r *http.Request) {
+	rule := RuleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case "POST":
+		err = rule.Add()
+	case "DELETE":
+		err = rule.Remove()
+	}
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("configured", w)
+}
+
+func RegisterRouterRule(router *mux.Router) {
+	s := router.PathPrefix("/netlink").Subrouter().StrictSlash(false)
+	s.Use(netns.Middleware)
+
+	s.HandleFunc("/rule", routerAcquireRules).Methods("GET")
+	s.HandleFunc("/rule", routerConfigureRule).Methods("POST", "DELETE")
+}
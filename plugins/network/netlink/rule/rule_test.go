@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package rule
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/pmd-next-gen/pkg/iproute2"
+)
+
+// encode builds the request of r and splits the message the kernel receives into its header
+// and attributes
+func encode(t *testing.T, r RuleRequest, add bool) (*nl.RtMsg, map[uint16][]byte) {
+	req := nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0)
+	if err := r.buildRequest(req, add); err != nil {
+		t.Fatalf("Failed to build request of %+v: %v", r, err)
+	}
+
+	b := req.Serialize()[unix.SizeofNlMsghdr:]
+	l, err := nl.ParseRouteAttr(b[unix.SizeofRtMsg:])
+	if err != nil {
+		t.Fatalf("Failed to parse attributes: %v", err)
+	}
+
+	attrs := map[uint16][]byte{}
+	for _, a := range l {
+		attrs[a.Attr.Type] = a.Value
+	}
+
+	return nl.DeserializeRtMsg(b), attrs
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	nl.NativeEndian().PutUint32(b, v)
+	return b
+}
+
+func TestAddDefaultsToMainTable(t *testing.T) {
+	msg, attrs := encode(t, RuleRequest{}, true)
+
+	if msg.Family != unix.AF_INET || msg.Type != unix.FR_ACT_TO_TBL || msg.Table != unix.RT_TABLE_MAIN {
+		t.Errorf("Rule added as %+v, want an IPv4 lookup of the main table", msg)
+	}
+	if len(attrs) != 0 {
+		t.Errorf("Rule from all added with attributes %v", attrs)
+	}
+
+	// Removing matches any action and table unless one is given
+	msg, _ = encode(t, RuleRequest{Priority: "100"}, false)
+	if msg.Type != unix.FR_ACT_UNSPEC || msg.Table != unix.RT_TABLE_UNSPEC {
+		t.Errorf("Removal by priority narrowed to %+v", msg)
+	}
+	msg, _ = encode(t, RuleRequest{Table: "100"}, false)
+	if msg.Type != unix.FR_ACT_TO_TBL || msg.Table != 100 {
+		t.Errorf("Removal by table built as %+v", msg)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	msg, attrs := encode(t, RuleRequest{From: "10.0.0.0/8", To: "192.168.1.1", Priority: "1000"}, true)
+
+	if msg.Family != unix.AF_INET || msg.Src_len != 8 || msg.Dst_len != 32 {
+		t.Errorf("Prefixes encoded as %+v", msg)
+	}
+	if !bytes.Equal(attrs[unix.FRA_SRC], []byte{10, 0, 0, 0}) || !bytes.Equal(attrs[unix.FRA_DST], []byte{192, 168, 1, 1}) {
+		t.Errorf("Prefix attributes %v", attrs)
+	}
+	if !bytes.Equal(attrs[unix.FRA_PRIORITY], u32(1000)) {
+		t.Errorf("Priority encoded as %v", attrs[unix.FRA_PRIORITY])
+	}
+
+	// The family follows the prefix, a prefix of the other family is refused
+	if msg, _ := encode(t, RuleRequest{To: "2001:db8::/32"}, true); msg.Family != unix.AF_INET6 || msg.Dst_len != 32 {
+		t.Errorf("IPv6 prefix encoded as %+v", msg)
+	}
+	for _, r := range []RuleRequest{
+		{Family: "ipv4", From: "2001:db8::/32"},
+		{From: "10.0.0.0/8", To: "2001:db8::/32"},
+		{From: "10.0.0.0/33"},
+	} {
+		if err := r.buildRequest(nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0), true); err == nil {
+			t.Errorf("Prefixes of %+v encoded", r)
+		}
+	}
+}
+
+func TestTableAttribute(t *testing.T) {
+	// The header holds 8 bit tables only, larger ones need FRA_TABLE
+	msg, attrs := encode(t, RuleRequest{Family: "ipv6", Table: "0x10000"}, true)
+	if msg.Table != unix.RT_TABLE_UNSPEC || !bytes.Equal(attrs[unix.FRA_TABLE], u32(0x10000)) {
+		t.Errorf("Table 0x10000 encoded as %+v %v", msg, attrs)
+	}
+
+	msg, attrs = encode(t, RuleRequest{Table: "200"}, true)
+	if msg.Table != 200 || attrs[unix.FRA_TABLE] != nil {
+		t.Errorf("Table 200 encoded as %+v %v", msg, attrs)
+	}
+
+	for _, table := range []string{"0", "unspec", "nosuchtable"} {
+		if err := (&RuleRequest{Table: table}).buildRequest(nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0), true); err == nil {
+			t.Errorf("Table '%s' encoded", table)
+		}
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	msg, attrs := encode(t, RuleRequest{IIF: "eth0", OIF: "vrf-blue", FwMark: "0x10/0xff", UIDRange: "1000-1999", Invert: true}, true)
+
+	if msg.Flags&unix.FIB_RULE_INVERT == 0 {
+		t.Errorf("Inverted rule encoded without FIB_RULE_INVERT")
+	}
+
+	want := map[uint16][]byte{
+		unix.FRA_IIFNAME:   append([]byte("eth0"), 0),
+		unix.FRA_OIFNAME:   append([]byte("vrf-blue"), 0),
+		unix.FRA_FWMARK:    u32(0x10),
+		unix.FRA_FWMASK:    u32(0xff),
+		unix.FRA_UID_RANGE: append(u32(1000), u32(1999)...),
+	}
+	for a, v := range want {
+		if !bytes.Equal(attrs[a], v) {
+			t.Errorf("Attribute %d = %v, want %v", a, attrs[a], v)
+		}
+	}
+
+	// A single uid is a range of one and a mark without mask leaves the mask to the kernel
+	_, attrs = encode(t, RuleRequest{UIDRange: "0", FwMark: "7"}, true)
+	if !bytes.Equal(attrs[unix.FRA_UID_RANGE], append(u32(0), u32(0)...)) || attrs[unix.FRA_FWMASK] != nil {
+		t.Errorf("Selectors encoded as %v", attrs)
+	}
+
+	for _, r := range []RuleRequest{
+		{FwMark: "mark"},
+		{FwMark: "1/0x100000000"},
+		{UIDRange: "2000-1000"},
+		{UIDRange: "1000-"},
+		{Priority: "-1"},
+	} {
+		if err := r.buildRequest(nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0), true); err == nil {
+			t.Errorf("Selectors of %+v encoded", r)
+		}
+	}
+}
+
+func TestActions(t *testing.T) {
+	msg, attrs := encode(t, RuleRequest{Action: "goto", Goto: "2000"}, true)
+	if msg.Type != unix.FR_ACT_GOTO || !bytes.Equal(attrs[unix.FRA_GOTO], u32(2000)) || msg.Table != unix.RT_TABLE_UNSPEC {
+		t.Errorf("Goto rule encoded as %+v %v", msg, attrs)
+	}
+
+	if msg, _ := encode(t, RuleRequest{Action: "Blackhole"}, true); msg.Type != unix.FR_ACT_BLACKHOLE || msg.Table != unix.RT_TABLE_UNSPEC {
+		t.Errorf("Blackhole rule encoded as %+v", msg)
+	}
+
+	_, attrs = encode(t, RuleRequest{Table: "main", SuppressPrefixLength: "0"}, true)
+	if !bytes.Equal(attrs[unix.FRA_SUPPRESS_PREFIXLEN], u32(0)) {
+		t.Errorf("Suppress prefix length encoded as %v", attrs[unix.FRA_SUPPRESS_PREFIXLEN])
+	}
+
+	// Goto targets and suppression only make sense with the action they belong to
+	for _, r := range []RuleRequest{
+		{Action: "drop"},
+		{Goto: "100"},
+		{Action: "goto"},
+		{Action: "goto", Goto: "next"},
+		{Action: "blackhole", SuppressPrefixLength: "0"},
+		{SuppressPrefixLength: "short"},
+	} {
+		if err := r.buildRequest(nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0), true); err == nil {
+			t.Errorf("Action of %+v encoded", r)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	req := nl.NewNetlinkRequest(unix.RTM_NEWRULE, 0)
+	r := RuleRequest{From: "192.168.1.1", Table: "0x10000", Priority: "32765", FwMark: "7", IIF: "eth0"}
+	if err := r.buildRequest(req, true); err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+
+	// The listing reads the same message back the way ip-rule(8) prints it
+	rule, err := parseRule(req.Serialize()[unix.SizeofNlMsghdr:], iproute2.Load())
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	want := Rule{
+		Family:               "ipv4",
+		Priority:             32765,
+		Action:               "table",
+		Table:                0x10000,
+		TableName:            "65536",
+		From:                 "192.168.1.1/32",
+		IIF:                  "eth0",
+		FwMark:               7,
+		SuppressPrefixLength: -1,
+	}
+	if *rule != want {
+		t.Errorf("Parsed %+v, want %+v", *rule, want)
+	}
+}
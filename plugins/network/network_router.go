@@ -4,6 +4,7 @@
 package network
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -13,9 +14,12 @@ import (
 	"github.com/vmware/pmd-next-gen/pkg/web"
 	"github.com/vmware/pmd-next-gen/plugins/network/ethtool"
 	"github.com/vmware/pmd-next-gen/plugins/network/firewall"
+	"github.com/vmware/pmd-next-gen/plugins/network/namespace"
 	"github.com/vmware/pmd-next-gen/plugins/network/netlink/address"
 	"github.com/vmware/pmd-next-gen/plugins/network/netlink/link"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/neigh"
 	"github.com/vmware/pmd-next-gen/plugins/network/netlink/route"
+	"github.com/vmware/pmd-next-gen/plugins/network/netlink/rule"
 	"github.com/vmware/pmd-next-gen/plugins/network/networkd"
 	"github.com/vmware/pmd-next-gen/plugins/network/resolved"
 	"github.com/vmware/pmd-next-gen/plugins/network/timesyncd"
@@ -31,55 +35,58 @@ type Describe struct {
 	Domains         []resolved.Domains        `json:"Domains"`
 }
 
-func routerDescribeNetwork(w http.ResponseWriter, r *http.Request) {
+func AcquireDescribe(ctx context.Context) (*Describe, error) {
 	var err error
 	n := Describe{}
 
-	n.NetworkDescribe, err = networkd.AcquireNetworkState(r.Context())
+	n.NetworkDescribe, err = networkd.AcquireNetworkState(ctx)
 	if err != nil {
 		log.Errorf("Failed to acquire network state from systemd-networkd: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	n.LinksDescribe, err = networkd.AcquireLinks(r.Context())
+	n.LinksDescribe, err = networkd.AcquireLinks(ctx)
 	if err != nil {
 		log.Errorf("Failed to acquire link state from systemd-networkd: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	n.Addresses, err = address.AcquireAddresses()
 	if err != nil {
 		log.Errorf("Failed to acquire addresses: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	n.Routes, err = route.AcquireRoutes()
 	if err != nil {
 		log.Errorf("Failed to acquire routes: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
 	n.Links, err = link.AcquireLinks()
 	if err != nil {
 		log.Errorf("Failed to acquire links: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	n.Dns, err = resolved.AcquireDns(r.Context())
+	n.Dns, err = resolved.AcquireDns(ctx)
 	if err != nil {
 		log.Errorf("Failed to acquire dDNS from systemd-resolved: %v", err)
-		web.JSONResponseError(err, w)
-		return
+		return nil, err
 	}
 
-	n.Domains, err = resolved.AcquireDomains(r.Context())
+	n.Domains, err = resolved.AcquireDomains(ctx)
 	if err != nil {
 		log.Errorf("Failed to acquire domains from systemd-resolved: %v", err)
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func routerDescribeNetwork(w http.ResponseWriter, r *http.Request) {
+	n, err := AcquireDescribe(r.Context())
+	if err != nil {
 		web.JSONResponseError(err, w)
 		return
 	}
@@ -94,6 +101,9 @@ func RegisterRouterNetwork(router *mux.Router) {
 	link.RegisterRouterLink(n)
 	address.RegisterRouterAddress(n)
 	route.RegisterRouterRoute(n)
+	rule.RegisterRouterRule(n)
+	neigh.RegisterRouterNeigh(n)
+	namespace.RegisterRouterNamespace(n)
 
 	// ethtool
 	ethtool.RegisterRouterEthTool(n)
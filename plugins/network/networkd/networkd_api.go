@@ -4,6 +4,7 @@
 package networkd
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path"
@@ -190,6 +191,27 @@ func CreateNetworkFile(link string) (*configfile.Meta, error) {
 	return m, nil
 }
 
+// NetworkFilePath returns the .network file systemd-networkd applied to the link, or else the one
+// CreateNetworkFile would create for it. The applied file is only picked when it lives in
+// /etc/systemd/network and its [Match] names no link but this one, rewriting it must never
+// change the configuration of other links
+func NetworkFilePath(l string) string {
+	if link, err := netlink.LinkByName(l); err == nil {
+		if n, err := ParseLinkNetworkFile(link.Attrs().Index); err == nil && path.Dir(n) == "/etc/systemd/network" {
+			if m, err := configfile.Load(n); err == nil {
+				match := MatchSection{}
+				m.DecodeSections("Match", &match)
+
+				if names := strings.Fields(match.Name); len(names) == 1 && names[0] == l {
+					return n
+				}
+			}
+		}
+	}
+
+	return path.Join("/etc/systemd/network", "10-"+l+".network")
+}
+
 func CreateOrParseNetworkFile(l string) (*configfile.Meta, error) {
 	link, err := netlink.LinkByName(l)
 	if err != nil {
@@ -305,6 +327,17 @@ func RemoveNetDevNetworkFile(link string, kind string) error {
 	return os.Remove(buildNetDevNetworkFilePath(link, kind))
 }
 
+// LinkFilePath returns the .link file in use by the link, or else the one CreateOrParseLinkFile
+// would create for it. Files outside /etc/systemd/network are vendor defaults shared by all links
+// and are never picked
+func LinkFilePath(link string) string {
+	if f, err := findLinkFile(link); err == nil && path.Dir(f) == "/etc/systemd/network" {
+		return f
+	}
+
+	return path.Join("/etc/systemd/network", "10-"+link+".link")
+}
+
 func CreateOrParseLinkFile(link string) (*configfile.Meta, error) {
 	file := "10-" + link + ".link"
 
@@ -339,3 +372,39 @@ func CreateOrParseLinkFile(link string) (*configfile.Meta, error) {
 
 	return m, nil
 }
+
+// ReloadAndReconfigure reloads systemd-networkd and reconfigures the links, all of them when none are given
+func ReloadAndReconfigure(ctx context.Context, names []string) error {
+	c, err := NewSDConnection()
+	if err != nil {
+		log.Errorf("Failed to establish connection with the system bus: %v", err)
+		return err
+	}
+	defer c.Close()
+
+	if err := c.DBusNetworkReload(ctx); err != nil {
+		return err
+	}
+
+	var links []netlink.Link
+	if len(names) == 0 {
+		links, err = netlink.LinkList()
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, n := range names {
+			// Netdevs are created by the reload and may not exist yet
+			if link, err := netlink.LinkByName(n); err == nil {
+				links = append(links, link)
+			}
+		}
+	}
+
+	for _, link := range links {
+		// Unmanaged links cannot be reconfigured
+		c.DBusNetworkReconfigureLink(ctx, link.Attrs().Index)
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package networkd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+)
+
+var (
+	// Directories systemd-networkd reads in order of precedence, a file in an earlier one masks
+	// the file of the same name in the later ones
+	networkConfigDirs = []string{"/etc/systemd/network", "/run/systemd/network", "/usr/local/lib/systemd/network", "/usr/lib/systemd/network", "/lib/systemd/network"}
+)
+
+// NetworkConfig is the .network file which applies to a link
+type NetworkConfig struct {
+	Network
+	File    string   `json:"File"`
+	DropIns []string `json:"DropIns"`
+}
+
+// NetDevConfig is the .netdev file which creates a netdev. Link lists the links whose .network
+// file attaches them to it
+type NetDevConfig struct {
+	NetDev
+	File    string   `json:"File"`
+	DropIns []string `json:"DropIns"`
+}
+
+// LinkConfig is the .link file udev applies to a link
+type LinkConfig struct {
+	Link
+	File    string   `json:"File"`
+	DropIns []string `json:"DropIns"`
+}
+
+// networkConfigFiles returns the files with the extension that are in effect, ordered by
+// name the way systemd-networkd processes them
+func networkConfigFiles(ext string) []string {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, dir := range networkConfigDirs {
+		matches, _ := filepath.Glob(path.Join(dir, "*"+ext))
+		for _, f := range matches {
+			name := path.Base(f)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			// An empty file or a link to /dev/null masks the file
+			if fi, err := os.Stat(f); err != nil || fi.Size() == 0 || fi.IsDir() {
+				continue
+			}
+
+			files = append(files, f)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return path.Base(files[i]) < path.Base(files[j])
+	})
+
+	return files
+}
+
+// networkConfigDropIns returns the drop-ins of the file ordered by name
+func networkConfigDropIns(file string) []string {
+	seen := make(map[string]bool)
+	var dropIns []string
+
+	for _, dir := range networkConfigDirs {
+		matches, _ := filepath.Glob(path.Join(dir, path.Base(file)+".d", "*.conf"))
+		for _, f := range matches {
+			if seen[path.Base(f)] {
+				continue
+			}
+			seen[path.Base(f)] = true
+
+			if fi, err := os.Stat(f); err != nil || fi.Size() == 0 {
+				continue
+			}
+
+			dropIns = append(dropIns, f)
+		}
+	}
+
+	sort.Slice(dropIns, func(i, j int) bool {
+		return path.Base(dropIns[i]) < path.Base(dropIns[j])
+	})
+
+	return dropIns
+}
+
+// matchGlobs tells whether one of the whitespace separated globs matches s. A leading ! inverts the list
+func matchGlobs(globs string, s string) bool {
+	l := strings.Fields(globs)
+	if len(l) == 0 {
+		return false
+	}
+
+	invert := strings.HasPrefix(l[0], "!")
+	if invert {
+		l[0] = strings.TrimPrefix(l[0], "!")
+	}
+
+	for _, g := range l {
+		if ok, _ := path.Match(g, s); ok {
+			return !invert
+		}
+	}
+
+	return invert
+}
+
+// decodeSectionList decodes every section of the name into a new element of the slice l points to
+func decodeSectionList[T any](m *configfile.Meta, section string, l *[]T) {
+	sections, err := m.Cfg.SectionsByName(section)
+	if err != nil {
+		return
+	}
+
+	for _, s := range sections {
+		var v T
+		configfile.DecodeSection(s, &v)
+		*l = append(*l, v)
+	}
+}
+
+func decodeNetwork(m *configfile.Meta, link string) *Network {
+	n := Network{
+		Link:                      link,
+		AddressSections:           []AddressSection{},
+		RouteSections:             []RouteSection{},
+		RoutingPolicyRuleSections: []RoutingPolicyRuleSection{},
+		IPv6PrefixSections:        []IPv6PrefixSection{},
+		IPv6RoutePrefixSections:   []IPv6RoutePrefixSection{},
+		SRIOVSections:             []SRIOVSection{},
+	}
+
+	m.DecodeSections("Match", &n.MatchSection)
+	m.DecodeSections("Link", &n.LinkSection)
+	m.DecodeSections("Network", &n.NetworkSection)
+	m.DecodeSections("DHCPv4", &n.DHCPv4Section)
+	m.DecodeSections("DHCPv6", &n.DHCPv6Section)
+	m.DecodeSections("DHCPServer", &n.DHCPv4ServerSection)
+	m.DecodeSections("IPv6SendRA", &n.IPv6SendRASection)
+
+	decodeSectionList(m, "Address", &n.AddressSections)
+	decodeSectionList(m, "Route", &n.RouteSections)
+	decodeSectionList(m, "RoutingPolicyRule", &n.RoutingPolicyRuleSections)
+	decodeSectionList(m, "IPv6Prefix", &n.IPv6PrefixSections)
+	decodeSectionList(m, "IPv6RoutePrefix", &n.IPv6RoutePrefixSections)
+	decodeSectionList(m, "SR-IOV", &n.SRIOVSections)
+
+	return &n
+}
+
+// findNetworkFile returns the .network file in use for the link as systemd-networkd reports it,
+// or else the first file whose [Match] Name= matches
+func findNetworkFile(link string) (string, error) {
+	if l, err := netlink.LinkByName(link); err == nil {
+		if f, err := ParseLinkNetworkFile(l.Attrs().Index); err == nil && f != "" {
+			return f, nil
+		}
+	}
+
+	for _, f := range networkConfigFiles(".network") {
+		m, err := configfile.LoadWithDropIns(f, networkConfigDropIns(f))
+		if err != nil {
+			continue
+		}
+
+		match := MatchSection{}
+		m.DecodeSections("Match", &match)
+		if matchGlobs(match.Name, link) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("no .network file for link '%s'", link)
+}
+
+// AcquireNetworkConfig parses the .network file of the link and its drop-ins
+func AcquireNetworkConfig(link string) (*NetworkConfig, error) {
+	f, err := findNetworkFile(link)
+	if err != nil {
+		return nil, err
+	}
+
+	dropIns := networkConfigDropIns(f)
+	m, err := configfile.LoadWithDropIns(f, dropIns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetworkConfig{
+		Network: *decodeNetwork(m, link),
+		File:    f,
+		DropIns: append([]string{}, dropIns...),
+	}, nil
+}
+
+func decodeNetDev(m *configfile.Meta) *NetDev {
+	n := NetDev{
+		Links: []string{},
+	}
+
+	m.DecodeSections("Match", &n.MatchSection)
+	m.DecodeSections("NetDev", &n)
+	m.DecodeSections("VLAN", &n.VLanSection)
+	m.DecodeSections("MACVLAN", &n.MacVLanSection)
+	m.DecodeSections("MACVTAP", &n.MacVLanSection)
+	m.DecodeSections("IPVLAN", &n.IpVLanSection)
+	m.DecodeSections("IPVTAP", &n.IpVLanSection)
+	m.DecodeSections("VXLAN", &n.VxLanSection)
+	m.DecodeSections("Bond", &n.BondSection)
+	m.DecodeSections("Bridge", &n.BridgeSection)
+	m.DecodeSections("WireGuard", &n.WireGuardSection)
+	m.DecodeSections("WireGuardPeer", &n.WireGuardPeerSection)
+	m.DecodeSections("Tun", &n.TunOrTapSection)
+	m.DecodeSections("Tap", &n.TunOrTapSection)
+
+	return &n
+}
+
+// netDevLinks returns the links whose .network file attaches them to the netdev, such as
+// VLAN= or Bond=
+func netDevLinks(name string, kind string) []string {
+	links := []string{}
+
+	key := netDevKindToNetworkKind(kind)
+	if key == "" {
+		return links
+	}
+
+	for _, f := range networkConfigFiles(".network") {
+		m, err := configfile.LoadWithDropIns(f, networkConfigDropIns(f))
+		if err != nil {
+			continue
+		}
+
+		var values []string
+		sections, _ := m.Cfg.SectionsByName("Network")
+		for _, s := range sections {
+			if !s.HasKey(key) {
+				continue
+			}
+
+			v, reset := configfile.KeyValues(s, key)
+			if reset {
+				values = nil
+			}
+			values = append(values, v...)
+		}
+
+		for _, e := range values {
+			if e != name {
+				continue
+			}
+
+			match := MatchSection{}
+			m.DecodeSections("Match", &match)
+			if match.Name != "" {
+				links = append(links, strings.Fields(match.Name)...)
+			}
+			break
+		}
+	}
+
+	return links
+}
+
+// AcquireNetDevConfig parses the .netdev file which creates the netdev and its drop-ins
+func AcquireNetDevConfig(name string) (*NetDevConfig, error) {
+	for _, f := range networkConfigFiles(".netdev") {
+		dropIns := networkConfigDropIns(f)
+		m, err := configfile.LoadWithDropIns(f, dropIns)
+		if err != nil {
+			continue
+		}
+
+		n := decodeNetDev(m)
+		if n.Name != name {
+			continue
+		}
+
+		n.Links = netDevLinks(n.Name, n.Kind)
+
+		return &NetDevConfig{
+			NetDev:  *n,
+			File:    f,
+			DropIns: append([]string{}, dropIns...),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no .netdev file for netdev '%s'", name)
+}
+
+// udevLinkFile returns the .link file udev applied to the link from its database
+func udevLinkFile(ifindex int) (string, error) {
+	f, err := os.Open("/run/udev/data/n" + strconv.Itoa(ifindex))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "E:ID_NET_LINK_FILE="); ok {
+			return v, nil
+		}
+	}
+
+	return "", errors.New("not found")
+}
+
+// findLinkFile returns the .link file udev applied to the link, or else the first file whose
+// [Match] MACAddress= or OriginalName= matches
+func findLinkFile(link string) (string, error) {
+	var mac string
+	if l, err := netlink.LinkByName(link); err == nil {
+		if f, err := udevLinkFile(l.Attrs().Index); err == nil && f != "" {
+			return f, nil
+		}
+		mac = l.Attrs().HardwareAddr.String()
+	}
+
+	for _, f := range networkConfigFiles(".link") {
+		m, err := configfile.LoadWithDropIns(f, networkConfigDropIns(f))
+		if err != nil {
+			continue
+		}
+
+		var match struct {
+			MACAddress   []string
+			OriginalName string
+		}
+		m.DecodeSections("Match", &match)
+
+		if mac != "" {
+			for _, a := range match.MACAddress {
+				if strings.EqualFold(a, mac) {
+					return f, nil
+				}
+			}
+		}
+		if matchGlobs(match.OriginalName, link) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("no .link file for link '%s'", link)
+}
+
+// AcquireLinkConfig parses the .link file of the link and its drop-ins
+func AcquireLinkConfig(link string) (*LinkConfig, error) {
+	f, err := findLinkFile(link)
+	if err != nil {
+		return nil, err
+	}
+
+	dropIns := networkConfigDropIns(f)
+	m, err := configfile.LoadWithDropIns(f, dropIns)
+	if err != nil {
+		return nil, err
+	}
+
+	l := Link{
+		Link: link,
+	}
+	m.DecodeSections("Match", &l.MatchSection)
+	m.DecodeSections("Link", &l)
+
+	return &LinkConfig{
+		Link:    l,
+		File:    f,
+		DropIns: append([]string{}, dropIns...),
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
 
 	"github.com/vmware/pmd-next-gen/pkg/configfile"
 	"github.com/vmware/pmd-next-gen/pkg/validator"
@@ -533,6 +534,25 @@ func (l *Link) BuildLinkSection(m *configfile.Meta) error {
 	return nil
 }
 
+// Render builds the .link file of the link from scratch without touching the disk. The file is
+// rendered at the path of the one in use by the link
+func (l *Link) Render() (*configfile.Meta, error) {
+	link, err := netlink.LinkByName(l.Link)
+	if err != nil {
+		return nil, err
+	}
+
+	m := configfile.New(LinkFilePath(l.Link))
+	m.NewSection("Match")
+	m.SetKeyToNewSectionString("MACAddress", link.Attrs().HardwareAddr.String())
+
+	if err := l.BuildLinkSection(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
 func (l *Link) ConfigureLink(ctx context.Context, w http.ResponseWriter) error {
 	m, err := CreateOrParseLinkFile(l.Link)
 	if err != nil {
@@ -577,6 +577,29 @@ func (n *NetDev) BuildKindSection(m *configfile.Meta) error {
 	return nil
 }
 
+// BuildKindInNetworkSection adds the netdev to the [Network] section of a link's .network file
+func (n *NetDev) BuildKindInNetworkSection(m *configfile.Meta) error {
+	return m.NewKeyToSectionString("Network", netDevKindToNetworkKind(n.Kind), n.Name)
+}
+
+// Render builds the .netdev file and the .network file of the netdev from scratch without touching the disk
+func (n *NetDev) Render() (*configfile.Meta, *configfile.Meta, error) {
+	m := configfile.New(buildNetDevFilePath(n.Name, n.Kind))
+	if err := n.BuildNetDevSection(m); err != nil {
+		return nil, nil, err
+	}
+	if err := n.BuildKindSection(m); err != nil {
+		return nil, nil, err
+	}
+
+	nm := configfile.New(buildNetDevNetworkFilePath(n.Name, n.Kind))
+	if err := CreateMatchSection(nm, n.Name); err != nil {
+		return nil, nil, err
+	}
+
+	return m, nm, nil
+}
+
 func (n *NetDev) ConfigureNetDev(ctx context.Context, w http.ResponseWriter) error {
 	m, _, err := CreateOrParseNetDevFile(n.Name, n.Kind)
 	if err != nil {
@@ -6,6 +6,7 @@ package networkd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
@@ -43,8 +44,8 @@ type LinkSection struct {
 type NetworkSection struct {
 	DHCP                string   `json:"DHCP"`
 	DHCPServer          string   `json:"DHCPServer"`
-	Address             string   `json:"Address"`
-	Gateway             string   `json:"Gateway"`
+	Address             []string `json:"Address"`
+	Gateway             []string `json:"Gateway"`
 	DNS                 []string `json:"DNS"`
 	Domains             []string `json:"Domains"`
 	NTP                 []string `json:"NTP"`
@@ -53,7 +54,7 @@ type NetworkSection struct {
 	LinkLocalAddressing string   `json:"LinkLocalAddressing"`
 	MulticastDNS        string   `json:"MulticastDNS"`
 
-	VLAN string `json:"VLAN"`
+	VLAN []string `json:"VLAN"`
 }
 type AddressSection struct {
 	Address string `json:"Address"`
@@ -352,22 +353,20 @@ func (n *Network) buildNetworkSection(m *configfile.Meta) error {
 		m.SetKeySectionString("Network", "MulticastDNS", n.NetworkSection.MulticastDNS)
 	}
 
-	if !validator.IsEmpty(n.NetworkSection.Address) {
-		if validator.IsIP(n.NetworkSection.Address) {
-			m.SetKeySectionString("Network", "Address", n.NetworkSection.Address)
-		} else {
-			log.Errorf("Failed to parse Address='%s'", n.NetworkSection.Address)
-			return fmt.Errorf("invalid Address='%s'", n.NetworkSection.Address)
+	for _, a := range n.NetworkSection.Address {
+		if !validator.IsIP(a) {
+			log.Errorf("Failed to parse Address='%s'", a)
+			return fmt.Errorf("invalid Address='%s'", a)
 		}
+		m.NewKeyToSectionString("Network", "Address", a)
 	}
 
-	if !validator.IsEmpty(n.NetworkSection.Gateway) {
-		if validator.IsIP(n.NetworkSection.Gateway) {
-			m.SetKeySectionString("Network", "Gateway", n.NetworkSection.Gateway)
-		} else {
-			log.Errorf("Failed to parse Gateway='%s'", n.NetworkSection.Gateway)
-			return fmt.Errorf("invalid Gateway='%s'", n.NetworkSection.Gateway)
+	for _, a := range n.NetworkSection.Gateway {
+		if !validator.IsIP(a) {
+			log.Errorf("Failed to parse Gateway='%s'", a)
+			return fmt.Errorf("invalid Gateway='%s'", a)
 		}
+		m.NewKeyToSectionString("Network", "Gateway", a)
 	}
 
 	if !validator.IsArrayEmpty(n.NetworkSection.DNS) {
@@ -410,15 +409,15 @@ func (n *Network) removeNetworkSection(m *configfile.Meta) error {
 		m.SetKeySectionString("Network", "DHCPServer", n.NetworkSection.DHCPServer)
 	}
 
-	if !validator.IsEmpty(n.NetworkSection.Address) {
-		if validator.IsIP(n.NetworkSection.Address) {
-			m.RemoveKeyFromSectionString("Network", "Address", n.NetworkSection.Address)
+	for _, a := range n.NetworkSection.Address {
+		if validator.IsIP(a) {
+			m.RemoveValueFromSectionKey("Network", "Address", a)
 		}
 	}
 
-	if !validator.IsEmpty(n.NetworkSection.Gateway) {
-		if validator.IsIP(n.NetworkSection.Gateway) {
-			m.RemoveKeyFromSectionString("Network", "Gateway", n.NetworkSection.Gateway)
+	for _, a := range n.NetworkSection.Gateway {
+		if validator.IsIP(a) {
+			m.RemoveValueFromSectionKey("Network", "Gateway", a)
 		}
 	}
 
@@ -1357,13 +1356,7 @@ func (n *Network) removeIPv6RoutePrefixSection(m *configfile.Meta) error {
 	return nil
 }
 
-func (n *Network) ConfigureNetwork(ctx context.Context, w http.ResponseWriter) error {
-	m, err := CreateOrParseNetworkFile(n.Link)
-	if err != nil {
-		log.Errorf("Failed to parse network file for link='%s': %v", n.Link, err)
-		return err
-	}
-
+func (n *Network) buildSections(m *configfile.Meta) error {
 	if err := n.buildNetworkSection(m); err != nil {
 		return err
 	}
@@ -1404,6 +1397,39 @@ func (n *Network) ConfigureNetwork(ctx context.Context, w http.ResponseWriter) e
 		return err
 	}
 
+	return nil
+}
+
+// Render builds the .network file of the link from scratch without touching the disk. The file is
+// rendered at the path of the one in use by the link
+func (n *Network) Render() (*configfile.Meta, error) {
+	if validator.IsEmpty(n.Link) {
+		return nil, errors.New("missing link")
+	}
+
+	m := configfile.New(NetworkFilePath(n.Link))
+	if err := CreateMatchSection(m, n.Link); err != nil {
+		return nil, err
+	}
+
+	if err := n.buildSections(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (n *Network) ConfigureNetwork(ctx context.Context, w http.ResponseWriter) error {
+	m, err := CreateOrParseNetworkFile(n.Link)
+	if err != nil {
+		log.Errorf("Failed to parse network file for link='%s': %v", n.Link, err)
+		return err
+	}
+
+	if err := n.buildSections(m); err != nil {
+		return err
+	}
+
 	if err := m.Save(); err != nil {
 		log.Errorf("Failed to update config file='%s': %v", m.Path, err)
 		return err
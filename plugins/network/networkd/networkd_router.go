@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
@@ -18,7 +19,9 @@ func routerConfigureNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.ConfigureNetwork(r.Context(), w); err != nil {
+	if err := withTransaction(w, r, func() error {
+		return n.ConfigureNetwork(r.Context(), w)
+	}); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
@@ -30,7 +33,9 @@ func routerRemoveNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.RemoveNetwork(r.Context(), w); err != nil {
+	if err := withTransaction(w, r, func() error {
+		return n.RemoveNetwork(r.Context(), w)
+	}); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
@@ -60,7 +65,9 @@ func routerConfigureNetDev(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.ConfigureNetDev(r.Context(), w); err != nil {
+	if err := withTransaction(w, r, func() error {
+		return n.ConfigureNetDev(r.Context(), w)
+	}); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
@@ -72,7 +79,9 @@ func routerRemoveNetDev(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.RemoveNetDev(r.Context(), w); err != nil {
+	if err := withTransaction(w, r, func() error {
+		return n.RemoveNetDev(r.Context(), w)
+	}); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
@@ -84,11 +93,103 @@ func routerConfigureLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.ConfigureLink(r.Context(), w); err != nil {
+	if err := withTransaction(w, r, func() error {
+		return n.ConfigureLink(r.Context(), w)
+	}); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
 
+func routerAcquireNetworkConfig(w http.ResponseWriter, r *http.Request) {
+	c, err := AcquireNetworkConfig(mux.Vars(r)["link"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func routerAcquireNetDevConfig(w http.ResponseWriter, r *http.Request) {
+	c, err := AcquireNetDevConfig(mux.Vars(r)["name"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func routerAcquireLinkConfig(w http.ResponseWriter, r *http.Request) {
+	c, err := AcquireLinkConfig(mux.Vars(r)["name"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+// withTransaction applies a change in commit confirmed mode when the request carries 'confirm-timeout'.
+// The change is rolled back unless the transaction returned in the X-Transaction-Id header is confirmed in time.
+// Both paths refuse changes while another transaction is pending, checked under the lock of the transaction store.
+func withTransaction(w http.ResponseWriter, r *http.Request, apply func() error) error {
+	s := r.URL.Query().Get("confirm-timeout")
+	if s == "" {
+		return ApplyOutsideTransaction(apply)
+	}
+
+	timeout, err := ParseConfirmTimeout(s)
+	if err != nil {
+		return err
+	}
+
+	t, err := BeginTransaction(timeout, r.Method+" "+r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("X-Transaction-Id", t.Id)
+	if err := apply(); err != nil {
+		w.Header().Del("X-Transaction-Id")
+		if err := RollbackTransaction(r.Context(), t.Id); err != nil {
+			log.Errorf("Failed to roll back network transaction='%s': %v", t.Id, err)
+		}
+		return err
+	}
+
+	t.Arm(timeout)
+	return nil
+}
+
+func routerAcquireTransaction(w http.ResponseWriter, r *http.Request) {
+	t, err := AcquireTransaction(mux.Vars(r)["id"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(t, w)
+}
+
+func routerConfirmTransaction(w http.ResponseWriter, r *http.Request) {
+	if err := ConfirmTransaction(mux.Vars(r)["id"]); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("confirmed", w)
+}
+
+func routerRollbackTransaction(w http.ResponseWriter, r *http.Request) {
+	if err := RollbackTransaction(r.Context(), mux.Vars(r)["id"]); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("rolled back", w)
+}
+
 func RegisterRouterNetworkd(router *mux.Router) {
 	n := router.PathPrefix("/networkd").Subrouter().StrictSlash(false)
 
@@ -96,9 +197,18 @@ func RegisterRouterNetworkd(router *mux.Router) {
 	n.HandleFunc("/network/describelinks", routerAcquireLinks).Methods("GET")
 	n.HandleFunc("/network/configure", routerConfigureNetwork).Methods("POST")
 	n.HandleFunc("/network/remove", routerRemoveNetwork).Methods("DELETE")
+	n.HandleFunc("/network/{link}", routerAcquireNetworkConfig).Methods("GET")
 
 	n.HandleFunc("/netdev/configure", routerConfigureNetDev).Methods("POST")
 	n.HandleFunc("/netdev/remove", routerRemoveNetDev).Methods("DELETE")
+	n.HandleFunc("/netdev/{name}", routerAcquireNetDevConfig).Methods("GET")
 
 	n.HandleFunc("/link/configure", routerConfigureLink).Methods("POST")
+	n.HandleFunc("/link/{name}", routerAcquireLinkConfig).Methods("GET")
+
+	n.HandleFunc("/transaction/{id}", routerAcquireTransaction).Methods("GET")
+	n.HandleFunc("/transaction/{id}/confirm", routerConfirmTransaction).Methods("POST")
+	n.HandleFunc("/transaction/{id}/rollback", routerRollbackTransaction).Methods("POST")
+
+	go RecoverTransaction()
 }
@@ -0,0 +1,426 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package networkd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/pmd-next-gen/pkg/conf"
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+)
+
+const (
+	networkConfigPath = "/etc/systemd/network"
+	transactionFile   = "network-transaction.json"
+
+	TransactionApplying   = "applying"
+	TransactionPending    = "pending"
+	TransactionConfirmed  = "confirmed"
+	TransactionRolledBack = "rolledback"
+
+	minConfirmTimeout = time.Second
+	maxConfirmTimeout = time.Hour
+
+	// Finished transactions are kept this long so that their outcome can be queried
+	finishedRetention = 10 * time.Minute
+)
+
+type fileSnapshot struct {
+	Path    string      `json:"Path"`
+	Content []byte      `json:"Content"`
+	Mode    os.FileMode `json:"Mode"`
+	Uid     int         `json:"Uid"`
+	Gid     int         `json:"Gid"`
+}
+
+type Transaction struct {
+	Id         string    `json:"Id"`
+	State      string    `json:"State"`
+	Endpoint   string    `json:"Endpoint"`
+	Timeout    string    `json:"Timeout"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	Deadline   time.Time `json:"Deadline"`
+	FinishedAt time.Time `json:"FinishedAt,omitempty"`
+	Error      string    `json:"Error,omitempty"`
+
+	Files []fileSnapshot `json:"Files,omitempty"`
+	Links []string       `json:"Links,omitempty"`
+
+	timer *time.Timer
+}
+
+var transactions = struct {
+	m       map[string]*Transaction
+	pending *Transaction
+	lock    sync.Mutex
+}{
+	m: make(map[string]*Transaction),
+}
+
+func transactionStatePath() string {
+	return path.Join(conf.StateDirPath, transactionFile)
+}
+
+// ParseConfirmTimeout accepts a duration such as '90s' or plain seconds
+func ParseConfirmTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid confirm timeout '%s'", s)
+		}
+		d = time.Duration(n) * time.Second
+	}
+
+	if d < minConfirmTimeout || d > maxConfirmTimeout {
+		return 0, fmt.Errorf("confirm timeout must be between %v and %v", minConfirmTimeout, maxConfirmTimeout)
+	}
+
+	return d, nil
+}
+
+func newTransactionId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// snapshotFiles saves the configuration files in dir and their drop-ins in the *.d directories
+func snapshotFiles(dir string) ([]fileSnapshot, error) {
+	var matches []string
+	for _, pattern := range []string{"*", "*.d/*"} {
+		m, err := filepath.Glob(path.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m...)
+	}
+
+	l := []fileSnapshot{}
+	for _, f := range matches {
+		st, err := os.Stat(f)
+		if err != nil || !st.Mode().IsRegular() {
+			continue
+		}
+
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		s := fileSnapshot{
+			Path:    f,
+			Content: b,
+			Mode:    st.Mode().Perm(),
+		}
+		if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+			s.Uid = int(sys.Uid)
+			s.Gid = int(sys.Gid)
+		}
+
+		l = append(l, s)
+	}
+
+	return l, nil
+}
+
+func snapshotLinks() []string {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil
+	}
+
+	l := []string{}
+	for _, link := range links {
+		l = append(l, link.Attrs().Name)
+	}
+
+	return l
+}
+
+// PendingTransaction returns the transaction awaiting confirmation, if any
+func PendingTransaction() *Transaction {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	return transactions.pending
+}
+
+func (t *Transaction) save() {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+
+	if err := os.WriteFile(transactionStatePath(), b, 0600); err != nil {
+		log.Errorf("Failed to persist network transaction='%s': %v", t.Id, err)
+	}
+}
+
+// ApplyOutsideTransaction applies a change which is not to be confirmed. The lock is held while
+// applying, so that no transaction begins meanwhile and snapshots a half written configuration
+func ApplyOutsideTransaction(apply func() error) error {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	if transactions.pending != nil {
+		return fmt.Errorf("network transaction '%s' is awaiting confirmation", transactions.pending.Id)
+	}
+
+	return apply()
+}
+
+// BeginTransaction snapshots the network configuration before a change is applied
+func BeginTransaction(timeout time.Duration, endpoint string) (*Transaction, error) {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	if transactions.pending != nil {
+		return nil, fmt.Errorf("network transaction '%s' is awaiting confirmation", transactions.pending.Id)
+	}
+
+	files, err := snapshotFiles(networkConfigPath)
+	if err != nil {
+		log.Errorf("Failed to snapshot '%s': %v", networkConfigPath, err)
+		return nil, err
+	}
+
+	t := &Transaction{
+		Id:        newTransactionId(),
+		State:     TransactionApplying,
+		Endpoint:  endpoint,
+		Timeout:   timeout.String(),
+		CreatedAt: time.Now(),
+		Files:     files,
+		Links:     snapshotLinks(),
+	}
+
+	transactions.m[t.Id] = t
+	transactions.pending = t
+	t.save()
+
+	return t, nil
+}
+
+// Arm starts the confirmation timer once the change has been applied
+func (t *Transaction) Arm(timeout time.Duration) {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	t.State = TransactionPending
+	t.Deadline = time.Now().Add(timeout)
+	t.save()
+
+	t.timer = time.AfterFunc(timeout, func() {
+		log.Warnf("Network transaction='%s' was not confirmed within %v, rolling back", t.Id, timeout)
+		if err := RollbackTransaction(context.Background(), t.Id); err != nil {
+			log.Errorf("Failed to roll back network transaction='%s': %v", t.Id, err)
+		}
+	})
+}
+
+// finish must be called with the lock held
+func (t *Transaction) finish(state string) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	t.State = state
+	t.FinishedAt = time.Now()
+	t.Files = nil
+	t.Links = nil
+
+	if transactions.pending == t {
+		transactions.pending = nil
+	}
+
+	os.Remove(transactionStatePath())
+
+	time.AfterFunc(finishedRetention, func() {
+		transactions.lock.Lock()
+		defer transactions.lock.Unlock()
+
+		delete(transactions.m, t.Id)
+	})
+}
+
+func ConfirmTransaction(id string) error {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	t, ok := transactions.m[id]
+	if !ok {
+		return fmt.Errorf("transaction '%s' not found", id)
+	}
+
+	if t.State != TransactionPending {
+		return fmt.Errorf("transaction '%s' is %s", id, t.State)
+	}
+
+	t.finish(TransactionConfirmed)
+	log.Infof("Network transaction='%s' confirmed", id)
+
+	return nil
+}
+
+func RollbackTransaction(ctx context.Context, id string) error {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	t, ok := transactions.m[id]
+	if !ok {
+		return fmt.Errorf("transaction '%s' not found", id)
+	}
+
+	if t.State != TransactionPending && t.State != TransactionApplying {
+		return fmt.Errorf("transaction '%s' is %s", id, t.State)
+	}
+
+	err := t.restore(ctx)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	t.finish(TransactionRolledBack)
+
+	return err
+}
+
+func AcquireTransaction(id string) (*Transaction, error) {
+	transactions.lock.Lock()
+	defer transactions.lock.Unlock()
+
+	t, ok := transactions.m[id]
+	if !ok {
+		return nil, fmt.Errorf("transaction '%s' not found", id)
+	}
+
+	c := *t
+	c.Files = nil
+	c.Links = nil
+	c.timer = nil
+
+	return &c, nil
+}
+
+// restore puts back the snapshot, removes netdevs created since and makes networkd pick up the old configuration
+func (t *Transaction) restore(ctx context.Context) error {
+	saved := make(map[string]bool)
+	for _, f := range t.Files {
+		saved[f.Path] = true
+	}
+
+	current, err := snapshotFiles(networkConfigPath)
+	if err != nil {
+		return err
+	}
+
+	var created []string
+	dropInDirs := make(map[string]bool)
+	for _, f := range current {
+		if saved[f.Path] {
+			continue
+		}
+
+		if d := path.Dir(f.Path); d != networkConfigPath {
+			dropInDirs[d] = true
+		}
+
+		if path.Ext(f.Path) == ".netdev" {
+			if m, err := configfile.Load(f.Path); err == nil {
+				if name := m.GetKeySectionString("NetDev", "Name"); name != "" {
+					created = append(created, name)
+				}
+			}
+		}
+
+		if err := os.Remove(f.Path); err != nil {
+			log.Errorf("Failed to remove '%s' during rollback: %v", f.Path, err)
+			return err
+		}
+	}
+
+	// Drop-in directories created since are left empty now, the others are filled again below
+	for d := range dropInDirs {
+		os.Remove(d)
+	}
+
+	for _, f := range t.Files {
+		if err := os.MkdirAll(path.Dir(f.Path), 0755); err != nil {
+			log.Errorf("Failed to restore '%s' during rollback: %v", path.Dir(f.Path), err)
+			return err
+		}
+
+		if err := os.WriteFile(f.Path, f.Content, f.Mode); err != nil {
+			log.Errorf("Failed to restore '%s' during rollback: %v", f.Path, err)
+			return err
+		}
+		os.Chmod(f.Path, f.Mode)
+		if err := os.Chown(f.Path, f.Uid, f.Gid); err != nil {
+			log.Debugf("Failed to restore ownership of '%s': %v", f.Path, err)
+		}
+	}
+
+	existed := make(map[string]bool)
+	for _, l := range t.Links {
+		existed[l] = true
+	}
+
+	for _, name := range created {
+		if existed[name] {
+			continue
+		}
+		if link, err := netlink.LinkByName(name); err == nil {
+			if err := netlink.LinkDel(link); err != nil {
+				log.Errorf("Failed to remove netdev='%s' during rollback: %v", name, err)
+			}
+		}
+	}
+
+	if err := ReloadAndReconfigure(ctx, nil); err != nil {
+		return err
+	}
+
+	log.Infof("Network transaction='%s' rolled back", t.Id)
+	return nil
+}
+
+// RecoverTransaction rolls back a transaction which was still awaiting confirmation when photon-mgmtd stopped
+func RecoverTransaction() {
+	b, err := os.ReadFile(transactionStatePath())
+	if err != nil {
+		return
+	}
+
+	t := Transaction{}
+	if err := json.Unmarshal(b, &t); err != nil {
+		log.Errorf("Failed to decode network transaction state: %v", err)
+		os.Remove(transactionStatePath())
+		return
+	}
+
+	log.Warnf("Network transaction='%s' was not confirmed before photon-mgmtd stopped, rolling back", t.Id)
+
+	transactions.lock.Lock()
+	transactions.m[t.Id] = &t
+	transactions.pending = &t
+	transactions.lock.Unlock()
+
+	if err := RollbackTransaction(context.Background(), t.Id); err != nil {
+		log.Errorf("Failed to roll back network transaction='%s': %v", t.Id, err)
+	}
+}
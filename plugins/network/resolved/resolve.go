@@ -285,3 +285,30 @@ func (d *GlobalDns) RemoveDns(ctx context.Context, w http.ResponseWriter) error
 
 	return web.JSONResponse("removed", w)
 }
+
+// SetDns replaces the configured global DNS servers and search domains
+func (d *GlobalDns) SetDns(ctx context.Context) error {
+	m, err := configfile.Load("/etc/systemd/resolved.conf")
+	if err != nil {
+		return err
+	}
+
+	if !validator.IsArrayEmpty(d.DnsServers) && !validator.IsIPs(d.DnsServers) {
+		return errors.New("invalid Ips")
+	}
+
+	m.SetKeySectionString("Resolve", "DNS", strings.Join(d.DnsServers, " "))
+	m.SetKeySectionString("Resolve", "Domains", strings.Join(d.Domains, " "))
+
+	if err := m.Save(); err != nil {
+		log.Errorf("Failed to update config file='%s': %v", m.Path, err)
+		return err
+	}
+
+	if err := restartResolved(ctx); err != nil {
+		log.Errorf("Failed to restart systemd-resolved: %v", err)
+		return err
+	}
+
+	return nil
+}
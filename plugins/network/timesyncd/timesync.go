@@ -166,3 +166,25 @@ func (n *NTP) RemoveNTP(ctx context.Context, w http.ResponseWriter) error {
 
 	return web.JSONResponse("removed", w)
 }
+
+// SetNTP replaces the configured NTP servers
+func (n *NTP) SetNTP(ctx context.Context) error {
+	m, err := configfile.Load("/etc/systemd/timesyncd.conf")
+	if err != nil {
+		return err
+	}
+
+	m.SetKeySectionString("Time", "NTP", strings.Join(n.NTPServers, " "))
+
+	if err := m.Save(); err != nil {
+		log.Errorf("Failed to update config file='%s': %v", m.Path, err)
+		return err
+	}
+
+	if err := restartTimeSyncd(ctx); err != nil {
+		log.Errorf("Failed to restart systemd-timesyncd: %v", err)
+		return err
+	}
+
+	return nil
+}
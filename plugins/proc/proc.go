@@ -19,13 +19,14 @@ import (
 	"github.com/shirou/gopsutil/v3/process"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/system"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
 const (
 	procMiscPath    = "/proc/misc"
-	procNetArpPath  = "/proc/net/arp"
+	procNetArpPath  = "net/arp"
 	procModulesPath = "/proc/modules"
 )
 
@@ -111,7 +112,7 @@ func AcquireTemperatureStat(ctx context.Context, w http.ResponseWriter) error {
 
 // read netstat from proc tcp/udp/sctp
 func AcquireNetStat(ctx context.Context, w http.ResponseWriter, protocol string) error {
-	conn, err := net.ConnectionsWithContext(ctx, protocol)
+	conn, err := net.ConnectionsWithContext(netns.WithProcEnv(ctx), protocol)
 	if err != nil {
 		return err
 	}
@@ -136,7 +137,7 @@ func AcquireNetStatPid(ctx context.Context, w http.ResponseWriter, protocol stri
 func AcquireProtoCountersStat(ctx context.Context, w http.ResponseWriter) error {
 	protocols := []string{"ip", "icmp", "icmpmsg", "tcp", "udp", "udplite"}
 
-	proto, err := net.ProtoCountersWithContext(ctx, protocols)
+	proto, err := net.ProtoCountersWithContext(netns.WithProcEnv(ctx), protocols)
 	if err != nil {
 		return err
 	}
@@ -145,7 +146,7 @@ func AcquireProtoCountersStat(ctx context.Context, w http.ResponseWriter) error
 }
 
 func AcquireNetDevIOCounters(ctx context.Context, w http.ResponseWriter) error {
-	netDev, err := net.IOCountersWithContext(ctx, true)
+	netDev, err := net.IOCountersWithContext(netns.WithProcEnv(ctx), true)
 	if err != nil {
 		return err
 	}
@@ -247,9 +248,10 @@ func AcquireMisc(ctx context.Context, w http.ResponseWriter) error {
 }
 
 func AcquireNetArp(ctx context.Context, w http.ResponseWriter) error {
-	lines, err := system.ReadFullFile(procNetArpPath)
+	arpPath := netns.ProcPath(ctx, procNetArpPath)
+	lines, err := system.ReadFullFile(arpPath)
 	if err != nil {
-		log.Errorf("Failed to read '%s': %v", procNetArpPath, err)
+		log.Errorf("Failed to read '%s': %v", arpPath, err)
 		return err
 	}
 
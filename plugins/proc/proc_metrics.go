@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package proc
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/plugins/network/networkd"
+	"github.com/vmware/pmd-next-gen/plugins/systemd"
+)
+
+const (
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	metricsNamespace = "photon_"
+
+	metricCounter = "counter"
+	metricGauge   = "gauge"
+)
+
+// Protocol counters which are current values rather than monotonic counts
+var protoGaugeFields = map[string]bool{
+	"Forwarding":   true,
+	"DefaultTTL":   true,
+	"RtoAlgorithm": true,
+	"RtoMin":       true,
+	"RtoMax":       true,
+	"MaxConn":      true,
+	"CurrEstab":    true,
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []sample
+}
+
+// Metrics holds the metric families of one scrape in the order they were first added
+type Metrics struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+type collector struct {
+	name    string
+	collect func(ctx context.Context, m *Metrics) error
+}
+
+var collectors = []collector{
+	{"load", collectLoad},
+	{"cpu", collectCPU},
+	{"memory", collectMemory},
+	{"disk", collectDisk},
+	{"netdev", collectNetDev},
+	{"netstat", collectNetStat},
+	{"temperature", collectTemperature},
+	{"networkd", collectNetworkd},
+	{"systemd", collectSystemd},
+}
+
+func (m *Metrics) add(name string, kind string, help string, value float64, labels ...label) {
+	if m.index == nil {
+		m.index = make(map[string]*metricFamily)
+	}
+
+	f, ok := m.index[name]
+	if !ok {
+		f = &metricFamily{
+			name: metricsNamespace + name,
+			kind: kind,
+			help: help,
+		}
+		m.index[name] = f
+		m.families = append(m.families, f)
+	}
+
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+func (m *Metrics) counter(name string, help string, value float64, labels ...label) {
+	m.add(name, metricCounter, help, value, labels...)
+}
+
+func (m *Metrics) gauge(name string, help string, value float64, labels ...label) {
+	m.add(name, metricGauge, help, value, labels...)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteTo encodes the metrics in the OpenMetrics text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	for _, f := range m.families {
+		b.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+		b.WriteString("# HELP " + f.name + " " + f.help + "\n")
+
+		name := f.name
+		if f.kind == metricCounter {
+			name += "_total"
+		}
+
+		for _, s := range f.samples {
+			b.WriteString(name)
+			if len(s.labels) > 0 {
+				l := make([]string, 0, len(s.labels))
+				for _, e := range s.labels {
+					l = append(l, e.name+`="`+escapeLabelValue(e.value)+`"`)
+				}
+				b.WriteString("{" + strings.Join(l, ",") + "}")
+			}
+			b.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+
+	b.WriteString("# EOF\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// AcquireMetrics runs all the collectors. A failing collector is reported through
+// photon_scrape_collector_success and does not fail the scrape
+func AcquireMetrics(ctx context.Context) *Metrics {
+	m := Metrics{}
+
+	for _, c := range collectors {
+		success := 1.0
+		if err := c.collect(ctx, &m); err != nil {
+			log.Errorf("Failed to collect '%s' metrics: %v", c.name, err)
+			success = 0
+		}
+
+		m.gauge("scrape_collector_success", "Whether a collector succeeded.", success, label{"collector", c.name})
+	}
+
+	return &m
+}
+
+func collectLoad(ctx context.Context, m *Metrics) error {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.gauge("load1", "1m load average.", avg.Load1)
+	m.gauge("load5", "5m load average.", avg.Load5)
+	m.gauge("load15", "15m load average.", avg.Load15)
+
+	return nil
+}
+
+func collectCPU(ctx context.Context, m *Metrics) error {
+	times, err := cpu.TimesWithContext(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	help := "Seconds the CPUs spent in each mode."
+	for _, t := range times {
+		c := strings.TrimPrefix(t.CPU, "cpu")
+		for _, mode := range []struct {
+			name  string
+			value float64
+		}{
+			{"user", t.User},
+			{"nice", t.Nice},
+			{"system", t.System},
+			{"idle", t.Idle},
+			{"iowait", t.Iowait},
+			{"irq", t.Irq},
+			{"softirq", t.Softirq},
+			{"steal", t.Steal},
+		} {
+			m.counter("cpu_seconds", help, mode.value, label{"cpu", c}, label{"mode", mode.name})
+		}
+	}
+
+	return nil
+}
+
+func collectMemory(ctx context.Context, m *Metrics) error {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.gauge("memory_total_bytes", "Total physical memory in bytes.", float64(v.Total))
+	m.gauge("memory_available_bytes", "Memory available for starting new applications in bytes.", float64(v.Available))
+	m.gauge("memory_used_bytes", "Used memory in bytes.", float64(v.Used))
+	m.gauge("memory_free_bytes", "Free memory in bytes.", float64(v.Free))
+	m.gauge("memory_buffers_bytes", "Memory used by kernel buffers in bytes.", float64(v.Buffers))
+	m.gauge("memory_cached_bytes", "Memory used by the page cache in bytes.", float64(v.Cached))
+	m.gauge("memory_shared_bytes", "Shared memory in bytes.", float64(v.Shared))
+	m.gauge("memory_swap_total_bytes", "Total swap space in bytes.", float64(v.SwapTotal))
+	m.gauge("memory_swap_free_bytes", "Free swap space in bytes.", float64(v.SwapFree))
+
+	return nil
+}
+
+func collectDisk(ctx context.Context, m *Metrics) error {
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(counters))
+	for n := range counters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		c := counters[n]
+		l := label{"disk", n}
+
+		m.counter("disk_reads_completed", "Reads completed successfully.", float64(c.ReadCount), l)
+		m.counter("disk_writes_completed", "Writes completed successfully.", float64(c.WriteCount), l)
+		m.counter("disk_read_bytes", "Bytes read successfully.", float64(c.ReadBytes), l)
+		m.counter("disk_written_bytes", "Bytes written successfully.", float64(c.WriteBytes), l)
+		m.counter("disk_read_time_seconds", "Seconds spent by all reads.", float64(c.ReadTime)/1000, l)
+		m.counter("disk_write_time_seconds", "Seconds spent by all writes.", float64(c.WriteTime)/1000, l)
+		m.counter("disk_io_time_seconds", "Seconds spent doing I/Os.", float64(c.IoTime)/1000, l)
+		m.gauge("disk_io_now", "I/Os currently in progress.", float64(c.IopsInProgress), l)
+	}
+
+	return nil
+}
+
+func collectNetDev(ctx context.Context, m *Metrics) error {
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range counters {
+		l := label{"interface", c.Name}
+
+		m.counter("network_receive_bytes", "Bytes received by the interface.", float64(c.BytesRecv), l)
+		m.counter("network_transmit_bytes", "Bytes transmitted by the interface.", float64(c.BytesSent), l)
+		m.counter("network_receive_packets", "Packets received by the interface.", float64(c.PacketsRecv), l)
+		m.counter("network_transmit_packets", "Packets transmitted by the interface.", float64(c.PacketsSent), l)
+		m.counter("network_receive_errors", "Receive errors of the interface.", float64(c.Errin), l)
+		m.counter("network_transmit_errors", "Transmit errors of the interface.", float64(c.Errout), l)
+		m.counter("network_receive_drop", "Received packets dropped by the interface.", float64(c.Dropin), l)
+		m.counter("network_transmit_drop", "Transmitted packets dropped by the interface.", float64(c.Dropout), l)
+	}
+
+	return nil
+}
+
+func collectNetStat(ctx context.Context, m *Metrics) error {
+	protocols := []string{"ip", "icmp", "icmpmsg", "tcp", "udp", "udplite"}
+
+	proto, err := net.ProtoCountersWithContext(ctx, protocols)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range proto {
+		fields := make([]string, 0, len(p.Stats))
+		for f := range p.Stats {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+
+		for _, f := range fields {
+			l := []label{{"protocol", p.Protocol}, {"field", f}}
+			if protoGaugeFields[f] {
+				m.gauge("netstat_value", "Current protocol values from /proc/net/snmp.", float64(p.Stats[f]), l...)
+			} else {
+				m.counter("netstat", "Protocol counters from /proc/net/snmp.", float64(p.Stats[f]), l...)
+			}
+		}
+	}
+
+	return nil
+}
+
+func collectTemperature(ctx context.Context, m *Metrics) error {
+	temperatures, err := host.SensorsTemperaturesWithContext(ctx)
+	if err != nil && len(temperatures) == 0 {
+		return err
+	}
+
+	for _, t := range temperatures {
+		l := label{"sensor", t.SensorKey}
+
+		m.gauge("temperature_celsius", "Temperature of the sensor in degrees celsius.", t.Temperature, l)
+		if t.Critical > 0 {
+			m.gauge("temperature_critical_celsius", "Critical temperature of the sensor in degrees celsius.", t.Critical, l)
+		}
+	}
+
+	return nil
+}
+
+func collectNetworkd(ctx context.Context, m *Metrics) error {
+	links, err := networkd.AcquireLinks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range links.Interfaces {
+		m.gauge("networkd_link_operational_state", "Operational state of the link as reported by systemd-networkd.", 1,
+			label{"interface", l.Name}, label{"state", l.OperationalState})
+	}
+
+	return nil
+}
+
+func collectSystemd(ctx context.Context, m *Metrics) error {
+	units, err := systemd.AcquireUnits(ctx)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].Name < units[j].Name
+	})
+
+	failed := 0
+	for _, u := range units {
+		if u.ActiveState == "failed" {
+			failed++
+		}
+
+		m.gauge("systemd_unit_state", "Active state of the unit.", 1, label{"unit", u.Name}, label{"state", u.ActiveState})
+	}
+
+	m.gauge("systemd_units_failed", "Number of units in the failed state.", float64(failed))
+
+	return nil
+}
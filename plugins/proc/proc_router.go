@@ -9,7 +9,9 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 
+	"github.com/vmware/pmd-next-gen/pkg/netns"
 	"github.com/vmware/pmd-next-gen/pkg/web"
 )
 
@@ -150,6 +152,7 @@ func routerAcquireSystem(w http.ResponseWriter, r *http.Request) {
 
 func RegisterRouterProc(router *mux.Router) {
 	n := router.PathPrefix("/proc").Subrouter().StrictSlash(false)
+	n.Use(netns.Middleware)
 
 	n.HandleFunc("/sys/net/{path}/{property}", routerAcquireProcSysNet).Methods("GET")
 	n.HandleFunc("/sys/net/{path}/{link}/{property}", routerAcquireProcSysNet).Methods("GET")
@@ -167,3 +170,17 @@ func RegisterRouterProc(router *mux.Router) {
 	n.HandleFunc("/process/{pid}/{property}", routerAcquireProcProcess).Methods("GET")
 	n.HandleFunc("/protopidstat/{pid}/{protocol}", routerAcquireProcPidNetStat).Methods("GET")
 }
+
+func routerAcquireMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", OpenMetricsContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := AcquireMetrics(r.Context()).WriteTo(w); err != nil {
+		log.Errorf("Failed to write metrics: %v", err)
+	}
+}
+
+// RegisterRouterMetrics exposes the metrics outside of the versioned API where scrapers expect them
+func RegisterRouterMetrics(router *mux.Router) {
+	router.HandleFunc("/metrics", routerAcquireMetrics).Methods("GET")
+}
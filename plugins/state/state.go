@@ -0,0 +1,624 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+	"github.com/vmware/pmd-next-gen/pkg/system"
+	"github.com/vmware/pmd-next-gen/pkg/validator"
+	"github.com/vmware/pmd-next-gen/plugins/management/hostname"
+	"github.com/vmware/pmd-next-gen/plugins/management/sysctl"
+	"github.com/vmware/pmd-next-gen/plugins/management/timedate"
+	"github.com/vmware/pmd-next-gen/plugins/network/firewall"
+	"github.com/vmware/pmd-next-gen/plugins/network/networkd"
+	"github.com/vmware/pmd-next-gen/plugins/network/resolved"
+	"github.com/vmware/pmd-next-gen/plugins/network/timesyncd"
+	"github.com/vmware/pmd-next-gen/plugins/systemd"
+)
+
+const (
+	ActionSet     = "set"
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionReload  = "reload"
+	ActionStart   = "start"
+	ActionStop    = "stop"
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+)
+
+type Dns struct {
+	Servers []string `json:"Servers"`
+	Domains []string `json:"Domains"`
+}
+
+type Firewall struct {
+	Tables []firewall.Table `json:"Tables"`
+	Chains []firewall.Chain `json:"Chains"`
+}
+
+type Unit struct {
+	State   string `json:"State"`
+	Enabled *bool  `json:"Enabled"`
+}
+
+// State is the desired configuration of the host. Omitted fields are left untouched
+type State struct {
+	Hostname string             `json:"Hostname"`
+	Timezone string             `json:"Timezone"`
+	NTP      []string           `json:"NTP"`
+	DNS      *Dns               `json:"DNS"`
+	Sysctl   map[string]string  `json:"Sysctl"`
+	NetDevs  []networkd.NetDev  `json:"NetDevs"`
+	Networks []networkd.Network `json:"Networks"`
+	Links    []networkd.Link    `json:"Links"`
+	Firewall *Firewall          `json:"Firewall"`
+	Units    map[string]Unit    `json:"Units"`
+}
+
+type Change struct {
+	Subsystem string      `json:"Subsystem"`
+	Name      string      `json:"Name"`
+	Action    string      `json:"Action"`
+	Current   interface{} `json:"Current,omitempty"`
+	Desired   interface{} `json:"Desired,omitempty"`
+	Applied   bool        `json:"Applied"`
+	Error     string      `json:"Error,omitempty"`
+
+	apply func(ctx context.Context) error
+}
+
+type Plan struct {
+	DryRun  bool      `json:"DryRun"`
+	Changes []*Change `json:"Changes"`
+}
+
+// Parse decodes a YAML or JSON state document
+func Parse(data []byte) (*State, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := State{}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (p *Plan) add(c *Change) {
+	p.Changes = append(p.Changes, c)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *State) planHostname(ctx context.Context, p *Plan) error {
+	if validator.IsEmpty(s.Hostname) {
+		return nil
+	}
+
+	d, err := hostname.MethodDescribe(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire hostname: %v", err)
+	}
+
+	if d.StaticHostname == s.Hostname {
+		return nil
+	}
+
+	p.add(&Change{
+		Subsystem: "hostname",
+		Name:      "StaticHostname",
+		Action:    ActionSet,
+		Current:   d.StaticHostname,
+		Desired:   s.Hostname,
+		apply: func(ctx context.Context) error {
+			c, err := hostname.NewSDConnection()
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			return c.DBusExecuteMethod(ctx, "SetStaticHostname", s.Hostname)
+		},
+	})
+
+	return nil
+}
+
+func (s *State) planTimezone(p *Plan) error {
+	if validator.IsEmpty(s.Timezone) {
+		return nil
+	}
+
+	d, err := timedate.DBusAcquireTimeDate()
+	if err != nil {
+		return fmt.Errorf("failed to acquire timezone: %v", err)
+	}
+
+	if d.Timezone == s.Timezone {
+		return nil
+	}
+
+	p.add(&Change{
+		Subsystem: "timedate",
+		Name:      "Timezone",
+		Action:    ActionSet,
+		Current:   d.Timezone,
+		Desired:   s.Timezone,
+		apply: func(ctx context.Context) error {
+			t := timedate.TimeDate{
+				Method: "SetTimezone",
+				Value:  s.Timezone,
+			}
+			return t.Configure()
+		},
+	})
+
+	return nil
+}
+
+func (s *State) planNTP(ctx context.Context, p *Plan) error {
+	if s.NTP == nil {
+		return nil
+	}
+
+	d, err := timesyncd.DescribeNTPServers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire NTP servers: %v", err)
+	}
+
+	if equalStrings(d.SystemNTPServers, s.NTP) {
+		return nil
+	}
+
+	p.add(&Change{
+		Subsystem: "timesyncd",
+		Name:      "NTP",
+		Action:    ActionSet,
+		Current:   d.SystemNTPServers,
+		Desired:   s.NTP,
+		apply: func(ctx context.Context) error {
+			n := timesyncd.NTP{
+				NTPServers: s.NTP,
+			}
+			return n.SetNTP(ctx)
+		},
+	})
+
+	return nil
+}
+
+func (s *State) planDns(ctx context.Context, p *Plan) error {
+	if s.DNS == nil {
+		return nil
+	}
+
+	dns, err := resolved.AcquireDns(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire DNS servers: %v", err)
+	}
+
+	domains, err := resolved.AcquireDomains(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire DNS domains: %v", err)
+	}
+
+	current := Dns{}
+	for _, d := range dns {
+		if d.Index == 0 {
+			current.Servers = append(current.Servers, d.Dns)
+		}
+	}
+	for _, d := range domains {
+		if d.Index == 0 {
+			current.Domains = append(current.Domains, d.Domain)
+		}
+	}
+
+	if equalStrings(current.Servers, s.DNS.Servers) && equalStrings(current.Domains, s.DNS.Domains) {
+		return nil
+	}
+
+	p.add(&Change{
+		Subsystem: "resolved",
+		Name:      "DNS",
+		Action:    ActionSet,
+		Current:   current,
+		Desired:   s.DNS,
+		apply: func(ctx context.Context) error {
+			d := resolved.GlobalDns{
+				DnsServers: s.DNS.Servers,
+				Domains:    s.DNS.Domains,
+			}
+			return d.SetDns(ctx)
+		},
+	})
+
+	return nil
+}
+
+func (s *State) planSysctl(p *Plan) error {
+	keys := make([]string, 0, len(s.Sysctl))
+	for k := range s.Sysctl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, err := sysctl.AcquireValue(k)
+		if err != nil {
+			return fmt.Errorf("failed to acquire sysctl key='%s': %v", k, err)
+		}
+
+		// /proc/sys separates multiple values with tabs
+		desired := strings.Join(strings.Fields(s.Sysctl[k]), " ")
+		current := strings.Join(strings.Fields(v), " ")
+		if current == desired {
+			continue
+		}
+
+		key := k
+		p.add(&Change{
+			Subsystem: "sysctl",
+			Name:      key,
+			Action:    ActionSet,
+			Current:   current,
+			Desired:   desired,
+			apply: func(ctx context.Context) error {
+				c := sysctl.Sysctl{
+					Key:   key,
+					Value: desired,
+					Apply: true,
+				}
+				return c.Configure()
+			},
+		})
+	}
+
+	return nil
+}
+
+// planFile compares a rendered configuration file with the one on disk and adds a change writing it when they differ
+func planFile(p *Plan, subsystem string, name string, m *configfile.Meta) (bool, error) {
+	desired, err := m.Bytes()
+	if err != nil {
+		return false, err
+	}
+
+	action := ActionCreate
+	if system.PathExists(m.Path) {
+		c, err := configfile.Load(m.Path)
+		if err != nil {
+			return false, err
+		}
+
+		current, err := c.Bytes()
+		if err != nil {
+			return false, err
+		}
+
+		if bytes.Equal(current, desired) {
+			return false, nil
+		}
+
+		action = ActionUpdate
+	}
+
+	p.add(&Change{
+		Subsystem: subsystem,
+		Name:      name,
+		Action:    action,
+		Desired:   m.Path,
+		apply: func(ctx context.Context) error {
+			if err := m.Save(); err != nil {
+				log.Errorf("Failed to update config file='%s': %v", m.Path, err)
+				return err
+			}
+
+			system.ChangePermission("systemd-network", m.Path)
+			return nil
+		},
+	})
+
+	return true, nil
+}
+
+func (s *State) planNetworkd(ctx context.Context, p *Plan) error {
+	var links []string
+	changed := false
+
+	for i := range s.NetDevs {
+		n := &s.NetDevs[i]
+
+		m, nm, err := n.Render()
+		if err != nil {
+			return fmt.Errorf("invalid netdev='%s': %v", n.Name, err)
+		}
+
+		for _, f := range []*configfile.Meta{m, nm} {
+			c, err := planFile(p, "netdev", n.Name, f)
+			if err != nil {
+				return err
+			}
+			changed = changed || c
+		}
+
+		links = append(links, n.Name)
+	}
+
+	// Links carrying a netdev need a .network file even when the document does not describe one
+	networks := s.Networks
+	for _, n := range s.NetDevs {
+		for _, l := range n.Links {
+			found := false
+			for _, nw := range networks {
+				if nw.Link == l {
+					found = true
+					break
+				}
+			}
+			if !found {
+				networks = append(networks, networkd.Network{Link: l})
+			}
+		}
+	}
+
+	for i := range networks {
+		n := &networks[i]
+
+		m, err := n.Render()
+		if err != nil {
+			return fmt.Errorf("invalid network='%s': %v", n.Link, err)
+		}
+
+		for j := range s.NetDevs {
+			d := &s.NetDevs[j]
+			for _, l := range d.Links {
+				if l == n.Link {
+					if err := d.BuildKindInNetworkSection(m); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		c, err := planFile(p, "network", n.Link, m)
+		if err != nil {
+			return err
+		}
+		if c {
+			changed = true
+			links = append(links, n.Link)
+		}
+	}
+
+	for i := range s.Links {
+		l := &s.Links[i]
+
+		m, err := l.Render()
+		if err != nil {
+			return fmt.Errorf("invalid link='%s': %v", l.Link, err)
+		}
+
+		c, err := planFile(p, "link", l.Link, m)
+		if err != nil {
+			return err
+		}
+		if c {
+			changed = true
+			links = append(links, l.Link)
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if t := networkd.PendingTransaction(); t != nil {
+		return fmt.Errorf("network transaction '%s' is awaiting confirmation", t.Id)
+	}
+
+	p.add(&Change{
+		Subsystem: "networkd",
+		Name:      "systemd-networkd",
+		Action:    ActionReload,
+		Desired:   links,
+		apply: func(ctx context.Context) error {
+			return networkd.ReloadAndReconfigure(ctx, links)
+		},
+	})
+
+	return nil
+}
+
+func (s *State) planFirewall(p *Plan) error {
+	if s.Firewall == nil {
+		return nil
+	}
+
+	for _, t := range s.Firewall.Tables {
+		n := firewall.Nft{Table: t}
+		ok, err := n.TableExists()
+		if err != nil {
+			return fmt.Errorf("failed to acquire nft tables: %v", err)
+		}
+		if ok {
+			continue
+		}
+
+		p.add(&Change{
+			Subsystem: "nft",
+			Name:      "table " + t.Name,
+			Action:    ActionCreate,
+			Desired:   t,
+			apply: func(ctx context.Context) error {
+				return n.ConfigureTable()
+			},
+		})
+	}
+
+	for _, ch := range s.Firewall.Chains {
+		n := firewall.Nft{Chain: ch}
+		ok, err := n.ChainExists()
+		if err != nil {
+			return fmt.Errorf("failed to acquire nft chains: %v", err)
+		}
+		if ok {
+			continue
+		}
+
+		p.add(&Change{
+			Subsystem: "nft",
+			Name:      "chain " + ch.Table + " " + ch.Name,
+			Action:    ActionCreate,
+			Desired:   ch,
+			apply: func(ctx context.Context) error {
+				return n.ConfigureChain()
+			},
+		})
+	}
+
+	return nil
+}
+
+func (s *State) planUnits(ctx context.Context, p *Plan) error {
+	names := make([]string, 0, len(s.Units))
+	for k := range s.Units {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		name := n
+		desired := s.Units[name]
+		if !validator.IsEmpty(desired.State) && desired.State != "active" && desired.State != "inactive" {
+			return fmt.Errorf("invalid State='%s' for unit='%s'", desired.State, name)
+		}
+
+		u := systemd.UnitRequest{Unit: name}
+		st, err := u.AcquireUnitState(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to acquire unit='%s' state: %v", name, err)
+		}
+
+		if desired.Enabled != nil && *desired.Enabled != (st.UnitFileState == "enabled") {
+			verb := ActionDisable
+			if *desired.Enabled {
+				verb = ActionEnable
+			}
+
+			p.add(&Change{
+				Subsystem: "systemd",
+				Name:      name,
+				Action:    verb,
+				Current:   st.UnitFileState,
+				apply: func(ctx context.Context) error {
+					u := systemd.UnitRequest{Unit: name, Verb: verb}
+					return u.UnitCommands(ctx)
+				},
+			})
+		}
+
+		if !validator.IsEmpty(desired.State) && desired.State != st.ActiveState {
+			verb := ActionStop
+			if desired.State == "active" {
+				verb = ActionStart
+			}
+
+			p.add(&Change{
+				Subsystem: "systemd",
+				Name:      name,
+				Action:    verb,
+				Current:   st.ActiveState,
+				Desired:   desired.State,
+				apply: func(ctx context.Context) error {
+					u := systemd.UnitRequest{Unit: name, Verb: verb}
+					return u.UnitCommands(ctx)
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+// Plan computes the changes needed to bring the host to the desired state
+func (s *State) Plan(ctx context.Context) (*Plan, error) {
+	p := Plan{
+		Changes: []*Change{},
+	}
+
+	if err := s.planHostname(ctx, &p); err != nil {
+		return nil, err
+	}
+	if err := s.planTimezone(&p); err != nil {
+		return nil, err
+	}
+	if err := s.planNTP(ctx, &p); err != nil {
+		return nil, err
+	}
+	if err := s.planDns(ctx, &p); err != nil {
+		return nil, err
+	}
+	if err := s.planSysctl(&p); err != nil {
+		return nil, err
+	}
+	if err := s.planNetworkd(ctx, &p); err != nil {
+		return nil, err
+	}
+	if err := s.planFirewall(&p); err != nil {
+		return nil, err
+	}
+	if err := s.planUnits(ctx, &p); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Apply executes the changes of the plan in order and stops at the first failure
+func (p *Plan) Apply(ctx context.Context) error {
+	for _, c := range p.Changes {
+		jobs.Progress(ctx, "%s %s '%s'", c.Action, c.Subsystem, c.Name)
+
+		if err := c.apply(ctx); err != nil {
+			log.Errorf("Failed to %s %s '%s': %v", c.Action, c.Subsystem, c.Name, err)
+			c.Error = err.Error()
+			return fmt.Errorf("failed to %s %s '%s': %v", c.Action, c.Subsystem, c.Name, err)
+		}
+
+		log.Infof("Desired state: %s %s '%s'", c.Action, c.Subsystem, c.Name)
+		c.Applied = true
+	}
+
+	return nil
+}
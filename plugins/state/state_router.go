@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+	"github.com/vmware/pmd-next-gen/pkg/web"
+)
+
+func routerApplyState(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	s, err := Parse(b)
+	if err != nil {
+		web.JSONResponseError(fmt.Errorf("invalid state document: %v", err), w)
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry-run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			web.JSONResponseError(err, w)
+			return
+		}
+	}
+
+	p, err := s.Plan(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	if dryRun {
+		p.DryRun = true
+		web.JSONResponse(p, w)
+		return
+	}
+
+	job := jobs.CreateJob(r, func(ctx context.Context) (interface{}, error) {
+		// The plan carries the status of each step, hand it back on failure too
+		return p, p.Apply(ctx)
+	})
+	jobs.AcceptedResponse(w, job)
+}
+
+func RegisterRouterState(router *mux.Router) {
+	n := router.PathPrefix("/state").Subrouter()
+
+	n.HandleFunc("/apply", routerApplyState).Methods("POST")
+}
@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"path"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -182,23 +184,57 @@ func ManagerAcquireSystemProperty(ctx context.Context, w http.ResponseWriter, pr
 	return web.JSONResponse(p, w)
 }
 
-func ListUnits(ctx context.Context, w http.ResponseWriter) error {
+// AcquireUnits returns the units currently loaded by systemd
+func AcquireUnits(ctx context.Context) ([]sd.UnitStatus, error) {
 	conn, err := sd.NewSystemdConnectionContext(ctx)
 	if err != nil {
 		log.Errorf("Failed to establish connection with system bus: %s", err)
-		return err
+		return nil, err
 	}
 	defer conn.Close()
 
 	units, err := conn.ListUnitsContext(ctx)
 	if err != nil {
 		log.Errorf("Failed list systemd units: %v", err)
+		return nil, err
+	}
+
+	return units, nil
+}
+
+func ListUnits(ctx context.Context, w http.ResponseWriter, f *UnitFilter) error {
+	units, err := AcquireFilteredUnits(ctx, f)
+	if err != nil {
 		return err
 	}
 
 	return web.JSONResponse(units, w)
 }
 
+// AcquireEnabledUnits returns the sorted names of the enabled unit files
+func AcquireEnabledUnits(ctx context.Context) ([]string, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	files, err := conn.ListUnitFilesByPatternsContext(ctx, []string{"enabled"}, nil)
+	if err != nil {
+		log.Errorf("Failed list systemd unit files: %v", err)
+		return nil, err
+	}
+
+	units := make([]string, 0, len(files))
+	for _, f := range files {
+		units = append(units, path.Base(f.Path))
+	}
+	sort.Strings(units)
+
+	return units, nil
+}
+
 func (u *UnitRequest) UnitCommands(ctx context.Context) error {
 	conn, err := sd.NewSystemdConnectionContext(ctx)
 	if err != nil {
@@ -207,7 +243,11 @@ func (u *UnitRequest) UnitCommands(ctx context.Context) error {
 	}
 	defer conn.Close()
 
-	c := make(chan string)
+	return u.unitCommand(ctx, conn, make(chan string, 1))
+}
+
+// unitCommand executes the verb on conn. The result of the job, if the verb queues one, is sent to c
+func (u *UnitRequest) unitCommand(ctx context.Context, conn *sd.Conn, c chan<- string) error {
 	switch u.Verb {
 	case "start":
 		jid, err := conn.StartUnitContext(ctx, u.Unit, "replace", c)
@@ -299,6 +339,14 @@ func (u *UnitRequest) UnitCommands(ctx context.Context) error {
 
 		log.Debugf("Successfully executed 'unmask' on systemd unit='%s' changes='%s'", u.Unit, changes)
 
+	case "reset-failed":
+		if err := conn.ResetFailedUnitContext(ctx, u.Unit); err != nil {
+			log.Errorf("Failed to reset failed systemd unit='%s': %v", u.Unit, err)
+			return err
+		}
+
+		log.Debugf("Successfully executed 'reset-failed' on systemd unit='%s'", u.Unit)
+
 	case "kill":
 		signal, err := strconv.ParseInt(u.Value, 10, 64)
 		if err != nil {
@@ -316,6 +364,41 @@ func (u *UnitRequest) UnitCommands(ctx context.Context) error {
 	return nil
 }
 
+// AcquireUnitState returns the active and unit file state of the unit
+func (u *UnitRequest) AcquireUnitState(ctx context.Context) (*UnitStatus, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsByNamesContext(ctx, []string{u.Unit})
+	if err != nil {
+		log.Errorf("Failed fetch systemd unit='%s' status: %v", u.Unit, err)
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, errors.New("unit not found")
+	}
+
+	unit := UnitStatus{
+		Unit:        u.Unit,
+		Name:        units[0].Name,
+		Status:      units[0].ActiveState,
+		LoadState:   units[0].LoadState,
+		ActiveState: units[0].ActiveState,
+		SubState:    units[0].SubState,
+	}
+
+	if st, err := conn.GetUnitPropertyContext(ctx, u.Unit, "UnitFileState"); err == nil {
+		s, _ := strconv.Unquote(st.Value.String())
+		unit.UnitFileState = s
+	}
+
+	return &unit, nil
+}
+
 func (u *UnitRequest) AcquireUnitStatus(ctx context.Context, w http.ResponseWriter) error {
 	conn, err := sd.NewSystemdConnectionContext(ctx)
 	if err != nil {
@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/bus"
+)
+
+const (
+	systemdBusName    = "org.freedesktop.systemd1"
+	systemdObjectPath = "/org/freedesktop/systemd1"
+	managerInterface  = "org.freedesktop.systemd1.Manager"
+)
+
+// BootTimes holds the time spent in each boot phase, as systemd-analyze time shows it
+type BootTimes struct {
+	FirmwareUSec  uint64 `json:"FirmwareUSec"`
+	LoaderUSec    uint64 `json:"LoaderUSec"`
+	KernelUSec    uint64 `json:"KernelUSec"`
+	InitRDUSec    uint64 `json:"InitRDUSec"`
+	UserspaceUSec uint64 `json:"UserspaceUSec"`
+	TotalUSec     uint64 `json:"TotalUSec"`
+
+	// Raw monotonic timestamps of the manager
+	FirmwareTimestampMonotonic  uint64 `json:"FirmwareTimestampMonotonic"`
+	LoaderTimestampMonotonic    uint64 `json:"LoaderTimestampMonotonic"`
+	InitRDTimestampMonotonic    uint64 `json:"InitRDTimestampMonotonic"`
+	UserspaceTimestampMonotonic uint64 `json:"UserspaceTimestampMonotonic"`
+	FinishTimestampMonotonic    uint64 `json:"FinishTimestampMonotonic"`
+}
+
+// UnitTime is the time a unit took to activate during boot
+type UnitTime struct {
+	Unit           string `json:"Unit"`
+	ActivatingUSec uint64 `json:"ActivatingUSec"`
+	ActivatedUSec  uint64 `json:"ActivatedUSec"`
+	TimeUSec       uint64 `json:"TimeUSec"`
+
+	after []string
+}
+
+// ChainUnit is one step of the critical chain. ActivatedUSec is relative to the start of userspace
+type ChainUnit struct {
+	Unit          string `json:"Unit"`
+	Level         int    `json:"Level"`
+	ActivatedUSec uint64 `json:"ActivatedUSec"`
+	TimeUSec      uint64 `json:"TimeUSec"`
+}
+
+func acquireManagerTimestamps(ctx context.Context) (*BootTimes, error) {
+	conn, err := bus.SystemBusPrivateConn()
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	props := make(map[string]dbus.Variant)
+	if err := conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, managerInterface).Store(&props); err != nil {
+		log.Errorf("Failed to fetch systemd manager properties: %v", err)
+		return nil, err
+	}
+
+	u := func(name string) uint64 {
+		v, _ := props[name].Value().(uint64)
+		return v
+	}
+
+	return &BootTimes{
+		FirmwareTimestampMonotonic:  u("FirmwareTimestampMonotonic"),
+		LoaderTimestampMonotonic:    u("LoaderTimestampMonotonic"),
+		InitRDTimestampMonotonic:    u("InitRDTimestampMonotonic"),
+		UserspaceTimestampMonotonic: u("UserspaceTimestampMonotonic"),
+		FinishTimestampMonotonic:    u("FinishTimestampMonotonic"),
+	}, nil
+}
+
+// AcquireBootTimes computes the boot phases the same way systemd-analyze time does. Firmware
+// and loader timestamps count backwards from the start of the kernel
+func AcquireBootTimes(ctx context.Context) (*BootTimes, error) {
+	b, err := acquireManagerTimestamps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.FinishTimestampMonotonic == 0 {
+		return nil, errors.New("boot has not finished yet")
+	}
+
+	kernelDone := b.UserspaceTimestampMonotonic
+	if b.InitRDTimestampMonotonic > 0 {
+		kernelDone = b.InitRDTimestampMonotonic
+		b.InitRDUSec = b.UserspaceTimestampMonotonic - b.InitRDTimestampMonotonic
+	}
+
+	if b.FirmwareTimestampMonotonic > b.LoaderTimestampMonotonic {
+		b.FirmwareUSec = b.FirmwareTimestampMonotonic - b.LoaderTimestampMonotonic
+	}
+	b.LoaderUSec = b.LoaderTimestampMonotonic
+	b.KernelUSec = kernelDone
+	b.UserspaceUSec = b.FinishTimestampMonotonic - b.UserspaceTimestampMonotonic
+	b.TotalUSec = b.FirmwareTimestampMonotonic + b.FinishTimestampMonotonic
+
+	return b, nil
+}
+
+// acquireUnitTimes returns the activation times and After= dependencies of all loaded units
+func acquireUnitTimes(ctx context.Context) (map[string]*UnitTime, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsContext(ctx)
+	if err != nil {
+		log.Errorf("Failed list systemd units: %v", err)
+		return nil, err
+	}
+
+	times := make(map[string]*UnitTime)
+	for _, u := range units {
+		p, err := conn.GetUnitPropertiesContext(ctx, u.Name)
+		if err != nil {
+			continue
+		}
+
+		t := UnitTime{
+			Unit: u.Name,
+		}
+		t.ActivatingUSec, _ = p["InactiveExitTimestampMonotonic"].(uint64)
+		t.ActivatedUSec, _ = p["ActiveEnterTimestampMonotonic"].(uint64)
+		t.after, _ = p["After"].([]string)
+		if t.ActivatedUSec >= t.ActivatingUSec {
+			t.TimeUSec = t.ActivatedUSec - t.ActivatingUSec
+		}
+
+		times[u.Name] = &t
+	}
+
+	return times, nil
+}
+
+// AcquireBlame returns the units which took time to activate, slowest first
+func AcquireBlame(ctx context.Context) ([]UnitTime, error) {
+	times, err := acquireUnitTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blame := []UnitTime{}
+	for _, t := range times {
+		if t.ActivatingUSec > 0 && t.TimeUSec > 0 {
+			blame = append(blame, *t)
+		}
+	}
+
+	sort.Slice(blame, func(i, j int) bool {
+		if blame[i].TimeUSec == blame[j].TimeUSec {
+			return blame[i].Unit < blame[j].Unit
+		}
+		return blame[i].TimeUSec > blame[j].TimeUSec
+	})
+
+	return blame, nil
+}
+
+// resolveUnitId returns the name a unit is loaded as, default.target is usually an alias
+func resolveUnitId(ctx context.Context, unit string) (string, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return "", err
+	}
+	defer conn.Close()
+
+	p, err := conn.GetUnitPropertyContext(ctx, unit, "Id")
+	if err != nil {
+		return "", err
+	}
+
+	id, _ := p.Value.Value().(string)
+	if id == "" {
+		return unit, nil
+	}
+
+	return id, nil
+}
+
+// AcquireCriticalChain follows from unit the After= dependency which activated last, like
+// systemd-analyze critical-chain does
+func AcquireCriticalChain(ctx context.Context, unit string) ([]ChainUnit, error) {
+	b, err := AcquireBootTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := resolveUnitId(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	times, err := acquireUnitTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(t *UnitTime) bool {
+		return t != nil && t.ActivatedUSec > 0 && t.ActivatedUSec <= b.FinishTimestampMonotonic
+	}
+
+	relative := func(v uint64) uint64 {
+		if v < b.UserspaceTimestampMonotonic {
+			return 0
+		}
+		return v - b.UserspaceTimestampMonotonic
+	}
+
+	t, ok := times[id]
+	if !ok {
+		return nil, errors.New("unit not found")
+	}
+
+	chain := []ChainUnit{
+		{
+			Unit:          id,
+			ActivatedUSec: relative(t.ActivatedUSec),
+			TimeUSec:      t.TimeUSec,
+		},
+	}
+
+	seen := map[string]bool{id: true}
+	for level := 1; ; level++ {
+		var next *UnitTime
+		for _, d := range t.after {
+			dt := times[d]
+			if !inRange(dt) || seen[d] {
+				continue
+			}
+
+			if next == nil || dt.ActivatedUSec > next.ActivatedUSec {
+				next = dt
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		seen[next.Unit] = true
+		chain = append(chain, ChainUnit{
+			Unit:          next.Unit,
+			Level:         level,
+			ActivatedUSec: relative(next.ActivatedUSec),
+			TimeUSec:      next.TimeUSec,
+		})
+		t = next
+	}
+
+	return chain, nil
+}
@@ -4,153 +4,568 @@
 package systemd
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
-	"github.com/go-ini/ini"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/pkg/bus"
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+	"github.com/vmware/pmd-next-gen/pkg/parser"
+	"github.com/vmware/pmd-next-gen/pkg/system"
 )
 
 const (
-	systemConfPath = "/etc/systemd/system.conf"
+	// Settings are written to this drop-in so that the packaged main file is never rewritten
+	confDropInName = "50-photon-mgmt.conf"
+
+	typeBool     = "bool"
+	typeUint     = "uint"
+	typeInt      = "int"
+	typeTimespan = "timespan"
+	typeSize     = "size"
+	typeEnum     = "enum"
+	typeList     = "list"
+	typeString   = "string"
 )
 
-var systemConfig = map[string]string{}
+var (
+	confDirPath = "/etc/systemd"
 
-func writeSystemConfig() error {
-	f, err := os.OpenFile(systemConfPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
+	timespanRegexp = regexp.MustCompile(`^(infinity|([0-9]+(\.[0-9]+)?\s*(usec|us|µs|msec|ms|seconds|second|sec|s|minutes|minute|min|m|hours|hour|hr|h|days|day|d|weeks|week|w|months|month|M|years|year|y)?\s*)+)$`)
+	sizeRegexp     = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?[KMGTPE]?|infinity)$`)
+
+	logLevels   = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	handleKeys  = []string{"ignore", "poweroff", "reboot", "halt", "kexec", "suspend", "hibernate", "hybrid-sleep", "suspend-then-hibernate", "lock", "factory-reset"}
+	yesNo       = []string{"yes", "no", "true", "false"}
+	resolveMode = append([]string{"resolve"}, yesNo...)
+)
+
+// ConfKey describes the value a setting accepts
+type ConfKey struct {
+	Type   string   `json:"Type"`
+	Values []string `json:"Values,omitempty"`
+}
+
+// ConfSchema describes the settings of a daemon configuration file by section
+type ConfSchema struct {
+	Daemon   string                        `json:"Daemon"`
+	Unit     string                        `json:"Unit,omitempty"`
+	Sections map[string]map[string]ConfKey `json:"Sections"`
+}
+
+// DaemonConf holds the effective settings of a daemon, merged from the main file and its drop-ins
+type DaemonConf struct {
+	Daemon   string                       `json:"Daemon"`
+	Path     string                       `json:"Path"`
+	DropIn   string                       `json:"DropIn"`
+	Files    []string                     `json:"Files"`
+	Settings map[string]map[string]string `json:"Settings"`
+}
+
+func newConfKey(t string, values ...string) ConfKey {
+	return ConfKey{Type: t, Values: values}
+}
+
+var confSchemas = map[string]*ConfSchema{
+	"system": {
+		Daemon: "system",
+		Sections: map[string]map[string]ConfKey{
+			"Manager": {
+				"LogLevel":                     newConfKey(typeEnum, logLevels...),
+				"LogTarget":                    newConfKey(typeEnum, "console", "journal", "kmsg", "journal-or-kmsg", "null", "auto"),
+				"LogColor":                     newConfKey(typeBool),
+				"LogLocation":                  newConfKey(typeBool),
+				"LogTime":                      newConfKey(typeBool),
+				"DumpCore":                     newConfKey(typeBool),
+				"ShowStatus":                   newConfKey(typeEnum, "yes", "no", "true", "false", "auto", "error"),
+				"StatusUnitFormat":             newConfKey(typeEnum, "description", "name", "combined"),
+				"CrashChangeVT":                newConfKey(typeString),
+				"CrashShell":                   newConfKey(typeBool),
+				"CrashReboot":                  newConfKey(typeBool),
+				"CtrlAltDelBurstAction":        newConfKey(typeEnum, "reboot-force", "poweroff-force", "reboot-immediate", "poweroff-immediate", "none"),
+				"CPUAffinity":                  newConfKey(typeList),
+				"NUMAPolicy":                   newConfKey(typeEnum, "default", "preferred", "bind", "interleave", "local"),
+				"NUMAMask":                     newConfKey(typeString),
+				"RuntimeWatchdogSec":           newConfKey(typeTimespan),
+				"RebootWatchdogSec":            newConfKey(typeTimespan),
+				"KExecWatchdogSec":             newConfKey(typeTimespan),
+				"WatchdogDevice":               newConfKey(typeString),
+				"CapabilityBoundingSet":        newConfKey(typeList),
+				"NoNewPrivileges":              newConfKey(typeBool),
+				"SystemCallArchitectures":      newConfKey(typeList),
+				"TimerSlackNSec":               newConfKey(typeString),
+				"DefaultTimerAccuracySec":      newConfKey(typeTimespan),
+				"DefaultStandardOutput":        newConfKey(typeString),
+				"DefaultStandardError":         newConfKey(typeString),
+				"DefaultTimeoutStartSec":       newConfKey(typeTimespan),
+				"DefaultTimeoutStopSec":        newConfKey(typeTimespan),
+				"DefaultTimeoutAbortSec":       newConfKey(typeTimespan),
+				"DefaultDeviceTimeoutSec":      newConfKey(typeTimespan),
+				"DefaultRestartSec":            newConfKey(typeTimespan),
+				"DefaultStartLimitIntervalSec": newConfKey(typeTimespan),
+				"DefaultStartLimitBurst":       newConfKey(typeUint),
+				"DefaultEnvironment":           newConfKey(typeString),
+				"DefaultCPUAccounting":         newConfKey(typeBool),
+				"DefaultIOAccounting":          newConfKey(typeBool),
+				"DefaultIPAccounting":          newConfKey(typeBool),
+				"DefaultBlockIOAccounting":     newConfKey(typeBool),
+				"DefaultMemoryAccounting":      newConfKey(typeBool),
+				"DefaultTasksAccounting":       newConfKey(typeBool),
+				"DefaultTasksMax":              newConfKey(typeString),
+				"DefaultLimitCPU":              newConfKey(typeString),
+				"DefaultLimitFSIZE":            newConfKey(typeString),
+				"DefaultLimitDATA":             newConfKey(typeString),
+				"DefaultLimitSTACK":            newConfKey(typeString),
+				"DefaultLimitCORE":             newConfKey(typeString),
+				"DefaultLimitRSS":              newConfKey(typeString),
+				"DefaultLimitNOFILE":           newConfKey(typeString),
+				"DefaultLimitAS":               newConfKey(typeString),
+				"DefaultLimitNPROC":            newConfKey(typeString),
+				"DefaultLimitMEMLOCK":          newConfKey(typeString),
+				"DefaultLimitLOCKS":            newConfKey(typeString),
+				"DefaultLimitSIGPENDING":       newConfKey(typeString),
+				"DefaultLimitMSGQUEUE":         newConfKey(typeString),
+				"DefaultLimitNICE":             newConfKey(typeString),
+				"DefaultLimitRTPRIO":           newConfKey(typeString),
+				"DefaultLimitRTTIME":           newConfKey(typeString),
+				"DefaultOOMPolicy":             newConfKey(typeEnum, "continue", "stop", "kill"),
+				"DefaultOOMScoreAdjust":        newConfKey(typeInt),
+			},
+		},
+	},
+	"journald": {
+		Daemon: "journald",
+		Unit:   "systemd-journald.service",
+		Sections: map[string]map[string]ConfKey{
+			"Journal": {
+				"Storage":              newConfKey(typeEnum, "volatile", "persistent", "auto", "none"),
+				"Compress":             newConfKey(typeString),
+				"Seal":                 newConfKey(typeBool),
+				"SplitMode":            newConfKey(typeEnum, "uid", "none"),
+				"SyncIntervalSec":      newConfKey(typeTimespan),
+				"RateLimitIntervalSec": newConfKey(typeTimespan),
+				"RateLimitBurst":       newConfKey(typeUint),
+				"SystemMaxUse":         newConfKey(typeSize),
+				"SystemKeepFree":       newConfKey(typeSize),
+				"SystemMaxFileSize":    newConfKey(typeSize),
+				"SystemMaxFiles":       newConfKey(typeUint),
+				"RuntimeMaxUse":        newConfKey(typeSize),
+				"RuntimeKeepFree":      newConfKey(typeSize),
+				"RuntimeMaxFileSize":   newConfKey(typeSize),
+				"RuntimeMaxFiles":      newConfKey(typeUint),
+				"MaxRetentionSec":      newConfKey(typeTimespan),
+				"MaxFileSec":           newConfKey(typeTimespan),
+				"ForwardToSyslog":      newConfKey(typeBool),
+				"ForwardToKMsg":        newConfKey(typeBool),
+				"ForwardToConsole":     newConfKey(typeBool),
+				"ForwardToWall":        newConfKey(typeBool),
+				"TTYPath":              newConfKey(typeString),
+				"MaxLevelStore":        newConfKey(typeEnum, logLevels...),
+				"MaxLevelSyslog":       newConfKey(typeEnum, logLevels...),
+				"MaxLevelKMsg":         newConfKey(typeEnum, logLevels...),
+				"MaxLevelConsole":      newConfKey(typeEnum, logLevels...),
+				"MaxLevelWall":         newConfKey(typeEnum, logLevels...),
+				"LineMax":              newConfKey(typeSize),
+				"ReadKMsg":             newConfKey(typeBool),
+				"Audit":                newConfKey(typeBool),
+			},
+		},
+	},
+	"logind": {
+		Daemon: "logind",
+		Unit:   "systemd-logind.service",
+		Sections: map[string]map[string]ConfKey{
+			"Login": {
+				"NAutoVTs":                     newConfKey(typeUint),
+				"ReserveVT":                    newConfKey(typeUint),
+				"KillUserProcesses":            newConfKey(typeBool),
+				"KillOnlyUsers":                newConfKey(typeList),
+				"KillExcludeUsers":             newConfKey(typeList),
+				"IdleAction":                   newConfKey(typeEnum, handleKeys...),
+				"IdleActionSec":                newConfKey(typeTimespan),
+				"InhibitDelayMaxSec":           newConfKey(typeTimespan),
+				"UserStopDelaySec":             newConfKey(typeTimespan),
+				"HandlePowerKey":               newConfKey(typeEnum, handleKeys...),
+				"HandleSuspendKey":             newConfKey(typeEnum, handleKeys...),
+				"HandleHibernateKey":           newConfKey(typeEnum, handleKeys...),
+				"HandleRebootKey":              newConfKey(typeEnum, handleKeys...),
+				"HandleLidSwitch":              newConfKey(typeEnum, handleKeys...),
+				"HandleLidSwitchExternalPower": newConfKey(typeEnum, handleKeys...),
+				"HandleLidSwitchDocked":        newConfKey(typeEnum, handleKeys...),
+				"PowerKeyIgnoreInhibited":      newConfKey(typeBool),
+				"SuspendKeyIgnoreInhibited":    newConfKey(typeBool),
+				"HibernateKeyIgnoreInhibited":  newConfKey(typeBool),
+				"LidSwitchIgnoreInhibited":     newConfKey(typeBool),
+				"RebootKeyIgnoreInhibited":     newConfKey(typeBool),
+				"HoldoffTimeoutSec":            newConfKey(typeTimespan),
+				"RuntimeDirectorySize":         newConfKey(typeString),
+				"RuntimeDirectoryInodesMax":    newConfKey(typeString),
+				"InhibitorsMax":                newConfKey(typeUint),
+				"SessionsMax":                  newConfKey(typeUint),
+				"RemoveIPC":                    newConfKey(typeBool),
+				"StopIdleSessionSec":           newConfKey(typeTimespan),
+			},
+		},
+	},
+	"resolved": {
+		Daemon: "resolved",
+		Unit:   "systemd-resolved.service",
+		Sections: map[string]map[string]ConfKey{
+			"Resolve": {
+				"DNS":                       newConfKey(typeList),
+				"FallbackDNS":               newConfKey(typeList),
+				"Domains":                   newConfKey(typeList),
+				"DNSSEC":                    newConfKey(typeEnum, append([]string{"allow-downgrade"}, yesNo...)...),
+				"DNSOverTLS":                newConfKey(typeEnum, append([]string{"opportunistic"}, yesNo...)...),
+				"MulticastDNS":              newConfKey(typeEnum, resolveMode...),
+				"LLMNR":                     newConfKey(typeEnum, resolveMode...),
+				"Cache":                     newConfKey(typeEnum, append([]string{"no-negative"}, yesNo...)...),
+				"CacheFromLocalhost":        newConfKey(typeBool),
+				"DNSStubListener":           newConfKey(typeEnum, append([]string{"udp", "tcp"}, yesNo...)...),
+				"DNSStubListenerExtra":      newConfKey(typeList),
+				"ReadEtcHosts":              newConfKey(typeBool),
+				"ResolveUnicastSingleLabel": newConfKey(typeBool),
+				"StaleRetentionSec":         newConfKey(typeTimespan),
+			},
+		},
+	},
+	"timesyncd": {
+		Daemon: "timesyncd",
+		Unit:   "systemd-timesyncd.service",
+		Sections: map[string]map[string]ConfKey{
+			"Time": {
+				"NTP":                newConfKey(typeList),
+				"FallbackNTP":        newConfKey(typeList),
+				"RootDistanceMaxSec": newConfKey(typeTimespan),
+				"PollIntervalMinSec": newConfKey(typeTimespan),
+				"PollIntervalMaxSec": newConfKey(typeTimespan),
+				"ConnectionRetrySec": newConfKey(typeTimespan),
+				"SaveIntervalSec":    newConfKey(typeTimespan),
+			},
+		},
+	},
+	"networkd": {
+		Daemon: "networkd",
+		Unit:   "systemd-networkd.service",
+		Sections: map[string]map[string]ConfKey{
+			"Network": {
+				"SpeedMeter":                      newConfKey(typeBool),
+				"SpeedMeterIntervalSec":           newConfKey(typeTimespan),
+				"ManageForeignRoutingPolicyRules": newConfKey(typeBool),
+				"ManageForeignRoutes":             newConfKey(typeBool),
+				"RouteTable":                      newConfKey(typeList),
+			},
+			"DHCPv4": {
+				"DUIDType":    newConfKey(typeString),
+				"DUIDRawData": newConfKey(typeString),
+			},
+			"DHCPv6": {
+				"DUIDType":    newConfKey(typeString),
+				"DUIDRawData": newConfKey(typeString),
+			},
+		},
+	},
+}
+
+func acquireConfSchema(daemon string) (*ConfSchema, error) {
+	s, ok := confSchemas[daemon]
+	if !ok {
+		return nil, fmt.Errorf("unknown daemon '%s'", daemon)
+	}
+
+	return s, nil
+}
+
+func confPath(daemon string) string {
+	return path.Join(confDirPath, daemon+".conf")
+}
+
+// confDropInDirs lists the drop-in directories in the order of their precedence, lowest first
+func confDropInDirs(daemon string) []string {
+	return []string{
+		path.Join("/usr/lib/systemd", daemon+".conf.d"),
+		path.Join("/run/systemd", daemon+".conf.d"),
+		path.Join(confDirPath, daemon+".conf.d"),
 	}
-	defer f.Close()
+}
 
-	w := bufio.NewWriter(f)
+// validate checks the value against the type of the key. An empty value resets the setting to
+// its default
+func (c ConfKey) validate(key string, value string) error {
+	if value == "" {
+		return nil
+	}
 
-	conf := "[Manager]\n"
-	for k, v := range systemConfig {
-		if v != "" {
-			conf += k + "=" + v
-		} else {
-			conf += "#" + k + "="
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return fmt.Errorf("invalid value for '%s'", key)
+	}
+
+	ok := true
+	switch c.Type {
+	case typeBool:
+		_, err := parser.ParseBool(value)
+		ok = err == nil
+	case typeUint:
+		_, err := strconv.ParseUint(value, 10, 64)
+		ok = err == nil
+	case typeInt:
+		_, err := strconv.ParseInt(value, 10, 64)
+		ok = err == nil
+	case typeTimespan:
+		ok = timespanRegexp.MatchString(value)
+	case typeSize:
+		ok = sizeRegexp.MatchString(value)
+	case typeEnum:
+		ok = false
+		for _, v := range c.Values {
+			if v == value {
+				ok = true
+				break
+			}
 		}
-		conf += "\n"
 	}
 
-	fmt.Fprintln(w, conf)
-	w.Flush()
+	if !ok {
+		if c.Type == typeEnum {
+			return fmt.Errorf("invalid value '%s' for '%s', must be one of %s", value, key, strings.Join(c.Values, ", "))
+		}
+		return fmt.Errorf("invalid value '%s' for '%s', expected %s", value, key, c.Type)
+	}
 
 	return nil
 }
 
-func readSystemConf() error {
-	cfg, err := ini.Load(systemConfPath)
+// writeConfDropIn writes the drop-in verbatim, values such as DNS=1.1.1.1#cloudflare-dns.com
+// must not be quoted or cut at the '#'
+func writeConfDropIn(m *configfile.Meta) error {
+	b, err := m.RawBytes()
 	if err != nil {
 		return err
 	}
 
-	for k := range systemConfig {
-		systemConfig[k] = cfg.Section("Manager").Key(k).String()
+	return system.WriteFileAtomic(m.Path, b, 0644)
+}
+
+// AcquireConfSchema returns the settings a daemon accepts
+func AcquireConfSchema(daemon string) (*ConfSchema, error) {
+	return acquireConfSchema(daemon)
+}
+
+// AcquireDaemonConf returns the settings of the main file overridden by the drop-ins.
+// Drop-ins are applied in the order of their file names, as systemd does
+func AcquireDaemonConf(daemon string) (*DaemonConf, error) {
+	schema, err := acquireConfSchema(daemon)
+	if err != nil {
+		return nil, err
+	}
+
+	c := DaemonConf{
+		Daemon:   daemon,
+		Path:     confPath(daemon),
+		DropIn:   path.Join(confDirPath, daemon+".conf.d", confDropInName),
+		Files:    []string{},
+		Settings: make(map[string]map[string]string),
+	}
+
+	dropIns := make(map[string]string)
+	for _, d := range confDropInDirs(daemon) {
+		files, _ := filepath.Glob(path.Join(d, "*.conf"))
+		for _, f := range files {
+			dropIns[path.Base(f)] = f
+		}
+	}
+
+	names := make([]string, 0, len(dropIns))
+	for n := range dropIns {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	files := []string{c.Path}
+	for _, n := range names {
+		files = append(files, dropIns[n])
+	}
+
+	for _, f := range files {
+		m, err := configfile.LoadRaw(f)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warnf("Failed to parse '%s': %v", f, err)
+			}
+			continue
+		}
+		c.Files = append(c.Files, f)
+
+		for section, keys := range schema.Sections {
+			sections, err := m.Cfg.SectionsByName(section)
+			if err != nil {
+				continue
+			}
+
+			for _, s := range sections {
+				for _, key := range s.Keys() {
+					if _, ok := keys[key.Name()]; !ok {
+						continue
+					}
+
+					if c.Settings[section] == nil {
+						c.Settings[section] = make(map[string]string)
+					}
+
+					// An empty assignment resets the setting
+					v := ""
+					if l := key.ValueWithShadows(); len(l) > 0 {
+						v = l[len(l)-1]
+					}
+					c.Settings[section][key.Name()] = v
+				}
+			}
+		}
+	}
+
+	return &c, nil
+}
+
+// Validate checks the sections, keys and values of settings against the schema of the daemon
+func (s *ConfSchema) Validate(settings map[string]map[string]string) error {
+	if len(settings) == 0 {
+		return fmt.Errorf("no settings for '%s'", s.Daemon)
+	}
+
+	for section, keys := range settings {
+		sk, ok := s.Sections[section]
+		if !ok {
+			return fmt.Errorf("unknown section [%s] in '%s'", section, s.Daemon)
+		}
+
+		for key, value := range keys {
+			c, ok := sk[key]
+			if !ok {
+				return fmt.Errorf("unknown key '%s' in section [%s] of '%s'", key, section, s.Daemon)
+			}
+
+			if err := c.validate(key, value); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func GetSystemConf(rw http.ResponseWriter) error {
-	if err := readSystemConf(); err != nil {
-		return err
+// ConfigureDaemonConf writes the validated settings to the drop-in owned by photon-mgmtd,
+// keeping the other settings and comments of the drop-in. An empty value is written as 'Key=',
+// which resets the setting to its default even when an earlier file sets it
+func ConfigureDaemonConf(ctx context.Context, daemon string, settings map[string]map[string]string, apply bool) (*DaemonConf, error) {
+	schema, err := acquireConfSchema(daemon)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schema.Validate(settings); err != nil {
+		return nil, err
+	}
+
+	d := path.Join(confDirPath, daemon+".conf.d")
+	p := path.Join(d, confDropInName)
+
+	m, err := configfile.LoadRaw(p)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		m = configfile.NewRaw(p)
 	}
 
-	return web.JSONResponse(systemConfig, rw)
+	for section, keys := range settings {
+		for key, value := range keys {
+			if err := m.SetKeySectionString(section, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := system.CreateDirectoryNested(d, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := writeConfDropIn(m); err != nil {
+		log.Errorf("Failed to write drop-in='%s': %v", p, err)
+		return nil, err
+	}
+
+	log.Infof("Wrote drop-in='%s'", p)
+
+	if apply {
+		if err := applyDaemonConf(ctx, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return AcquireDaemonConf(daemon)
 }
 
-func UpdateSystemConf(rw http.ResponseWriter, r *http.Request) error {
-	body, err := ioutil.ReadAll(r.Body)
+// AcquireSystemConf returns the [Manager] settings of system.conf as a flat object. Keys which
+// are not set are empty
+func AcquireSystemConf() (map[string]string, error) {
+	c, err := AcquireDaemonConf("system")
 	if err != nil {
-		log.Errorf("Failed to parse HTTP request: %v", err)
-		return err
+		return nil, err
 	}
 
-	conf := make(map[string]string)
-	if err = json.Unmarshal([]byte(body), &conf); err != nil {
-		log.Errorf("Failed to Decode HTTP request to json: %v", err)
-		return err
+	return systemConfManager(c), nil
+}
+
+// ConfigureSystemConf writes the flat [Manager] settings of system.conf
+func ConfigureSystemConf(ctx context.Context, manager map[string]string) (map[string]string, error) {
+	c, err := ConfigureDaemonConf(ctx, "system", map[string]map[string]string{"Manager": manager}, false)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := readSystemConf(); err != nil {
-		return err
+	return systemConfManager(c), nil
+}
+
+func systemConfManager(c *DaemonConf) map[string]string {
+	manager := make(map[string]string)
+	for k := range confSchemas["system"].Sections["Manager"] {
+		manager[k] = c.Settings["Manager"][k]
 	}
 
-	for k, v := range conf {
-		_, ok := systemConfig[k]
-		if ok {
-			systemConfig[k] = v
+	return manager
+}
+
+// applyDaemonConf makes the daemon pick up the new settings. The manager re-executes itself,
+// the other daemons are restarted
+func applyDaemonConf(ctx context.Context, schema *ConfSchema) error {
+	if schema.Unit == "" {
+		conn, err := bus.SystemBusPrivateConn()
+		if err != nil {
+			log.Errorf("Failed to establish connection with system bus: %v", err)
+			return err
+		}
+		defer conn.Close()
+
+		if err := conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, managerInterface+".Reexecute", 0).Err; err != nil {
+			log.Errorf("Failed to re-execute systemd manager: %v", err)
+			return err
 		}
+
+		return nil
 	}
 
-	if err = writeSystemConfig(); err != nil {
-		log.Errorf("Failed Write to system conf: %v", err)
-		return err
+	u := UnitRequest{
+		Verb: "try-restart",
+		Unit: schema.Unit,
 	}
 
-	return web.JSONResponse(systemConfig, rw)
-}
-
-func InitSystemd() {
-	systemConfig["LogLevel"] = ""
-	systemConfig["LogTarget"] = ""
-	systemConfig["LogColor"] = ""
-	systemConfig["LogLocation"] = ""
-	systemConfig["DumpCore"] = ""
-	systemConfig["ShowStatus"] = ""
-	systemConfig["CrashChangeVT"] = ""
-	systemConfig["CrashShell"] = ""
-	systemConfig["CrashReboot"] = ""
-	systemConfig["CtrlAltDelBurstAction"] = ""
-	systemConfig["CPUAffinity"] = ""
-	systemConfig["JoinControllers"] = ""
-	systemConfig["RuntimeWatchdogSec"] = ""
-	systemConfig["ShutdownWatchdogSec"] = ""
-	systemConfig["CapabilityBoundingSe"] = ""
-	systemConfig["SystemCallArchitectures"] = ""
-	systemConfig["TimerSlackNSec"] = ""
-	systemConfig["DefaultTimerAccuracySec"] = ""
-	systemConfig["DefaultStandardOutput"] = ""
-	systemConfig["DefaultStandardError"] = ""
-	systemConfig["DefaultTimeoutStartSec"] = ""
-	systemConfig["DefaultTimeoutStopSec"] = ""
-	systemConfig["DefaultRestartSec"] = ""
-	systemConfig["DefaultStartLimitIntervalSec"] = ""
-	systemConfig["DefaultStartLimitBurst"] = ""
-	systemConfig["DefaultEnvironment"] = ""
-	systemConfig["DefaultCPUAccounting"] = ""
-	systemConfig["DefaultIOAccounting"] = ""
-	systemConfig["DefaultIPAccounting"] = ""
-	systemConfig["DefaultBlockIOAccounting"] = ""
-	systemConfig["DefaultMemoryAccounting"] = ""
-	systemConfig["DefaultTasksAccounting"] = ""
-	systemConfig["DefaultTasksMax"] = ""
-	systemConfig["DefaultLimitCPU"] = ""
-	systemConfig["DefaultLimitFSIZE"] = ""
-	systemConfig["DefaultLimitDATA"] = ""
-	systemConfig["DefaultLimitSTACK"] = ""
-	systemConfig["DefaultLimitCORE"] = ""
-	systemConfig["DefaultLimitRSS"] = ""
-	systemConfig["DefaultLimitNOFILE"] = ""
-	systemConfig["DefaultLimitAS"] = ""
-	systemConfig["DefaultLimitNPROC"] = ""
-	systemConfig["DefaultLimitMEMLOCK"] = ""
-	systemConfig["DefaultLimitLOCKS"] = ""
-	systemConfig["DefaultLimitSIGPENDING"] = ""
-	systemConfig["DefaultLimitMSGQUEUE"] = ""
-	systemConfig["DefaultLimitNICE"] = ""
-	systemConfig["DefaultLimitRTPRIO"] = ""
-	systemConfig["DefaultLimitRTTIME"] = ""
-	systemConfig["IPAddressAllow"] = ""
-	systemConfig["IPAddressDeny"] = ""
+	return u.UnitCommands(ctx)
 }
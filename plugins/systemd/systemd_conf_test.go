@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+)
+
+func TestConfDropInRoundTrip(t *testing.T) {
+	p := path.Join(t.TempDir(), "50-photon-mgmt.conf")
+	in := `# Managed by photon-mgmtd
+[Resolve]
+# Cloudflare over TLS
+DNS=1.1.1.1#cloudflare-dns.com 1.0.0.1#cloudflare-dns.com
+Domains=~.
+DNSOverTLS=yes
+`
+	if err := os.WriteFile(p, []byte(in), 0644); err != nil {
+		t.Fatalf("Failed to write drop-in: %v", err)
+	}
+
+	m, err := configfile.LoadRaw(p)
+	if err != nil {
+		t.Fatalf("Failed to load drop-in: %v", err)
+	}
+
+	if got := m.GetKeySectionString("Resolve", "DNS"); got != "1.1.1.1#cloudflare-dns.com 1.0.0.1#cloudflare-dns.com" {
+		t.Errorf("DNS = '%s'", got)
+	}
+
+	if err := m.SetKeySectionString("Resolve", "FallbackDNS", "9.9.9.9#dns.quad9.net"); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if s, err := m.Cfg.GetSection("Resolve"); err == nil {
+		s.DeleteKey("DNSOverTLS")
+	}
+
+	if err := writeConfDropIn(m); err != nil {
+		t.Fatalf("Failed to write drop-in: %v", err)
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Failed to read drop-in: %v", err)
+	}
+
+	want := `# Managed by photon-mgmtd
+[Resolve]
+# Cloudflare over TLS
+DNS=1.1.1.1#cloudflare-dns.com 1.0.0.1#cloudflare-dns.com
+Domains=~.
+FallbackDNS=9.9.9.9#dns.quad9.net
+`
+	if string(b) != want {
+		t.Errorf("Unexpected drop-in:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestConfSchemaValidate(t *testing.T) {
+	s := confSchemas["system"]
+
+	accepted := []map[string]map[string]string{
+		{"Manager": {"DefaultTimeoutStopSec": "30s"}},
+		{"Manager": {"LogLevel": "debug", "DumpCore": "no"}},
+		{"Manager": {"DefaultTimeoutStopSec": ""}},
+	}
+	for _, v := range accepted {
+		if err := s.Validate(v); err != nil {
+			t.Errorf("Settings %v of system.conf not accepted: %v", v, err)
+		}
+	}
+
+	// Unknown sections and keys, values of the wrong kind and anything which could start a new
+	// line in the drop-in are refused
+	refused := []map[string]map[string]string{
+		{},
+		{"Journal": {"Storage": "auto"}},
+		{"Manager": {"NoSuchKey": "1"}},
+		{"Manager": {"LogLevel": "chatty"}},
+		{"Manager": {"DumpCore": "perhaps"}},
+		{"Manager": {"DefaultTimeoutStopSec": "soon"}},
+		{"Manager": {"DefaultStartLimitBurst": "-1"}},
+		{"Manager": {"LogLevel": "debug\n[Manager]"}},
+	}
+	for _, v := range refused {
+		if s.Validate(v) == nil {
+			t.Errorf("Settings %v of system.conf accepted", v)
+		}
+	}
+}
+
+func TestConfigureDaemonConfEmptyValue(t *testing.T) {
+	confDirPath = t.TempDir()
+	t.Cleanup(func() { confDirPath = "/etc/systemd" })
+
+	if err := os.WriteFile(confPath("journald"), []byte("[Journal]\nStorage=volatile\n"), 0644); err != nil {
+		t.Fatalf("Failed to write journald.conf: %v", err)
+	}
+
+	c, err := ConfigureDaemonConf(context.Background(), "journald", map[string]map[string]string{
+		"Journal": {"Storage": "", "Compress": "no"},
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to configure journald.conf: %v", err)
+	}
+
+	b, err := os.ReadFile(c.DropIn)
+	if err != nil {
+		t.Fatalf("Failed to read drop-in: %v", err)
+	}
+
+	// The drop-in must reset Storage= set by the main file rather than leave it alone
+	if !strings.Contains(string(b), "Storage=\n") || !strings.Contains(string(b), "Compress=no\n") {
+		t.Errorf("Unexpected drop-in:\n%s", b)
+	}
+
+	if got := c.Settings["Journal"]["Storage"]; got != "" {
+		t.Errorf("Storage = '%s' after the reset, want it empty", got)
+	}
+}
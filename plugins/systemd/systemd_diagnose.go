@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/plugins/journal"
+)
+
+const (
+	DefaultDiagnoseLines = 20
+)
+
+// Diagnosis explains why a unit is down
+type Diagnosis struct {
+	Unit                   string          `json:"Unit"`
+	Description            string          `json:"Description"`
+	LoadState              string          `json:"LoadState"`
+	ActiveState            string          `json:"ActiveState"`
+	SubState               string          `json:"SubState"`
+	Result                 string          `json:"Result"`
+	ExecMainCode           string          `json:"ExecMainCode"`
+	ExecMainStatus         int32           `json:"ExecMainStatus"`
+	NRestarts              uint32          `json:"NRestarts"`
+	InactiveEnterTimestamp uint64          `json:"InactiveEnterTimestamp"`
+	FailedDependencies     []string        `json:"FailedDependencies"`
+	Journal                []journal.Entry `json:"Journal"`
+	Error                  string          `json:"Error,omitempty"`
+}
+
+// unitTypeInterface returns the D-Bus interface suffix of the units which report a Result
+func unitTypeInterface(unit string) string {
+	switch path.Ext(unit) {
+	case ".service":
+		return "Service"
+	case ".socket":
+		return "Socket"
+	case ".timer":
+		return "Timer"
+	case ".path":
+		return "Path"
+	case ".mount":
+		return "Mount"
+	case ".automount":
+		return "Automount"
+	case ".swap":
+		return "Swap"
+	case ".scope":
+		return "Scope"
+	}
+
+	return ""
+}
+
+func diagnoseUnit(ctx context.Context, conn *sd.Conn, unit string, lines int) (*Diagnosis, error) {
+	p, err := conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		log.Errorf("Failed to fetch systemd unit='%s' properties: %v", unit, err)
+		return nil, err
+	}
+
+	d := Diagnosis{
+		Unit:               unit,
+		FailedDependencies: []string{},
+		Journal:            []journal.Entry{},
+	}
+	d.Description, _ = p["Description"].(string)
+	d.LoadState, _ = p["LoadState"].(string)
+	d.ActiveState, _ = p["ActiveState"].(string)
+	d.SubState, _ = p["SubState"].(string)
+	d.InactiveEnterTimestamp, _ = p["InactiveEnterTimestamp"].(uint64)
+
+	if t := unitTypeInterface(unit); t != "" {
+		if tp, err := conn.GetUnitTypePropertiesContext(ctx, unit, t); err == nil {
+			d.Result, _ = tp["Result"].(string)
+			d.ExecMainStatus, _ = tp["ExecMainStatus"].(int32)
+			d.NRestarts, _ = tp["NRestarts"].(uint32)
+			code, _ := tp["ExecMainCode"].(int32)
+			d.ExecMainCode = execMainCodeString(code)
+		}
+	}
+
+	var deps []string
+	for _, k := range []string{"Requires", "Requisite", "BindsTo", "Wants", "PartOf"} {
+		if l, ok := p[k].([]string); ok {
+			deps = append(deps, l...)
+		}
+	}
+
+	if len(deps) > 0 {
+		units, err := conn.ListUnitsByNamesContext(ctx, deps)
+		if err == nil {
+			for _, u := range units {
+				if u.ActiveState == "failed" {
+					d.FailedDependencies = append(d.FailedDependencies, u.Name)
+				}
+			}
+		}
+		sort.Strings(d.FailedDependencies)
+	}
+
+	if lines > 0 {
+		q := journal.Query{
+			Unit:  unit,
+			Lines: lines,
+		}
+		if err := q.Validate(); err != nil {
+			return nil, err
+		}
+
+		page, err := journal.AcquirePage(ctx, &q)
+		if err != nil {
+			log.Warnf("Failed to acquire journal of unit='%s': %v", unit, err)
+		} else {
+			d.Journal = page.Entries
+		}
+	}
+
+	return &d, nil
+}
+
+// DiagnoseUnit returns the result, exit status, restarts, failed dependencies and the last
+// journal lines of the unit
+func DiagnoseUnit(ctx context.Context, unit string, lines int) (*Diagnosis, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	return diagnoseUnit(ctx, conn, unit, lines)
+}
+
+// AcquireFailedUnits diagnoses all the units in the failed state. Units which can not be diagnosed
+// carry the error
+func AcquireFailedUnits(ctx context.Context, lines int) ([]Diagnosis, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsFilteredContext(ctx, []string{"failed"})
+	if err != nil {
+		log.Errorf("Failed list failed systemd units: %v", err)
+		return nil, err
+	}
+
+	// A unit which can not be diagnosed is still listed, with the reason
+	failed := []Diagnosis{}
+	for _, u := range units {
+		d, err := diagnoseUnit(ctx, conn, u.Name, lines)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			d = &Diagnosis{
+				Unit:               u.Name,
+				Description:        u.Description,
+				LoadState:          u.LoadState,
+				ActiveState:        u.ActiveState,
+				SubState:           u.SubState,
+				FailedDependencies: []string{},
+				Journal:            []journal.Entry{},
+				Error:              err.Error(),
+			}
+		}
+		failed = append(failed, *d)
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Unit < failed[j].Unit
+	})
+
+	return failed, nil
+}
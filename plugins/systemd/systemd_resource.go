@@ -0,0 +1,344 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxCPUs = 8192
+
+var cgroupPath = "/sys/fs/cgroup"
+
+// ResourceRequest changes the cgroup resource controls of a unit. Values use the unit file
+// syntax such as "512M", "50%" and "0-3,6", "infinity" removes a limit
+type ResourceRequest struct {
+	CPUWeight   string `json:"CPUWeight"`
+	CPUQuota    string `json:"CPUQuota"`
+	MemoryMax   string `json:"MemoryMax"`
+	MemoryHigh  string `json:"MemoryHigh"`
+	IOWeight    string `json:"IOWeight"`
+	TasksMax    string `json:"TasksMax"`
+	AllowedCPUs string `json:"AllowedCPUs"`
+
+	// Persist writes the settings to /etc/systemd/system.control so that they survive a reboot
+	Persist bool `json:"Persist"`
+}
+
+type ResourceLimits struct {
+	CPUWeight          uint64 `json:"CPUWeight"`
+	CPUQuotaPerSecUSec uint64 `json:"CPUQuotaPerSecUSec"`
+	MemoryMax          uint64 `json:"MemoryMax"`
+	MemoryHigh         uint64 `json:"MemoryHigh"`
+	IOWeight           uint64 `json:"IOWeight"`
+	TasksMax           uint64 `json:"TasksMax"`
+	AllowedCPUs        string `json:"AllowedCPUs"`
+}
+
+type ResourceUsage struct {
+	MemoryCurrent uint64 `json:"MemoryCurrent"`
+	CPUUsageUSec  uint64 `json:"CPUUsageUSec"`
+	TasksCurrent  uint64 `json:"TasksCurrent"`
+	IOReadBytes   uint64 `json:"IOReadBytes"`
+	IOWriteBytes  uint64 `json:"IOWriteBytes"`
+}
+
+type Resources struct {
+	Unit         string         `json:"Unit"`
+	ControlGroup string         `json:"ControlGroup"`
+	Limits       ResourceLimits `json:"Limits"`
+	Usage        ResourceUsage  `json:"Usage"`
+}
+
+// unitCgroupType returns the D-Bus interface suffix of the units which own a cgroup
+func unitCgroupType(unit string) (string, error) {
+	switch path.Ext(unit) {
+	case ".service":
+		return "Service", nil
+	case ".slice":
+		return "Slice", nil
+	case ".scope":
+		return "Scope", nil
+	case ".socket":
+		return "Socket", nil
+	case ".mount":
+		return "Mount", nil
+	case ".swap":
+		return "Swap", nil
+	}
+
+	return "", fmt.Errorf("unit '%s' has no resource controls", unit)
+}
+
+// parseWeight parses CPUWeight and IOWeight, which range from 1 to 10000
+func parseWeight(name string, s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v < 1 || v > 10000 {
+		return 0, fmt.Errorf("invalid %s '%s', must be between 1 and 10000", name, s)
+	}
+
+	return v, nil
+}
+
+// parseCPUSet parses a list of CPUs such as 0-3,6 into the bitmask systemd expects
+func parseCPUSet(s string) ([]byte, error) {
+	var mask []byte
+
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		first, last, found := strings.Cut(r, "-")
+		if !found {
+			last = first
+		}
+
+		a, err := strconv.ParseUint(first, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list '%s'", s)
+		}
+		b, err := strconv.ParseUint(last, 10, 32)
+		if err != nil || b < a || b >= maxCPUs {
+			return nil, fmt.Errorf("invalid CPU list '%s'", s)
+		}
+
+		for c := a; c <= b; c++ {
+			for uint64(len(mask)) <= c/8 {
+				mask = append(mask, 0)
+			}
+			mask[c/8] |= 1 << (c % 8)
+		}
+	}
+
+	if len(mask) == 0 {
+		return nil, fmt.Errorf("invalid CPU list '%s'", s)
+	}
+
+	return mask, nil
+}
+
+// formatCPUSet is the reverse of parseCPUSet
+func formatCPUSet(mask []byte) string {
+	var l []string
+
+	n := len(mask) * 8
+	for c := 0; c < n; c++ {
+		if mask[c/8]&(1<<(c%8)) == 0 {
+			continue
+		}
+
+		e := c
+		for e+1 < n && mask[(e+1)/8]&(1<<((e+1)%8)) != 0 {
+			e++
+		}
+
+		if e == c {
+			l = append(l, strconv.Itoa(c))
+		} else {
+			l = append(l, fmt.Sprintf("%d-%d", c, e))
+		}
+		c = e
+	}
+
+	return strings.Join(l, ",")
+}
+
+func (r *ResourceRequest) properties() ([]sd.Property, error) {
+	var props []sd.Property
+
+	add := func(name string, v interface{}) {
+		props = append(props, sd.Property{Name: name, Value: dbus.MakeVariant(v)})
+	}
+
+	if r.CPUWeight != "" {
+		v, err := parseWeight("CPUWeight", r.CPUWeight)
+		if err != nil {
+			return nil, err
+		}
+		add("CPUWeight", v)
+	}
+	if r.CPUQuota != "" {
+		v := uint64(math.MaxUint64)
+		if r.CPUQuota != "infinity" {
+			q, err := parseCPUQuota(r.CPUQuota)
+			if err != nil {
+				return nil, err
+			}
+			v = q
+		}
+		add("CPUQuotaPerSecUSec", v)
+	}
+	if r.MemoryMax != "" {
+		v, err := parseBytes(r.MemoryMax)
+		if err != nil {
+			return nil, err
+		}
+		add("MemoryMax", v)
+	}
+	if r.MemoryHigh != "" {
+		v, err := parseBytes(r.MemoryHigh)
+		if err != nil {
+			return nil, err
+		}
+		add("MemoryHigh", v)
+	}
+	if r.IOWeight != "" {
+		v, err := parseWeight("IOWeight", r.IOWeight)
+		if err != nil {
+			return nil, err
+		}
+		add("IOWeight", v)
+	}
+	if r.TasksMax != "" {
+		v := uint64(math.MaxUint64)
+		if r.TasksMax != "infinity" {
+			n, err := strconv.ParseUint(r.TasksMax, 10, 64)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid TasksMax '%s'", r.TasksMax)
+			}
+			v = n
+		}
+		add("TasksMax", v)
+	}
+	if r.AllowedCPUs != "" {
+		v, err := parseCPUSet(r.AllowedCPUs)
+		if err != nil {
+			return nil, err
+		}
+		add("AllowedCPUs", v)
+	}
+
+	if len(props) == 0 {
+		return nil, errors.New("no resource control to set")
+	}
+
+	return props, nil
+}
+
+// ConfigureResources applies the resource controls at runtime and optionally persists them
+func (r *ResourceRequest) ConfigureResources(ctx context.Context, unit string) error {
+	if _, err := unitCgroupType(unit); err != nil {
+		return err
+	}
+
+	props, err := r.properties()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetUnitPropertiesContext(ctx, unit, !r.Persist, props...); err != nil {
+		log.Errorf("Failed to set resource controls of unit='%s': %v", unit, err)
+		return err
+	}
+
+	log.Infof("Changed resource controls of unit='%s' persist='%t'", unit, r.Persist)
+	return nil
+}
+
+func readCgroupUint(cgroup string, file string) uint64 {
+	b, err := os.ReadFile(path.Join(cgroupPath, cgroup, file))
+	if err != nil {
+		return 0
+	}
+
+	v, _ := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	return v
+}
+
+// readCgroupKeyed sums the given key of the flat or nested keyed cgroup file, such as
+// usage_usec in cpu.stat or rbytes in io.stat
+func readCgroupKeyed(cgroup string, file string, key string) uint64 {
+	f, err := os.Open(path.Join(cgroupPath, cgroup, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var sum uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i, e := range fields {
+			switch {
+			case e == key && i+1 < len(fields):
+				v, _ := strconv.ParseUint(fields[i+1], 10, 64)
+				sum += v
+			case strings.HasPrefix(e, key+"="):
+				v, _ := strconv.ParseUint(strings.TrimPrefix(e, key+"="), 10, 64)
+				sum += v
+			}
+		}
+	}
+
+	return sum
+}
+
+// AcquireResources returns the resource controls of the unit and the usage of its cgroup
+func AcquireResources(ctx context.Context, unit string) (*Resources, error) {
+	t, err := unitCgroupType(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	p, err := conn.GetUnitTypePropertiesContext(ctx, unit, t)
+	if err != nil {
+		log.Errorf("Failed to fetch systemd unit='%s' properties: %v", unit, err)
+		return nil, err
+	}
+
+	r := Resources{
+		Unit: unit,
+	}
+	r.ControlGroup, _ = p["ControlGroup"].(string)
+	r.Limits.CPUWeight, _ = p["CPUWeight"].(uint64)
+	r.Limits.CPUQuotaPerSecUSec, _ = p["CPUQuotaPerSecUSec"].(uint64)
+	r.Limits.MemoryMax, _ = p["MemoryMax"].(uint64)
+	r.Limits.MemoryHigh, _ = p["MemoryHigh"].(uint64)
+	r.Limits.IOWeight, _ = p["IOWeight"].(uint64)
+	r.Limits.TasksMax, _ = p["TasksMax"].(uint64)
+	if m, ok := p["AllowedCPUs"].([]byte); ok {
+		r.Limits.AllowedCPUs = formatCPUSet(m)
+	}
+
+	// The unit has no cgroup while it is inactive
+	if r.ControlGroup != "" {
+		r.Usage = ResourceUsage{
+			MemoryCurrent: readCgroupUint(r.ControlGroup, "memory.current"),
+			CPUUsageUSec:  readCgroupKeyed(r.ControlGroup, "cpu.stat", "usage_usec"),
+			TasksCurrent:  readCgroupUint(r.ControlGroup, "pids.current"),
+			IOReadBytes:   readCgroupKeyed(r.ControlGroup, "io.stat", "rbytes"),
+			IOWriteBytes:  readCgroupKeyed(r.ControlGroup, "io.stat", "wbytes"),
+		}
+	}
+
+	return &r, nil
+}
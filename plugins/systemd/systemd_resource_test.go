@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestResourceProperties(t *testing.T) {
+	r := ResourceRequest{
+		CPUWeight:   "200",
+		CPUQuota:    "50%",
+		MemoryMax:   "512M",
+		MemoryHigh:  "infinity",
+		IOWeight:    "10000",
+		TasksMax:    "infinity",
+		AllowedCPUs: "6,0-3",
+	}
+
+	props, err := r.properties()
+	if err != nil {
+		t.Fatalf("Failed to build properties: %v", err)
+	}
+
+	got := map[string]interface{}{}
+	for _, p := range props {
+		got[p.Name] = p.Value.Value()
+	}
+
+	// systemd takes the quota per second in microseconds and no limit as the maximum value
+	want := map[string]uint64{
+		"CPUWeight":          200,
+		"CPUQuotaPerSecUSec": 500000,
+		"MemoryMax":          512 << 20,
+		"MemoryHigh":         math.MaxUint64,
+		"IOWeight":           10000,
+		"TasksMax":           math.MaxUint64,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %d", k, got[k], v)
+		}
+	}
+
+	if m, ok := got["AllowedCPUs"].([]byte); !ok || !bytes.Equal(m, []byte{0x4f}) {
+		t.Errorf("AllowedCPUs = %v, want the mask of CPUs 0-3 and 6", got["AllowedCPUs"])
+	}
+
+	if len(props) != len(want)+1 {
+		t.Errorf("Got %d properties, want %d", len(props), len(want)+1)
+	}
+}
+
+func TestResourcePropertiesRefused(t *testing.T) {
+	if _, err := (&ResourceRequest{Persist: true}).properties(); err == nil {
+		t.Errorf("Request without any resource control accepted")
+	}
+
+	// The error names the value at fault
+	for value, r := range map[string]ResourceRequest{
+		"0":       {CPUWeight: "0"},
+		"10001":   {IOWeight: "10001"},
+		"50":      {CPUWeight: "100", CPUQuota: "50"},
+		"512m":    {MemoryMax: "512m"},
+		"1.5G":    {MemoryHigh: "1.5G"},
+		"-1":      {TasksMax: "-1"},
+		"3-1":     {AllowedCPUs: "3-1"},
+		"0-8192":  {AllowedCPUs: "0-8192"},
+		",":       {AllowedCPUs: ","},
+		"forever": {TasksMax: "forever"},
+	} {
+		_, err := r.properties()
+		if err == nil {
+			t.Errorf("Request %+v accepted", r)
+			continue
+		}
+		if !strings.Contains(err.Error(), "'"+value+"'") {
+			t.Errorf("Error '%v' does not name '%s'", err, value)
+		}
+	}
+}
+
+func TestAllowedCPUsRoundTrip(t *testing.T) {
+	// Lists come back sorted with adjacent CPUs merged into ranges
+	for in, want := range map[string]string{
+		"0":          "0",
+		"6,0-3":      "0-3,6",
+		" 1 , 2 ":    "1-2",
+		"0,,2":       "0,2",
+		"1,3,5,7":    "1,3,5,7",
+		"7,8":        "7-8",
+		"0-8":        "0-8",
+		"2,4-9,63":   "2,4-9,63",
+		"8191,8190":  "8190-8191",
+		"2-2,3-3,10": "2-3,10",
+	} {
+		mask, err := parseCPUSet(in)
+		if err != nil {
+			t.Errorf("Failed to parse CPU list '%s': %v", in, err)
+			continue
+		}
+		if got := formatCPUSet(mask); got != want {
+			t.Errorf("CPU list '%s' came back as '%s', want '%s'", in, got, want)
+		}
+	}
+
+	if got := formatCPUSet(make([]byte, 4)); got != "" {
+		t.Errorf("Empty mask formatted as '%s'", got)
+	}
+}
+
+func TestReadCgroup(t *testing.T) {
+	saved := cgroupPath
+	cgroupPath = t.TempDir()
+	t.Cleanup(func() { cgroupPath = saved })
+
+	cgroup := "system.slice/sshd.service"
+	files := map[string]string{
+		"memory.current": "7340032\n",
+		"pids.current":   "3\n",
+		"cpu.stat":       "usage_usec 1200\nuser_usec 1000\nsystem_usec 200\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=512 rios=1 wios=1\n259:0 rbytes=1024 wbytes=0 rios=1 wios=0\n",
+	}
+	if err := os.MkdirAll(path.Join(cgroupPath, cgroup), 0755); err != nil {
+		t.Fatalf("Failed to create cgroup: %v", err)
+	}
+	for f, c := range files {
+		if err := os.WriteFile(path.Join(cgroupPath, cgroup, f), []byte(c), 0644); err != nil {
+			t.Fatalf("Failed to write '%s': %v", f, err)
+		}
+	}
+
+	if v := readCgroupUint(cgroup, "memory.current"); v != 7340032 {
+		t.Errorf("memory.current = %d", v)
+	}
+	if v := readCgroupKeyed(cgroup, "cpu.stat", "usage_usec"); v != 1200 {
+		t.Errorf("usage_usec = %d", v)
+	}
+
+	// io.stat is summed over all devices
+	if v := readCgroupKeyed(cgroup, "io.stat", "rbytes"); v != 5120 {
+		t.Errorf("rbytes = %d, want the sum of both devices", v)
+	}
+	if v := readCgroupKeyed(cgroup, "io.stat", "wbytes"); v != 512 {
+		t.Errorf("wbytes = %d", v)
+	}
+
+	// Controllers which are not enabled for the cgroup read as zero
+	if v := readCgroupUint(cgroup, "memory.max"); v != 0 {
+		t.Errorf("Missing memory.max read as %d", v)
+	}
+	if v := readCgroupKeyed("system.slice/gone.service", "cpu.stat", "usage_usec"); v != 0 {
+		t.Errorf("Missing cgroup read as %d", v)
+	}
+}
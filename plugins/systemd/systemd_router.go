@@ -4,13 +4,19 @@
 package systemd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
 
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
 	"github.com/vmware/pmd-next-gen/pkg/web"
+	"github.com/vmware/pmd-next-gen/plugins/journal"
 )
 
 func (u *UnitRequest) appendSuffixIfMissing() {
@@ -35,17 +41,67 @@ func routerSystemdManagerDescribe(w http.ResponseWriter, r *http.Request) {
 	web.JSONResponse(d, w)
 }
 
+func routerAcquireDaemonConf(w http.ResponseWriter, r *http.Request) {
+	c, err := AcquireDaemonConf(mux.Vars(r)["daemon"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func routerAcquireSystemdConfSchema(w http.ResponseWriter, r *http.Request) {
+	s, err := AcquireConfSchema(mux.Vars(r)["daemon"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(s, w)
+}
+
+func routerConfigureDaemonConf(w http.ResponseWriter, r *http.Request) {
+	settings := make(map[string]map[string]string)
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	c, err := ConfigureDaemonConf(r.Context(), mux.Vars(r)["daemon"], settings, r.URL.Query().Get("apply") == "true")
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+// routerConfigureSystemdConf shows and updates the [Manager] settings of system.conf as a flat object
 func routerConfigureSystemdConf(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		if err := GetSystemConf(w); err != nil {
+		c, err := AcquireSystemConf()
+		if err != nil {
 			web.JSONResponseError(err, w)
 			return
 		}
+
+		web.JSONResponse(c, w)
 	case "POST":
-		if err := UpdateSystemConf(w, r); err != nil {
+		manager := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&manager); err != nil {
+			http.Error(w, "Error decoding request", http.StatusBadRequest)
+			return
+		}
+
+		c, err := ConfigureSystemConf(r.Context(), manager)
+		if err != nil {
 			web.JSONResponseError(err, w)
+			return
 		}
+
+		web.JSONResponse(c, w)
 	}
 }
 
@@ -65,12 +121,48 @@ func routerConfigureUnit(w http.ResponseWriter, r *http.Request) {
 	web.JSONResponse("", w)
 }
 
+// queryList collects a query parameter given several times or as a comma separated list
+func queryList(r *http.Request, key string) []string {
+	var l []string
+	for _, v := range r.URL.Query()[key] {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				l = append(l, e)
+			}
+		}
+	}
+
+	return l
+}
+
 func routerAcquireAllSystemdUnits(w http.ResponseWriter, r *http.Request) {
-	if err := ListUnits(r.Context(), w); err != nil {
+	f := UnitFilter{
+		States:   queryList(r, "state"),
+		Types:    queryList(r, "type"),
+		Patterns: queryList(r, "pattern"),
+	}
+
+	if err := ListUnits(r.Context(), w, &f); err != nil {
 		web.JSONResponseError(err, w)
 	}
 }
 
+func routerBulkUnitCommands(w http.ResponseWriter, r *http.Request) {
+	b := BulkUnitRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	results, err := b.BulkUnitCommands(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(results, w)
+}
+
 func routerAcquireUnitStatus(w http.ResponseWriter, r *http.Request) {
 	u := UnitRequest{
 		Unit: mux.Vars(r)["unit"],
@@ -116,23 +208,366 @@ func routerAcquireUnitTypeProperty(w http.ResponseWriter, r *http.Request) {
 	u.AcquireUnitTypeProperty(r.Context(), w)
 }
 
+func routerConfigureUnitFile(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	u := UnitFile{}
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	if err := u.ConfigureUnitFile(r.Context(), unit); err != nil {
+		if errors.Is(err, ErrUnitExists) {
+			web.JSONResponseErrorWithStatus(err, http.StatusConflict, w)
+			return
+		}
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("", w)
+}
+
+func routerRemoveUnitFile(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	if err := RemoveUnitFile(r.Context(), unit); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("", w)
+}
+
+func routerConfigureDropIn(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	name, err := dropInFileName(mux.Vars(r)["name"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	u := UnitFile{}
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	if err := u.ConfigureDropIn(r.Context(), unit, name); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("", w)
+}
+
+func routerRemoveDropIn(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	name, err := dropInFileName(mux.Vars(r)["name"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	if err := RemoveDropIn(r.Context(), unit, name); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("", w)
+}
+
+func routerAcquireUnitCat(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	c, err := AcquireUnitCat(r.Context(), unit)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func routerAcquireTimers(w http.ResponseWriter, r *http.Request) {
+	t, err := AcquireTimers(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(t, w)
+}
+
+func routerAcquireTimer(w http.ResponseWriter, r *http.Request) {
+	timer := mux.Vars(r)["timer"]
+	if !strings.HasSuffix(timer, ".timer") {
+		timer += ".timer"
+	}
+
+	unit, err := UnitName(timer)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	t, err := AcquireTimer(r.Context(), unit)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(t, w)
+}
+
+func routerCreateTimer(w http.ResponseWriter, r *http.Request) {
+	t := TimerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	timer, err := t.CreateTimer(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrUnitExists) {
+			web.JSONResponseErrorWithStatus(err, http.StatusConflict, w)
+			return
+		}
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(timer, w)
+}
+
+func routerParseCalendar(w http.ResponseWriter, r *http.Request) {
+	c, err := ParseCalendar(r.Context(), r.URL.Query().Get("expression"))
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func routerRunTransientUnit(w http.ResponseWriter, r *http.Request) {
+	run := RunRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	if err := run.Validate(); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	job := jobs.CreateJob(r, func(ctx context.Context) (interface{}, error) {
+		return run.Run(ctx)
+	})
+	jobs.AcceptedResponse(w, job)
+}
+
+func routerAcquireResources(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	res, err := AcquireResources(r.Context(), unit)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(res, w)
+}
+
+func routerConfigureResources(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	res := ResourceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(w, "Error decoding request", http.StatusBadRequest)
+		return
+	}
+
+	if err := res.ConfigureResources(r.Context(), unit); err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse("", w)
+}
+
+func routerAcquireBootTimes(w http.ResponseWriter, r *http.Request) {
+	b, err := AcquireBootTimes(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(b, w)
+}
+
+func routerAcquireBlame(w http.ResponseWriter, r *http.Request) {
+	b, err := AcquireBlame(r.Context())
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(b, w)
+}
+
+func routerAcquireCriticalChain(w http.ResponseWriter, r *http.Request) {
+	unit := mux.Vars(r)["unit"]
+	if unit == "" {
+		unit = "default.target"
+	}
+
+	unit, err := UnitName(unit)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	c, err := AcquireCriticalChain(r.Context(), unit)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(c, w)
+}
+
+func parseDiagnoseLines(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("lines")
+	if s == "" {
+		return DefaultDiagnoseLines, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > journal.MaxLines {
+		return 0, fmt.Errorf("invalid lines '%s', must be between 0 and %d", s, journal.MaxLines)
+	}
+
+	return n, nil
+}
+
+func routerAcquireFailedUnits(w http.ResponseWriter, r *http.Request) {
+	lines, err := parseDiagnoseLines(r)
+	if err != nil {
+		web.JSONResponseErrorWithStatus(err, http.StatusBadRequest, w)
+		return
+	}
+
+	f, err := AcquireFailedUnits(r.Context(), lines)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(f, w)
+}
+
+func routerDiagnoseUnit(w http.ResponseWriter, r *http.Request) {
+	unit, err := UnitName(mux.Vars(r)["unit"])
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	lines, err := parseDiagnoseLines(r)
+	if err != nil {
+		web.JSONResponseErrorWithStatus(err, http.StatusBadRequest, w)
+		return
+	}
+
+	d, err := DiagnoseUnit(r.Context(), unit, lines)
+	if err != nil {
+		web.JSONResponseError(err, w)
+		return
+	}
+
+	web.JSONResponse(d, w)
+}
+
 func RegisterRouterSystemd(router *mux.Router) {
 	n := router.PathPrefix("/service").Subrouter()
 
 	// systemd unit commands
 	n.HandleFunc("/systemd", routerConfigureUnit).Methods("POST")
+	n.HandleFunc("/systemd/bulk", routerBulkUnitCommands).Methods("POST")
+	n.HandleFunc("/systemd/run", routerRunTransientUnit).Methods("POST")
 
 	// systemd unit status and property
 	n.HandleFunc("/systemd/manager/property/{property}", routerAcquireSystemdManagerProperty).Methods("GET")
 	n.HandleFunc("/systemd/manager/describe", routerSystemdManagerDescribe).Methods("GET")
 
 	n.HandleFunc("/systemd/units", routerAcquireAllSystemdUnits).Methods("GET")
+	n.HandleFunc("/systemd/failed", routerAcquireFailedUnits).Methods("GET")
 	n.HandleFunc("/systemd/{unit}/status", routerAcquireUnitStatus).Methods("GET")
+	n.HandleFunc("/systemd/{unit}/diagnose", routerDiagnoseUnit).Methods("GET")
 	n.HandleFunc("/systemd/{unit}/property", routerAcquireUnitProperty).Methods("GET")
 	n.HandleFunc("/systemd/{unit}/propertyall", routerAcquireUnitPropertyAll).Methods("GET")
 	n.HandleFunc("/systemd/{unit}/property/{unittype}", routerAcquireUnitTypeProperty).Methods("GET")
 
+	// systemd boot analysis
+	n.HandleFunc("/systemd/analyze/time", routerAcquireBootTimes).Methods("GET")
+	n.HandleFunc("/systemd/analyze/blame", routerAcquireBlame).Methods("GET")
+	n.HandleFunc("/systemd/analyze/critical-chain", routerAcquireCriticalChain).Methods("GET")
+	n.HandleFunc("/systemd/analyze/critical-chain/{unit}", routerAcquireCriticalChain).Methods("GET")
+
+	// systemd cgroup resource controls
+	n.HandleFunc("/systemd/{unit}/resources", routerAcquireResources).Methods("GET")
+	n.HandleFunc("/systemd/{unit}/resources", routerConfigureResources).Methods("PUT")
+
+	// systemd timers
+	n.HandleFunc("/systemd/timers", routerAcquireTimers).Methods("GET")
+	n.HandleFunc("/systemd/timers", routerCreateTimer).Methods("POST")
+	n.HandleFunc("/systemd/timers/calendar", routerParseCalendar).Methods("GET")
+	n.HandleFunc("/systemd/timers/{timer}", routerAcquireTimer).Methods("GET")
+
+	// systemd unit files and drop-ins
+	n.HandleFunc("/systemd/{unit}/unitfile", routerConfigureUnitFile).Methods("PUT")
+	n.HandleFunc("/systemd/{unit}/unitfile", routerRemoveUnitFile).Methods("DELETE")
+	n.HandleFunc("/systemd/{unit}/dropin/{name}", routerConfigureDropIn).Methods("PUT")
+	n.HandleFunc("/systemd/{unit}/dropin/{name}", routerRemoveDropIn).Methods("DELETE")
+	n.HandleFunc("/systemd/{unit}/cat", routerAcquireUnitCat).Methods("GET")
+
 	// systemd configuration
-	n.HandleFunc("/systemd/conf", routerConfigureSystemdConf)
-	n.HandleFunc("/systemd/conf/update", routerConfigureSystemdConf)
+	n.HandleFunc("/systemd/conf", routerConfigureSystemdConf).Methods("GET", "POST")
+	n.HandleFunc("/systemd/conf/update", routerConfigureSystemdConf).Methods("GET", "POST")
+	n.HandleFunc("/systemd/conf/{daemon}", routerAcquireDaemonConf).Methods("GET")
+	n.HandleFunc("/systemd/conf/{daemon}", routerConfigureDaemonConf).Methods("PUT")
+	n.HandleFunc("/systemd/conf/{daemon}/schema", routerAcquireSystemdConfSchema).Methods("GET")
 }
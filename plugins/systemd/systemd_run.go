@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/jobs"
+	"github.com/vmware/pmd-next-gen/plugins/journal"
+)
+
+const (
+	runUnitPrefix = "photon-mgmt-run-"
+)
+
+// RunRequest describes a command to run as a transient service, like systemd-run --wait does
+type RunRequest struct {
+	Unit             string   `json:"Unit"`
+	Description      string   `json:"Description"`
+	Command          []string `json:"Command"`
+	Environment      []string `json:"Environment"`
+	WorkingDirectory string   `json:"WorkingDirectory"`
+	User             string   `json:"User"`
+	Group            string   `json:"Group"`
+	TimeoutSec       uint64   `json:"TimeoutSec"`
+
+	// Resource limits, in the unit file syntax such as "512M" and "50%"
+	MemoryMax string `json:"MemoryMax"`
+	CPUQuota  string `json:"CPUQuota"`
+	TasksMax  uint64 `json:"TasksMax"`
+
+	// Sandboxing
+	DynamicUser       bool     `json:"DynamicUser"`
+	NoNewPrivileges   bool     `json:"NoNewPrivileges"`
+	PrivateTmp        bool     `json:"PrivateTmp"`
+	PrivateNetwork    bool     `json:"PrivateNetwork"`
+	PrivateDevices    bool     `json:"PrivateDevices"`
+	ProtectSystem     string   `json:"ProtectSystem"`
+	ProtectHome       string   `json:"ProtectHome"`
+	ReadOnlyPaths     []string `json:"ReadOnlyPaths"`
+	ReadWritePaths    []string `json:"ReadWritePaths"`
+	InaccessiblePaths []string `json:"InaccessiblePaths"`
+
+	// The unit name, generated once when Unit is empty
+	unit string
+}
+
+type RunResult struct {
+	Unit       string   `json:"Unit"`
+	Result     string   `json:"Result"`
+	ExitCode   string   `json:"ExitCode"`
+	ExitStatus int32    `json:"ExitStatus"`
+	StartTime  uint64   `json:"StartTime"`
+	ExitTime   uint64   `json:"ExitTime"`
+	Output     []string `json:"Output"`
+}
+
+// parseBytes parses a size such as 512M with the 1024 based suffixes systemd accepts
+func parseBytes(s string) (uint64, error) {
+	if s == "infinity" {
+		return math.MaxUint64, nil
+	}
+
+	v, m := s, uint64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			m = 1 << 10
+		case 'M':
+			m = 1 << 20
+		case 'G':
+			m = 1 << 30
+		case 'T':
+			m = 1 << 40
+		}
+		if m > 1 {
+			v = v[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || n > math.MaxUint64/m {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+
+	return n * m, nil
+}
+
+// parseCPUQuota converts a percentage such as 50% into CPU time per second
+func parseCPUQuota(s string) (uint64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || !strings.HasSuffix(s, "%") || v <= 0 {
+		return 0, fmt.Errorf("invalid CPUQuota '%s'", s)
+	}
+
+	return uint64(v * 10000), nil
+}
+
+// execMainCodeString names the si_code of the main process: CLD_EXITED, CLD_KILLED or CLD_DUMPED
+func execMainCodeString(code int32) string {
+	switch code {
+	case 1:
+		return "exited"
+	case 2:
+		return "killed"
+	case 3:
+		return "dumped"
+	}
+
+	return ""
+}
+
+func (r *RunRequest) properties() ([]sd.Property, error) {
+	if len(r.Command) == 0 || r.Command[0] == "" {
+		return nil, errors.New("missing command")
+	}
+
+	// ExecStart needs an absolute path, systemd-run resolves it on the client side as well
+	command := append([]string{}, r.Command...)
+	if !path.IsAbs(command[0]) {
+		p, err := exec.LookPath(command[0])
+		if err != nil {
+			return nil, err
+		}
+		command[0] = p
+	}
+
+	description := r.Description
+	if description == "" {
+		description = strings.Join(r.Command, " ")
+	}
+
+	props := []sd.Property{
+		sd.PropDescription(description),
+		sd.PropType("oneshot"),
+		// Keep the unit around after the command exited so that its exit status can be read
+		sd.PropRemainAfterExit(true),
+		sd.PropExecStart(command, false),
+	}
+
+	add := func(name string, v interface{}) {
+		props = append(props, sd.Property{Name: name, Value: dbus.MakeVariant(v)})
+	}
+
+	for _, e := range r.Environment {
+		if !strings.Contains(e, "=") {
+			return nil, fmt.Errorf("invalid environment '%s'", e)
+		}
+	}
+	if len(r.Environment) > 0 {
+		add("Environment", r.Environment)
+	}
+	if r.WorkingDirectory != "" {
+		add("WorkingDirectory", r.WorkingDirectory)
+	}
+	if r.User != "" {
+		add("User", r.User)
+	}
+	if r.Group != "" {
+		add("Group", r.Group)
+	}
+	if r.TimeoutSec > 0 {
+		add("TimeoutStartUSec", r.TimeoutSec*uint64(time.Second/time.Microsecond))
+	}
+
+	if r.MemoryMax != "" {
+		v, err := parseBytes(r.MemoryMax)
+		if err != nil {
+			return nil, err
+		}
+		add("MemoryAccounting", true)
+		add("MemoryMax", v)
+	}
+	if r.CPUQuota != "" {
+		v, err := parseCPUQuota(r.CPUQuota)
+		if err != nil {
+			return nil, err
+		}
+		add("CPUAccounting", true)
+		add("CPUQuotaPerSecUSec", v)
+	}
+	if r.TasksMax > 0 {
+		add("TasksAccounting", true)
+		add("TasksMax", r.TasksMax)
+	}
+
+	if r.DynamicUser {
+		add("DynamicUser", true)
+	}
+	if r.NoNewPrivileges {
+		add("NoNewPrivileges", true)
+	}
+	if r.PrivateTmp {
+		add("PrivateTmp", true)
+	}
+	if r.PrivateNetwork {
+		add("PrivateNetwork", true)
+	}
+	if r.PrivateDevices {
+		add("PrivateDevices", true)
+	}
+	if r.ProtectSystem != "" {
+		add("ProtectSystem", r.ProtectSystem)
+	}
+	if r.ProtectHome != "" {
+		add("ProtectHome", r.ProtectHome)
+	}
+	if len(r.ReadOnlyPaths) > 0 {
+		add("ReadOnlyPaths", r.ReadOnlyPaths)
+	}
+	if len(r.ReadWritePaths) > 0 {
+		add("ReadWritePaths", r.ReadWritePaths)
+	}
+	if len(r.InaccessiblePaths) > 0 {
+		add("InaccessiblePaths", r.InaccessiblePaths)
+	}
+
+	return props, nil
+}
+
+func (r *RunRequest) unitName() (string, error) {
+	if r.unit != "" {
+		return r.unit, nil
+	}
+
+	if r.Unit != "" {
+		name := r.Unit
+		if !strings.HasSuffix(name, ".service") {
+			name += ".service"
+		}
+
+		u, err := UnitName(name)
+		if err != nil {
+			return "", err
+		}
+		r.unit = u
+
+		return r.unit, nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	r.unit = runUnitPrefix + hex.EncodeToString(b) + ".service"
+
+	return r.unit, nil
+}
+
+// Validate checks the request so that errors are reported before a job is created
+func (r *RunRequest) Validate() error {
+	if _, err := r.unitName(); err != nil {
+		return err
+	}
+
+	_, err := r.properties()
+	return err
+}
+
+// Run starts the command as a transient service and waits for it to exit. The unit is
+// stopped when ctx is cancelled
+func (r *RunRequest) Run(ctx context.Context) (*RunResult, error) {
+	props, err := r.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	unit, err := r.unitName()
+	if err != nil {
+		return nil, err
+	}
+
+	// The connection is closed together with its context, it has to outlive ctx to stop the unit
+	conn, err := sd.NewSystemdConnectionContext(context.Background())
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	done := make(chan string, 1)
+	if _, err := conn.StartTransientUnitContext(ctx, unit, "fail", props, done); err != nil {
+		log.Errorf("Failed to start transient unit='%s': %v", unit, err)
+		return nil, err
+	}
+
+	jobs.Progress(ctx, "Running as unit %s", unit)
+
+	// Start jobs of oneshot services finish once the command exited
+	select {
+	case s := <-done:
+		log.Debugf("Transient unit='%s' finished job result='%s'", unit, s)
+	case <-ctx.Done():
+		if _, err := conn.StopUnitContext(context.Background(), unit, "replace", nil); err != nil {
+			log.Errorf("Failed to stop cancelled transient unit='%s': %v", unit, err)
+			return nil, err
+		}
+		conn.ResetFailedUnitContext(context.Background(), unit)
+		return nil, ctx.Err()
+	}
+
+	// Release the unit, it is kept active or failed until then
+	defer func() {
+		if _, err := conn.StopUnitContext(context.Background(), unit, "replace", nil); err != nil {
+			log.Warnf("Failed to stop transient unit='%s': %v", unit, err)
+		}
+		conn.ResetFailedUnitContext(context.Background(), unit)
+	}()
+
+	p, err := conn.GetUnitTypePropertiesContext(ctx, unit, "Service")
+	if err != nil {
+		log.Errorf("Failed to fetch transient unit='%s' properties: %v", unit, err)
+		return nil, err
+	}
+
+	// A unit of the same name may have run before, only its current invocation is of interest
+	v, err := conn.GetUnitPropertyContext(ctx, unit, "InvocationID")
+	if err != nil {
+		log.Errorf("Failed to fetch transient unit='%s' invocation id: %v", unit, err)
+		return nil, err
+	}
+	id, _ := v.Value.Value().([]byte)
+
+	result := RunResult{
+		Unit:   unit,
+		Output: []string{},
+	}
+	result.Result, _ = p["Result"].(string)
+	result.ExitStatus, _ = p["ExecMainStatus"].(int32)
+	result.StartTime, _ = p["ExecMainStartTimestamp"].(uint64)
+	result.ExitTime, _ = p["ExecMainExitTimestamp"].(uint64)
+
+	code, _ := p["ExecMainCode"].(int32)
+	result.ExitCode = execMainCodeString(code)
+
+	if len(id) == 0 {
+		log.Warnf("Transient unit='%s' has no invocation id, skipping its journal", unit)
+		return &result, nil
+	}
+
+	q := journal.Query{
+		InvocationId: hex.EncodeToString(id),
+		Lines:        journal.MaxLines,
+	}
+	page, err := journal.AcquirePage(ctx, &q)
+	if err != nil {
+		log.Warnf("Failed to acquire journal of transient unit='%s': %v", unit, err)
+		return &result, nil
+	}
+
+	// Skip the messages systemd itself logs about the unit
+	for _, e := range page.Entries {
+		if e.Unit == unit {
+			result.Output = append(result.Output, e.Message)
+		}
+	}
+
+	return &result, nil
+}
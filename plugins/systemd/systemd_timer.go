@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/system"
+)
+
+const (
+	systemdAnalyzePath = "systemd-analyze"
+)
+
+// ErrUnitExists is returned when writing a unit file or a timer would overwrite an existing unit
+var ErrUnitExists = errors.New("unit already exists")
+
+type Timer struct {
+	Unit                    string   `json:"Unit"`
+	Description             string   `json:"Description"`
+	ActiveState             string   `json:"ActiveState"`
+	SubState                string   `json:"SubState"`
+	Activates               string   `json:"Activates"`
+	ActivatesActiveState    string   `json:"ActivatesActiveState"`
+	OnCalendar              []string `json:"OnCalendar"`
+	Persistent              bool     `json:"Persistent"`
+	NextElapseUSecRealtime  uint64   `json:"NextElapseUSecRealtime"`
+	NextElapseUSecMonotonic uint64   `json:"NextElapseUSecMonotonic"`
+	LastTriggerUSec         uint64   `json:"LastTriggerUSec"`
+}
+
+// CalendarSpec is the result of validating an OnCalendar expression
+type CalendarSpec struct {
+	Expression string `json:"Expression"`
+	Normalized string `json:"Normalized"`
+	NextElapse string `json:"NextElapse"`
+}
+
+// TimerRequest creates a calendar timer <Name>.timer activating the oneshot <Name>.service
+type TimerRequest struct {
+	Name               string   `json:"Name"`
+	Description        string   `json:"Description"`
+	OnCalendar         []string `json:"OnCalendar"`
+	Persistent         bool     `json:"Persistent"`
+	RandomizedDelaySec string   `json:"RandomizedDelaySec"`
+	AccuracySec        string   `json:"AccuracySec"`
+	ExecStart          []string `json:"ExecStart"`
+	User               string   `json:"User"`
+	Group              string   `json:"Group"`
+	Environment        []string `json:"Environment"`
+	Enable             bool     `json:"Enable"`
+	Replace            bool     `json:"Replace"`
+}
+
+// ParseCalendar validates an OnCalendar expression the way systemd does and returns its
+// normalized form and the next time it elapses
+func ParseCalendar(ctx context.Context, expression string) (*CalendarSpec, error) {
+	if strings.TrimSpace(expression) == "" || strings.ContainsAny(expression, "\n\x00") {
+		return nil, fmt.Errorf("invalid calendar expression '%s'", expression)
+	}
+
+	var stderr strings.Builder
+	c := exec.CommandContext(ctx, systemdAnalyzePath, "calendar", "--", expression)
+	c.Stderr = &stderr
+
+	out, err := c.Output()
+	if err != nil {
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			return nil, errors.New(s)
+		}
+		return nil, err
+	}
+
+	spec := CalendarSpec{
+		Expression: expression,
+	}
+	for _, l := range strings.Split(string(out), "\n") {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(k) {
+		case "Normalized form":
+			spec.Normalized = strings.TrimSpace(v)
+		case "Next elapse":
+			spec.NextElapse = strings.TrimSpace(v)
+		}
+	}
+
+	return &spec, nil
+}
+
+func timerUSec(v interface{}) uint64 {
+	u, ok := v.(uint64)
+	if !ok || u == math.MaxUint64 {
+		return 0
+	}
+
+	return u
+}
+
+func acquireTimer(ctx context.Context, conn *sd.Conn, u *sd.UnitStatus) (*Timer, error) {
+	p, err := conn.GetUnitTypePropertiesContext(ctx, u.Name, "Timer")
+	if err != nil {
+		log.Errorf("Failed to fetch timer='%s' properties: %v", u.Name, err)
+		return nil, err
+	}
+
+	t := Timer{
+		Unit:                    u.Name,
+		Description:             u.Description,
+		ActiveState:             u.ActiveState,
+		SubState:                u.SubState,
+		OnCalendar:              []string{},
+		NextElapseUSecRealtime:  timerUSec(p["NextElapseUSecRealtime"]),
+		NextElapseUSecMonotonic: timerUSec(p["NextElapseUSecMonotonic"]),
+		LastTriggerUSec:         timerUSec(p["LastTriggerUSec"]),
+	}
+	t.Activates, _ = p["Unit"].(string)
+	t.Persistent, _ = p["Persistent"].(bool)
+
+	// TimersCalendar is a(stt): the setting, the expression and the next elapse
+	if l, ok := p["TimersCalendar"].([][]interface{}); ok {
+		for _, e := range l {
+			if len(e) < 2 {
+				continue
+			}
+			if s, ok := e[1].(string); ok {
+				t.OnCalendar = append(t.OnCalendar, s)
+			}
+		}
+	}
+
+	if t.Activates != "" {
+		if s, err := conn.GetUnitPropertyContext(ctx, t.Activates, "ActiveState"); err == nil {
+			t.ActivatesActiveState, _ = s.Value.Value().(string)
+		}
+	}
+
+	return &t, nil
+}
+
+// AcquireTimers returns the loaded timers joined with the units they activate
+func AcquireTimers(ctx context.Context) ([]Timer, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsByPatternsContext(ctx, nil, []string{"*.timer"})
+	if err != nil {
+		log.Errorf("Failed list systemd timers: %v", err)
+		return nil, err
+	}
+
+	timers := []Timer{}
+	for i := range units {
+		t, err := acquireTimer(ctx, conn, &units[i])
+		if err != nil {
+			continue
+		}
+		timers = append(timers, *t)
+	}
+
+	return timers, nil
+}
+
+func AcquireTimer(ctx context.Context, unit string) (*Timer, error) {
+	if !strings.HasSuffix(unit, ".timer") {
+		return nil, fmt.Errorf("unit '%s' is not a timer", unit)
+	}
+
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsByNamesContext(ctx, []string{unit})
+	if err != nil {
+		log.Errorf("Failed fetch systemd timer='%s': %v", unit, err)
+		return nil, err
+	}
+	if len(units) == 0 || units[0].LoadState == "not-found" {
+		return nil, fmt.Errorf("timer '%s' not found", unit)
+	}
+
+	return acquireTimer(ctx, conn, &units[0])
+}
+
+// CreateTimer writes <Name>.service and <Name>.timer into /etc/systemd/system, reloads systemd
+// and optionally enables and starts the timer. Existing units are only overwritten with Replace
+func (t *TimerRequest) CreateTimer(ctx context.Context) (*Timer, error) {
+	if t.Name == "" || strings.Contains(t.Name, ".") {
+		return nil, fmt.Errorf("invalid timer name '%s'", t.Name)
+	}
+	if len(t.OnCalendar) == 0 {
+		return nil, errors.New("missing OnCalendar")
+	}
+	if len(t.ExecStart) == 0 {
+		return nil, errors.New("missing ExecStart")
+	}
+
+	for _, c := range t.OnCalendar {
+		if _, err := ParseCalendar(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	service, err := UnitName(t.Name + ".service")
+	if err != nil {
+		return nil, err
+	}
+	timer := t.Name + ".timer"
+
+	if !t.Replace {
+		for _, u := range []string{service, timer} {
+			if system.PathExists(path.Join(unitDirPath, u)) {
+				return nil, fmt.Errorf("%w: '%s'", ErrUnitExists, u)
+			}
+		}
+	}
+
+	description := t.Description
+	if description == "" {
+		description = t.Name
+	}
+
+	s := UnitFile{
+		Unit: &UnitSection{
+			Description: description,
+		},
+		Service: &ServiceSection{
+			Type:        "oneshot",
+			ExecStart:   t.ExecStart,
+			User:        t.User,
+			Group:       t.Group,
+			Environment: t.Environment,
+		},
+	}
+
+	persistent := "false"
+	if t.Persistent {
+		persistent = "true"
+	}
+
+	f := UnitFile{
+		Unit: &UnitSection{
+			Description: description,
+		},
+		Timer: &TimerSection{
+			OnCalendar:         t.OnCalendar,
+			Persistent:         persistent,
+			RandomizedDelaySec: t.RandomizedDelaySec,
+			AccuracySec:        t.AccuracySec,
+			Unit:               service,
+		},
+		Install: &InstallSection{
+			WantedBy: []string{"timers.target"},
+		},
+	}
+
+	sm, err := s.Render(path.Join(unitDirPath, service), service, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tm, err := f.Render(path.Join(unitDirPath, timer), timer, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeUnitFile(sm); err != nil {
+		log.Errorf("Failed to write unit file='%s': %v", sm.Path, err)
+		return nil, err
+	}
+
+	if err := writeUnitFile(tm); err != nil {
+		log.Errorf("Failed to write unit file='%s': %v", tm.Path, err)
+		os.Remove(sm.Path)
+		return nil, err
+	}
+
+	log.Infof("Wrote timer='%s' activating service='%s'", tm.Path, sm.Path)
+
+	if err := daemonReload(ctx); err != nil {
+		return nil, err
+	}
+
+	if t.Enable {
+		for _, verb := range []string{"enable", "start"} {
+			u := UnitRequest{
+				Verb: verb,
+				Unit: timer,
+			}
+			if err := u.UnitCommands(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return AcquireTimer(ctx, timer)
+}
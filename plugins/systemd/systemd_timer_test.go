@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+// setupUnitDir points unitDirPath to an empty directory of the test
+func setupUnitDir(t *testing.T) string {
+	saved := unitDirPath
+	unitDirPath = t.TempDir()
+	t.Cleanup(func() { unitDirPath = saved })
+
+	return unitDirPath
+}
+
+func TestCreateTimerConflict(t *testing.T) {
+	if _, err := exec.LookPath(systemdAnalyzePath); err != nil {
+		t.Skipf("%s not available", systemdAnalyzePath)
+	}
+
+	dir := setupUnitDir(t)
+
+	existing := "[Service]\nExecStart=/usr/local/bin/backup --full\n"
+	if err := os.WriteFile(path.Join(dir, "backup.service"), []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to write unit: %v", err)
+	}
+
+	r := TimerRequest{
+		Name:       "backup",
+		OnCalendar: []string{"Mon..Fri 02:00"},
+		ExecStart:  []string{"/usr/bin/true"},
+	}
+
+	_, err := r.CreateTimer(context.Background())
+	if !errors.Is(err, ErrUnitExists) {
+		t.Fatalf("Timer over an existing service created, err=%v", err)
+	}
+
+	b, err := os.ReadFile(path.Join(dir, "backup.service"))
+	if err != nil || string(b) != existing {
+		t.Errorf("Existing service was modified: %s", b)
+	}
+	if _, err := os.Stat(path.Join(dir, "backup.timer")); err == nil {
+		t.Errorf("Timer written although its service exists")
+	}
+
+	// Nothing is written for an expression systemd does not accept
+	r = TimerRequest{
+		Name:       "cleanup",
+		OnCalendar: []string{"daily", "25:00"},
+		ExecStart:  []string{"/usr/bin/true"},
+	}
+	if _, err := r.CreateTimer(context.Background()); err == nil {
+		t.Errorf("Timer with calendar '25:00' created")
+	}
+	if l, _ := os.ReadDir(dir); len(l) != 1 {
+		t.Errorf("Refused timer left %d files behind", len(l)-1)
+	}
+}
+
+func TestParseCalendarNormalizes(t *testing.T) {
+	if _, err := exec.LookPath(systemdAnalyzePath); err != nil {
+		t.Skipf("%s not available", systemdAnalyzePath)
+	}
+
+	spec, err := ParseCalendar(context.Background(), "Sat,Sun 10:00")
+	if err != nil {
+		t.Fatalf("Failed to parse calendar: %v", err)
+	}
+	if spec.Normalized != "Sat,Sun *-*-* 10:00:00" || spec.NextElapse == "" {
+		t.Errorf("Unexpected calendar %+v", spec)
+	}
+
+	// Options and line breaks never reach systemd-analyze as a second argument
+	for _, e := range []string{"-h", "daily\nhourly", " "} {
+		if spec, err := ParseCalendar(context.Background(), e); err == nil {
+			t.Errorf("Calendar %q accepted as %+v", e, spec)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+	"github.com/vmware/pmd-next-gen/pkg/system"
+)
+
+var (
+	unitDirPath = "/etc/systemd/system"
+
+	unitTypes = []string{".service", ".socket", ".timer", ".path", ".target", ".mount", ".automount", ".swap", ".slice", ".scope", ".device"}
+
+	unitNameRegexp   = regexp.MustCompile(`^[a-zA-Z0-9:_.\\@-]+$`)
+	dropInNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+type UnitSection struct {
+	Description           string   `json:"Description,omitempty"`
+	Documentation         []string `json:"Documentation,omitempty"`
+	Requires              []string `json:"Requires,omitempty"`
+	Wants                 []string `json:"Wants,omitempty"`
+	BindsTo               []string `json:"BindsTo,omitempty"`
+	PartOf                []string `json:"PartOf,omitempty"`
+	Conflicts             []string `json:"Conflicts,omitempty"`
+	Before                []string `json:"Before,omitempty"`
+	After                 []string `json:"After,omitempty"`
+	OnFailure             []string `json:"OnFailure,omitempty"`
+	ConditionPathExists   []string `json:"ConditionPathExists,omitempty"`
+	DefaultDependencies   string   `json:"DefaultDependencies,omitempty"`
+	StartLimitIntervalSec string   `json:"StartLimitIntervalSec,omitempty"`
+	StartLimitBurst       string   `json:"StartLimitBurst,omitempty"`
+}
+
+type ServiceSection struct {
+	Type             string   `json:"Type,omitempty"`
+	ExecStartPre     []string `json:"ExecStartPre,omitempty"`
+	ExecStart        []string `json:"ExecStart,omitempty"`
+	ExecStartPost    []string `json:"ExecStartPost,omitempty"`
+	ExecReload       []string `json:"ExecReload,omitempty"`
+	ExecStop         []string `json:"ExecStop,omitempty"`
+	ExecStopPost     []string `json:"ExecStopPost,omitempty"`
+	RemainAfterExit  string   `json:"RemainAfterExit,omitempty"`
+	PIDFile          string   `json:"PIDFile,omitempty"`
+	Restart          string   `json:"Restart,omitempty"`
+	RestartSec       string   `json:"RestartSec,omitempty"`
+	TimeoutStartSec  string   `json:"TimeoutStartSec,omitempty"`
+	TimeoutStopSec   string   `json:"TimeoutStopSec,omitempty"`
+	KillMode         string   `json:"KillMode,omitempty"`
+	User             string   `json:"User,omitempty"`
+	Group            string   `json:"Group,omitempty"`
+	WorkingDirectory string   `json:"WorkingDirectory,omitempty"`
+	Environment      []string `json:"Environment,omitempty"`
+	EnvironmentFile  []string `json:"EnvironmentFile,omitempty"`
+	StandardOutput   string   `json:"StandardOutput,omitempty"`
+	StandardError    string   `json:"StandardError,omitempty"`
+}
+
+type TimerSection struct {
+	OnActiveSec        []string `json:"OnActiveSec,omitempty"`
+	OnBootSec          []string `json:"OnBootSec,omitempty"`
+	OnStartupSec       []string `json:"OnStartupSec,omitempty"`
+	OnUnitActiveSec    []string `json:"OnUnitActiveSec,omitempty"`
+	OnUnitInactiveSec  []string `json:"OnUnitInactiveSec,omitempty"`
+	OnCalendar         []string `json:"OnCalendar,omitempty"`
+	AccuracySec        string   `json:"AccuracySec,omitempty"`
+	RandomizedDelaySec string   `json:"RandomizedDelaySec,omitempty"`
+	Persistent         string   `json:"Persistent,omitempty"`
+	WakeSystem         string   `json:"WakeSystem,omitempty"`
+	Unit               string   `json:"Unit,omitempty"`
+}
+
+type SocketSection struct {
+	ListenStream           []string `json:"ListenStream,omitempty"`
+	ListenDatagram         []string `json:"ListenDatagram,omitempty"`
+	ListenSequentialPacket []string `json:"ListenSequentialPacket,omitempty"`
+	ListenFIFO             []string `json:"ListenFIFO,omitempty"`
+	Accept                 string   `json:"Accept,omitempty"`
+	SocketUser             string   `json:"SocketUser,omitempty"`
+	SocketGroup            string   `json:"SocketGroup,omitempty"`
+	SocketMode             string   `json:"SocketMode,omitempty"`
+	Service                string   `json:"Service,omitempty"`
+}
+
+type PathSection struct {
+	PathExists        []string `json:"PathExists,omitempty"`
+	PathExistsGlob    []string `json:"PathExistsGlob,omitempty"`
+	PathChanged       []string `json:"PathChanged,omitempty"`
+	PathModified      []string `json:"PathModified,omitempty"`
+	DirectoryNotEmpty []string `json:"DirectoryNotEmpty,omitempty"`
+	MakeDirectory     string   `json:"MakeDirectory,omitempty"`
+	DirectoryMode     string   `json:"DirectoryMode,omitempty"`
+	Unit              string   `json:"Unit,omitempty"`
+}
+
+type InstallSection struct {
+	WantedBy   []string `json:"WantedBy,omitempty"`
+	RequiredBy []string `json:"RequiredBy,omitempty"`
+	Alias      []string `json:"Alias,omitempty"`
+	Also       []string `json:"Also,omitempty"`
+}
+
+// UnitFile is the structured model of a unit file or a drop-in
+type UnitFile struct {
+	Unit    *UnitSection    `json:"Unit,omitempty"`
+	Service *ServiceSection `json:"Service,omitempty"`
+	Timer   *TimerSection   `json:"Timer,omitempty"`
+	Socket  *SocketSection  `json:"Socket,omitempty"`
+	Path    *PathSection    `json:"Path,omitempty"`
+	Install *InstallSection `json:"Install,omitempty"`
+
+	// Replace overwrites a unit file which exists already. Drop-ins are always overwritten
+	Replace bool `json:"Replace,omitempty"`
+}
+
+type UnitCat struct {
+	Unit         string   `json:"Unit"`
+	FragmentPath string   `json:"FragmentPath"`
+	DropInPaths  []string `json:"DropInPaths"`
+	Text         string   `json:"Text"`
+}
+
+// UnitName appends .service to names without a unit type suffix and validates the result
+func UnitName(name string) (string, error) {
+	found := false
+	for _, t := range unitTypes {
+		if strings.HasSuffix(name, t) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		name += ".service"
+	}
+
+	if !unitNameRegexp.MatchString(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid unit name '%s'", name)
+	}
+
+	return name, nil
+}
+
+func dropInFileName(name string) (string, error) {
+	if !strings.HasSuffix(name, ".conf") {
+		name += ".conf"
+	}
+
+	if !dropInNameRegexp.MatchString(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid drop-in name '%s'", name)
+	}
+
+	return name, nil
+}
+
+// writeSection adds the non empty fields of the section struct v as keys. List fields
+// become one key per element. A list starting with an empty element first resets the
+// setting, as drop-ins need to replace ExecStart=
+func writeSection(m *configfile.Meta, section string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return nil
+	}
+	rv = rv.Elem()
+
+	// The ini writer drops empty values next to others, so resets go into a section of their own
+	var resets []string
+	for i := 0; i < rv.NumField(); i++ {
+		if f, ok := rv.Field(i).Interface().([]string); ok && len(f) > 0 && f[0] == "" {
+			resets = append(resets, rv.Type().Field(i).Name)
+		}
+	}
+
+	if len(resets) > 0 {
+		if err := m.NewSection(section); err != nil {
+			return err
+		}
+		for _, k := range resets {
+			if _, err := m.Section.NewKey(k, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.NewSection(section); err != nil {
+		return err
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		key := rv.Type().Field(i).Name
+		switch f := rv.Field(i).Interface().(type) {
+		case string:
+			if f != "" {
+				if _, err := m.Section.NewKey(key, f); err != nil {
+					return err
+				}
+			}
+		case []string:
+			for _, e := range f {
+				if e == "" {
+					continue
+				}
+				if _, err := m.Section.NewKey(key, e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Render builds the unit file in memory. Sections must match the unit type and only drop-ins may be empty
+func (u *UnitFile) Render(p string, unit string, isDropIn bool) (*configfile.Meta, error) {
+	sections := []struct {
+		name   string
+		suffix string
+		value  interface{}
+	}{
+		{"Unit", "", u.Unit},
+		{"Service", ".service", u.Service},
+		{"Timer", ".timer", u.Timer},
+		{"Socket", ".socket", u.Socket},
+		{"Path", ".path", u.Path},
+		{"Install", "", u.Install},
+	}
+
+	m := configfile.NewRaw(p)
+	for _, s := range sections {
+		if reflect.ValueOf(s.value).IsNil() {
+			continue
+		}
+
+		if s.suffix != "" && !strings.HasSuffix(unit, s.suffix) {
+			return nil, fmt.Errorf("section [%s] does not apply to unit '%s'", s.name, unit)
+		}
+
+		if err := writeSection(m, s.name, s.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isDropIn && len(m.Cfg.Sections()) <= 1 {
+		return nil, errors.New("unit file has no sections")
+	}
+
+	return m, nil
+}
+
+func daemonReload(ctx context.Context) error {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.ReloadContext(ctx); err != nil {
+		log.Errorf("Failed to reload systemd manager configuration: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func writeUnitFile(m *configfile.Meta) error {
+	b, err := m.RawBytes()
+	if err != nil {
+		return err
+	}
+
+	return system.WriteFileAtomic(m.Path, b, 0644)
+}
+
+// ConfigureUnitFile writes the unit into /etc/systemd/system and reloads systemd. An existing
+// unit file is only overwritten with Replace
+func (u *UnitFile) ConfigureUnitFile(ctx context.Context, unit string) error {
+	if !u.Replace && system.PathExists(path.Join(unitDirPath, unit)) {
+		return fmt.Errorf("%w: '%s'", ErrUnitExists, unit)
+	}
+
+	m, err := u.Render(path.Join(unitDirPath, unit), unit, false)
+	if err != nil {
+		return err
+	}
+
+	if err := writeUnitFile(m); err != nil {
+		log.Errorf("Failed to write unit file='%s': %v", m.Path, err)
+		return err
+	}
+
+	log.Infof("Wrote unit file='%s'", m.Path)
+	return daemonReload(ctx)
+}
+
+// RemoveUnitFile removes a unit from /etc/systemd/system. Units shipped by packages can not be removed
+func RemoveUnitFile(ctx context.Context, unit string) error {
+	p := path.Join(unitDirPath, unit)
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("unit file '%s' not found", p)
+		}
+		return err
+	}
+
+	log.Infof("Removed unit file='%s'", p)
+	return daemonReload(ctx)
+}
+
+// ConfigureDropIn writes /etc/systemd/system/<unit>.d/<name>.conf and reloads systemd
+func (u *UnitFile) ConfigureDropIn(ctx context.Context, unit string, name string) error {
+	d := path.Join(unitDirPath, unit+".d")
+	m, err := u.Render(path.Join(d, name), unit, true)
+	if err != nil {
+		return err
+	}
+
+	if err := system.CreateDirectoryNested(d, 0755); err != nil {
+		return err
+	}
+
+	if err := writeUnitFile(m); err != nil {
+		log.Errorf("Failed to write drop-in='%s': %v", m.Path, err)
+		return err
+	}
+
+	log.Infof("Wrote drop-in='%s'", m.Path)
+	return daemonReload(ctx)
+}
+
+func RemoveDropIn(ctx context.Context, unit string, name string) error {
+	d := path.Join(unitDirPath, unit+".d")
+	p := path.Join(d, name)
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("drop-in '%s' not found", p)
+		}
+		return err
+	}
+
+	// Drop the directory once the last drop-in is gone
+	os.Remove(d)
+
+	log.Infof("Removed drop-in='%s'", p)
+	return daemonReload(ctx)
+}
+
+// AcquireUnitCat returns the unit file followed by its drop-ins, as systemctl cat shows them
+func AcquireUnitCat(ctx context.Context, unit string) (*UnitCat, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	p, err := conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		log.Errorf("Failed to fetch systemd unit='%s' properties: %v", unit, err)
+		return nil, err
+	}
+
+	c := UnitCat{
+		Unit:        unit,
+		DropInPaths: []string{},
+	}
+	c.FragmentPath, _ = p["FragmentPath"].(string)
+	if v, ok := p["DropInPaths"].([]string); ok {
+		c.DropInPaths = v
+	}
+
+	if c.FragmentPath == "" && len(c.DropInPaths) == 0 {
+		return nil, fmt.Errorf("no files found for unit '%s'", unit)
+	}
+
+	var b strings.Builder
+	for _, f := range append([]string{c.FragmentPath}, c.DropInPaths...) {
+		if f == "" {
+			continue
+		}
+
+		s, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("# " + f + "\n")
+		b.Write(s)
+		if len(s) > 0 && s[len(s)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	}
+	c.Text = b.String()
+
+	return &c, nil
+}
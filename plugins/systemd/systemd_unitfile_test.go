@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/pmd-next-gen/pkg/configfile"
+)
+
+func TestUnitFileRoundTrip(t *testing.T) {
+	u := UnitFile{
+		Unit: &UnitSection{
+			Description: "Echo; and # more",
+		},
+		Service: &ServiceSection{
+			Type:        "oneshot",
+			ExecStart:   []string{`/bin/sh -c "echo a; echo b # c"`, `/bin/echo 'done'`},
+			Environment: []string{`"A=1 2" B=3`},
+		},
+		Install: &InstallSection{
+			WantedBy: []string{"multi-user.target"},
+		},
+	}
+
+	p := path.Join(t.TempDir(), "echo.service")
+	m, err := u.Render(p, "echo.service", false)
+	if err != nil {
+		t.Fatalf("Failed to render unit: %v", err)
+	}
+
+	if err := writeUnitFile(m); err != nil {
+		t.Fatalf("Failed to write unit: %v", err)
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Failed to read unit: %v", err)
+	}
+
+	want := `[Unit]
+Description=Echo; and # more
+
+[Service]
+Type=oneshot
+ExecStart=/bin/sh -c "echo a; echo b # c"
+ExecStart=/bin/echo 'done'
+Environment="A=1 2" B=3
+
+[Install]
+WantedBy=multi-user.target
+`
+	if string(b) != want {
+		t.Fatalf("Unexpected unit file:\n%s\nwant:\n%s", b, want)
+	}
+
+	l, err := configfile.LoadRaw(p)
+	if err != nil {
+		t.Fatalf("Failed to load unit: %v", err)
+	}
+
+	if got := l.Cfg.Section("Service").Key("ExecStart").ValueWithShadows(); !reflect.DeepEqual(got, u.Service.ExecStart) {
+		t.Errorf("ExecStart = %q, want %q", got, u.Service.ExecStart)
+	}
+
+	again, err := l.RawBytes()
+	if err != nil {
+		t.Fatalf("Failed to render loaded unit: %v", err)
+	}
+	if string(again) != want {
+		t.Errorf("Unit changed on round trip:\n%s", again)
+	}
+}
+
+func TestUnitFileResetAndLineBreak(t *testing.T) {
+	u := UnitFile{
+		Service: &ServiceSection{
+			ExecStart: []string{"", "/usr/bin/true"},
+		},
+	}
+
+	m, err := u.Render("override.conf", "echo.service", true)
+	if err != nil {
+		t.Fatalf("Failed to render drop-in: %v", err)
+	}
+
+	b, err := m.RawBytes()
+	if err != nil {
+		t.Fatalf("Failed to render drop-in: %v", err)
+	}
+
+	want := "[Service]\nExecStart=\n\n[Service]\nExecStart=/usr/bin/true\n"
+	if string(b) != want {
+		t.Errorf("Unexpected drop-in:\n%s\nwant:\n%s", b, want)
+	}
+
+	u.Service.ExecStart = []string{"/usr/bin/true\n[Service]\nUser=root"}
+	m, err = u.Render("override.conf", "echo.service", true)
+	if err != nil {
+		t.Fatalf("Failed to render drop-in: %v", err)
+	}
+
+	if _, err := m.RawBytes(); err == nil {
+		t.Errorf("Expected a value with a line break to be refused")
+	}
+}
+
+func TestConfigureUnitFileExists(t *testing.T) {
+	d := setupUnitDir(t)
+
+	p := path.Join(d, "backup.service")
+	in := "[Service]\nExecStart=/usr/bin/backup.sh\n"
+	if err := os.WriteFile(p, []byte(in), 0644); err != nil {
+		t.Fatalf("Failed to write unit file: %v", err)
+	}
+
+	u := UnitFile{
+		Service: &ServiceSection{
+			ExecStart: []string{"/usr/bin/true"},
+		},
+	}
+	if err := u.ConfigureUnitFile(context.Background(), "backup.service"); !errors.Is(err, ErrUnitExists) {
+		t.Fatalf("Overwriting backup.service without Replace returned '%v'", err)
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Failed to read unit file: %v", err)
+	}
+	if string(b) != in {
+		t.Errorf("Refused request changed the unit file:\n%s", b)
+	}
+}
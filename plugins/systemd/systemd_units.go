@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2023 VMware, Inc.
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	sd "github.com/coreos/go-systemd/v22/dbus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Verbs which queue a job, the bulk operation waits for it before moving on to the next unit
+	unitJobVerbs = map[string]bool{
+		"start":             true,
+		"stop":              true,
+		"restart":           true,
+		"try-restart":       true,
+		"reload-or-restart": true,
+		"reload":            true,
+	}
+)
+
+// UnitFilter selects loaded units. States match the load, active or sub state and patterns
+// are shell globs such as nginx*
+type UnitFilter struct {
+	States   []string `json:"States"`
+	Types    []string `json:"Types"`
+	Patterns []string `json:"Patterns"`
+}
+
+// BulkUnitRequest applies one verb to a list of units, entries may be globs
+type BulkUnitRequest struct {
+	Verb  string   `json:"Verb"`
+	Units []string `json:"Units"`
+	Value string   `json:"Value"`
+}
+
+type UnitResult struct {
+	Unit    string `json:"Unit"`
+	Success bool   `json:"Success"`
+	Result  string `json:"Result"`
+	Error   string `json:"Error"`
+}
+
+func isUnitPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// AcquireFilteredUnits returns the loaded units matching the filter sorted by name
+func AcquireFilteredUnits(ctx context.Context, f *UnitFilter) ([]sd.UnitStatus, error) {
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsByPatternsContext(ctx, f.States, f.Patterns)
+	if err != nil {
+		log.Errorf("Failed list systemd units: %v", err)
+		return nil, err
+	}
+
+	filtered := []sd.UnitStatus{}
+	for _, u := range units {
+		if len(f.Types) > 0 {
+			found := false
+			for _, t := range f.Types {
+				if path.Ext(u.Name) == "."+strings.TrimPrefix(t, ".") {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		filtered = append(filtered, u)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Name < filtered[j].Name
+	})
+
+	return filtered, nil
+}
+
+// expandUnits resolves the names and globs of the request. Globs match the loaded units and
+// the installed unit files so that disabled units can be enabled
+func (b *BulkUnitRequest) expandUnits(ctx context.Context, conn *sd.Conn) ([]string, error) {
+	seen := make(map[string]bool)
+	var units, patterns []string
+
+	add := func(unit string) {
+		if !seen[unit] {
+			seen[unit] = true
+			units = append(units, unit)
+		}
+	}
+
+	for _, u := range b.Units {
+		if isUnitPattern(u) {
+			patterns = append(patterns, u)
+			continue
+		}
+
+		unit, err := UnitName(u)
+		if err != nil {
+			return nil, err
+		}
+		add(unit)
+	}
+
+	if len(patterns) > 0 {
+		loaded, err := conn.ListUnitsByPatternsContext(ctx, nil, patterns)
+		if err != nil {
+			log.Errorf("Failed list systemd units: %v", err)
+			return nil, err
+		}
+		for _, u := range loaded {
+			add(u.Name)
+		}
+
+		files, err := conn.ListUnitFilesByPatternsContext(ctx, nil, patterns)
+		if err != nil {
+			log.Errorf("Failed list systemd unit files: %v", err)
+			return nil, err
+		}
+		for _, f := range files {
+			add(path.Base(f.Path))
+		}
+	}
+
+	if len(units) == 0 {
+		return nil, errors.New("no unit matched")
+	}
+
+	return units, nil
+}
+
+// orderUnits sorts the units so that each one comes after the units of the set it is ordered
+// After=, stopping goes the other way round. Units in a cycle keep their name order
+func orderUnits(ctx context.Context, conn *sd.Conn, units []string, verb string) []string {
+	sort.Strings(units)
+
+	in := make(map[string]bool)
+	for _, u := range units {
+		in[u] = true
+	}
+
+	after := make(map[string][]string)
+	for _, u := range units {
+		p, err := conn.GetUnitPropertyContext(ctx, u, "After")
+		if err != nil {
+			continue
+		}
+
+		l, _ := p.Value.Value().([]string)
+		for _, d := range l {
+			if in[d] && d != u {
+				after[u] = append(after[u], d)
+			}
+		}
+	}
+
+	var ordered []string
+	done := make(map[string]bool)
+	for len(ordered) < len(units) {
+		progress := false
+		for _, u := range units {
+			if done[u] {
+				continue
+			}
+
+			ready := true
+			for _, d := range after[u] {
+				if !done[d] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			done[u] = true
+			ordered = append(ordered, u)
+			progress = true
+		}
+
+		if !progress {
+			for _, u := range units {
+				if !done[u] {
+					done[u] = true
+					ordered = append(ordered, u)
+				}
+			}
+		}
+	}
+
+	if verb == "stop" {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+
+	return ordered
+}
+
+// BulkUnitCommands runs the verb on every unit in dependency order and reports the result
+// of each unit. A failing unit does not stop the others
+func (b *BulkUnitRequest) BulkUnitCommands(ctx context.Context) ([]UnitResult, error) {
+	if b.Verb == "" {
+		return nil, errors.New("missing verb")
+	}
+
+	conn, err := sd.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		log.Errorf("Failed to establish connection with system bus: %v", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	units, err := b.expandUnits(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []UnitResult{}
+	for _, unit := range orderUnits(ctx, conn, units, b.Verb) {
+		u := UnitRequest{
+			Verb:  b.Verb,
+			Unit:  unit,
+			Value: b.Value,
+		}
+
+		r := UnitResult{
+			Unit: unit,
+		}
+
+		c := make(chan string, 1)
+		if err := u.unitCommand(ctx, conn, c); err != nil {
+			r.Error = err.Error()
+			results = append(results, r)
+			continue
+		}
+
+		if unitJobVerbs[b.Verb] {
+			select {
+			case r.Result = <-c:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			if r.Result != "done" {
+				r.Error = fmt.Sprintf("job for unit '%s' finished with result '%s'", unit, r.Result)
+			}
+		}
+
+		r.Success = r.Error == ""
+		results = append(results, r)
+	}
+
+	log.Infof("Executed '%s' on %d systemd units", b.Verb, len(results))
+	return results, nil
+}
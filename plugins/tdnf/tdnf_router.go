@@ -108,40 +108,40 @@ func routeracquireCommand(w http.ResponseWriter, r *http.Request) {
 
 	switch cmd := mux.Vars(r)["command"]; cmd {
 	case "autoremove":
-		err = acquireAlterCmd(w, cmd, "", options)
+		err = acquireAlterCmd(w, r, cmd, "", options)
 	case "check-update":
-		err = acquireCheckUpdate(w, "", options)
+		err = acquireCheckUpdate(w, r, "", options)
 	case "clean":
 		err = acquireClean(w, options)
 	case "distro-sync":
-		err = acquireAlterCmd(w, cmd, "", options)
+		err = acquireAlterCmd(w, r, cmd, "", options)
 	case "downgrade":
-		err = acquireAlterCmd(w, cmd, "", options)
+		err = acquireAlterCmd(w, r, cmd, "", options)
 	case "info":
 		listOptions := ListOptions{options, routerParseScopeOptions(r.Form)}
-		err = acquireInfoList(w, "", listOptions)
+		err = acquireInfoList(w, r, "", listOptions)
 	case "list":
 		listOptions := ListOptions{options, routerParseScopeOptions(r.Form)}
-		err = acquireList(w, "", listOptions)
+		err = acquireList(w, r, "", listOptions)
 	case "makecache":
-		err = acquireMakeCache(w, options)
+		err = acquireMakeCache(w, r, options)
 	case "repolist":
 		err = acquireRepoList(w, options)
 	case "repoquery":
 		repoQueryOptions := RepoQueryOptions{options, routerParseQueryOptions(r.Form)}
-		err = acquireRepoQuery(w, "", repoQueryOptions)
+		err = acquireRepoQuery(w, r, "", repoQueryOptions)
 	case "search":
 		q := r.FormValue("q")
 		if q != "" {
-			err = acquireSearch(w, q, options)
+			err = acquireSearch(w, r, q, options)
 		} else {
 			err = errors.New("search needs 'q=str' query")
 		}
 	case "update":
-		err = acquireAlterCmd(w, cmd, "", options)
+		err = acquireAlterCmd(w, r, cmd, "", options)
 	case "updateinfo":
 		updateInfoOptions := UpdateInfoOptions{options, routerParseScopeOptions(r.Form), routerParseModeOptions(r.Form)}
-		err = acquireUpdateInfo(w, "", updateInfoOptions)
+		err = acquireUpdateInfo(w, r, "", updateInfoOptions)
 	case "version":
 		err = acquireVersion(w, options)
 	default:
@@ -165,31 +165,31 @@ func routeracquireCommandPkgs(w http.ResponseWriter, r *http.Request) {
 
 	switch cmd := mux.Vars(r)["command"]; cmd {
 	case "autoremove":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "downgrade":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "check-update":
-		err = acquireCheckUpdate(w, pkgs, options)
+		err = acquireCheckUpdate(w, r, pkgs, options)
 	case "erase":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "info":
 		listOptions := ListOptions{options, routerParseScopeOptions(r.Form)}
-		err = acquireInfoList(w, pkgs, listOptions)
+		err = acquireInfoList(w, r, pkgs, listOptions)
 	case "install":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "list":
 		listOptions := ListOptions{options, routerParseScopeOptions(r.Form)}
-		err = acquireList(w, pkgs, listOptions)
+		err = acquireList(w, r, pkgs, listOptions)
 	case "reinstall":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "repoquery":
 		repoQueryOptions := RepoQueryOptions{options, routerParseQueryOptions(r.Form)}
-		err = acquireRepoQuery(w, pkgs, repoQueryOptions)
+		err = acquireRepoQuery(w, r, pkgs, repoQueryOptions)
 	case "update":
-		err = acquireAlterCmd(w, cmd, pkgs, options)
+		err = acquireAlterCmd(w, r, cmd, pkgs, options)
 	case "updateinfo":
 		updateInfoOptions := UpdateInfoOptions{options, routerParseScopeOptions(r.Form), routerParseModeOptions(r.Form)}
-		err = acquireUpdateInfo(w, pkgs, updateInfoOptions)
+		err = acquireUpdateInfo(w, r, pkgs, updateInfoOptions)
 	default:
 		err = errors.New("unsupported")
 	}
@@ -214,11 +214,11 @@ func routeracquireHistoryCommand(w http.ResponseWriter, r *http.Request) {
 	case "list":
 		err = acquireHistoryList(w, historyCmdOptions)
 	case "rollback":
-		err = acquireHistoryAlterCmd(w, cmd, historyCmdOptions)
+		err = acquireHistoryAlterCmd(w, r, cmd, historyCmdOptions)
 	case "undo":
-		err = acquireHistoryAlterCmd(w, cmd, historyCmdOptions)
+		err = acquireHistoryAlterCmd(w, r, cmd, historyCmdOptions)
 	case "redo":
-		err = acquireHistoryAlterCmd(w, cmd, historyCmdOptions)
+		err = acquireHistoryAlterCmd(w, r, cmd, historyCmdOptions)
 	default:
 		err = errors.New("unsupported")
 	}
@@ -239,9 +239,9 @@ func routeracquireMarkCommand(w http.ResponseWriter, r *http.Request) {
 
 	switch what := mux.Vars(r)["what"]; what {
 	case "install":
-		err = acquireMarkCmd(w, what, pkgs, options)
+		err = acquireMarkCmd(w, r, what, pkgs, options)
 	case "remove":
-		err = acquireMarkCmd(w, what, pkgs, options)
+		err = acquireMarkCmd(w, r, what, pkgs, options)
 	default:
 		err = errors.New("unsupported")
 	}